@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// printAuditTable writes records as an aligned table.
+func printAuditTable(w io.Writer, recs []state.AuditRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSERVICE\tREPO\tISSUE\tFINGERPRINT\tURL")
+	for _, rec := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t#%d\t%s\t%s\n",
+			rec.Time.Format(time.RFC3339), rec.Service, rec.Repo, rec.Number, rec.Fingerprint, rec.URL)
+	}
+
+	return tw.Flush()
+}
+
+// runAudit implements the audit subcommand, printing the issues osprey has created.
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	svc := fs.String("service", "", "only show issues created for this service")
+	repo := fs.String("repo", "", "only show issues created in this repository (owner/name)")
+	fingerprint := fs.String("fingerprint", "", "only show issues of the error with this fingerprint")
+	since := fs.Duration("since", 0, "only show issues created within this duration, e.g. 24h")
+	asJSON := fs.Bool("json", false, "print records as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := state.AuditFilter{Service: *svc, Repo: *repo, Fingerprint: *fingerprint}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	recs, err := state.NewAuditLog(state.AuditFilePath()).Query(filter)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range recs {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return printAuditTable(os.Stdout, recs)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"io"
+	"os"
+	"sync"
+)
+
+// ANSI escapes of the console reporter. clearLine returns to the start of the line and erases it.
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+	clearLine  = "\r\033[K"
+)
+
+// console renders the events of a run as a live view for people watching a terminal: a status line per service
+// while it is scanned, then its matches, issues and errors.
+type console struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// color is set unless NO_COLOR is.
+	color bool
+
+	// matched counts the findings of the service being scanned.
+	matched int
+
+	// failed is set once the scan of the service has failed.
+	failed bool
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// newConsole creates a console reporter writing to w.
+func newConsole(w io.Writer) *console {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &console{w: w, color: !noColor}
+}
+
+// paint wraps text in an ANSI style if colors are enabled.
+func (c *console) paint(style, text string) string {
+	if !c.color {
+		return text
+	}
+
+	return style + text + ansiReset
+}
+
+// handle renders an event, implementing telemetry.Handler.
+func (c *console) handle(ev telemetry.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ev.Type {
+	case telemetry.EventScanStarted:
+		c.matched, c.failed = 0, false
+		c.status(ev.Service)
+	case telemetry.EventScanFailed:
+		c.failed = true
+		fmt.Fprintf(c.w, "%s%s %s %s\n", clearLine, c.paint(ansiRed, "✗"), c.paint(ansiBold, ev.Service),
+			c.paint(ansiRed, ev.Error))
+	case telemetry.EventFindingMatched:
+		c.matched++
+		c.println("  "+c.paint(ansiYellow, "●")+" "+ev.Line, ev.Service)
+	case telemetry.EventIssueCreated, telemetry.EventIssueUpdated:
+		c.println(fmt.Sprintf("    %s %s", c.paint(ansiGreen, fmt.Sprintf("↳ #%d", ev.IssueNumber)),
+			c.paint(ansiDim, ev.IssueURL)), ev.Service)
+	case telemetry.EventDeliveryFailed, telemetry.EventSecretDetected:
+		c.println("    "+c.paint(ansiRed, "↳ "+ev.Error), ev.Service)
+	}
+}
+
+// status draws the status line of the service being scanned.
+func (c *console) status(service string) {
+	fmt.Fprintf(c.w, "%s%s %s %s", clearLine, c.paint(ansiCyan, "…"), c.paint(ansiBold, service),
+		c.paint(ansiDim, fmt.Sprintf("%d matched", c.matched)))
+}
+
+// println prints a line above the status line of the service being scanned.
+func (c *console) println(line, service string) {
+	fmt.Fprintf(c.w, "%s%s\n", clearLine, line)
+	c.status(service)
+}
+
+// finish completes the status line of a scanned service with its report. It is called after the scan rather than
+// on scan_finished, which comes before the findings are delivered.
+func (c *console) finish(rep scanner.Report, dryRun bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failed {
+		return
+	}
+	filed := "filed"
+	if dryRun {
+		filed = "to file"
+	}
+	fmt.Fprintf(c.w, "%s%s %s %s\n", clearLine, c.paint(ansiGreen, "✓"), c.paint(ansiBold, rep.Service),
+		c.paint(ansiDim, fmt.Sprintf("%d lines, %d findings, %d issues %s", rep.LinesScanned, rep.Findings,
+			rep.IssuesCreated, filed)))
+}
@@ -0,0 +1,212 @@
+// Command osprey scans the log files of the services defined in its config file and files a github issue for every
+// error found. The scanning pipeline lives in the pkg packages, so other Go programs can embed it.
+package main
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/admin"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	_ "github.com/NBCFB/Iguana2/pkg/match/wasm"
+	"github.com/NBCFB/Iguana2/pkg/plugin"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// flushTimeout bounds the sending of the findings sinks hold back when osprey stops.
+const flushTimeout = time.Minute
+
+// gitHubSink returns the github sink, reading the token through a rotating token source so it can be reloaded. With
+// a github app in config file it authenticates as the app's installation instead.
+func gitHubSink(ctx context.Context, creds credentials.Provider) (*sink.GitHub, credentials.ReloadableTokenSource,
+	error) {
+	var ts credentials.ReloadableTokenSource
+	var err error
+	if viper.IsSet("github.app.id") {
+		ts, err = credentials.NewAppTokenSource(creds)
+	} else {
+		ts, err = credentials.NewRotatingTokenSource(creds)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	gh, err := sink.NewGitHub(ctx, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+	gh.Credentials = creds
+	sink.SetCredentials(creds)
+
+	return gh, ts, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	// Report the version and update without a config file, the update settings are read if there is one.
+	if len(os.Args) > 1 && (os.Args[1] == "version" || os.Args[1] == "self-update") {
+		config.Load()
+		if os.Args[1] == "version" {
+			if err := runVersion(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Unable to check version, %s", err.Error())
+			}
+			return
+		}
+		if err := runSelfUpdate(ctx, os.Args[2:]); err != nil {
+			log.Fatalf("Unable to update osprey, %s", err.Error())
+		}
+		return
+	}
+
+	// Read config file.
+	err := config.Load()
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+
+	// Run a subcommand if one is given.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "status":
+			if err := runStatus(os.Args[2:]); err != nil {
+				log.Fatalf("Unable to read status, %s", err.Error())
+			}
+			return
+		case "state":
+			if err := runState(os.Args[2:]); err != nil {
+				log.Fatalf("Unable to update state, %s", err.Error())
+			}
+			return
+		case "audit":
+			if err := runAudit(os.Args[2:]); err != nil {
+				log.Fatalf("Unable to read audit log, %s", err.Error())
+			}
+			return
+		case "once", "check":
+			if err := runOnce(ctx, os.Args[1], os.Args[2:]); err != nil {
+				log.Fatalf("Unable to scan, %s", err.Error())
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
+	// Read osprey configurations first.
+	interval := viper.GetInt("interval")
+	maxWorkers := viper.GetInt("max_workers")
+
+	// Start the plugin binaries, their sources, matchers and sinks are registered before the scanners are created.
+	n, err := plugin.Load()
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	// Stop on SIGINT or SIGTERM, once the findings sinks hold back are sent and the plugin processes stopped.
+	run, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if n > 0 {
+		defer plugin.Close()
+	}
+
+	// Obtain github API client. The token is read through a rotating token source, so it can be reloaded.
+	creds, err := credentials.New()
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	gh, ts, err := gitHubSink(ctx, creds)
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, fail to obtain github API service client, %s", err.Error())
+	}
+
+	// Re-read the token on SIGHUP, e.g. after a secret has been rotated.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go ts.ReloadOnSignal(hup)
+
+	// Create scanners based on the services defined in the config file.
+	statsd, err := telemetry.NewStatsd()
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+
+	events, err := telemetry.NewEventStream()
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+
+	findings := scanner.NewFindingLog(scanner.DefaultRecentFindings)
+	key, err := stateKey(creds)
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	scanners, err := scanner.FromConfig(scanner.Deps{
+		Sink:     gh,
+		Audit:    state.NewAuditLog(state.AuditFilePath()),
+		Findings: findings,
+		Statsd:   statsd,
+		Events:   events,
+		StateKey: key,
+	})
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	if statsd != nil {
+		go scanner.PushSLOGauges(statsd, scanners)
+	}
+
+	if scanners == nil || len(scanners) == 0 {
+		log.Fatalf("Unable to start Iguana, no jobs are found.")
+	}
+
+	// Setup a worker pool
+	workerN := len(scanners)
+	if workerN > maxWorkers {
+		workerN = maxWorkers
+	}
+
+	log.Printf("%d scanners are created.\n", len(scanners))
+	queue := make(chan *scanner.Scanner, workerN)
+
+	// Start the admin and gRPC servers if configured.
+	ctl := admin.NewControl(scanners, findings, queue)
+	adm, err := admin.NewServer(ctl)
+	if err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	if adm != nil {
+		if err := adm.Listen(); err != nil {
+			log.Fatalf("Unable to start Iguana, %s", err.Error())
+		}
+		go adm.Serve()
+	}
+	if g := admin.NewGRPCServer(ctl); g != nil {
+		if err := g.Listen(); err != nil {
+			log.Fatalf("Unable to start Iguana, %s", err.Error())
+		}
+		go g.Serve()
+	}
+
+	// Give up root once the ports are bound.
+	if err := dropPrivileges(); err != nil {
+		log.Fatalf("Unable to start Iguana, %s", err.Error())
+	}
+	checkLogAccess(scanners)
+
+	log.Println("osprey is ready")
+	err = scanner.Run(run, scanners, queue, time.Duration(interval)*time.Second)
+	// The scanners have moved their anchors past the findings held back, e.g. for an email digest, so send them.
+	fctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+	if err := sink.Flush(fctx); err != nil {
+		log.Printf("Unable to flush sinks, %s\n", err.Error())
+	}
+	log.Printf("osprey stopped, %s\n", err.Error())
+}
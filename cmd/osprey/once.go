@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	"github.com/NBCFB/Iguana2/pkg/plugin"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// Summary is the outcome of osprey once or osprey check, printed as JSON with --output json.
+type Summary struct {
+	// DryRun is set for osprey check, which files no issues.
+	DryRun bool `json:"dry_run"`
+
+	// Services are the reports of the services, in config order.
+	Services []scanner.Report `json:"services"`
+}
+
+// printSummaryTable writes a summary as an aligned table.
+func printSummaryTable(w io.Writer, sum Summary) error {
+	issues := "ISSUES CREATED"
+	if sum.DryRun {
+		issues = "ISSUES (DRY RUN)"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SERVICE\tLINES SCANNED\tFINDINGS\t%s\tERRORS\n", issues)
+	for _, rep := range sum.Services {
+		var errs string
+		if len(rep.Errors) > 0 {
+			errs = rep.Errors[0]
+		}
+		if len(rep.Errors) > 1 {
+			errs += fmt.Sprintf(" (and %d more)", len(rep.Errors)-1)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n",
+			rep.Service, rep.LinesScanned, rep.Findings, rep.IssuesCreated, errs)
+	}
+
+	return tw.Flush()
+}
+
+// runOnce implements the once and check subcommands, scanning every service once and printing a summary to
+// stdout. "osprey once" files issues and moves the anchors like a tick of the daemon; "osprey check" is a dry run
+// which does neither, to try a config on the current logs. It fails if any service had errors.
+func runOnce(ctx context.Context, cmd string, args []string) error {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	output := fs.String("output", "json", "print the summary as json or table")
+	view := fs.String("console", "auto", "show a live view of the run instead of log lines: auto (on a terminal), "+
+		"always or never")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != "json" && *output != "table" {
+		return fmt.Errorf("unknown output %q, want json or table", *output)
+	}
+	if *view != "auto" && *view != "always" && *view != "never" {
+		return fmt.Errorf("unknown console %q, want auto, always or never", *view)
+	}
+	dryRun := cmd == "check"
+
+	if _, err := plugin.Load(); err != nil {
+		return err
+	}
+	defer plugin.Close()
+
+	creds, err := credentials.New()
+	if err != nil {
+		return err
+	}
+	gh, _, err := gitHubSink(ctx, creds)
+	if err != nil {
+		return err
+	}
+	key, err := stateKey(creds)
+	if err != nil {
+		return err
+	}
+
+	d := scanner.Deps{Sink: gh, StateKey: key, DryRun: dryRun}
+	if !dryRun {
+		d.Audit = state.NewAuditLog(state.AuditFilePath())
+	}
+	scanners, err := scanner.FromConfig(d)
+	if err != nil {
+		return err
+	}
+
+	// The live view goes to stderr, leaving stdout to the summary.
+	var con *console
+	if *view == "always" || *view == "auto" && isTerminal(os.Stderr) {
+		con = newConsole(os.Stderr)
+		defer telemetry.Subscribe(con.handle)()
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	sum := Summary{DryRun: dryRun, Services: []scanner.Report{}}
+	failed := 0
+	for _, s := range scanners {
+		rep, _ := s.Scan(ctx)
+		if con != nil {
+			con.finish(rep, dryRun)
+		}
+		if len(rep.Errors) > 0 {
+			failed++
+		}
+		sum.Services = append(sum.Services, rep)
+	}
+	// Send the findings sinks hold back, e.g. email digests, before exiting.
+	if err := sink.Flush(ctx); err != nil {
+		log.Printf("Unable to flush sinks, %s\n", err.Error())
+	}
+
+	if *output == "table" {
+		err = printSummaryTable(os.Stdout, sum)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(sum)
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d services had errors", failed, len(scanners))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// runAsIDs resolves run_as.user and run_as.group in config file, given as names or numeric ids, to a uid and gid.
+// The group defaults to the primary group of the user. ok is false if neither is configured.
+func runAsIDs() (uid, gid int, ok bool, err error) {
+	name, group := viper.GetString("run_as.user"), viper.GetString("run_as.group")
+	if name == "" && group == "" {
+		return 0, 0, false, nil
+	}
+
+	uid, gid = os.Getuid(), os.Getgid()
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			if u, err = user.LookupId(name); err != nil {
+				return 0, 0, false, fmt.Errorf("unknown run_as.user %q", name)
+			}
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, false, fmt.Errorf("user %s has a non numeric uid %s", name, u.Uid)
+		}
+		if gid, err = strconv.Atoi(u.Gid); err != nil {
+			return 0, 0, false, fmt.Errorf("user %s has a non numeric gid %s", name, u.Gid)
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return 0, 0, false, fmt.Errorf("unknown run_as.group %q", group)
+			}
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, false, fmt.Errorf("group %s has a non numeric gid %s", group, g.Gid)
+		}
+	}
+
+	return uid, gid, true, nil
+}
+
+// checkLogAccess warns about log files osprey cannot read, so a misconfigured permission shows at startup rather
+// than on the first scan.
+func checkLogAccess(scanners []*scanner.Scanner) {
+	for _, s := range scanners {
+		svc := s.Service()
+		pattern, _ := source.Pattern(svc.Location, svc.FilePattern)
+		if err := source.CheckAccess(pattern); err != nil {
+			log.Printf("Unable to read logs of %s, %s\n", s.Name(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"syscall"
+)
+
+// dropPrivileges switches to the uid and gid of run_as in config file. It is called once the admin and gRPC ports
+// are bound, so osprey can be started as root to bind privileged ports without keeping root while scanning.
+func dropPrivileges() error {
+	uid, gid, ok, err := runAsIDs()
+	if err != nil || !ok {
+		return err
+	}
+
+	// Supplementary groups are dropped first, they would otherwise keep those of root.
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("unable to set groups, %s", err.Error())
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("unable to set gid %d, %s", gid, err.Error())
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("unable to set uid %d, %s", uid, err.Error())
+	}
+
+	// Make sure root cannot be regained.
+	if uid != 0 && syscall.Setuid(0) == nil {
+		return fmt.Errorf("root privileges could be regained after dropping them")
+	}
+
+	log.Printf("running as uid %d gid %d\n", uid, gid)
+
+	return nil
+}
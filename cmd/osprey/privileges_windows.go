@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+// dropPrivileges is not supported on windows, run osprey as a low privileged service account instead.
+func dropPrivileges() error {
+	if _, _, ok, err := runAsIDs(); err != nil || !ok {
+		return err
+	}
+
+	return fmt.Errorf("run_as is not supported on windows")
+}
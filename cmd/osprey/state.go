@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+)
+
+// stateKey returns the key state files are signed with, or nil if state.sign is not enabled in config file.
+func stateKey(creds credentials.Provider) ([]byte, error) {
+	if !viper.GetBool("state.sign") {
+		return nil, nil
+	}
+
+	key, err := creds.Secret(state.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("state.sign is enabled but there is no signing key, %s", err.Error())
+	}
+
+	return []byte(key), nil
+}
+
+// runState implements the state subcommand. "osprey state sign" signs the existing unsigned state files, so
+// state.sign can be enabled without scanning the logs again from the start. "osprey state reset <service>..."
+// starts the services over from the start of their logs, creating their state files, which signed state refuses
+// to do by itself.
+func runState(args []string) error {
+	fs := flag.NewFlagSet("state", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	creds, err := credentials.New()
+	if err != nil {
+		return err
+	}
+	switch fs.Arg(0) {
+	case "sign":
+		key, err := creds.Secret(state.SecretKey)
+		if err != nil {
+			return err
+		}
+		for name := range viper.GetStringMap(config.RootKey) {
+			signStates(name, []byte(key))
+		}
+	case "reset":
+		if fs.NArg() < 2 {
+			return fmt.Errorf("usage: osprey state reset <service>...")
+		}
+		key, err := stateKey(creds)
+		if err != nil {
+			return err
+		}
+		for _, name := range fs.Args()[1:] {
+			if !viper.IsSet(config.Key(name, "location")) {
+				return fmt.Errorf("unknown service %s", name)
+			}
+			if err := resetStates(name, key); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("usage: osprey state sign | reset <service>...")
+	}
+
+	return nil
+}
+
+// anchors returns the state files of a service: its own, or those of the files its glob pattern or directory
+// location matches, which have anchors of their own.
+func anchors(name string, key []byte) ([]*state.Anchor, bool, error) {
+	pattern, glob := source.Pattern(viper.GetString(config.Key(name, "location")),
+		viper.GetString(config.Key(name, "file_pattern")))
+	if !glob {
+		return []*state.Anchor{state.NewAnchor(config.StateDir(), name, key)}, false, nil
+	}
+
+	paths, err := source.Glob(pattern)
+	if err != nil {
+		return nil, true, err
+	}
+	var as []*state.Anchor
+	for _, p := range paths {
+		as = append(as, state.NewFileAnchor(config.StateDir(), name, p, key))
+	}
+
+	return as, true, nil
+}
+
+// signStates signs the state files of a service, those of files not scanned yet are skipped.
+func signStates(name string, key []byte) {
+	as, glob, err := anchors(name, key)
+	if err != nil {
+		log.Printf("Unable to sign state of %s, %s\n", name, err.Error())
+		return
+	}
+	for _, a := range as {
+		signed, err := withLock(a, a.Sign)
+		if glob && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Unable to sign state of %s, %s\n", a.Service, err.Error())
+			continue
+		}
+		if !signed {
+			log.Printf("state of %s is already signed\n", a.Service)
+			continue
+		}
+		log.Printf("state of %s is signed\n", a.Service)
+	}
+}
+
+// resetStates moves the state files of a service back to the start of its logs.
+func resetStates(name string, key []byte) error {
+	as, _, err := anchors(name, key)
+	if err != nil {
+		return fmt.Errorf("unable to reset state of %s, %s", name, err.Error())
+	}
+	for _, a := range as {
+		if _, err := withLock(a, func() (bool, error) { return true, a.Reset() }); err != nil {
+			return fmt.Errorf("unable to reset state of %s, %s", a.Service, err.Error())
+		}
+		log.Printf("state of %s is reset\n", a.Service)
+	}
+
+	return nil
+}
+
+// withLock runs f on a state file while holding its lock, so a running osprey does not rewrite it meanwhile.
+func withLock(a *state.Anchor, f func() (bool, error)) (bool, error) {
+	unlock, err := a.Lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	return f()
+}
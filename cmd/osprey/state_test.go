@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResetStates(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	dir, logs, key := t.TempDir(), t.TempDir(), []byte("secret")
+	viper.Set("state.dir", dir)
+	viper.Set(config.Key("apple", "location"), filepath.Join(logs, "apple.log"))
+	viper.Set(config.Key("orange", "location"), filepath.Join(logs, "orange-*.log"))
+	for _, name := range []string{"orange-1.log", "orange-2.log"} {
+		if err := os.WriteFile(filepath.Join(logs, name), []byte("a\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		service string
+		anchors []*state.Anchor
+	}{
+		{"single file", "apple", []*state.Anchor{state.NewAnchor(dir, "apple", key)}},
+		{"glob", "orange", []*state.Anchor{
+			state.NewFileAnchor(dir, "orange", filepath.Join(logs, "orange-1.log"), key),
+			state.NewFileAnchor(dir, "orange", filepath.Join(logs, "orange-2.log"), key),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := resetStates(tt.service, key); err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range tt.anchors {
+				if pos, err := a.LoadPosition(); err != nil || pos != (source.Position{}) {
+					t.Fatalf("got %+v, %v, want the signed start", pos, err)
+				}
+			}
+		})
+	}
+}
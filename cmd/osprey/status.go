@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/admin"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// printStatusTable writes service statuses as an aligned table.
+func printStatusTable(w io.Writer, sts []scanner.Status) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tSCANS\tFAILURES\tAVG DURATION\tFINDINGS (1H)\tLAG\tLAST ERROR")
+	for _, st := range sts {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%s\t%s\n",
+			st.Name, st.Scans, st.Failures, st.AvgDuration, st.FindingsHour, st.Lag, st.LastError)
+	}
+
+	return tw.Flush()
+}
+
+// adminURL returns the base url of the configured admin server.
+func adminURL() (string, error) {
+	addr := viper.GetString("admin.addr")
+	if addr == "" {
+		return "", fmt.Errorf("admin.addr is not configured")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if viper.GetString("admin.tls.cert_file") != "" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, port)), nil
+}
+
+// runStatus implements the status subcommand, printing per-service statistics of the running daemon.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print Statuses as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	base, err := adminURL()
+	if err != nil {
+		return err
+	}
+
+	// tls.admin holds the CA and client certificate to reach an admin server serving TLS.
+	client, err := transport.NewHTTPClient("admin", 10*time.Second)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, base+"/status", nil)
+	if err != nil {
+		return err
+	}
+	if tk := admin.APIToken(); tk != "" {
+		req.Header.Set("Authorization", "Bearer "+tk)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("admin server returned %s", resp.Status)
+	}
+
+	var sts []scanner.Status
+	if err := json.NewDecoder(resp.Body).Decode(&sts); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sts)
+	}
+
+	return printStatusTable(os.Stdout, sts)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/google/go-github/github"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultUpdateRepo is the repository whose releases osprey updates from, owner/name.
+	defaultUpdateRepo = "iamharvey/osprey"
+
+	// checksumsAsset lists the sha256 sums of the release binaries, as written by sha256sum. checksumsSigAsset is
+	// its ed25519 signature.
+	checksumsAsset    = "checksums.txt"
+	checksumsSigAsset = "checksums.txt.sig"
+)
+
+// version is the release of this build, set with -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+// buildVersion returns the release of this build, the module version if none is set, or "dev".
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+
+	return "dev"
+}
+
+// newerVersion reports whether release a is newer than b, comparing their numeric dot-separated parts. A
+// development build is older than any release.
+func newerVersion(a, b string) bool {
+	if b == "dev" {
+		return a != "dev"
+	}
+
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+
+	return false
+}
+
+// versionParts returns the numbers of a version such as v1.2.3-rc1, ignoring its pre-release suffix.
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		parts = append(parts, n)
+	}
+
+	return parts
+}
+
+// latestRelease returns the latest release of the update repository, update.repo in config file.
+func latestRelease(ctx context.Context, hc *http.Client) (*github.RepositoryRelease, error) {
+	repo := viper.GetString("update.repo")
+	if repo == "" {
+		repo = defaultUpdateRepo
+	}
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return nil, fmt.Errorf("update.repo %q should be owner/name", repo)
+	}
+
+	rel, _, err := github.NewClient(hc).Repositories.GetLatestRelease(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return rel, nil
+}
+
+// runVersion implements the version subcommand, printing the version of this build. With -check it also compares
+// it with the latest release.
+func runVersion(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	check := fs.Bool("check", false, "compare with the latest release")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	current := buildVersion()
+	fmt.Printf("osprey %s %s/%s\n", current, runtime.GOOS, runtime.GOARCH)
+	if !*check {
+		return nil
+	}
+
+	hc, err := transport.NewHTTPClient("github", 30*time.Second)
+	if err != nil {
+		return err
+	}
+	rel, err := latestRelease(ctx, hc)
+	if err != nil {
+		return err
+	}
+	if newerVersion(rel.GetTagName(), current) {
+		fmt.Printf("%s is available, run osprey self-update: %s\n", rel.GetTagName(), rel.GetHTMLURL())
+		return nil
+	}
+	fmt.Println("osprey is up to date")
+
+	return nil
+}
+
+// runSelfUpdate implements the self-update subcommand. It downloads the binary of the latest release for this
+// platform, verifies it against the release checksums and their signature with update.public_key, and swaps it for
+// the running executable. Without a key it refuses to update unless update.insecure is set.
+func runSelfUpdate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	force := fs.Bool("force", false, "install the latest release even if it is not newer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// The checksums come from the release itself, so only their signature protects against a tampered release.
+	key := viper.GetString("update.public_key")
+	if key == "" && !viper.GetBool("update.insecure") {
+		return fmt.Errorf("update.public_key is not configured, the release signature cannot be checked; set " +
+			"update.insecure to trust the release checksums alone")
+	}
+
+	hc, err := transport.NewHTTPClient("github", 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	rel, err := latestRelease(ctx, hc)
+	if err != nil {
+		return err
+	}
+	current := buildVersion()
+	if !*force && !newerVersion(rel.GetTagName(), current) {
+		log.Printf("osprey %s is up to date\n", current)
+		return nil
+	}
+
+	binName := fmt.Sprintf("osprey_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	assets := make(map[string]string)
+	for _, a := range rel.Assets {
+		assets[a.GetName()] = a.GetBrowserDownloadURL()
+	}
+	if assets[binName] == "" || assets[checksumsAsset] == "" {
+		return fmt.Errorf("release %s has no %s or %s", rel.GetTagName(), binName, checksumsAsset)
+	}
+
+	sums, err := download(ctx, hc, assets[checksumsAsset])
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		log.Printf("Release %s is not verified against a signature, update.insecure is set\n", rel.GetTagName())
+	} else {
+		if assets[checksumsSigAsset] == "" {
+			return fmt.Errorf("release %s has no %s", rel.GetTagName(), checksumsSigAsset)
+		}
+		sig, err := download(ctx, hc, assets[checksumsSigAsset])
+		if err != nil {
+			return err
+		}
+		if err := verifySignature(key, sums, sig); err != nil {
+			return err
+		}
+	}
+	want, err := checksum(sums, binName)
+	if err != nil {
+		return err
+	}
+
+	bin, err := download(ctx, hc, assets[binName])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(bin)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("%s has checksum %s, want %s", binName, got, want)
+	}
+
+	if err := replaceExecutable(bin); err != nil {
+		return err
+	}
+	log.Printf("osprey is updated from %s to %s\n", current, rel.GetTagName())
+
+	return nil
+}
+
+// download returns the content at url.
+func download(ctx context.Context, hc *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download %s, %s", url, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// verifySignature checks the ed25519 signature of the checksums with the base64 public key. The signature may be
+// raw or base64.
+func verifySignature(key string, sums, sig []byte) error {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("update.public_key should be a base64 ed25519 public key")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		if sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err != nil {
+			return fmt.Errorf("%s is not a signature", checksumsSigAsset)
+		}
+	}
+	if !ed25519.Verify(pub, sums, sig) {
+		return fmt.Errorf("%s has an invalid signature", checksumsAsset)
+	}
+
+	return nil
+}
+
+// checksum returns the sha256 sum of a file listed in checksums.txt.
+func checksum(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s has no checksum of %s", checksumsAsset, name)
+}
+
+// replaceExecutable swaps the running executable for bin. The new binary is written next to it and renamed into
+// place, the old one is moved aside first since a running executable cannot be overwritten on every platform.
+func replaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(exe), ".osprey-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()|0111); err != nil {
+		return err
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		// Put the running executable back.
+		os.Rename(old, exe)
+		return err
+	}
+	// On windows the old executable stays until the next update, it cannot be removed while it runs.
+	os.Remove(old)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+)
+
+func TestSelfUpdateNeedsKey(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	err := runSelfUpdate(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "update.public_key") {
+		t.Fatalf("got %v, want the update to be refused without a public key", err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+	sums := []byte("0123abcd  osprey_linux_amd64\n")
+	sig := ed25519.Sign(priv, sums)
+
+	tests := []struct {
+		name    string
+		key     string
+		sums    []byte
+		sig     []byte
+		wantErr bool
+	}{
+		{"raw signature", key, sums, sig, false},
+		{"base64 signature", key, sums, []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), false},
+		{"tampered checksums", key, []byte("ffff  osprey_linux_amd64\n"), sig, true},
+		{"invalid key", "not a key", sums, sig, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifySignature(tt.key, tt.sums, tt.sig); (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
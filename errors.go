@@ -0,0 +1,32 @@
+package osprey
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+)
+
+// The error kinds of osprey, returned wrapped so callers can branch on them with errors.Is. They are defined by the
+// packages returning them and repeated here for convenience.
+var (
+	// ErrLogNotFound is returned when a log file does not exist.
+	ErrLogNotFound = source.ErrLogNotFound
+
+	// ErrLogUnreadable is returned when osprey is not allowed to read a log file.
+	ErrLogUnreadable = source.ErrLogUnreadable
+
+	// ErrStateCorrupt is returned when a state file is unreadable or its signature is invalid.
+	ErrStateCorrupt = state.ErrStateCorrupt
+
+	// ErrStateLocked is returned when another process holds the lock of a state file.
+	ErrStateLocked = state.ErrStateLocked
+
+	// ErrRateLimited is returned when a sink refuses deliveries for a while.
+	ErrRateLimited = sink.ErrRateLimited
+
+	// ErrSinkUnavailable is returned when a sink cannot be reached or fails on its side.
+	ErrSinkUnavailable = sink.ErrSinkUnavailable
+
+	// ErrDropped is returned when a sink or its middleware decides not to deliver a finding.
+	ErrDropped = sink.ErrDropped
+)
@@ -0,0 +1,127 @@
+package osprey
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"time"
+)
+
+// Option sets a field of a Config. Options keep working when fields are added or moved, so they are the preferred
+// way to configure osprey from other modules.
+type Option func(*Config)
+
+// NewConfig returns a Config with the options applied, in order.
+func NewConfig(opts ...Option) Config {
+	var cfg Config
+	cfg.apply(opts)
+
+	return cfg
+}
+
+// apply applies the options to cfg, in order.
+func (cfg *Config) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(cfg)
+	}
+}
+
+// WithServices adds services to scan.
+func WithServices(svcs ...config.Service) Option {
+	return func(cfg *Config) {
+		cfg.Services = append(cfg.Services, svcs...)
+	}
+}
+
+// WithSink sets the sink of services without a sink key.
+func WithSink(s sink.Sink) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Sink = s
+	}
+}
+
+// WithGitHubToken sets the token of the github sink used when no sink is set.
+func WithGitHubToken(token string) Option {
+	return func(cfg *Config) {
+		cfg.GitHubToken = token
+	}
+}
+
+// WithInterval sets the time between scans.
+func WithInterval(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Interval = d
+	}
+}
+
+// WithMaxWorkers bounds the scans running at once.
+func WithMaxWorkers(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxWorkers = n
+	}
+}
+
+// WithStateDir sets the directory of the .igu state files.
+func WithStateDir(dir string) Option {
+	return func(cfg *Config) {
+		cfg.Deps.StateDir = dir
+	}
+}
+
+// WithStateKey signs the state files with key.
+func WithStateKey(key []byte) Option {
+	return func(cfg *Config) {
+		cfg.Deps.StateKey = key
+	}
+}
+
+// WithAudit records every created issue in the audit log.
+func WithAudit(a *state.AuditLog) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Audit = a
+	}
+}
+
+// WithFindings keeps the recent findings in the finding log.
+func WithFindings(l *scanner.FindingLog) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Findings = l
+	}
+}
+
+// WithBus publishes the internal events on the bus instead of telemetry.DefaultBus.
+func WithBus(b *telemetry.Bus) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Bus = b
+	}
+}
+
+// WithEventStream writes the internal events to the event stream.
+func WithEventStream(es *telemetry.EventStream) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Events = es
+	}
+}
+
+// WithStatsd pushes metrics with the statsd client.
+func WithStatsd(c *telemetry.Statsd) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Statsd = c
+	}
+}
+
+// WithSecretGuard checks the findings for credentials with the guard instead of the secret_guard config.
+func WithSecretGuard(g *scanner.SecretGuard) Option {
+	return func(cfg *Config) {
+		cfg.Deps.Guard = g
+	}
+}
+
+// WithSLO sets the SLO targets instead of the slo config.
+func WithSLO(slo scanner.SLOConfig) Option {
+	return func(cfg *Config) {
+		cfg.Deps.SLO = slo
+	}
+}
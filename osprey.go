@@ -0,0 +1,130 @@
+// Package osprey runs osprey in-process, so a service can watch its own log output and file issues about itself
+// without a separate daemon:
+//
+//	w := source.NewWriter()
+//	logger := log.New(io.MultiWriter(os.Stderr, w), "", log.LstdFlags)
+//	source.Register("self", func(config.Service) (source.Source, error) { return w, nil })
+//
+//	go osprey.Run(ctx, osprey.NewConfig(
+//		osprey.WithServices(config.Service{Name: "billing", Source: "self", RepoOwner: "acme", RepoName: "billing"}),
+//		osprey.WithGitHubToken(os.Getenv("GITHUB_TOKEN")),
+//		osprey.WithStateDir("/var/lib/billing/osprey"),
+//	))
+//
+// The service logs through its own logger: osprey reports through the standard one, and scanning those reports
+// would file issues about them. Settings not in Config, e.g. secret_guard or exec hooks, are read from viper as the
+// osprey command does; they are optional.
+//
+// # Stability
+//
+// This package and the config, source, parse, match, pipeline, sink, scanner, state and telemetry packages under
+// pkg are the library API and follow semantic versioning: within a major version, exported identifiers are not
+// removed and their signatures and behavior do not change incompatibly. Interfaces implemented by users, e.g.
+// source.Source or match.Matcher, only change in a major version; new behavior comes as optional interfaces or
+// options. An identifier being replaced is kept as a shim marked Deprecated for at least one minor version. The
+// admin, aws, credentials, plugin and transport packages serve the osprey command and may change in any release;
+// the plugin protocol is versioned by its proto package instead.
+package osprey
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"golang.org/x/oauth2"
+	"log"
+	"time"
+)
+
+// DefaultInterval is the scan interval of a Config without one.
+const DefaultInterval = 5 * time.Second
+
+// flushTimeout bounds the sending of the findings sinks hold back when Run returns.
+const flushTimeout = time.Minute
+
+// Config configures an embedded osprey.
+type Config struct {
+	// Services are the services to scan.
+	Services []config.Service
+
+	// Deps holds what the scanners share. If Deps.Sink is nil, issues are filed on GitHub with GitHubToken.
+	Deps scanner.Deps
+
+	// GitHubToken authenticates the GitHub sink used when Deps.Sink is nil.
+	GitHubToken string
+
+	// Interval is the time between scans, DefaultInterval if zero.
+	Interval time.Duration
+
+	// MaxWorkers bounds the scans running at once, one per service if zero.
+	MaxWorkers int
+}
+
+// NewScanner creates the scanner of a service, for programs scheduling the scans themselves with
+// Scanner.Execute. The options are applied to the dependencies d.
+func NewScanner(svc config.Service, d scanner.Deps, opts ...Option) (*scanner.Scanner, error) {
+	cfg := Config{Deps: d}
+	cfg.apply(opts)
+
+	return scanner.New(svc, cfg.Deps)
+}
+
+// Run scans the services of cfg every interval until ctx is done, then sends the findings sinks hold back and
+// returns ctx.Err(). The options are applied to cfg first. It returns early if a scanner cannot be created.
+func Run(ctx context.Context, cfg Config, opts ...Option) error {
+	cfg.apply(opts)
+	if len(cfg.Services) == 0 {
+		return errors.New("osprey has no services to scan")
+	}
+	if cfg.Deps.StateDir == "" && config.StateDir() == "" {
+		return errors.New("osprey needs a state directory, set Deps.StateDir or state.dir")
+	}
+
+	d := cfg.Deps
+	if d.Sink == nil && cfg.GitHubToken != "" {
+		gh, err := sink.NewGitHub(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.GitHubToken}))
+		if err != nil {
+			return err
+		}
+		d.Sink = gh
+	}
+	if d.Guard == nil {
+		guard, err := scanner.NewSecretGuard()
+		if err != nil {
+			return err
+		}
+		d.Guard = guard
+	}
+	if d.Findings == nil {
+		d.Findings = scanner.NewFindingLog(scanner.DefaultRecentFindings)
+	}
+
+	var scanners []*scanner.Scanner
+	for _, svc := range cfg.Services {
+		s, err := scanner.New(svc, d)
+		if err != nil {
+			return err
+		}
+		scanners = append(scanners, s)
+	}
+
+	workerN := len(scanners)
+	if cfg.MaxWorkers > 0 && workerN > cfg.MaxWorkers {
+		workerN = cfg.MaxWorkers
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	err := scanner.Run(ctx, scanners, make(chan *scanner.Scanner, workerN), interval)
+	// The scanners have moved their anchors past the findings held back, e.g. for an email digest, so send them.
+	fctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if ferr := sink.Flush(fctx); ferr != nil {
+		log.Printf("Unable to flush sinks, %s\n", ferr.Error())
+	}
+
+	return err
+}
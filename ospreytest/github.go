@@ -0,0 +1,311 @@
+package ospreytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issue is an issue filed on a GitHubServer.
+type Issue struct {
+	// Owner and Repo locate the repository.
+	Owner, Repo string
+
+	// Number is the issue number, from 1 per repository.
+	Number int
+
+	// Title and Body are the issue text.
+	Title, Body string
+
+	// Labels and Assignees are the names given when the issue was filed or edited.
+	Labels, Assignees []string
+
+	// Milestone is the milestone number, 0 for none.
+	Milestone int
+
+	// State is open or closed.
+	State string
+
+	// Comments are the comment bodies, in order.
+	Comments []string
+
+	// Created is when the issue was filed.
+	Created time.Time
+}
+
+// GitHubServer is a fake of the github issues API, serving the calls osprey's github sink makes: filing, listing,
+// searching, reading and editing issues, and commenting on them. Close it when done.
+type GitHubServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	issues map[string][]*Issue
+
+	// Status, if set, is returned by every call instead of serving it, e.g. 403 to test rate limits. Set it before
+	// the calls it should affect.
+	Status int
+}
+
+// NewGitHubServer starts a fake github server.
+func NewGitHubServer() *GitHubServer {
+	g := &GitHubServer{issues: make(map[string][]*Issue)}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+
+	return g
+}
+
+// Client returns a github client talking to the fake server.
+func (g *GitHubServer) Client() *github.Client {
+	c := github.NewClient(g.Server.Client())
+	u, _ := url.Parse(g.URL + "/")
+	c.BaseURL, c.UploadURL = u, u
+
+	return c
+}
+
+// Sink returns a github sink filing issues on the fake server.
+func (g *GitHubServer) Sink() *sink.GitHub {
+	return &sink.GitHub{Client: g.Client()}
+}
+
+// Issues returns copies of the issues of a repository, by number.
+func (g *GitHubServer) Issues(owner, repo string) []Issue {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var issues []Issue
+	for _, iss := range g.issues[owner+"/"+repo] {
+		c := *iss
+		c.Labels = append([]string(nil), iss.Labels...)
+		c.Assignees = append([]string(nil), iss.Assignees...)
+		c.Comments = append([]string(nil), iss.Comments...)
+		issues = append(issues, c)
+	}
+
+	return issues
+}
+
+// serve routes the calls under /repos/{owner}/{repo}/issues.
+func (g *GitHubServer) serve(w http.ResponseWriter, r *http.Request) {
+	if g.Status != 0 {
+		http.Error(w, `{"message":"ospreytest status"}`, g.Status)
+		return
+	}
+
+	if r.URL.Path == "/search/issues" && r.Method == http.MethodGet {
+		g.search(w, r.URL.Query().Get("q"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "repos" || parts[3] != "issues" {
+		http.NotFound(w, r)
+		return
+	}
+	owner, repo := parts[1], parts[2]
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case len(parts) == 4 && r.Method == http.MethodPost:
+		var req github.IssueRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		key := owner + "/" + repo
+		iss := &Issue{Owner: owner, Repo: repo, Number: len(g.issues[key]) + 1, State: "open", Created: time.Now()}
+		applyRequest(iss, req)
+		g.issues[key] = append(g.issues[key], iss)
+		g.write(w, http.StatusCreated, iss)
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		state := r.URL.Query().Get("state")
+		if state == "" {
+			state = "open"
+		}
+		var out []*github.Issue
+		for _, iss := range g.issues[owner+"/"+repo] {
+			if state == "all" || iss.State == state {
+				out = append(out, g.toGitHub(iss))
+			}
+		}
+		writeJSON(w, http.StatusOK, out)
+	case len(parts) >= 5:
+		iss := g.issue(owner, repo, parts[4])
+		if iss == nil {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case len(parts) == 5 && r.Method == http.MethodGet:
+			g.write(w, http.StatusOK, iss)
+		case len(parts) == 5 && r.Method == http.MethodPatch:
+			var req github.IssueRequest
+			if !decode(w, r, &req) {
+				return
+			}
+			applyRequest(iss, req)
+			g.write(w, http.StatusOK, iss)
+		case len(parts) == 6 && parts[5] == "comments" && r.Method == http.MethodPost:
+			var c github.IssueComment
+			if !decode(w, r, &c) {
+				return
+			}
+			iss.Comments = append(iss.Comments, c.GetBody())
+			writeJSON(w, http.StatusCreated, &github.IssueComment{ID: github.Int64(int64(len(iss.Comments))),
+				Body: c.Body})
+		default:
+			http.Error(w, "unsupported call", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, "unsupported call", http.StatusMethodNotAllowed)
+	}
+}
+
+// search answers an issue search. It understands the repo: and is:open or is:closed qualifiers; the other terms,
+// quoted or not, must all be in the title or body of an issue.
+func (g *GitHubServer) search(w http.ResponseWriter, q string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var repo, state string
+	var terms []string
+	for _, term := range splitQuery(q) {
+		switch {
+		case strings.HasPrefix(term, "repo:"):
+			repo = strings.TrimPrefix(term, "repo:")
+		case term == "is:open" || term == "is:closed":
+			state = strings.TrimPrefix(term, "is:")
+		case term == "is:issue":
+		default:
+			terms = append(terms, term)
+		}
+	}
+
+	res := &github.IssuesSearchResult{Issues: []github.Issue{}}
+	for key, issues := range g.issues {
+		if repo != "" && key != repo {
+			continue
+		}
+	next:
+		for _, iss := range issues {
+			if state != "" && iss.State != state {
+				continue
+			}
+			for _, t := range terms {
+				if !strings.Contains(iss.Title, t) && !strings.Contains(iss.Body, t) {
+					continue next
+				}
+			}
+			res.Issues = append(res.Issues, *g.toGitHub(iss))
+		}
+	}
+	res.Total = github.Int(len(res.Issues))
+	writeJSON(w, http.StatusOK, res)
+}
+
+// splitQuery splits a search query into its terms, a quoted phrase being one term.
+func splitQuery(q string) []string {
+	var terms []string
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			if part != "" {
+				terms = append(terms, part)
+			}
+			continue
+		}
+		terms = append(terms, strings.Fields(part)...)
+	}
+
+	return terms
+}
+
+// issue returns an issue by its number in the path, nil if there is none.
+func (g *GitHubServer) issue(owner, repo, number string) *Issue {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil
+	}
+	issues := g.issues[owner+"/"+repo]
+	if n < 1 || n > len(issues) {
+		return nil
+	}
+
+	return issues[n-1]
+}
+
+// applyRequest sets the fields given in an issue request.
+func applyRequest(iss *Issue, req github.IssueRequest) {
+	if req.Title != nil {
+		iss.Title = *req.Title
+	}
+	if req.Body != nil {
+		iss.Body = *req.Body
+	}
+	if req.Labels != nil {
+		iss.Labels = append([]string(nil), *req.Labels...)
+	}
+	if req.Assignees != nil {
+		iss.Assignees = append([]string(nil), *req.Assignees...)
+	}
+	if req.State != nil {
+		iss.State = *req.State
+	}
+	if req.Milestone != nil {
+		iss.Milestone = *req.Milestone
+	}
+}
+
+// write writes an issue in the github format.
+func (g *GitHubServer) write(w http.ResponseWriter, status int, iss *Issue) {
+	writeJSON(w, status, g.toGitHub(iss))
+}
+
+// toGitHub converts an issue to the github format.
+func (g *GitHubServer) toGitHub(iss *Issue) *github.Issue {
+	out := &github.Issue{
+		Number:    github.Int(iss.Number),
+		Title:     github.String(iss.Title),
+		Body:      github.String(iss.Body),
+		State:     github.String(iss.State),
+		Comments:  github.Int(len(iss.Comments)),
+		CreatedAt: &iss.Created,
+		HTMLURL:   github.String(fmt.Sprintf("%s/%s/%s/issues/%d", g.URL, iss.Owner, iss.Repo, iss.Number)),
+	}
+	for _, l := range iss.Labels {
+		out.Labels = append(out.Labels, github.Label{Name: github.String(l)})
+	}
+	for _, a := range iss.Assignees {
+		out.Assignees = append(out.Assignees, &github.User{Login: github.String(a)})
+	}
+	if iss.Milestone > 0 {
+		out.Milestone = &github.Milestone{Number: github.Int(iss.Milestone)}
+	}
+
+	return out
+}
+
+// decode reads the JSON request body into v, answering 400 if it is invalid.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
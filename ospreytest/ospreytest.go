@@ -0,0 +1,164 @@
+// Package ospreytest provides fakes for testing sources, matchers and sinks written for osprey, and osprey itself,
+// without real log files or API calls:
+//
+//	src := ospreytest.NewSource("boot ok", "error: disk full")
+//	rec := &ospreytest.Sink{}
+//	s := ospreytest.NewScanner(t, config.Service{Name: "apple"}, src, rec)
+//	if err := s.Execute(ctx); err != nil {
+//		t.Fatal(err)
+//	}
+//	if got := rec.Findings(); len(got) != 1 {
+//		t.Fatalf("got %d findings, want 1", len(got))
+//	}
+package ospreytest
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Source is an in-memory source. The checkpoint is the number of lines appended so far.
+type Source struct {
+	mu    sync.Mutex
+	lines []string
+
+	// Err is returned by Read if set.
+	Err error
+}
+
+// NewSource creates an in-memory source holding lines.
+func NewSource(lines ...string) *Source {
+	return &Source{lines: lines}
+}
+
+// Append adds lines, as if they were logged.
+func (s *Source) Append(lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines = append(s.lines, lines...)
+}
+
+// Read implements source.Source.
+func (s *Source) Read(checkpoint int) ([]string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, checkpoint, s.Err
+	}
+	if checkpoint > len(s.lines) {
+		checkpoint = len(s.lines)
+	}
+	lines := append([]string(nil), s.lines[checkpoint:]...)
+
+	return lines, len(s.lines), nil
+}
+
+// Delivered is a finding delivered to a Sink.
+type Delivered struct {
+	// Service is the service the finding belongs to.
+	Service config.Service
+
+	// Finding is the delivered finding.
+	Finding match.Finding
+
+	// Delivery is what the sink returned.
+	Delivery sink.Delivery
+}
+
+// Sink is a sink recording the findings delivered to it. The zero value is ready to use.
+type Sink struct {
+	mu        sync.Mutex
+	delivered []Delivered
+
+	// Err is returned by Deliver if set, and the finding is not recorded.
+	Err error
+}
+
+// Deliver implements sink.Sink, numbering the deliveries from 1.
+func (s *Sink) Deliver(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return sink.Delivery{}, s.Err
+	}
+	n := len(s.delivered) + 1
+	dlv := sink.Delivery{Number: n, URL: fmt.Sprintf("ospreytest://%s/%d", svc.Name, n)}
+	s.delivered = append(s.delivered, Delivered{Service: svc, Finding: f, Delivery: dlv})
+
+	return dlv, nil
+}
+
+// Delivered returns the deliveries so far, in order.
+func (s *Sink) Delivered() []Delivered {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Delivered(nil), s.delivered...)
+}
+
+// Findings returns the findings delivered so far, in order.
+func (s *Sink) Findings() []match.Finding {
+	var findings []match.Finding
+	for _, d := range s.Delivered() {
+		findings = append(findings, d.Finding)
+	}
+
+	return findings
+}
+
+// Reset forgets the deliveries so far.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delivered = nil
+}
+
+// sourceN numbers the sources registered by RegisterSource.
+var sourceN int64
+
+// RegisterSource registers src under a name of its own and returns the name, for services selecting it with their
+// Source field. Sources are registered for the life of the test binary.
+func RegisterSource(src source.Source) string {
+	name := fmt.Sprintf("ospreytest-%d", atomic.AddInt64(&sourceN, 1))
+	source.Register(name, func(config.Service) (source.Source, error) {
+		return src, nil
+	})
+
+	return name
+}
+
+// NewScanner creates a scanner of svc reading src and delivering to s, with its state in a temporary directory of
+// the test. Missing repository fields of svc are filled in, so tests only name what they need.
+func NewScanner(tb testing.TB, svc config.Service, src source.Source, s sink.Sink) *scanner.Scanner {
+	tb.Helper()
+
+	if svc.Name == "" {
+		svc.Name = "ospreytest"
+	}
+	if svc.RepoOwner == "" {
+		svc.RepoOwner = "owner"
+	}
+	if svc.RepoName == "" {
+		svc.RepoName = svc.Name
+	}
+	svc.Source = RegisterSource(src)
+
+	sc, err := scanner.New(svc, scanner.Deps{Sink: s, StateDir: tb.TempDir()})
+	if err != nil {
+		tb.Fatalf("ospreytest: unable to create scanner of %s, %s", svc.Name, err.Error())
+	}
+
+	return sc
+}
@@ -0,0 +1,208 @@
+// Package admin serves osprey's admin port, dashboard and control APIs over HTTP and gRPC.
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// Server serves osprey's admin endpoints on the admin port.
+type Server struct {
+	// addr is the address the admin server listens on.
+	addr string
+
+	// mux routes admin requests.
+	mux *http.ServeMux
+
+	// listener is the bound admin port.
+	listener net.Listener
+
+	// auth signs users in to the dashboard and API, nil if OIDC is not configured.
+	auth *oidcAuth
+
+	*Control
+}
+
+// NewServer creates an admin server based on config file. It returns nil if no admin address is configured.
+func NewServer(c *Control) (*Server, error) {
+	addr := viper.GetString("admin.addr")
+	if addr == "" {
+		return nil, nil
+	}
+
+	auth, err := newOIDCAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Server{
+		addr:    addr,
+		mux:     http.NewServeMux(),
+		auth:    auth,
+		Control: c,
+	}
+
+	a.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	a.mux.HandleFunc("/status", a.protect("status", a.handleStatus))
+	a.mux.HandleFunc("/metrics", a.protect("metrics", a.handleMetrics))
+
+	if a.auth != nil {
+		a.mux.HandleFunc("/auth/login", a.auth.handleLogin)
+		a.mux.HandleFunc("/auth/callback", a.auth.handleCallback)
+		a.mux.HandleFunc("/auth/logout", a.auth.handleLogout)
+	}
+
+	if viper.GetBool("admin.dashboard") {
+		a.mux.HandleFunc("/", a.protect("dashboard", a.handleDashboard))
+		// Pausing and resuming services needs an operator's sign-in, without OIDC the dashboard is read-only.
+		if a.auth != nil {
+			a.mux.HandleFunc("/services/", a.auth.require(roleOperator, a.handleServiceAction))
+		}
+	}
+
+	// The API can trigger scans and pause services, so it is never served without a token or sign-in.
+	if a.apiToken != "" || a.auth != nil {
+		a.mux.HandleFunc("/api/", a.handleAPI)
+	} else {
+		log.Println("admin API is disabled, no API token or OIDC is configured")
+	}
+
+	// Profiling endpoints can leak internals and cost CPU, so they are only exposed on demand.
+	if viper.GetBool("admin.pprof") {
+		a.mux.HandleFunc("/debug/pprof/", a.protect("pprof", pprof.Index))
+		a.mux.HandleFunc("/debug/pprof/cmdline", a.protect("pprof", pprof.Cmdline))
+		a.mux.HandleFunc("/debug/pprof/profile", a.protect("pprof", pprof.Profile))
+		a.mux.HandleFunc("/debug/pprof/symbol", a.protect("pprof", pprof.Symbol))
+		a.mux.HandleFunc("/debug/pprof/trace", a.protect("pprof", pprof.Trace))
+	}
+
+	return a, nil
+}
+
+// protect wraps the handler of a read-only endpoint, e.g. status or the dashboard, which tell the services' names,
+// locations and errors, or pprof, which tells the command line. Once the API token or OIDC is configured it
+// requires the token or a signed-in user, sending browsers without a session to sign in, unless
+// admin.public_endpoints lists the endpoint, e.g. metrics for a Prometheus without the token.
+func (a *Server) protect(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	if a.apiToken == "" && a.auth == nil {
+		return h
+	}
+	for _, e := range viper.GetStringSlice("admin.public_endpoints") {
+		if strings.Trim(e, "/ ") == endpoint {
+			return h
+		}
+	}
+
+	signIn := a.auth.require(roleViewer, h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case a.bearer(r):
+			h(w, r)
+		case a.auth != nil:
+			signIn(w, r)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="osprey"`)
+			http.Error(w, "invalid or missing API token", http.StatusUnauthorized)
+		}
+	}
+}
+
+// handleStatus reports per-service statistics as JSON.
+func (a *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, scanner.Statuses(a.scanners))
+}
+
+// Listen binds the admin port.
+func (a *Server) Listen() (err error) {
+	a.listener, err = listenAdmin(a.addr, "http/1.1")
+	return err
+}
+
+// Serve serves admin requests on the bound admin port. It blocks until the listener fails.
+func (a *Server) Serve() {
+	log.Printf("admin server listening on %s\n", a.listener.Addr())
+	if err := http.Serve(a.listener, a.mux); err != nil {
+		log.Printf("admin server stopped, %s\n", err.Error())
+	}
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus writes v as a JSON response with the given status code.
+func writeJSONStatus(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Unable to write admin response, %s\n", err.Error())
+	}
+}
+
+// listenAdmin binds an admin or gRPC port, serving TLS with the given ALPN protocols if admin.tls is configured.
+// With admin.tls.client_ca_file set, clients must present a certificate signed by one of its CAs (mutual TLS).
+func listenAdmin(addr string, protos ...string) (net.Listener, error) {
+	cfg, err := adminTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin TLS settings, %s", err.Error())
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil || cfg == nil {
+		return l, err
+	}
+	cfg.NextProtos = protos
+
+	return tls.NewListener(l, cfg), nil
+}
+
+// adminTLSConfig builds the server TLS config from admin.tls. It returns nil if no certificate is configured.
+func adminTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := viper.GetString("admin.tls.cert_file"), viper.GetString("admin.tls.key_file")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if v := viper.GetString("admin.tls.min_version"); v != "" {
+		ver, err := transport.ParseTLSVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MinVersion = ver
+	}
+
+	if path := viper.GetString("admin.tls.client_ca_file"); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		public []string
+		header string
+		want   int
+	}{
+		{"no token configured", "", nil, "", http.StatusOK},
+		{"missing token", "secret", nil, "", http.StatusUnauthorized},
+		{"wrong token", "secret", nil, "Bearer forged", http.StatusUnauthorized},
+		{"token", "secret", nil, "Bearer secret", http.StatusOK},
+		{"public endpoint", "secret", []string{"/status"}, "", http.StatusOK},
+		{"other public endpoint", "secret", []string{"metrics"}, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("admin.public_endpoints", tt.public)
+
+			a := &Server{Control: &Control{apiToken: tt.token}}
+			h := a.protect("status", func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodGet, "/status", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedEndpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		public []string
+		header string
+		want   int
+	}{
+		{"dashboard", "/", nil, "", http.StatusUnauthorized},
+		{"dashboard with token", "/", nil, "Bearer secret", http.StatusOK},
+		{"public dashboard", "/", []string{"dashboard"}, "", http.StatusOK},
+		{"pprof", "/debug/pprof/cmdline", nil, "", http.StatusUnauthorized},
+		{"pprof index", "/debug/pprof/", nil, "", http.StatusUnauthorized},
+		{"pprof with token", "/debug/pprof/cmdline", nil, "Bearer secret", http.StatusOK},
+		{"public pprof", "/debug/pprof/cmdline", []string{"pprof"}, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("admin.addr", "127.0.0.1:0")
+			viper.Set("admin.dashboard", true)
+			viper.Set("admin.pprof", true)
+			viper.Set("admin.public_endpoints", tt.public)
+
+			c, _ := newTestControl(t)
+			c.apiToken = "secret"
+			a, err := NewServer(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			a.mux.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectSendsBrowsersToSignIn(t *testing.T) {
+	a := &Server{Control: &Control{}, auth: &oidcAuth{key: []byte("key")}}
+	h := a.protect("dashboard", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "/auth/login") {
+		t.Fatalf("got %d to %q, want a redirect to sign in", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// apiError is the body of a failed API response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiAction is the body of a successful service action.
+type apiAction struct {
+	Service string `json:"service"`
+	Action  string `json:"action"`
+}
+
+// handleAPI routes /api requests after checking the API token or sign-in.
+func (a *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.apiRole(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="osprey"`)
+		apiFail(w, http.StatusUnauthorized, "invalid or missing API token")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	tks := strings.Split(path, "/")
+
+	switch {
+	case path == "services":
+		if r.Method != http.MethodGet {
+			apiFail(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, scanner.Statuses(a.scanners))
+	case path == "findings":
+		if r.Method != http.MethodGet {
+			apiFail(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		a.apiFindings(w, r)
+	case len(tks) == 3 && tks[0] == "services":
+		if r.Method != http.MethodPost {
+			apiFail(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if !allows(role, roleOperator) {
+			apiFail(w, http.StatusForbidden, "the operator role is required")
+			return
+		}
+		// A session cookie is sent by the browser along with any site's requests, the token is not.
+		if !a.bearer(r) && !sameOrigin(r) {
+			apiFail(w, http.StatusForbidden, "cross-site requests are refused")
+			return
+		}
+		a.apiServiceAction(w, tks[1], tks[2])
+	default:
+		apiFail(w, http.StatusNotFound, "not found")
+	}
+}
+
+// apiRole returns the role of an API caller. The API token grants the operator role, a signed-in user has its
+// own role.
+func (a *Server) apiRole(r *http.Request) (string, bool) {
+	if a.bearer(r) {
+		return roleOperator, true
+	}
+	if s, ok := a.auth.session(r); ok {
+		return s.Role, true
+	}
+
+	return "", false
+}
+
+// bearer reports whether a request carries the API token.
+func (a *Server) bearer(r *http.Request) bool {
+	return a.authorized(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+}
+
+// apiFindings lists recent findings, e.g. GET /api/findings?service=apple&limit=10.
+func (a *Server) apiFindings(w http.ResponseWriter, r *http.Request) {
+	fs := a.findings.Recent(r.URL.Query().Get("service"))
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			apiFail(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if limit < len(fs) {
+			fs = fs[:limit]
+		}
+	}
+
+	if fs == nil {
+		fs = []scanner.Finding{}
+	}
+	writeJSON(w, fs)
+}
+
+// apiServiceAction scans, pauses or resumes a service, e.g. POST /api/services/apple/scan.
+func (a *Server) apiServiceAction(w http.ResponseWriter, name, action string) {
+	s := a.scanner(name)
+	if s == nil {
+		apiFail(w, http.StatusNotFound, "unknown service "+name)
+		return
+	}
+
+	switch action {
+	case "scan":
+		if !a.scan(s) {
+			apiFail(w, http.StatusServiceUnavailable, "scan queue is full, try again later")
+			return
+		}
+	case "pause":
+		s.Pause()
+	case "resume":
+		s.Resume()
+	default:
+		apiFail(w, http.StatusNotFound, "unknown action "+action)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusAccepted, apiAction{Service: name, Action: action})
+}
+
+// apiFail writes an API error response.
+func apiFail(w http.ResponseWriter, code int, msg string) {
+	writeJSONStatus(w, code, apiError{Error: msg})
+}
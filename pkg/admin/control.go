@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+const (
+	adminAPITokenEnvKey = "OSPREY_ADMIN_TOKEN"
+)
+
+// Control is the control surface of a running daemon, shared by the admin API and the gRPC API.
+type Control struct {
+	// scanners are the controlled scanners.
+	scanners []*scanner.Scanner
+
+	// findings are the recent findings of all scanners.
+	findings *scanner.FindingLog
+
+	// queue is the worker queue used to trigger manual scans.
+	queue chan<- *scanner.Scanner
+
+	// apiToken is the bearer token required by the control APIs.
+	apiToken string
+}
+
+// NewControl creates the control surface based on config file.
+func NewControl(scanners []*scanner.Scanner, findings *scanner.FindingLog, queue chan<- *scanner.Scanner) *Control {
+	return &Control{
+		scanners: scanners,
+		findings: findings,
+		queue:    queue,
+		apiToken: APIToken(),
+	}
+}
+
+// APIToken returns the bearer token of the admin API: OSPREY_ADMIN_TOKEN, or else admin.api_token in config file.
+func APIToken() string {
+	if tk := strings.TrimSpace(os.Getenv(adminAPITokenEnvKey)); tk != "" {
+		return tk
+	}
+
+	return viper.GetString("admin.api_token")
+}
+
+// scanner returns the scanner of the named service, or nil if there is none.
+func (c *Control) scanner(name string) *scanner.Scanner {
+	for _, s := range c.scanners {
+		if s.Name() == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// scan schedules an immediate scan. Manual scans go through the worker queue like scheduled ones,
+// so a busy daemon refuses them instead of piling up; scan reports false in that case.
+func (c *Control) scan(s *scanner.Scanner) bool {
+	select {
+	case c.queue <- s:
+		return true
+	default:
+		return false
+	}
+}
+
+// authorized reports whether the given bearer token matches the API token.
+func (c *Control) authorized(token string) bool {
+	if c.apiToken == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.apiToken)) == 1
+}
@@ -0,0 +1,183 @@
+package admin
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	dashboardIssues = 20
+)
+
+// dashboardTmpl is the dashboard page. It is compiled into the binary so the dashboard needs no asset files.
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Truncate(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>osprey</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+code { font-size: 90%; white-space: pre-wrap; word-break: break-all; }
+.error { color: #b00; }
+.paused { color: #888; }
+</style>
+</head>
+<body>
+<h1>osprey</h1>
+{{if .User}}<p>Signed in as {{.User}}{{if not .Operator}} (viewer){{end}} &middot; <a href="/auth/logout">Sign out</a></p>{{end}}
+
+<h2>Services</h2>
+<table>
+<tr><th>Service</th><th>Scans</th><th>Failures</th><th>Avg duration</th><th>Findings (1h)</th><th>Lag</th><th>Last error</th><th></th></tr>
+{{range .Services}}
+<tr{{if .Paused}} class="paused"{{end}}>
+<td>{{.Name}}{{if .Paused}} (paused){{end}}</td>
+<td>{{.Scans}}</td>
+<td>{{.Failures}}</td>
+<td>{{.AvgDuration}}</td>
+<td>{{.FindingsHour}}</td>
+<td>{{.Lag}}</td>
+<td class="error">{{.LastError}}</td>
+<td>
+{{if $.Operator}}
+<form method="post" action="/services/{{.Name}}/{{if .Paused}}resume{{else}}pause{{end}}">
+<input type="hidden" name="csrf" value="{{$.CSRF}}">
+<button type="submit">{{if .Paused}}Resume{{else}}Pause{{end}}</button>
+</form>
+{{end}}
+</td>
+</tr>
+{{end}}
+</table>
+
+<h2>Recent findings</h2>
+<table>
+<tr><th>Time</th><th>Service</th><th>Line</th><th>Issue</th></tr>
+{{range .Findings}}
+<tr>
+<td>{{since .Time}}</td>
+<td>{{.Service}}</td>
+<td><code>{{.Line}}</code></td>
+<td>{{if .IssueURL}}<a href="{{.IssueURL}}">{{.IssueURL}}</a>{{else}}<span class="error">{{.Error}}</span>{{end}}</td>
+</tr>
+{{else}}
+<tr><td colspan="4">No findings yet.</td></tr>
+{{end}}
+</table>
+
+<h2>Created issues</h2>
+<table>
+<tr><th>Time</th><th>Service</th><th>Repository</th><th>Issue</th></tr>
+{{range .Issues}}
+<tr>
+<td>{{since .Time}}</td>
+<td>{{.Service}}</td>
+<td>{{.Repo}}</td>
+<td><a href="{{.URL}}">#{{.Number}}</a></td>
+</tr>
+{{else}}
+<tr><td colspan="4">No issues created yet.</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// dashboardData is rendered by the dashboard template.
+type dashboardData struct {
+	// User is the signed-in user, empty without OIDC.
+	User string
+
+	// Operator is set if the user may pause and resume services, which needs OIDC.
+	Operator bool
+
+	// CSRF is the token the pause and resume forms post, tied to the user's session.
+	CSRF string
+
+	Services []scanner.Status
+	Findings []scanner.Finding
+	Issues   []state.AuditRecord
+}
+
+// handleDashboard renders the dashboard page.
+func (a *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := dashboardData{
+		Services: scanner.Statuses(a.scanners),
+		Findings: a.findings.Recent(""),
+	}
+	if s, ok := a.auth.session(r); ok {
+		data.User, data.Operator, data.CSRF = s.User, allows(s.Role, roleOperator), a.auth.csrfToken(r)
+	}
+
+	recs, err := state.NewAuditLog(state.AuditFilePath()).Query(state.AuditFilter{})
+	if err != nil {
+		log.Printf("Unable to read audit log, %s\n", err.Error())
+	}
+	// Show the newest issues first.
+	for i := len(recs) - 1; i >= 0 && len(data.Issues) < dashboardIssues; i-- {
+		data.Issues = append(data.Issues, recs[i])
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTmpl.Execute(w, data); err != nil {
+		log.Printf("Unable to render dashboard, %s\n", err.Error())
+	}
+}
+
+// handleServiceAction pauses or resumes a service from the dashboard, e.g. POST /services/apple/pause. The form must
+// carry the CSRF token of the operator's session, so other sites cannot post it on their behalf.
+func (a *Server) handleServiceAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.auth.checkCSRF(r) {
+		http.Error(w, "invalid or missing CSRF token", http.StatusForbidden)
+		return
+	}
+
+	tks := strings.Split(strings.TrimPrefix(r.URL.Path, "/services/"), "/")
+	if len(tks) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s := a.scanner(tks[0])
+	if s == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch tks[1] {
+	case "pause":
+		s.Pause()
+	case "resume":
+		s.Resume()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestControl returns the control surface of a scanner of the apple service.
+func newTestControl(t *testing.T) (*Control, *scanner.Scanner) {
+	t.Helper()
+
+	s, err := scanner.New(config.Service{Name: "apple", Location: t.TempDir() + "/apple.log", RepoOwner: "owner",
+		RepoName: "apple"}, scanner.Deps{Sink: &ospreytest.Sink{}, StateDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Control{scanners: []*scanner.Scanner{s}, findings: scanner.NewFindingLog(0)}, s
+}
+
+func TestServiceActionWithoutOIDC(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("admin.addr", "127.0.0.1:0")
+	viper.Set("admin.dashboard", true)
+
+	c, s := newTestControl(t)
+	a, err := NewServer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/services/apple/pause", nil))
+	if rec.Code == http.StatusSeeOther || s.IsPaused() {
+		t.Fatalf("got %d, the service must not be paused without a sign-in", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "<form") {
+		t.Fatal("the dashboard shows the pause and resume buttons without OIDC")
+	}
+}
+
+func TestServiceActionCSRF(t *testing.T) {
+	c, s := newTestControl(t)
+	o := &oidcAuth{key: []byte("key"), conf: &oauth2.Config{}}
+	a := &Server{auth: o, Control: c}
+	h := o.require(roleOperator, a.handleServiceAction)
+
+	w := httptest.NewRecorder()
+	o.setSession(w, httptest.NewRequest(http.MethodGet, "/", nil), session{User: "alice", Role: roleOperator,
+		Expires: time.Now().Add(time.Hour).Unix()})
+	cookie := w.Result().Cookies()[0]
+	token := func() string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(cookie)
+		return o.csrfToken(r)
+	}()
+
+	tests := []struct {
+		name   string
+		csrf   string
+		want   int
+		paused bool
+	}{
+		{"missing token", "", http.StatusForbidden, false},
+		{"wrong token", "forged", http.StatusForbidden, false},
+		{"session token", token, http.StatusSeeOther, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.Resume()
+			r := httptest.NewRequest(http.MethodPost, "/services/apple/pause",
+				strings.NewReader(url.Values{"csrf": {tt.csrf}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(cookie)
+			rec := httptest.NewRecorder()
+			h(rec, r)
+			if rec.Code != tt.want || s.IsPaused() != tt.paused {
+				t.Fatalf("got %d, paused %v, want %d, paused %v", rec.Code, s.IsPaused(), tt.want, tt.paused)
+			}
+		})
+	}
+}
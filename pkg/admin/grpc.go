@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	ospreyv1 "github.com/NBCFB/Iguana2/proto/osprey/v1"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// GRPCServer serves the control API over gRPC.
+type GRPCServer struct {
+	ospreyv1.UnimplementedControlServer
+
+	// addr is the address the gRPC server listens on.
+	addr string
+
+	// listener is the bound gRPC port.
+	listener net.Listener
+
+	// server is the underlying gRPC server.
+	server *grpc.Server
+
+	*Control
+}
+
+// NewGRPCServer creates a gRPC server based on config file. It returns nil if no gRPC address is configured.
+func NewGRPCServer(c *Control) *GRPCServer {
+	addr := viper.GetString("admin.grpc_addr")
+	if addr == "" {
+		return nil
+	}
+	if c.apiToken == "" {
+		// The control API can trigger scans and pause services, so it is never served without a token.
+		log.Println("gRPC API is disabled, no API token is configured")
+		return nil
+	}
+
+	g := &GRPCServer{
+		addr:    addr,
+		Control: c,
+	}
+	g.server = grpc.NewServer(
+		grpc.UnaryInterceptor(g.authUnary),
+		grpc.StreamInterceptor(g.authStream),
+	)
+	ospreyv1.RegisterControlServer(g.server, g)
+
+	return g
+}
+
+// Listen binds the gRPC port.
+func (g *GRPCServer) Listen() (err error) {
+	g.listener, err = listenAdmin(g.addr, "h2")
+	return err
+}
+
+// Serve serves gRPC requests on the bound port. It blocks until the listener fails.
+func (g *GRPCServer) Serve() {
+	log.Printf("gRPC server listening on %s\n", g.listener.Addr())
+	if err := g.server.Serve(g.listener); err != nil {
+		log.Printf("gRPC server stopped, %s\n", err.Error())
+	}
+}
+
+// authenticate checks the bearer token carried in the request metadata.
+func (g *GRPCServer) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if g.authorized(strings.TrimPrefix(v, "Bearer ")) {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing API token")
+}
+
+// authUnary authenticates unary calls.
+func (g *GRPCServer) authUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// authStream authenticates streaming calls.
+func (g *GRPCServer) authStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.authenticate(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// ListServices returns the status of every service.
+func (g *GRPCServer) ListServices(ctx context.Context, req *ospreyv1.ListServicesRequest) (*ospreyv1.ListServicesResponse, error) {
+	resp := &ospreyv1.ListServicesResponse{}
+	for _, st := range scanner.Statuses(g.scanners) {
+		resp.Services = append(resp.Services, toProtoService(st))
+	}
+
+	return resp, nil
+}
+
+// GetService returns the status of a service.
+func (g *GRPCServer) GetService(ctx context.Context, req *ospreyv1.ServiceRequest) (*ospreyv1.Service, error) {
+	s, err := g.lookup(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return g.serviceOf(s), nil
+}
+
+// Scan schedules an immediate scan of a service.
+func (g *GRPCServer) Scan(ctx context.Context, req *ospreyv1.ServiceRequest) (*ospreyv1.Service, error) {
+	s, err := g.lookup(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if !g.scan(s) {
+		return nil, status.Error(codes.Unavailable, "scan queue is full, try again later")
+	}
+
+	return g.serviceOf(s), nil
+}
+
+// Pause stops a service from being scanned until it is resumed.
+func (g *GRPCServer) Pause(ctx context.Context, req *ospreyv1.ServiceRequest) (*ospreyv1.Service, error) {
+	s, err := g.lookup(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	s.Pause()
+
+	return g.serviceOf(s), nil
+}
+
+// Resume schedules a paused service again.
+func (g *GRPCServer) Resume(ctx context.Context, req *ospreyv1.ServiceRequest) (*ospreyv1.Service, error) {
+	s, err := g.lookup(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	s.Resume()
+
+	return g.serviceOf(s), nil
+}
+
+// ListFindings returns recent findings, newest first.
+func (g *GRPCServer) ListFindings(ctx context.Context, req *ospreyv1.ListFindingsRequest) (*ospreyv1.ListFindingsResponse, error) {
+	fs := g.findings.Recent(req.GetService())
+	if l := int(req.GetLimit()); l > 0 && l < len(fs) {
+		fs = fs[:l]
+	}
+
+	resp := &ospreyv1.ListFindingsResponse{}
+	for _, f := range fs {
+		resp.Findings = append(resp.Findings, toProtoFinding(f))
+	}
+
+	return resp, nil
+}
+
+// StreamFindings streams findings as they are matched until the client cancels.
+func (g *GRPCServer) StreamFindings(req *ospreyv1.StreamFindingsRequest, stream ospreyv1.Control_StreamFindingsServer) error {
+	ch, cancel := g.findings.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case f := <-ch:
+			if req.GetService() != "" && req.GetService() != f.Service {
+				continue
+			}
+			if err := stream.Send(toProtoFinding(f)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lookup returns the scanner of the named service or a NotFound error.
+func (g *GRPCServer) lookup(name string) (*scanner.Scanner, error) {
+	s := g.scanner(name)
+	if s == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", name)
+	}
+
+	return s, nil
+}
+
+// serviceOf returns the current status of a scanner.
+func (g *GRPCServer) serviceOf(s *scanner.Scanner) *ospreyv1.Service {
+	return toProtoService(s.Status())
+}
+
+// toProtoService converts a service status to its proto message.
+func toProtoService(st scanner.Status) *ospreyv1.Service {
+	svc := &ospreyv1.Service{
+		Name:             st.Name,
+		Paused:           st.Paused,
+		Scans:            int64(st.Scans),
+		Failures:         int64(st.Failures),
+		AvgDurationMs:    float64(st.AverageDuration()) / float64(time.Millisecond),
+		FindingsLastHour: int64(st.FindingsHour),
+		LagSeconds:       -1,
+		LastError:        st.LastError,
+	}
+	if lag := st.LagDuration(); lag >= 0 {
+		svc.LagSeconds = lag.Seconds()
+	}
+	if !st.LastScan.IsZero() {
+		svc.LastScan = timestamppb.New(st.LastScan)
+	}
+	if !st.LastErrorTime.IsZero() {
+		svc.LastErrorTime = timestamppb.New(st.LastErrorTime)
+	}
+
+	return svc
+}
+
+// toProtoFinding converts a finding to its proto message.
+func toProtoFinding(f scanner.Finding) *ospreyv1.Finding {
+	return &ospreyv1.Finding{
+		Time:     timestamppb.New(f.Time),
+		Service:  f.Service,
+		Line:     f.Line,
+		IssueUrl: f.IssueURL,
+		Error:    f.Error,
+	}
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// handleMetrics exposes scanner and SLO metrics in the Prometheus text format.
+func (a *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := writeMetrics(w, a.scanners); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// metricSample is a single sample of a metric family.
+type metricSample struct {
+	service string
+	labels  string
+	value   float64
+}
+
+// writeMetrics writes the metrics of all scanners in the Prometheus text format.
+func writeMetrics(w io.Writer, scanners []*scanner.Scanner) error {
+	bw := bufio.NewWriter(w)
+
+	var (
+		scans, failures, findings                   []metricSample
+		scanRatio, scanBurn, detRatio, detBurn      []metricSample
+		latBuckets, latSum, latCount, latencyTarget []metricSample
+	)
+	for _, s := range scanners {
+		name := s.Name()
+		st := s.Status()
+		scans = append(scans, metricSample{service: name, value: float64(st.Scans)})
+		failures = append(failures, metricSample{service: name, value: float64(st.Failures)})
+		findings = append(findings, metricSample{service: name, value: float64(s.TotalFindings())})
+
+		slo := st.SLO
+		scanRatio = append(scanRatio, metricSample{service: name, value: slo.ScanSuccessRatio})
+		scanBurn = append(scanBurn, metricSample{service: name, value: slo.ScanBurnRate})
+		detRatio = append(detRatio, metricSample{service: name, value: slo.DetectionGoodRatio})
+		detBurn = append(detBurn, metricSample{service: name, value: slo.DetectionBurnRate})
+		latencyTarget = append(latencyTarget, metricSample{service: name, value: s.SLO().LatencyTarget().Seconds()})
+
+		counts, sum, count := s.SLO().Histogram()
+		var cum uint64
+		for i, c := range counts {
+			cum += c
+			le := "+Inf"
+			if i < len(scanner.LatencyBuckets) {
+				le = strconv.FormatFloat(scanner.LatencyBuckets[i], 'g', -1, 64)
+			}
+			latBuckets = append(latBuckets, metricSample{service: name, labels: fmt.Sprintf(`le="%s"`, le), value: float64(cum)})
+		}
+		latSum = append(latSum, metricSample{service: name, value: sum})
+		latCount = append(latCount, metricSample{service: name, value: float64(count)})
+	}
+
+	writeFamily(bw, "osprey_scans_total", "counter", "Number of completed scans.", scans)
+	writeFamily(bw, "osprey_scan_failures_total", "counter", "Number of scans which returned an error.", failures)
+	writeFamily(bw, "osprey_findings_total", "counter", "Number of matched log lines.", findings)
+	writeFamily(bw, "osprey_slo_scan_success_ratio", "gauge", "Fraction of successful scans within the SLO window.", scanRatio)
+	writeFamily(bw, "osprey_slo_scan_burn_rate", "gauge", "Error budget burn rate of scan success, 1 means on budget.", scanBurn)
+	writeFamily(bw, "osprey_slo_detection_good_ratio", "gauge", "Fraction of findings filed within the latency target in the SLO window.", detRatio)
+	writeFamily(bw, "osprey_slo_detection_burn_rate", "gauge", "Error budget burn rate of detection latency, 1 means on budget.", detBurn)
+	writeFamily(bw, "osprey_slo_detection_latency_target_seconds", "gauge", "Target latency from an error being logged to its issue being created.", latencyTarget)
+
+	fmt.Fprintln(bw, "# HELP osprey_detection_latency_seconds Latency from an error being logged to its issue being created.")
+	fmt.Fprintln(bw, "# TYPE osprey_detection_latency_seconds histogram")
+	writeSamples(bw, "osprey_detection_latency_seconds_bucket", latBuckets)
+	writeSamples(bw, "osprey_detection_latency_seconds_sum", latSum)
+	writeSamples(bw, "osprey_detection_latency_seconds_count", latCount)
+
+	return bw.Flush()
+}
+
+// writeFamily writes a metric family with its help and type lines.
+func writeFamily(w io.Writer, name, typ, help string, samples []metricSample) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	writeSamples(w, name, samples)
+}
+
+// writeSamples writes the samples of a metric.
+func writeSamples(w io.Writer, name string, samples []metricSample) {
+	for _, s := range samples {
+		labels := fmt.Sprintf(`service=%q`, s.service)
+		if s.labels != "" {
+			labels += "," + s.labels
+		}
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
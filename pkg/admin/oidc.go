@@ -0,0 +1,412 @@
+package admin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+
+	oidcClientSecretEnvKey = "OSPREY_OIDC_CLIENT_SECRET"
+	sessionCookie          = "osprey_session"
+	oauthStateCookie       = "osprey_oauth_state"
+	defaultSessionTTL      = 12 * time.Hour
+	oidcRequestTimeout     = 10 * time.Second
+	githubUserURL          = "https://api.github.com/user"
+)
+
+// session is the signed-in user, kept in a signed cookie.
+type session struct {
+	User    string `json:"u"`
+	Role    string `json:"r"`
+	Expires int64  `json:"e"`
+}
+
+// oidcAuth signs users in to the dashboard and admin API with an OIDC provider (e.g. Google or Okta) or GitHub
+// OAuth, granting them the viewer or operator role.
+type oidcAuth struct {
+	// provider is oidc or github.
+	provider string
+
+	// conf is the OAuth2 client config.
+	conf *oauth2.Config
+
+	// userinfoURL returns the identity of the signed-in user.
+	userinfoURL string
+
+	// client talks to the provider.
+	client *http.Client
+
+	// operators and viewers list the users of each role: an email or GitHub login, "@<domain>" for a whole
+	// email domain or "*" for anyone the provider signs in.
+	operators []string
+	viewers   []string
+
+	// key signs session cookies. It is generated at startup, so users sign in again after a restart.
+	key []byte
+
+	// ttl is how long a session lasts.
+	ttl time.Duration
+}
+
+// newOIDCAuth creates the sign-in based on config file. It returns nil if admin.oidc.client_id is not configured.
+func newOIDCAuth() (*oidcAuth, error) {
+	clientID := viper.GetString("admin.oidc.client_id")
+	if clientID == "" {
+		return nil, nil
+	}
+
+	client, err := transport.NewHTTPClient("oidc", oidcRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &oidcAuth{
+		provider:  viper.GetString("admin.oidc.provider"),
+		client:    client,
+		operators: viper.GetStringSlice("admin.oidc.operators"),
+		viewers:   viper.GetStringSlice("admin.oidc.viewers"),
+		key:       make([]byte, 32),
+		ttl:       viper.GetDuration("admin.oidc.session_ttl"),
+		conf: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: viper.GetString("admin.oidc.client_secret"),
+			RedirectURL:  viper.GetString("admin.oidc.redirect_url"),
+		},
+	}
+	if s := os.Getenv(oidcClientSecretEnvKey); s != "" {
+		o.conf.ClientSecret = s
+	}
+	if o.ttl <= 0 {
+		o.ttl = defaultSessionTTL
+	}
+	if o.conf.RedirectURL == "" {
+		return nil, fmt.Errorf("admin.oidc.redirect_url is not configured")
+	}
+	if _, err := rand.Read(o.key); err != nil {
+		return nil, err
+	}
+
+	switch o.provider {
+	case "github":
+		o.conf.Endpoint = githuboauth.Endpoint
+		o.conf.Scopes = []string{"read:user"}
+		o.userinfoURL = githubUserURL
+	case "", "oidc":
+		o.provider = "oidc"
+		if err := o.discover(viper.GetString("admin.oidc.issuer")); err != nil {
+			return nil, fmt.Errorf("unable to discover OIDC provider, %s", err.Error())
+		}
+		o.conf.Scopes = []string{"openid", "email", "profile"}
+	default:
+		return nil, fmt.Errorf("unknown admin.oidc.provider %q, use oidc or github", o.provider)
+	}
+
+	return o, nil
+}
+
+// discover reads the endpoints of an OIDC provider from its discovery document.
+func (o *oidcAuth) discover(issuer string) error {
+	if issuer == "" {
+		return fmt.Errorf("admin.oidc.issuer is not configured")
+	}
+
+	res, err := o.client.Get(strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("discovery document returned %s", res.Status)
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserinfoEndpoint == "" {
+		return fmt.Errorf("discovery document lacks the authorization, token or userinfo endpoint")
+	}
+
+	o.conf.Endpoint = oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint}
+	o.userinfoURL = doc.UserinfoEndpoint
+
+	return nil
+}
+
+// handleLogin redirects to the provider, e.g. GET /auth/login?next=/.
+func (o *oidcAuth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "unable to sign in", http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(b)
+
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state + "|" + url.QueryEscape(next),
+		Path:     "/auth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   o.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, o.conf.AuthCodeURL(state), http.StatusFound)
+}
+
+// handleCallback completes the sign-in, e.g. GET /auth/callback?code=...&state=....
+func (o *oidcAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		http.Error(w, "sign-in expired, try again", http.StatusBadRequest)
+		return
+	}
+	tks := strings.SplitN(c.Value, "|", 2)
+	if len(tks) != 2 || !hmac.Equal([]byte(tks[0]), []byte(r.URL.Query().Get("state"))) {
+		http.Error(w, "invalid sign-in state", http.StatusBadRequest)
+		return
+	}
+	next, _ := url.QueryUnescape(tks[1])
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, o.client)
+	tk, err := o.conf.Exchange(ctx, r.URL.Query().Get("code"))
+	if err != nil {
+		log.Printf("Unable to complete sign-in, %s\n", err.Error())
+		http.Error(w, "unable to complete sign-in", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := o.identity(ctx, tk)
+	if err != nil {
+		log.Printf("Unable to read signed-in user, %s\n", err.Error())
+		http.Error(w, "unable to complete sign-in", http.StatusUnauthorized)
+		return
+	}
+
+	role := o.roleOf(user)
+	if role == "" {
+		log.Printf("sign-in of %s refused, it has no role\n", user)
+		http.Error(w, user+" is not allowed to use osprey", http.StatusForbidden)
+		return
+	}
+
+	o.setSession(w, r, session{User: user, Role: role, Expires: time.Now().Add(o.ttl).Unix()})
+	log.Printf("%s signed in as %s\n", user, role)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// handleLogout ends the session.
+func (o *oidcAuth) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// identity returns the signed-in user: the verified email for OIDC, the login for GitHub.
+func (o *oidcAuth) identity(ctx context.Context, tk *oauth2.Token) (string, error) {
+	res, err := o.conf.Client(ctx, tk).Get(o.userinfoURL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("userinfo returned %s", res.Status)
+	}
+
+	var info struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+		Login         string `json:"login"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return "", err
+	}
+
+	if o.provider == "github" {
+		if info.Login == "" {
+			return "", fmt.Errorf("github returned no login")
+		}
+		return info.Login, nil
+	}
+
+	if info.Email == "" {
+		return "", fmt.Errorf("provider returned no email")
+	}
+	if info.EmailVerified != nil && !*info.EmailVerified {
+		return "", fmt.Errorf("email %s is not verified", info.Email)
+	}
+
+	return strings.ToLower(info.Email), nil
+}
+
+// roleOf returns the role of a user, operator taking precedence, or "" if it has none.
+func (o *oidcAuth) roleOf(user string) string {
+	match := func(entries []string) bool {
+		for _, e := range entries {
+			e = strings.ToLower(strings.TrimSpace(e))
+			switch {
+			case e == "*", e == strings.ToLower(user):
+				return true
+			case strings.HasPrefix(e, "@") && strings.HasSuffix(strings.ToLower(user), e):
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case match(o.operators):
+		return roleOperator
+	case match(o.viewers):
+		return roleViewer
+	default:
+		return ""
+	}
+}
+
+// setSession sets the signed session cookie.
+func (o *oidcAuth) setSession(w http.ResponseWriter, r *http.Request, s session) {
+	dat, _ := json.Marshal(s)
+	val := base64.RawURLEncoding.EncodeToString(dat)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    val + "." + o.sign(val),
+		Path:     "/",
+		Expires:  time.Unix(s.Expires, 0),
+		HttpOnly: true,
+		Secure:   o.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// session returns the session of a request, if it carries a valid one.
+func (o *oidcAuth) session(r *http.Request) (*session, bool) {
+	if o == nil {
+		return nil, false
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, false
+	}
+
+	tks := strings.SplitN(c.Value, ".", 2)
+	if len(tks) != 2 || !hmac.Equal([]byte(tks[1]), []byte(o.sign(tks[0]))) {
+		return nil, false
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(tks[0])
+	if err != nil {
+		return nil, false
+	}
+
+	var s session
+	if err := json.Unmarshal(dat, &s); err != nil || time.Now().Unix() > s.Expires {
+		return nil, false
+	}
+
+	return &s, true
+}
+
+// sign returns the signature of a session cookie value.
+func (o *oidcAuth) sign(val string) string {
+	mac := hmac.New(sha256.New, o.key)
+	mac.Write([]byte(val))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfToken returns the CSRF token of the session of a request, empty if it has none. It is derived from the session
+// cookie, so it changes on every sign-in and cannot be guessed without the cookie.
+func (o *oidcAuth) csrfToken(r *http.Request) string {
+	if o == nil {
+		return ""
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+
+	return o.sign("csrf:" + c.Value)
+}
+
+// checkCSRF reports whether a form posted the CSRF token of its session.
+func (o *oidcAuth) checkCSRF(r *http.Request) bool {
+	want := o.csrfToken(r)
+	return want != "" && hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(want))
+}
+
+// sameOrigin reports whether a request was sent by a page of the admin server itself, as the Origin and
+// Sec-Fetch-Site headers browsers set tell; requests without them, e.g. of curl, are not cross-site.
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+
+	return true
+}
+
+// secure reports whether cookies must be limited to https.
+func (o *oidcAuth) secure(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(o.conf.RedirectURL, "https://")
+}
+
+// require wraps a dashboard handler, sending users without a session to sign in and refusing those lacking role.
+// Without OIDC configured the handler is served as is.
+func (o *oidcAuth) require(role string, h http.HandlerFunc) http.HandlerFunc {
+	if o == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := o.session(r)
+		if !ok {
+			if r.Method != http.MethodGet {
+				http.Error(w, "not signed in", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+		if !allows(s.Role, role) {
+			http.Error(w, "the "+role+" role is required", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// allows reports whether a role grants another, operators can do everything viewers can.
+func allows(have, want string) bool {
+	return have == want || have == roleOperator
+}
@@ -0,0 +1,310 @@
+// Package aws is a minimal client of the AWS JSON and Query APIs, signing requests with Signature Version 4.
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	awsContainerCredsHost = "http://169.254.170.2"
+	awsIMDSHost           = "http://169.254.169.254"
+
+	// RequestTimeout is the timeout of AWS API requests.
+	RequestTimeout = 10 * time.Second
+)
+
+// credentials are the credentials requests to AWS are signed with.
+type credentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// Client sends signed requests to AWS JSON APIs. Credentials are taken from the environment, the ECS container
+// endpoint or the EC2 instance metadata service, in this order, and refreshed before they expire.
+type Client struct {
+	mu sync.Mutex
+
+	// region is the AWS region requests are sent to.
+	region string
+
+	// client sends API requests.
+	client *http.Client
+
+	// direct reads credentials from link-local metadata endpoints, which must never go through a proxy.
+	direct *http.Client
+
+	// creds are the cached credentials.
+	creds *credentials
+}
+
+// NewClient returns a client for the given region, defaulting to AWS_REGION, sending API requests with client.
+func NewClient(region string, client *http.Client) *Client {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	return &Client{
+		region: region,
+		client: client,
+		direct: &http.Client{Timeout: RequestTimeout},
+	}
+}
+
+// credentials returns valid credentials, fetching them if needed.
+func (c *Client) credentials() (*credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds != nil && (c.creds.Expiration.IsZero() || time.Until(c.creds.Expiration) > 5*time.Minute) {
+		return c.creds, nil
+	}
+
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		c.creds = &credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		return c.creds, nil
+	}
+
+	var (
+		creds *credentials
+		err   error
+	)
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		creds, err = c.fetchCredentials(awsContainerCredsHost+uri, nil)
+	} else {
+		creds, err = c.imdsCredentials()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain AWS credentials, %s", err.Error())
+	}
+	c.creds = creds
+
+	return c.creds, nil
+}
+
+// imdsCredentials fetches the instance role credentials from the EC2 instance metadata service (IMDSv2).
+func (c *Client) imdsCredentials() (*credentials, error) {
+	req, err := http.NewRequest(http.MethodPut, awsIMDSHost+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := c.read(c.direct, req)
+	if err != nil {
+		return nil, err
+	}
+	hdr := http.Header{"X-Aws-Ec2-Metadata-Token": []string{string(token)}}
+
+	req, err = http.NewRequest(http.MethodGet, awsIMDSHost+"/latest/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = hdr
+	role, err := c.read(c.direct, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.fetchCredentials(awsIMDSHost+"/latest/meta-data/iam/security-credentials/"+strings.TrimSpace(string(role)), hdr)
+}
+
+// fetchCredentials reads credentials from a credentials endpoint.
+func (c *Client) fetchCredentials(url string, hdr http.Header) (*credentials, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if hdr != nil {
+		req.Header = hdr
+	}
+	dat, err := c.read(c.direct, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(dat, &creds); err != nil {
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+// APIError is the error of a request answered with a non 2xx status.
+type APIError struct {
+	// StatusCode is the status of the response.
+	StatusCode int
+
+	msg string
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return e.msg
+}
+
+// read sends a request with the given client and returns its body, failing on non 2xx responses.
+func (c *Client) read(client *http.Client, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	dat, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, &APIError{StatusCode: res.StatusCode,
+			msg: fmt.Sprintf("%s %s returned %s: %s", req.Method, req.URL, res.Status, strings.TrimSpace(string(dat)))}
+	}
+
+	return dat, nil
+}
+
+// CallJSON calls an AWS JSON protocol API, e.g. service "secretsmanager" and target "secretsmanager.GetSecretValue",
+// decoding the response into out.
+func (c *Client) CallJSON(service, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://%s.%s.amazonaws.com/", service, c.region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	if err := c.sign(req, body, service); err != nil {
+		return err
+	}
+
+	dat, err := c.read(c.client, req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dat, out)
+}
+
+// CallQuery calls an AWS Query protocol API, e.g. service "sns" and the Action and Version of params, at endpoint,
+// or at the regional endpoint of the service if it is empty, decoding the XML response into out unless it is nil.
+func (c *Client) CallQuery(ctx context.Context, service, endpoint string, params url.Values, out interface{}) error {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com/", service, c.region)
+	}
+	body := []byte(params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	if err := c.sign(req, body, service); err != nil {
+		return err
+	}
+
+	dat, err := c.read(c.client, req)
+	if err != nil || out == nil {
+		return err
+	}
+
+	return xml.Unmarshal(dat, out)
+}
+
+// sign signs a request with AWS Signature Version 4.
+func (c *Client) sign(req *http.Request, body []byte, service string) error {
+	if c.region == "" {
+		return fmt.Errorf("no AWS region, set it in config or AWS_REGION")
+	}
+
+	creds, err := c.credentials()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("Host", req.URL.Host)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	var names []string
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256Hex(body)
+	canonReq := strings.Join([]string{
+		req.Method, path, req.URL.Query().Encode(), canonHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{day, c.region, service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonReq))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), day)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, sig))
+
+	return nil
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,314 @@
+// Package config reads osprey's config file and the services defined in it.
+package config
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultConfigName = "osprey"
+	defaultConfigType = "yml"
+	defaultConfigPath = "/usr/local/etc/"
+
+	// RootKey is the config key the services are defined under.
+	RootKey = "services"
+
+	// TitleFingerprint and TitleTimestamp are the title formats of issues, see Service.TitleFormat.
+	TitleFingerprint = "fingerprint"
+	TitleTimestamp   = "timestamp"
+
+	// DuplicateSkip and DuplicateComment are what is done with a finding whose issue is open, see
+	// Service.OnDuplicate.
+	DuplicateSkip    = "skip"
+	DuplicateComment = "comment"
+
+	// DefaultTimeFormat is the layout of the times in issue titles and bodies of services without a time_format.
+	DefaultTimeFormat = "2006-01-02 15:04:05"
+)
+
+// Service holds the information about a service, including log file location and target repository.
+type Service struct {
+	// Name is the service name.
+	Name string
+
+	// Location is the log file location, a glob pattern of several or a directory of them. A relative path is
+	// resolved against BaseDir.
+	Location string
+
+	// FilePattern is the glob pattern of the log files of a directory location, *.log if empty.
+	FilePattern string
+
+	// BaseDir is the directory relative locations are resolved against: the base_dir key, itself relative to the
+	// config file's directory, or else the config file's directory.
+	BaseDir string
+
+	// RepoOwner is the target repository owner.
+	RepoOwner string
+
+	// RepoName is the target repository name.
+	RepoName string
+
+	// Labels are the labels of the service's issues, besides those of their severity.
+	Labels []string
+
+	// Assignees are the users the service's issues are assigned to.
+	Assignees []string
+
+	// Milestone is the number of the milestone of the service's issues, 0 for none.
+	Milestone int
+
+	// SearchDuplicates is whether an open issue with the fingerprint of a finding is searched for before filing it,
+	// so a recurring error is filed once. It is true unless search_duplicates is false.
+	SearchDuplicates bool
+
+	// OnDuplicate is what is done with a finding whose issue is open: DuplicateSkip, the default, drops it;
+	// DuplicateComment comments on the issue that the error was seen again, with the number of occurrences.
+	OnDuplicate string
+
+	// Reopen is whether a closed issue of an error seen again is reopened and commented on rather than a new one
+	// filed, so its history stays in one thread.
+	Reopen bool
+
+	// Source is the registered source reading the logs, the file source if empty.
+	Source string
+
+	// Parser is the registered parser turning log lines into entries, the plain parser if empty. It is read from
+	// the parser key, or else the format key, e.g. format: json.
+	Parser string
+
+	// Matcher is the registered matcher finding errors in the logs, the keyword matcher if empty.
+	Matcher string
+
+	// Enrichers are the registered enrichers completing findings, in order. Nil selects the default ones, an empty
+	// list none.
+	Enrichers []string
+
+	// Sink is the registered sink delivering the findings, the scanner's default sink if empty. It is read from the
+	// sink key, or else the tracker key, e.g. tracker: gitlab.
+	Sink string
+
+	// Sinks are the registered sinks a finding is delivered to, the primary one, filing the issue, first. Sink alone
+	// is delivered to if it is empty.
+	Sinks []string
+
+	// Middleware are the registered middleware wrapping the sink, in order, e.g. dedupe and throttle.
+	Middleware []string
+
+	// TitleFormat is how default issue titles are made: TitleFingerprint, the default, summarizes the line and names
+	// its fingerprint, so occurrences of an error share a title; TitleTimestamp is the former <service>-bug-<time>.
+	TitleFormat string
+
+	// TimeFormat is the layout of the times in issue titles and bodies, DefaultTimeFormat if empty.
+	TimeFormat string
+
+	// TimeZone is the zone of the times in issue titles and bodies, the local zone if nil.
+	TimeZone *time.Location
+
+	// Locale is the registered locale of the default issue text, e.g. de, English if empty.
+	Locale string
+
+	// MaxIssuesPerInterval caps the issues filed or commented on per scan, 0 for no cap. The errors beyond it are
+	// summarized in a comment on the last issue.
+	MaxIssuesPerInterval int
+
+	// MaxOpenIssues stops filing while the service's repository has this many open issues filed by osprey, 0 for
+	// no cap.
+	MaxOpenIssues int
+
+	// CloseAfter is how long an error may go unseen before the issue filed for it is closed, 0 disables it.
+	CloseAfter time.Duration
+
+	// StaleAfter is how long the log file may stay unwritten before a "logs stopped" issue is raised, 0 disables it.
+	StaleAfter time.Duration
+
+	// Follow is whether the log file is scanned as soon as it is written, like tail -F, besides every interval.
+	Follow bool
+}
+
+// Load reads osprey config file. A config of an older version is migrated to CurrentVersion, again whenever the
+// watched file changes.
+func Load() error {
+	viper.SetConfigName(defaultConfigName)
+	viper.SetConfigType(defaultConfigType)
+	viper.AddConfigPath(defaultConfigPath)
+
+	// Read the config file.
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+
+	viper.OnConfigChange(func(fsnotify.Event) {
+		if err := upgrade(); err != nil {
+			log.Printf("Unable to migrate config, %s\n", err.Error())
+		}
+	})
+	viper.WatchConfig()
+
+	return upgrade()
+}
+
+// Services returns the services defined in config file.
+func Services() ([]Service, error) {
+	var svcs []Service
+	for name := range viper.GetStringMap(RootKey) {
+		svc := Service{
+			Name:      name,
+			Location:  viper.GetString(Key(name, "location")),
+			RepoOwner: viper.GetString(Key(name, "repo_owner")),
+			RepoName:  viper.GetString(Key(name, "repo_name")),
+			Labels:    viper.GetStringSlice(Key(name, "labels")),
+			Assignees: viper.GetStringSlice(Key(name, "assignees")),
+			Milestone: viper.GetInt(Key(name, "milestone")),
+			SearchDuplicates: !viper.IsSet(Key(name, "search_duplicates")) ||
+				viper.GetBool(Key(name, "search_duplicates")),
+			Source:               viper.GetString(Key(name, "source")),
+			Parser:               serviceParser(name),
+			Matcher:              viper.GetString(Key(name, "matcher")),
+			Sink:                 serviceSink(name),
+			Sinks:                viper.GetStringSlice(Key(name, "sinks")),
+			StaleAfter:           viper.GetDuration(Key(name, "stale_after")),
+			CloseAfter:           viper.GetDuration(Key(name, "close_after")),
+			MaxIssuesPerInterval: viper.GetInt(Key(name, "max_issues_per_interval")),
+			MaxOpenIssues:        viper.GetInt(Key(name, "max_open_issues")),
+			Middleware:           viper.GetStringSlice(Key(name, "middleware")),
+			OnDuplicate:          viper.GetString(Key(name, "on_duplicate")),
+			Reopen:               viper.GetBool(Key(name, "reopen")),
+			TitleFormat:          viper.GetString(Key(name, "title_format")),
+			TimeFormat:           serviceString(name, "time_format"),
+			BaseDir:              baseDir(name),
+			Locale:               serviceString(name, "locale"),
+			Follow:               serviceBool(name, "follow"),
+			FilePattern:          viper.GetString(Key(name, "file_pattern")),
+		}
+		if svc.Location != "" && !filepath.IsAbs(svc.Location) && !strings.Contains(svc.Location, "://") {
+			svc.Location = filepath.Join(svc.BaseDir, svc.Location)
+		}
+		if viper.IsSet(Key(name, "enrichers")) {
+			svc.Enrichers = append([]string{}, viper.GetStringSlice(Key(name, "enrichers"))...)
+		}
+		if svc.Location == "" {
+			return nil, fmt.Errorf("service %s needs a location", name)
+		}
+		if (svc.RepoOwner == "" || svc.RepoName == "") && filesInRepo(svc) {
+			return nil, fmt.Errorf("service %s needs a repo_owner and repo_name", name)
+		}
+		if f := svc.TitleFormat; f != "" && f != TitleFingerprint && f != TitleTimestamp {
+			return nil, fmt.Errorf("service %s has an unknown title_format %q, want %s or %s", name, f,
+				TitleFingerprint, TitleTimestamp)
+		}
+		if d := svc.OnDuplicate; d != "" && d != DuplicateSkip && d != DuplicateComment {
+			return nil, fmt.Errorf("service %s has an unknown on_duplicate %q, want %s or %s", name, d,
+				DuplicateSkip, DuplicateComment)
+		}
+		if tz := serviceString(name, "time_zone"); tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("service %s has an unknown time_zone %q, %s", name, tz, err.Error())
+			}
+			svc.TimeZone = loc
+		}
+		svcs = append(svcs, svc)
+	}
+
+	return svcs, nil
+}
+
+// repoSinks are the sinks filing issues in the repo_owner/repo_name repository, github also being the default sink.
+var repoSinks = map[string]bool{"": true, "github": true, "gitea": true}
+
+// filesInRepo reports whether a sink of a service, or of one of its routes, files issues in its repository. The
+// other trackers check their own project keys.
+func filesInRepo(svc Service) bool {
+	names := svc.Sinks
+	if len(names) == 0 {
+		names = []string{svc.Sink}
+	}
+	for _, route := range viper.GetStringMapStringSlice(Key(svc.Name, "routes")) {
+		names = append(names, route...)
+	}
+	for _, name := range names {
+		if repoSinks[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceString returns a setting of a service, or the top-level setting of the same name for all services.
+func serviceString(service, key string) string {
+	if v := viper.GetString(Key(service, key)); v != "" {
+		return v
+	}
+
+	return viper.GetString(key)
+}
+
+// serviceBool returns a boolean setting of a service, or the top-level setting of the same name if the service does
+// not set it.
+func serviceBool(service, key string) bool {
+	if viper.IsSet(Key(service, key)) {
+		return viper.GetBool(Key(service, key))
+	}
+
+	return viper.GetBool(key)
+}
+
+// serviceParser returns the parser of a service, format being another name for the parser key.
+func serviceParser(service string) string {
+	if p := viper.GetString(Key(service, "parser")); p != "" {
+		return p
+	}
+
+	return viper.GetString(Key(service, "format"))
+}
+
+// serviceSink returns the sink of a service, tracker being another name for the sink key.
+func serviceSink(service string) string {
+	if s := viper.GetString(Key(service, "sink")); s != "" {
+		return s
+	}
+
+	return viper.GetString(Key(service, "tracker"))
+}
+
+// baseDir returns the directory relative locations of a service are resolved against.
+func baseDir(service string) string {
+	dir := filepath.Dir(viper.ConfigFileUsed())
+	if base := serviceString(service, "base_dir"); base != "" {
+		if filepath.IsAbs(base) {
+			return filepath.Clean(base)
+		}
+		return filepath.Join(dir, base)
+	}
+
+	return dir
+}
+
+// FormatTime formats t for the issue titles and bodies of the service, in its time format and zone.
+func (s Service) FormatTime(t time.Time) string {
+	if s.TimeZone != nil {
+		t = t.In(s.TimeZone)
+	}
+	if s.TimeFormat == "" {
+		return t.Format(DefaultTimeFormat)
+	}
+
+	return t.Format(s.TimeFormat)
+}
+
+// Key returns the config key of a service setting, e.g. services.apple.stale_after.
+func Key(service, key string) string {
+	return fmt.Sprintf("%s.%s.%s", RootKey, service, key)
+}
+
+// StateDir returns the directory of the .igu state files.
+func StateDir() string {
+	return viper.GetString("state.dir")
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func TestServicesRepo(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		ok       bool
+	}{
+		{"github by default", map[string]interface{}{}, false},
+		{"github", map[string]interface{}{"sink": "github"}, false},
+		{"gitea", map[string]interface{}{"tracker": "gitea"}, false},
+		{"jira", map[string]interface{}{"tracker": "jira", "jira": map[string]interface{}{"project": "APPLE"}}, true},
+		{"slack", map[string]interface{}{"sink": "slack"}, true},
+		{"fan-out to github", map[string]interface{}{"sinks": []string{"slack", "github"}}, false},
+		{"route to github", map[string]interface{}{"sink": "slack",
+			"routes": map[string]interface{}{"fatal": []string{"github"}}}, false},
+		{"github with a repository", map[string]interface{}{"repo_owner": "owner", "repo_name": "apple"}, true},
+		{"no location", map[string]interface{}{"sink": "slack", "location": ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			svc := map[string]interface{}{"location": "/var/log/apple.log"}
+			for k, v := range tt.settings {
+				svc[k] = v
+			}
+			viper.Set(RootKey, map[string]interface{}{"apple": svc})
+
+			if _, err := Services(); (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
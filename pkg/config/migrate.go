@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// CurrentVersion is the config version this osprey reads. A config without a version key is version 0; older
+// versions are migrated when they are loaded.
+const CurrentVersion = 1
+
+// migrations upgrade the settings of version n to version n+1 in place, indexed by n.
+var migrations = []func(settings map[string]interface{}){
+	migrateV0,
+}
+
+// migrateV0 moves the state file paths into the state section and drops the service mode, superseded by source.
+func migrateV0(settings map[string]interface{}) {
+	move(settings, "igu_file_path", "state", "dir")
+	move(settings, "audit_file_path", "state", "audit_file")
+
+	svcs, _ := settings[RootKey].(map[string]interface{})
+	for _, v := range svcs {
+		if svc, ok := v.(map[string]interface{}); ok {
+			delete(svc, "mode")
+		}
+	}
+}
+
+// move moves a top level setting into a section, creating the section if needed.
+func move(settings map[string]interface{}, from, section, to string) {
+	v, ok := settings[from]
+	if !ok {
+		return
+	}
+	delete(settings, from)
+
+	sec, ok := settings[section].(map[string]interface{})
+	if !ok {
+		sec = make(map[string]interface{})
+		settings[section] = sec
+	}
+	if _, ok := sec[to]; !ok {
+		sec[to] = v
+	}
+}
+
+// Migrate upgrades settings to CurrentVersion in place and returns the version they had. Settings of a version
+// newer than CurrentVersion are refused.
+func Migrate(settings map[string]interface{}) (from int, err error) {
+	from, err = version(settings)
+	if err != nil {
+		return 0, err
+	}
+	if from > CurrentVersion {
+		return from, fmt.Errorf("config version %d is newer than this osprey supports (%d)", from, CurrentVersion)
+	}
+
+	for v := from; v < CurrentVersion; v++ {
+		migrations[v](settings)
+		settings["version"] = v + 1
+	}
+
+	return from, nil
+}
+
+// version returns the version of settings.
+func version(settings map[string]interface{}) (int, error) {
+	v, ok := settings["version"]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := v.(int)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("invalid config version %v", v)
+	}
+
+	return n, nil
+}
+
+// upgrade checks the config file read by viper against the schema of its version and, if it is older than
+// CurrentVersion, migrates it and prints the difference, so the file can be updated.
+func upgrade() error {
+	path := viper.ConfigFileUsed()
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(dat, &raw); err != nil {
+		return err
+	}
+	settings := normalize(raw).(map[string]interface{})
+
+	from, err := version(settings)
+	if err != nil {
+		return err
+	}
+	if schema, ok := schemas[from]; ok {
+		for _, p := range schema.Check(settings) {
+			log.Printf("config %s: %s\n", path, p)
+		}
+	}
+
+	before, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if _, err := Migrate(settings); err != nil {
+		return err
+	}
+	if from == CurrentVersion {
+		return nil
+	}
+
+	after, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	log.Printf("config %s is version %d, migrated to version %d. Update it with:\n%s", path, from,
+		CurrentVersion, diff(string(before), string(after)))
+
+	return viper.ReadConfig(bytes.NewReader(after))
+}
+
+// normalize turns the maps decoded by yaml into string keyed maps.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+	}
+
+	return v
+}
+
+// diffContext is the number of unchanged lines shown around a change.
+const diffContext = 2
+
+// diff returns the lines changed from a to b, prefixed with - and +, and a few unchanged lines around them.
+func diff(a, b string) string {
+	x, y := strings.Split(strings.TrimSuffix(a, "\n"), "\n"), strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, "  "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+x[i])
+			i++
+		default:
+			lines = append(lines, "+ "+y[j])
+			j++
+		}
+	}
+
+	// Keep the changed lines and their context.
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l[0] == ' ' {
+			continue
+		}
+		for k := i - diffContext; k <= i+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for i, l := range lines {
+		if !keep[i] {
+			if i > 0 && keep[i-1] {
+				sb.WriteString("  ...\n")
+			}
+			continue
+		}
+		sb.WriteString(l + "\n")
+	}
+
+	return sb.String()
+}
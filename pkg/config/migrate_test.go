@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		want     map[string]interface{}
+		from     int
+		ok       bool
+	}{
+		{
+			"v0",
+			map[string]interface{}{
+				"igu_file_path":   "/var/lib/osprey",
+				"audit_file_path": "/var/lib/osprey/audit.log",
+				RootKey:           map[string]interface{}{"apple": map[string]interface{}{"mode": "file", "location": "a.log"}},
+			},
+			map[string]interface{}{
+				"version": 1,
+				"state":   map[string]interface{}{"dir": "/var/lib/osprey", "audit_file": "/var/lib/osprey/audit.log"},
+				RootKey:   map[string]interface{}{"apple": map[string]interface{}{"location": "a.log"}},
+			},
+			0, true,
+		},
+		{
+			"v0 keeps the state section",
+			map[string]interface{}{"igu_file_path": "/tmp", "state": map[string]interface{}{"dir": "/var/lib/osprey"}},
+			map[string]interface{}{"version": 1, "state": map[string]interface{}{"dir": "/var/lib/osprey"}},
+			0, true,
+		},
+		{
+			"current",
+			map[string]interface{}{"version": 1, "state": map[string]interface{}{"dir": "/var/lib/osprey"}},
+			map[string]interface{}{"version": 1, "state": map[string]interface{}{"dir": "/var/lib/osprey"}},
+			1, true,
+		},
+		{"newer", map[string]interface{}{"version": 2}, nil, 2, false},
+		{"invalid", map[string]interface{}{"version": "one"}, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := Migrate(tt.settings)
+			if (err == nil) != tt.ok || from != tt.from {
+				t.Fatalf("got %d, %v, want %d, ok %v", from, err, tt.from, tt.ok)
+			}
+			if tt.ok && !reflect.DeepEqual(tt.settings, tt.want) {
+				t.Fatalf("got %v, want %v", tt.settings, tt.want)
+			}
+		})
+	}
+}
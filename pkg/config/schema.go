@@ -0,0 +1,472 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Type is the type of a config value.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	Duration
+	List
+
+	// Map is a free-form section, its keys are not checked.
+	Map
+)
+
+// String returns the type name, as used in config problems.
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "a string"
+	case Int:
+		return "an integer"
+	case Float:
+		return "a number"
+	case Bool:
+		return "a boolean"
+	case Duration:
+		return "a duration"
+	case List:
+		return "a list"
+	}
+
+	return "a section"
+}
+
+// Schema maps the dotted keys of a config version to their types. A * segment matches any key, e.g. a service
+// name. Keys under services.* which are not listed are taken as settings of a registered component and not
+// checked.
+type Schema map[string]Type
+
+// common holds the keys shared by all config versions.
+var common = Schema{
+	"interval":                           Int,
+	"max_workers":                        Int,
+	"admin.addr":                         String,
+	"admin.dashboard":                    Bool,
+	"admin.api_token":                    String,
+	"admin.grpc_addr":                    String,
+	"admin.pprof":                        Bool,
+	"admin.public_endpoints":             List,
+	"admin.tls.cert_file":                String,
+	"admin.tls.key_file":                 String,
+	"admin.tls.client_ca_file":           String,
+	"admin.tls.min_version":              String,
+	"admin.oidc.provider":                String,
+	"admin.oidc.issuer":                  String,
+	"admin.oidc.client_id":               String,
+	"admin.oidc.client_secret":           String,
+	"admin.oidc.redirect_url":            String,
+	"admin.oidc.session_ttl":             Duration,
+	"admin.oidc.operators":               List,
+	"admin.oidc.viewers":                 List,
+	"github.token_file":                  String,
+	"github.token_refresh":               Duration,
+	"github.token_command":               List,
+	"github.breaker.failures":            Int,
+	"github.breaker.cooldown":            Duration,
+	"github.base_url":                    String,
+	"github.upload_url":                  String,
+	"github.app.id":                      Int,
+	"github.app.installation_id":         Int,
+	"github.app.private_key_file":        String,
+	"gitlab.base_url":                    String,
+	"gitlab.breaker.failures":            Int,
+	"gitlab.breaker.cooldown":            Duration,
+	"jira.base_url":                      String,
+	"jira.user":                          String,
+	"jira.close_transition":              String,
+	"jira.reopen_transition":             String,
+	"jira.breaker.failures":              Int,
+	"jira.breaker.cooldown":              Duration,
+	"gitea.base_url":                     String,
+	"gitea.breaker.failures":             Int,
+	"gitea.breaker.cooldown":             Duration,
+	"bitbucket.base_url":                 String,
+	"bitbucket.user":                     String,
+	"bitbucket.breaker.failures":         Int,
+	"bitbucket.breaker.cooldown":         Duration,
+	"azure_devops.base_url":              String,
+	"azure_devops.organization":          String,
+	"azure_devops.close_state":           String,
+	"azure_devops.reopen_state":          String,
+	"azure_devops.breaker.failures":      Int,
+	"azure_devops.breaker.cooldown":      Duration,
+	"slack.channel":                      String,
+	"slack.webhook_secret":               String,
+	"slack.breaker.failures":             Int,
+	"slack.breaker.cooldown":             Duration,
+	"teams.webhook_secret":               String,
+	"teams.breaker.failures":             Int,
+	"teams.breaker.cooldown":             Duration,
+	"discord.webhook_secret":             String,
+	"discord.breaker.failures":           Int,
+	"discord.breaker.cooldown":           Duration,
+	"email.host":                         String,
+	"email.port":                         Int,
+	"email.security":                     String,
+	"email.user":                         String,
+	"email.from":                         String,
+	"email.to":                           List,
+	"email.interval":                     Duration,
+	"email.breaker.failures":             Int,
+	"email.breaker.cooldown":             Duration,
+	"pagerduty.base_url":                 String,
+	"pagerduty.key_secret":               String,
+	"pagerduty.severity":                 String,
+	"pagerduty.breaker.failures":         Int,
+	"pagerduty.breaker.cooldown":         Duration,
+	"opsgenie.base_url":                  String,
+	"opsgenie.key_secret":                String,
+	"opsgenie.responders":                List,
+	"opsgenie.priorities":                Map,
+	"opsgenie.priority":                  String,
+	"opsgenie.breaker.failures":          Int,
+	"opsgenie.breaker.cooldown":          Duration,
+	"webhook.url":                        String,
+	"webhook.method":                     String,
+	"webhook.headers":                    Map,
+	"webhook.header_secrets":             Map,
+	"webhook.hmac_secret":                String,
+	"webhook.template":                   String,
+	"webhook.breaker.failures":           Int,
+	"webhook.breaker.cooldown":           Duration,
+	"sentry.dsn_secret":                  String,
+	"sentry.environment":                 String,
+	"sentry.breaker.failures":            Int,
+	"sentry.breaker.cooldown":            Duration,
+	"telegram.base_url":                  String,
+	"telegram.chat_id":                   String,
+	"telegram.token_secret":              String,
+	"telegram.breaker.failures":          Int,
+	"telegram.breaker.cooldown":          Duration,
+	"sns.topic_arn":                      String,
+	"sns.endpoint":                       String,
+	"sns.breaker.failures":               Int,
+	"sns.breaker.cooldown":               Duration,
+	"sqs.queue_url":                      String,
+	"sqs.endpoint":                       String,
+	"sqs.breaker.failures":               Int,
+	"sqs.breaker.cooldown":               Duration,
+	"nats.url":                           String,
+	"nats.user":                          String,
+	"nats.token_secret":                  String,
+	"nats.subject":                       String,
+	"nats.breaker.failures":              Int,
+	"nats.breaker.cooldown":              Duration,
+	"mqtt.url":                           String,
+	"mqtt.client_id":                     String,
+	"mqtt.user":                          String,
+	"mqtt.topic":                         String,
+	"mqtt.qos":                           Int,
+	"mqtt.retain":                        Bool,
+	"mqtt.breaker.failures":              Int,
+	"mqtt.breaker.cooldown":              Duration,
+	"credentials.provider":               String,
+	"credentials.refresh":                Duration,
+	"credentials.secrets":                Map,
+	"vault.addr":                         String,
+	"vault.auth":                         String,
+	"vault.mount":                        String,
+	"vault.role":                         String,
+	"vault.role_id":                      String,
+	"vault.secret_id_file":               String,
+	"vault.jwt_file":                     String,
+	"vault.token_file":                   String,
+	"aws.region":                         String,
+	"proxy.url":                          String,
+	"proxy.no_proxy":                     String,
+	"tls.*.ca_file":                      String,
+	"tls.*.cert_file":                    String,
+	"tls.*.key_file":                     String,
+	"tls.*.min_version":                  String,
+	"tls.*.server_name":                  String,
+	"run_as.user":                        String,
+	"run_as.group":                       String,
+	"state.sign":                         Bool,
+	"secret_guard.mode":                  String,
+	"statsd.addr":                        String,
+	"statsd.prefix":                      String,
+	"statsd.dogstatsd":                   Bool,
+	"statsd.tags":                        List,
+	"statsd.interval":                    Duration,
+	"events.target":                      String,
+	"time_format":                        String,
+	"update.repo":                        String,
+	"update.public_key":                  String,
+	"update.insecure":                    Bool,
+	"base_dir":                           String,
+	"locale":                             String,
+	"time_zone":                          String,
+	"follow":                             Bool,
+	"slo.latency_target":                 Duration,
+	"slo.objective":                      Float,
+	"slo.window":                         Duration,
+	"plugins.*.path":                     String,
+	"plugins.*.args":                     List,
+	"services.*.location":                String,
+	"services.*.file_pattern":            String,
+	"services.*.base_dir":                String,
+	"services.*.repo_owner":              String,
+	"services.*.repo_name":               String,
+	"services.*.token":                   String,
+	"services.*.token_env":               String,
+	"services.*.token_file":              String,
+	"services.*.token_command":           List,
+	"services.*.token_secret":            String,
+	"services.*.labels":                  List,
+	"services.*.assignees":               List,
+	"services.*.milestone":               Int,
+	"services.*.search_duplicates":       Bool,
+	"services.*.on_duplicate":            String,
+	"services.*.reopen":                  Bool,
+	"services.*.source":                  String,
+	"services.*.parser":                  String,
+	"services.*.format":                  String,
+	"services.*.regex":                   String,
+	"services.*.matcher":                 String,
+	"services.*.keywords":                List,
+	"services.*.patterns":                List,
+	"services.*.level_key":               String,
+	"services.*.levels":                  List,
+	"services.*.fields":                  List,
+	"services.*.digest":                  Bool,
+	"services.*.batch_dedupe":            Bool,
+	"services.*.cluster.similarity":      Float,
+	"services.*.ignore_patterns":         List,
+	"services.*.context_before":          Int,
+	"services.*.context_after":           Int,
+	"services.*.multiline.enabled":       Bool,
+	"services.*.multiline.continuation":  List,
+	"services.*.multiline.max_lines":     Int,
+	"services.*.enrichers":               List,
+	"services.*.sink":                    String,
+	"services.*.tracker":                 String,
+	"services.*.sinks":                   List,
+	"services.*.routes":                  Map,
+	"services.*.gitlab.project":          String,
+	"services.*.jira.project":            String,
+	"services.*.jira.issue_type":         String,
+	"services.*.bitbucket.workspace":     String,
+	"services.*.bitbucket.repo_slug":     String,
+	"services.*.azure_devops.project":    String,
+	"services.*.azure_devops.item_type":  String,
+	"services.*.slack.channel":           String,
+	"services.*.slack.webhook_secret":    String,
+	"services.*.teams.webhook_secret":    String,
+	"services.*.discord.webhook_secret":  String,
+	"services.*.email.to":                List,
+	"services.*.pagerduty.key_secret":    String,
+	"services.*.pagerduty.severity":      String,
+	"services.*.opsgenie.key_secret":     String,
+	"services.*.opsgenie.responders":     List,
+	"services.*.opsgenie.priorities":     Map,
+	"services.*.opsgenie.priority":       String,
+	"services.*.webhook.url":             String,
+	"services.*.webhook.method":          String,
+	"services.*.webhook.headers":         Map,
+	"services.*.webhook.header_secrets":  Map,
+	"services.*.webhook.hmac_secret":     String,
+	"services.*.webhook.template":        String,
+	"services.*.sentry.dsn_secret":       String,
+	"services.*.sentry.environment":      String,
+	"services.*.telegram.chat_id":        String,
+	"services.*.telegram.token_secret":   String,
+	"services.*.sns.topic_arn":           String,
+	"services.*.sqs.queue_url":           String,
+	"services.*.nats.subject":            String,
+	"services.*.mqtt.topic":              String,
+	"services.*.mqtt.qos":                Int,
+	"services.*.middleware":              List,
+	"services.*.dedupe.window":           Duration,
+	"services.*.throttle.rate":           Int,
+	"services.*.throttle.per":            Duration,
+	"services.*.cooldown.period":         Duration,
+	"services.*.threshold.count":         Int,
+	"services.*.threshold.window":        Duration,
+	"services.*.threshold.patterns":      List,
+	"services.*.retry.attempts":          Int,
+	"services.*.retry.initial":           Duration,
+	"services.*.retry.max":               Duration,
+	"services.*.stale_after":             Duration,
+	"services.*.follow":                  Bool,
+	"services.*.close_after":             Duration,
+	"services.*.max_issues_per_interval": Int,
+	"services.*.max_open_issues":         Int,
+	"services.*.title_format":            String,
+	"services.*.time_format":             String,
+	"services.*.time_zone":               String,
+	"services.*.locale":                  String,
+	"services.*.redact":                  List,
+	"services.*.severities":              List,
+	"services.*.title_template":          String,
+	"services.*.body_template":           String,
+	"services.*.mask_pii":                Bool,
+	"services.*.exec.command":            List,
+	"services.*.exec.timeout":            Duration,
+	"services.*.wasm_module":             String,
+	"services.*.wasm_timeout":            Duration,
+}
+
+// schemas are the schemas by config version.
+var schemas = map[int]Schema{
+	0: common.with(Schema{
+		"igu_file_path":   String,
+		"audit_file_path": String,
+		"services.*.mode": String,
+	}),
+	1: common.with(Schema{
+		"version":          Int,
+		"state.dir":        String,
+		"state.audit_file": String,
+	}),
+}
+
+// with returns the schema extended by the keys of extra.
+func (s Schema) with(extra Schema) Schema {
+	out := make(Schema, len(s)+len(extra))
+	for k, t := range s {
+		out[k] = t
+	}
+	for k, t := range extra {
+		out[k] = t
+	}
+
+	return out
+}
+
+// Check returns the problems of settings against the schema, sorted: unknown keys, often typos, and values of the
+// wrong type.
+func (s Schema) Check(settings map[string]interface{}) []string {
+	var problems []string
+	walk(settings, nil, func(path []string, v interface{}) bool {
+		key := strings.Join(path, ".")
+		if v == nil {
+			return false
+		}
+		t, ok := s.lookup(path)
+		if !ok {
+			if s.hasSection(path) {
+				if _, isSection := v.(map[string]interface{}); isSection {
+					return true
+				}
+				problems = append(problems, fmt.Sprintf("%s should be a section", key))
+				return false
+			}
+			if len(path) > 2 && strings.EqualFold(path[0], RootKey) {
+				return false
+			}
+			problems = append(problems, fmt.Sprintf("unknown key %s", key))
+			return false
+		}
+		if !t.accepts(v) {
+			problems = append(problems, fmt.Sprintf("%s should be %s", key, t))
+		}
+
+		return false
+	})
+	sort.Strings(problems)
+
+	return problems
+}
+
+// lookup returns the type of a key.
+func (s Schema) lookup(path []string) (Type, bool) {
+	for k, t := range s {
+		if matches(strings.Split(k, "."), path) {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
+// hasSection reports whether path is a section holding schema keys.
+func (s Schema) hasSection(path []string) bool {
+	for k := range s {
+		segs := strings.Split(k, ".")
+		if len(segs) > len(path) && matches(segs[:len(path)], path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches reports whether a key path matches schema segments, * matching any key.
+func matches(segs, path []string) bool {
+	if len(segs) != len(path) {
+		return false
+	}
+	for i, seg := range segs {
+		if seg != "*" && !strings.EqualFold(seg, path[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// accepts reports whether v is a valid value of the type.
+func (t Type) accepts(v interface{}) bool {
+	switch t {
+	case String:
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+		return true
+	case Int:
+		_, ok := v.(int)
+		return ok
+	case Float:
+		switch v.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	case Bool:
+		_, ok := v.(bool)
+		return ok
+	case Duration:
+		switch d := v.(type) {
+		case int:
+			return true
+		case string:
+			_, err := time.ParseDuration(d)
+			return err == nil
+		}
+		return false
+	case List:
+		_, ok := v.([]interface{})
+		return ok
+	}
+
+	return true
+}
+
+// walk calls fn for every key of settings, in key order, descending into a section if fn returns true.
+func walk(settings map[string]interface{}, prefix []string, fn func(path []string, v interface{}) bool) {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := append(append([]string{}, prefix...), k)
+		v := settings[k]
+		if fn(path, v) {
+			walk(v.(map[string]interface{}), path, fn)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/aws"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSecretRefresh  = 5 * time.Minute
+	gcpSecretManagerURL   = "https://secretmanager.googleapis.com/v1/"
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	gcpRequestTimeout     = 10 * time.Second
+)
+
+// cachedSecret is a cached secret value.
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// cloudProvider reads secrets from a cloud secret manager, caching them for credentials.refresh.
+type cloudProvider struct {
+	mu sync.Mutex
+
+	// fetch reads the secret at a location from the secret manager.
+	fetch func(loc string) (string, error)
+
+	// secrets maps secret names to their location, an ARN or resource name with an optional #<json key>.
+	secrets map[string]string
+
+	// refresh is how long fetched secrets are cached.
+	refresh time.Duration
+
+	// cache holds fetched secrets by name.
+	cache map[string]cachedSecret
+}
+
+// newCloudProvider creates a provider reading secrets with the given fetch function.
+func newCloudProvider(fetch func(loc string) (string, error)) *cloudProvider {
+	p := &cloudProvider{
+		fetch:   fetch,
+		secrets: viper.GetStringMapString("credentials.secrets"),
+		refresh: viper.GetDuration("credentials.refresh"),
+		cache:   make(map[string]cachedSecret),
+	}
+	if p.refresh <= 0 {
+		p.refresh = defaultSecretRefresh
+	}
+
+	return p
+}
+
+// Secret returns a named secret, reading it from the secret manager if it is not cached or has expired.
+func (p *cloudProvider) Secret(name string) (string, error) {
+	p.mu.Lock()
+	cached, hit := p.cache[name]
+	p.mu.Unlock()
+	if hit && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	loc, ok := p.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s is not mapped in credentials.secrets", name)
+	}
+
+	res, key := splitSecretKey(loc)
+	value, err := p.fetch(res)
+	if err == nil && key != "" {
+		value, err = jsonSecretKey(value, key)
+	}
+	if err != nil {
+		// Serve a stale value rather than nothing while the secret manager is unreachable.
+		if hit {
+			log.Printf("Unable to refresh secret %s, %s\n", name, err.Error())
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("unable to read secret %s, %s", name, err.Error())
+	}
+
+	p.mu.Lock()
+	p.cache[name] = cachedSecret{value: value, expires: time.Now().Add(p.refresh)}
+	p.mu.Unlock()
+
+	return value, nil
+}
+
+// splitSecretKey splits a secret location into the resource and the optional JSON key after '#'.
+func splitSecretKey(loc string) (string, string) {
+	if i := strings.LastIndex(loc, "#"); i >= 0 {
+		return loc[:i], loc[i+1:]
+	}
+
+	return loc, ""
+}
+
+// jsonSecretKey picks a key from a secret holding a JSON object.
+func jsonSecretKey(secret, key string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &obj); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, %s", err.Error())
+	}
+
+	v, ok := obj[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret has no string key %s", key)
+	}
+
+	return v, nil
+}
+
+// awsClients returns AWS clients by region, creating them on first use.
+func awsClients() (func(region string) *aws.Client, error) {
+	hc, err := transport.NewHTTPClient("aws", aws.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*aws.Client)
+	)
+
+	return func(region string) *aws.Client {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if c, ok := clients[region]; ok {
+			return c
+		}
+		c := aws.NewClient(region, hc)
+		clients[region] = c
+		return c
+	}, nil
+}
+
+// arnRegion returns the region of an ARN, or the configured aws.region for plain names.
+func arnRegion(loc string) string {
+	if tks := strings.Split(loc, ":"); len(tks) > 4 && tks[0] == "arn" {
+		return tks[3]
+	}
+
+	return viper.GetString("aws.region")
+}
+
+// newAWSSecretsManagerProvider returns a provider reading AWS Secrets Manager secrets by ARN or name.
+func newAWSSecretsManagerProvider() (*cloudProvider, error) {
+	client, err := awsClients()
+	if err != nil {
+		return nil, err
+	}
+
+	return newCloudProvider(func(loc string) (string, error) {
+		var out struct {
+			SecretString string `json:"SecretString"`
+			SecretBinary []byte `json:"SecretBinary"`
+		}
+		in := map[string]string{"SecretId": loc}
+		if err := client(arnRegion(loc)).CallJSON("secretsmanager", "secretsmanager.GetSecretValue", in, &out); err != nil {
+			return "", err
+		}
+		if out.SecretString == "" {
+			return strings.TrimSpace(string(out.SecretBinary)), nil
+		}
+
+		return strings.TrimSpace(out.SecretString), nil
+	}), nil
+}
+
+// newAWSSSMProvider returns a provider reading AWS SSM Parameter Store parameters by ARN or name, decrypting
+// SecureString parameters.
+func newAWSSSMProvider() (*cloudProvider, error) {
+	client, err := awsClients()
+	if err != nil {
+		return nil, err
+	}
+
+	return newCloudProvider(func(loc string) (string, error) {
+		var out struct {
+			Parameter struct {
+				Value string `json:"Value"`
+			} `json:"Parameter"`
+		}
+		in := map[string]interface{}{"Name": loc, "WithDecryption": true}
+		if err := client(arnRegion(loc)).CallJSON("ssm", "AmazonSSM.GetParameter", in, &out); err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(out.Parameter.Value), nil
+	}), nil
+}
+
+// newGCPSecretManagerProvider returns a provider reading GCP Secret Manager secrets by resource name, e.g.
+// projects/my-project/secrets/github-token. Credentials are found the usual Google way: GOOGLE_APPLICATION_CREDENTIALS,
+// gcloud credentials or the metadata server.
+func newGCPSecretManagerProvider() (*cloudProvider, error) {
+	hc, err := transport.NewHTTPClient("gcp", gcpRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, hc)
+	ts, err := google.DefaultTokenSource(ctx, gcpCloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find GCP credentials, %s", err.Error())
+	}
+	client := oauth2.NewClient(ctx, ts)
+	client.Timeout = gcpRequestTimeout
+
+	return newCloudProvider(func(loc string) (string, error) {
+		if !strings.Contains(loc, "/versions/") {
+			loc += "/versions/latest"
+		}
+
+		res, err := client.Get(gcpSecretManagerURL + loc + ":access")
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		dat, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("secret manager returned %s: %s", res.Status, strings.TrimSpace(string(dat)))
+		}
+
+		var out struct {
+			Payload struct {
+				Data string `json:"data"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(dat, &out); err != nil {
+			return "", err
+		}
+		secret, err := base64.StdEncoding.DecodeString(out.Payload.Data)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(secret)), nil
+	}), nil
+}
+
+// Flush drops all cached secrets.
+func (p *cloudProvider) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache = make(map[string]cachedSecret)
+}
@@ -0,0 +1,87 @@
+package credentials
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"testing"
+	"time"
+)
+
+func TestCloudProvider(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("credentials.secrets", map[string]string{
+		SecretGithubToken: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:osprey#github_token",
+		"webhook":         "osprey/webhook",
+		"slack":           "osprey/slack#token",
+	})
+	secrets := map[string]string{
+		"arn:aws:secretsmanager:eu-west-1:123456789012:secret:osprey": `{"github_token": "ghp_cloud"}`,
+		"osprey/webhook": "hook-secret",
+		"osprey/slack":   "not json",
+	}
+	var fetches int
+	var down bool
+	p := newCloudProvider(func(loc string) (string, error) {
+		if down {
+			return "", errors.New("unreachable")
+		}
+		fetches++
+		s, ok := secrets[loc]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return s, nil
+	})
+
+	tests := []struct {
+		name    string
+		secret  string
+		expire  bool
+		down    bool
+		want    string
+		ok      bool
+		fetches int
+	}{
+		{"json key", SecretGithubToken, false, false, "ghp_cloud", true, 1},
+		{"cached", SecretGithubToken, false, false, "ghp_cloud", true, 1},
+		{"expired", SecretGithubToken, true, false, "ghp_cloud", true, 2},
+		// A stale value is served while the secret manager is unreachable.
+		{"stale", SecretGithubToken, true, true, "ghp_cloud", true, 2},
+		{"plain", "webhook", false, false, "hook-secret", true, 3},
+		{"not a json object", "slack", false, false, "", false, 4},
+		{"not mapped", "teams", false, false, "", false, 4},
+	}
+	for _, tt := range tests {
+		if tt.expire {
+			p.cache[tt.secret] = cachedSecret{value: p.cache[tt.secret].value, expires: time.Now()}
+		}
+		down = tt.down
+		got, err := p.Secret(tt.secret)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Fatalf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		if fetches != tt.fetches {
+			t.Fatalf("%s: got %d fetches, want %d", tt.name, fetches, tt.fetches)
+		}
+	}
+}
+
+func TestARNRegion(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("aws.region", "us-east-1")
+
+	tests := []struct {
+		loc  string
+		want string
+	}{
+		{"arn:aws:ssm:eu-central-1:123456789012:parameter/osprey/token", "eu-central-1"},
+		{"/osprey/token", "us-east-1"},
+	}
+	for _, tt := range tests {
+		if got := arnRegion(tt.loc); got != tt.want {
+			t.Fatalf("%s: got %s, want %s", tt.loc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package credentials supplies osprey's secrets, such as the github token, from files, the environment or a
+// secret store.
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	githubAuthEnvKey     = "GITHUB_AUTH_TOKEN"
+	githubAuthFileEnvKey = "GITHUB_AUTH_TOKEN_FILE"
+
+	// SecretGithubToken is the name of the github token secret.
+	SecretGithubToken = "github_token"
+
+	defaultTokenRefresh = 5 * time.Minute
+
+	// tokenCommandTimeout bounds a run of a token command.
+	tokenCommandTimeout = 10 * time.Second
+
+	// secretEnvPrefix prefixes the environment variables holding secrets other than the github token.
+	secretEnvPrefix = "OSPREY_SECRET_"
+)
+
+// Provider supplies secrets such as the github token and webhook secrets.
+type Provider interface {
+	// Secret returns the current value of a named secret.
+	Secret(name string) (string, error)
+}
+
+// New creates the credentials provider selected by credentials.provider in config file.
+func New() (Provider, error) {
+	switch p := viper.GetString("credentials.provider"); p {
+	case "", "env":
+		return EnvProvider{}, nil
+	case "vault":
+		return newVaultProvider()
+	case "aws_secrets_manager":
+		return newAWSSecretsManagerProvider()
+	case "aws_ssm":
+		return newAWSSSMProvider()
+	case "gcp_secret_manager":
+		return newGCPSecretManagerProvider()
+	default:
+		return nil, fmt.Errorf("unknown credentials provider %q", p)
+	}
+}
+
+// EnvProvider reads secrets from files and environment variables.
+type EnvProvider struct{}
+
+// Secret returns a named secret. The github token is read from the output of github.token_command, or from
+// github.token_file, GITHUB_AUTH_TOKEN_FILE or GITHUB_AUTH_TOKEN; other secrets from a <NAME>_FILE or plain
+// OSPREY_SECRET_<NAME> environment variable.
+func (EnvProvider) Secret(name string) (string, error) {
+	if name == SecretGithubToken {
+		return githubToken()
+	}
+
+	key := secretEnvPrefix + strings.ToUpper(name)
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		return ReadTokenFile(path)
+	}
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("secret %s is not set, set %s or %s_FILE", name, key, key)
+}
+
+// githubToken returns the github token. A token command, e.g. [pass, show, github/osprey], or a token file, given by
+// github.token_file or GITHUB_AUTH_TOKEN_FILE, take precedence over GITHUB_AUTH_TOKEN so the token can be kept in a
+// password manager or mounted as a secret rather than exposed in the environment.
+func githubToken() (string, error) {
+	if cmd := viper.GetStringSlice("github.token_command"); len(cmd) > 0 {
+		return RunTokenCommand(cmd)
+	}
+	path := viper.GetString("github.token_file")
+	if path == "" {
+		path = os.Getenv(githubAuthFileEnvKey)
+	}
+	if path == "" {
+		return strings.TrimSpace(os.Getenv(githubAuthEnvKey)), nil
+	}
+
+	return ReadTokenFile(path)
+}
+
+// ReadTokenFile reads a token from a file, ignoring surrounding whitespace.
+func ReadTokenFile(path string) (string, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token file, %s", err.Error())
+	}
+
+	tk := strings.TrimSpace(string(dat))
+	if tk == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+
+	return tk, nil
+}
+
+// RunTokenCommand runs a command, the program and its arguments, and returns its output as a token, ignoring
+// surrounding whitespace.
+func RunTokenCommand(command []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("unable to run token command %s, %s", command[0], msg)
+	}
+
+	tk := strings.TrimSpace(string(out))
+	if tk == "" {
+		return "", fmt.Errorf("token command %s printed no token", command[0])
+	}
+
+	return tk, nil
+}
+
+// Flusher is implemented by credentials providers caching secrets, so a reload can bypass their cache.
+type Flusher interface {
+	// Flush drops all cached secrets.
+	Flush()
+}
+
+// RotatingTokenSource is an oauth2 token source re-reading the github token from the credentials provider every
+// refresh interval, so a rotated token takes effect without restarting osprey.
+type RotatingTokenSource struct {
+	mu sync.Mutex
+
+	// creds supplies the github token.
+	creds Provider
+
+	// refresh is how long a token is used before it is read again.
+	refresh time.Duration
+
+	// token is the current token.
+	token *oauth2.Token
+}
+
+// NewRotatingTokenSource returns a token source reading the github token from creds, failing if there is none yet.
+func NewRotatingTokenSource(creds Provider) (*RotatingTokenSource, error) {
+	ts := &RotatingTokenSource{
+		creds:   creds,
+		refresh: viper.GetDuration("github.token_refresh"),
+	}
+	if ts.refresh <= 0 {
+		ts.refresh = defaultTokenRefresh
+	}
+
+	if _, err := ts.Token(); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// Token returns the current token, reading it again once it is older than the refresh interval. If reading fails,
+// the previous token keeps being used.
+func (ts *RotatingTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != nil && time.Now().Before(ts.token.Expiry) {
+		return ts.token, nil
+	}
+
+	tk, err := ts.creds.Secret(SecretGithubToken)
+	if err != nil {
+		if ts.token != nil {
+			log.Printf("Unable to reload github token, keep using the current one, %s\n", err.Error())
+			ts.token.Expiry = time.Now().Add(ts.refresh)
+			return ts.token, nil
+		}
+		return nil, err
+	}
+
+	if ts.token != nil && ts.token.AccessToken != tk {
+		log.Println("github token rotated")
+	}
+	ts.token = &oauth2.Token{AccessToken: tk, Expiry: time.Now().Add(ts.refresh)}
+
+	return ts.token, nil
+}
+
+// Reload drops the current token and any cached secrets, so the next request reads the token again.
+func (ts *RotatingTokenSource) Reload() {
+	if f, ok := ts.creds.(Flusher); ok {
+		f.Flush()
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != nil {
+		ts.token.Expiry = time.Time{}
+	}
+}
+
+// ReloadOnSignal reloads the token whenever a signal such as SIGHUP is received. It never returns.
+func (ts *RotatingTokenSource) ReloadOnSignal(sigs <-chan os.Signal) {
+	for range sigs {
+		log.Println("reloading github token")
+		ts.Reload()
+	}
+}
@@ -0,0 +1,112 @@
+package credentials
+
+import (
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGithubTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("  ghp_file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		setting string
+		envFile string
+		want    string
+		ok      bool
+	}{
+		{"environment", "", "", "ghp_env", true},
+		{"token_file", file, "", "ghp_file", true},
+		{"token file environment", "", file, "ghp_file", true},
+		{"empty file", empty, "", "", false},
+		{"missing file", filepath.Join(dir, "missing"), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			t.Setenv(githubAuthEnvKey, "ghp_env")
+			t.Setenv(githubAuthFileEnvKey, tt.envFile)
+			viper.Set("github.token_file", tt.setting)
+
+			got, err := EnvProvider{}.Secret(SecretGithubToken)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvProviderSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OSPREY_SECRET_WEBHOOK", "from-env")
+	t.Setenv("OSPREY_SECRET_SLACK_FILE", file)
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"webhook", "from-env", true},
+		{"slack", "from-file", true},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		got, err := EnvProvider{}.Secret(tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Fatalf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestGithubTokenCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+		ok      bool
+		msg     string
+	}{
+		{"output", []string{"echo", " ghp_command "}, "ghp_command", true, ""},
+		{"no output", []string{"true"}, "", false, "printed no token"},
+		{"failure", []string{"sh", "-c", "echo locked >&2; exit 1"}, "", false, "locked"},
+		{"missing program", []string{filepath.Join(t.TempDir(), "missing")}, "", false, "unable to run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			// The command takes precedence over the token in the environment.
+			t.Setenv(githubAuthEnvKey, "ghp_env")
+			viper.Set("github.token_command", tt.command)
+
+			got, err := EnvProvider{}.Secret(SecretGithubToken)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.msg) {
+				t.Fatalf("got %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
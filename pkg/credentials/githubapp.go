@@ -0,0 +1,270 @@
+package credentials
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/google/go-github/github"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretGithubAppKey is the name of the private key secret of a github app, read if github.app.private_key_file
+	// is not set.
+	SecretGithubAppKey = "github_app_private_key"
+
+	// appJWTLifetime is how long the JWT authenticating as the app is valid, github allows up to 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+
+	// appTokenMargin is how long before its expiry an installation token is replaced.
+	appTokenMargin = 5 * time.Minute
+
+	appRequestTimeout = 10 * time.Second
+)
+
+// ReloadableTokenSource is a github token source which can be told to read its credentials again, e.g. on SIGHUP.
+type ReloadableTokenSource interface {
+	oauth2.TokenSource
+
+	// Reload drops the current token and any cached secrets, so the next request reads them again.
+	Reload()
+
+	// ReloadOnSignal reloads whenever a signal is received. It never returns.
+	ReloadOnSignal(sigs <-chan os.Signal)
+}
+
+// AppTokenSource is an oauth2 token source authenticating as a github app installation. It signs a JWT with the
+// app's private key and exchanges it for an installation token, replacing the token before it expires, so no
+// personal access token has to be managed.
+type AppTokenSource struct {
+	mu sync.Mutex
+
+	// creds supplies the private key if no key file is set.
+	creds Provider
+
+	// appID and installationID identify the app and its installation on the repositories' owner.
+	appID, installationID int64
+
+	// keyFile is the path of the app's PEM private key, empty to read SecretGithubAppKey from creds.
+	keyFile string
+
+	// key is the app's private key, nil until read.
+	key *rsa.PrivateKey
+
+	// client calls the github API as the app.
+	client *github.Client
+
+	// token is the current installation token.
+	token *oauth2.Token
+}
+
+// NewAppTokenSource returns a token source of the github app in the github.app section of config file, failing if
+// no installation token can be obtained:
+//
+//	github:
+//	  app:
+//	    id: 12345
+//	    installation_id: 67890
+//	    private_key_file: /run/secrets/osprey-app.pem
+func NewAppTokenSource(creds Provider) (*AppTokenSource, error) {
+	ts := &AppTokenSource{
+		creds:          creds,
+		appID:          viper.GetInt64("github.app.id"),
+		installationID: viper.GetInt64("github.app.installation_id"),
+		keyFile:        viper.GetString("github.app.private_key_file"),
+	}
+	if ts.appID == 0 || ts.installationID == 0 {
+		return nil, errors.New("github app needs github.app.id and github.app.installation_id")
+	}
+
+	hc, err := transport.NewHTTPClient("github", appRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	hc.Transport = &appTransport{ts: ts, base: hc.Transport}
+	ts.client = github.NewClient(hc)
+	if base := viper.GetString("github.base_url"); base != "" {
+		u, err := url.Parse(strings.TrimRight(base, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("github base_url %q is invalid, %s", base, err.Error())
+		}
+		ts.client.BaseURL = u
+	}
+
+	if _, err := ts.Token(); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// Token returns the current installation token, obtaining a new one shortly before it expires. If that fails, the
+// previous token keeps being used while it is valid.
+func (ts *AppTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != nil && time.Now().Add(appTokenMargin).Before(ts.token.Expiry) {
+		return ts.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), appRequestTimeout)
+	defer cancel()
+	it, err := ts.createInstallationToken(ctx)
+	if err != nil {
+		if ts.token != nil && time.Now().Before(ts.token.Expiry) {
+			log.Printf("Unable to renew github app installation token, keep using the current one, %s\n",
+				err.Error())
+			return ts.token, nil
+		}
+		return nil, fmt.Errorf("unable to obtain github app installation token, %s", err.Error())
+	}
+
+	ts.token = &oauth2.Token{AccessToken: it.GetToken(), Expiry: it.GetExpiresAt()}
+	if ts.token.Expiry.IsZero() {
+		ts.token.Expiry = time.Now().Add(time.Hour)
+	}
+
+	return ts.token, nil
+}
+
+// Reload implements ReloadableTokenSource, reading the private key again before the next installation token.
+func (ts *AppTokenSource) Reload() {
+	if f, ok := ts.creds.(Flusher); ok {
+		f.Flush()
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.key = nil
+	ts.token = nil
+}
+
+// ReloadOnSignal implements ReloadableTokenSource.
+func (ts *AppTokenSource) ReloadOnSignal(sigs <-chan os.Signal) {
+	for range sigs {
+		log.Println("reloading github app key")
+		ts.Reload()
+	}
+}
+
+// createInstallationToken obtains an installation token. go-github's Apps.CreateInstallationToken calls the retired
+// installations endpoint, hence the request of its own.
+func (ts *AppTokenSource) createInstallationToken(ctx context.Context) (*github.InstallationToken, error) {
+	req, err := ts.client.NewRequest(http.MethodPost,
+		fmt.Sprintf("app/installations/%d/access_tokens", ts.installationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	it := new(github.InstallationToken)
+	if _, err := ts.client.Do(ctx, req, it); err != nil {
+		return nil, err
+	}
+
+	return it, nil
+}
+
+// jwt returns a JWT authenticating as the app, reading its private key first if needed. ts.mu must be held.
+func (ts *AppTokenSource) jwt() (string, error) {
+	if ts.key == nil {
+		key, err := ts.readKey()
+		if err != nil {
+			return "", err
+		}
+		ts.key = key
+	}
+
+	// Backdate the JWT a minute, allowing for clock drift.
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": ts.appID,
+	})
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign github app JWT, %s", err.Error())
+	}
+
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// readKey reads the app's PEM private key from its file, or the credentials provider.
+func (ts *AppTokenSource) readKey() (*rsa.PrivateKey, error) {
+	var dat []byte
+	if ts.keyFile != "" {
+		var err error
+		if dat, err = ioutil.ReadFile(ts.keyFile); err != nil {
+			return nil, fmt.Errorf("unable to read github app private key, %s", err.Error())
+		}
+	} else {
+		s, err := ts.creds.Secret(SecretGithubAppKey)
+		if err != nil {
+			return nil, err
+		}
+		dat = []byte(s)
+	}
+
+	block, _ := pem.Decode(dat)
+	if block == nil {
+		return nil, errors.New("github app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse github app private key, %s", err.Error())
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key is not an RSA key")
+	}
+
+	return key, nil
+}
+
+// appTransport authenticates the requests of an AppTokenSource's client with the app's JWT.
+type appTransport struct {
+	ts   *AppTokenSource
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. It is only called from Token, which holds ts.mu.
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := t.ts.jwt()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,145 @@
+package credentials
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newAppServer starts a fake github API issuing installation tokens of installation 67890 to JWTs signed by key.
+func newAppServer(t *testing.T, key *rsa.PrivateKey, issued *int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/67890/access_tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := verifyJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"message": %q}`, err.Error())
+			return
+		}
+		n := atomic.AddInt32(issued, 1)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token": fmt.Sprintf("ghs_%d", n), "expires_at": time.Now().Add(time.Hour).Format(time.RFC3339)})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// verifyJWT checks a JWT of app 12345 is signed by key.
+func verifyJWT(jwt string, key *rsa.PublicKey) error {
+	tks := strings.Split(jwt, ".")
+	if len(tks) != 3 {
+		return fmt.Errorf("malformed JWT %q", jwt)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(tks[2])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(tks[0] + "." + tks[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return err
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(tks[1])
+	if err != nil {
+		return err
+	}
+	var claims map[string]int64
+	if err := json.Unmarshal(dat, &claims); err != nil {
+		return err
+	}
+	if claims["iss"] != 12345 || claims["exp"] <= time.Now().Unix() {
+		return fmt.Errorf("unexpected claims %v", claims)
+	}
+
+	return nil
+}
+
+func TestAppTokenSource(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(other)})
+
+	tests := []struct {
+		name    string
+		keyFile string
+		secret  string
+		ok      bool
+	}{
+		{"key file", keyFile, "", true},
+		{"key secret", "", string(keyPEM), true},
+		{"key of another app", "", string(otherPEM), false},
+		{"not pem", "", "not a key", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			var issued int32
+			srv := newAppServer(t, key, &issued)
+			viper.Set("github.base_url", srv.URL)
+			viper.Set("github.app.id", 12345)
+			viper.Set("github.app.installation_id", 67890)
+			viper.Set("github.app.private_key_file", tt.keyFile)
+
+			ts, err := NewAppTokenSource(&fakeProvider{token: tt.secret})
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			// The installation token is used until shortly before it expires, or a reload.
+			for _, want := range []string{"ghs_1", "ghs_1"} {
+				tk, err := ts.Token()
+				if err != nil || tk.AccessToken != want {
+					t.Fatalf("got %v, %v, want %s", tk, err, want)
+				}
+			}
+			ts.Reload()
+			if tk, err := ts.Token(); err != nil || tk.AccessToken != "ghs_2" {
+				t.Fatalf("got %v, %v after reload, want ghs_2", tk, err)
+			}
+		})
+	}
+}
+
+func TestAppTokenSourceNeedsIDs(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("github.app.id", 12345)
+
+	if _, err := NewAppTokenSource(&fakeProvider{}); err == nil {
+		t.Fatal("got nil, want an error without an installation id")
+	}
+}
@@ -0,0 +1,67 @@
+package credentials
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+// fakeProvider returns token as the github token, or err if set.
+type fakeProvider struct {
+	token   string
+	err     error
+	flushed int
+}
+
+func (p *fakeProvider) Secret(name string) (string, error) {
+	return p.token, p.err
+}
+
+func (p *fakeProvider) Flush() {
+	p.flushed++
+}
+
+func TestRotatingTokenSource(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	p := &fakeProvider{token: "ghp_one"}
+	ts, err := NewRotatingTokenSource(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		err    error
+		reload bool
+		want   string
+	}{
+		{"cached until refresh", "ghp_two", nil, false, "ghp_one"},
+		{"rotated on reload", "ghp_two", nil, true, "ghp_two"},
+		// A failed read keeps the current token in use.
+		{"read fails", "", errors.New("unreachable"), true, "ghp_two"},
+	}
+	for _, tt := range tests {
+		p.token, p.err = tt.token, tt.err
+		if tt.reload {
+			ts.Reload()
+		}
+		tk, err := ts.Token()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tk.AccessToken != tt.want {
+			t.Fatalf("%s: got %s, want %s", tt.name, tk.AccessToken, tt.want)
+		}
+	}
+	if p.flushed != 2 {
+		t.Fatalf("got %d flushes, want 2", p.flushed)
+	}
+}
+
+func TestRotatingTokenSourceWithoutToken(t *testing.T) {
+	if _, err := NewRotatingTokenSource(&fakeProvider{err: errors.New("not set")}); err == nil {
+		t.Fatal("got nil, want an error without a token")
+	}
+}
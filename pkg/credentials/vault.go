@@ -0,0 +1,307 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vaultAddrEnvKey  = "VAULT_ADDR"
+	vaultTokenEnvKey = "VAULT_TOKEN"
+
+	defaultVaultRefresh    = 5 * time.Minute
+	defaultVaultK8sJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	vaultRequestTimeout    = 10 * time.Second
+	vaultRetryDelay        = 30 * time.Second
+)
+
+// vaultProvider fetches secrets from HashiCorp Vault. It keeps its auth token alive and re-reads secrets before
+// their lease runs out, so no static secret has to be put on disk or in the environment.
+type vaultProvider struct {
+	mu sync.Mutex
+
+	// addr is the Vault server address, e.g. https://vault:8200.
+	addr string
+
+	// client talks to the Vault server.
+	client *http.Client
+
+	// auth is the auth method: token, approle or kubernetes.
+	auth string
+
+	// token is the current Vault auth token.
+	token string
+
+	// secrets maps secret names to their location, <path>#<key>.
+	secrets map[string]string
+
+	// cache holds fetched secrets by name.
+	cache map[string]cachedSecret
+}
+
+// vaultResponse is the common envelope of Vault API responses.
+type vaultResponse struct {
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// newVaultProvider creates a Vault provider based on config file and logs in.
+func newVaultProvider() (*vaultProvider, error) {
+	client, err := transport.NewHTTPClient("vault", vaultRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &vaultProvider{
+		addr:    strings.TrimRight(viper.GetString("vault.addr"), "/"),
+		client:  client,
+		auth:    viper.GetString("vault.auth"),
+		secrets: viper.GetStringMapString("credentials.secrets"),
+		cache:   make(map[string]cachedSecret),
+	}
+	if v.addr == "" {
+		v.addr = strings.TrimRight(os.Getenv(vaultAddrEnvKey), "/")
+	}
+	if v.addr == "" {
+		return nil, fmt.Errorf("vault.addr is not configured")
+	}
+	if v.auth == "" {
+		v.auth = "token"
+	}
+
+	ttl, renewable, err := v.login()
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in to vault, %s", err.Error())
+	}
+	go v.keepAlive(ttl, renewable)
+
+	return v, nil
+}
+
+// login obtains a Vault token with the configured auth method, returning its ttl and whether it is renewable.
+func (v *vaultProvider) login() (time.Duration, bool, error) {
+	var (
+		path string
+		body map[string]string
+	)
+
+	switch v.auth {
+	case "token":
+		tk := os.Getenv(vaultTokenEnvKey)
+		if p := viper.GetString("vault.token_file"); p != "" {
+			var err error
+			if tk, err = ReadTokenFile(p); err != nil {
+				return 0, false, err
+			}
+		}
+		if tk == "" {
+			return 0, false, fmt.Errorf("no vault token, set vault.token_file or %s", vaultTokenEnvKey)
+		}
+		v.setToken(tk)
+
+		// Look the token up to learn its ttl.
+		resp, err := v.do(http.MethodGet, "/v1/auth/token/lookup-self", nil)
+		if err != nil {
+			return 0, false, err
+		}
+		ttl, _ := resp.Data["ttl"].(float64)
+		renewable, _ := resp.Data["renewable"].(bool)
+		return time.Duration(ttl) * time.Second, renewable, nil
+	case "approle":
+		secretID, err := ReadTokenFile(viper.GetString("vault.secret_id_file"))
+		if err != nil {
+			return 0, false, err
+		}
+		path = "/v1/auth/" + v.mount("approle") + "/login"
+		body = map[string]string{"role_id": viper.GetString("vault.role_id"), "secret_id": secretID}
+	case "kubernetes":
+		jwtPath := viper.GetString("vault.jwt_file")
+		if jwtPath == "" {
+			jwtPath = defaultVaultK8sJWTPath
+		}
+		jwt, err := ReadTokenFile(jwtPath)
+		if err != nil {
+			return 0, false, err
+		}
+		path = "/v1/auth/" + v.mount("kubernetes") + "/login"
+		body = map[string]string{"role": viper.GetString("vault.role"), "jwt": jwt}
+	default:
+		return 0, false, fmt.Errorf("unknown vault auth method %q", v.auth)
+	}
+
+	resp, err := v.do(http.MethodPost, path, body)
+	if err != nil {
+		return 0, false, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return 0, false, fmt.Errorf("vault login returned no token")
+	}
+	v.setToken(resp.Auth.ClientToken)
+
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, resp.Auth.Renewable, nil
+}
+
+// mount returns the mount path of an auth method, defaulting to the method name.
+func (v *vaultProvider) mount(method string) string {
+	if m := viper.GetString("vault.mount"); m != "" {
+		return strings.Trim(m, "/")
+	}
+
+	return method
+}
+
+// keepAlive renews the Vault token at half its ttl, logging in again when it can no longer be renewed.
+// Tokens without a ttl (e.g. root tokens) never expire and need no renewal. It never returns.
+func (v *vaultProvider) keepAlive(ttl time.Duration, renewable bool) {
+	for ttl > 0 {
+		time.Sleep(ttl / 2)
+
+		if renewable {
+			resp, err := v.do(http.MethodPost, "/v1/auth/token/renew-self", map[string]string{})
+			if err == nil && resp.Auth != nil {
+				ttl, renewable = time.Duration(resp.Auth.LeaseDuration)*time.Second, resp.Auth.Renewable
+				continue
+			}
+			if err != nil {
+				log.Printf("Unable to renew vault token, %s\n", err.Error())
+			}
+		}
+
+		var err error
+		if ttl, renewable, err = v.login(); err != nil {
+			log.Printf("Unable to log in to vault, %s\n", err.Error())
+			ttl, renewable = vaultRetryDelay*2, false
+		}
+	}
+}
+
+// Secret returns a named secret, reading it from Vault if it is not cached or its lease is about to run out.
+func (v *vaultProvider) Secret(name string) (string, error) {
+	v.mu.Lock()
+	cached, hit := v.cache[name]
+	v.mu.Unlock()
+	if hit && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	loc, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s is not mapped in credentials.secrets", name)
+	}
+	tks := strings.SplitN(loc, "#", 2)
+	if len(tks) != 2 {
+		return "", fmt.Errorf("vault secret %s must be given as <path>#<key>, got %q", name, loc)
+	}
+	path, key := strings.Trim(tks[0], "/"), tks[1]
+
+	resp, err := v.do(http.MethodGet, "/v1/"+path, nil)
+	if err != nil {
+		// Serve a stale value rather than nothing while Vault is unreachable.
+		if hit {
+			log.Printf("Unable to refresh vault secret %s, %s\n", name, err.Error())
+			return cached.value, nil
+		}
+		return "", err
+	}
+
+	data := resp.Data
+	// KV version 2 nests the secret under data.data.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+
+	refresh := defaultVaultRefresh
+	if resp.LeaseDuration > 0 {
+		refresh = time.Duration(resp.LeaseDuration) * time.Second / 2
+	}
+
+	v.mu.Lock()
+	v.cache[name] = cachedSecret{value: value, expires: time.Now().Add(refresh)}
+	v.mu.Unlock()
+
+	return value, nil
+}
+
+// setToken replaces the Vault auth token.
+func (v *vaultProvider) setToken(tk string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.token = tk
+}
+
+// do sends a Vault API request and decodes its response.
+func (v *vaultProvider) do(method, path string, body interface{}) (*vaultResponse, error) {
+	var rd io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(dat)
+	}
+
+	req, err := http.NewRequest(method, v.addr+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	v.mu.Unlock()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	dat, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp vaultResponse
+	if len(dat) > 0 {
+		if err := json.Unmarshal(dat, &resp); err != nil {
+			return nil, fmt.Errorf("vault %s %s: %s", method, path, err.Error())
+		}
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("vault %s %s returned %s: %s", method, path, res.Status, strings.Join(resp.Errors, ", "))
+	}
+
+	return &resp, nil
+}
+
+// Flush drops all cached secrets.
+func (v *vaultProvider) Flush() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.cache = make(map[string]cachedSecret)
+}
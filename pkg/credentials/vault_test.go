@@ -0,0 +1,127 @@
+package credentials
+
+import (
+	"encoding/json"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newVaultServer starts a fake Vault server accepting the token s.root and serving a KV version 2 secret.
+func newVaultServer(t *testing.T, reads *int32, down *atomic.Bool) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.root" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"ttl": 0}})
+		case "/v1/secret/data/osprey":
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			atomic.AddInt32(reads, 1)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": map[string]interface{}{"github_token": "ghp_vault"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestVaultProvider(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	var reads int32
+	var down atomic.Bool
+	srv := newVaultServer(t, &reads, &down)
+	t.Setenv(vaultTokenEnvKey, "s.root")
+	viper.Set("credentials.provider", "vault")
+	viper.Set("vault.addr", srv.URL)
+	viper.Set("credentials.secrets", map[string]string{
+		SecretGithubToken: "secret/data/osprey#github_token",
+		"webhook":         "secret/data/osprey#webhook",
+		"missing":         "secret/data/missing#token",
+	})
+
+	p, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		flush  bool
+		down   bool
+		want   string
+		ok     bool
+		reads  int32
+	}{
+		{"read", SecretGithubToken, false, false, "ghp_vault", true, 1},
+		{"cached", SecretGithubToken, false, false, "ghp_vault", true, 1},
+		{"flushed", SecretGithubToken, true, false, "ghp_vault", true, 2},
+		// A stale value is served while Vault is unreachable.
+		{"stale", SecretGithubToken, false, true, "ghp_vault", true, 2},
+		{"missing key", "webhook", false, false, "", false, 3},
+		{"missing secret", "missing", false, false, "", false, 3},
+		{"not mapped", "slack", false, false, "", false, 3},
+	}
+	for _, tt := range tests {
+		if tt.flush {
+			p.(Flusher).Flush()
+		}
+		if tt.down {
+			p.(*vaultProvider).cache[tt.secret] = cachedSecret{value: "ghp_vault"}
+		}
+		down.Store(tt.down)
+		got, err := p.Secret(tt.secret)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Fatalf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		if n := atomic.LoadInt32(&reads); n != tt.reads {
+			t.Fatalf("%s: got %d reads, want %d", tt.name, n, tt.reads)
+		}
+	}
+}
+
+func TestVaultProviderLogin(t *testing.T) {
+	var reads int32
+	var down atomic.Bool
+	srv := newVaultServer(t, &reads, &down)
+
+	tests := []struct {
+		name  string
+		token string
+		auth  string
+		ok    bool
+	}{
+		{"token", "s.root", "", true},
+		{"denied token", "s.other", "", false},
+		{"no token", "", "", false},
+		{"unknown auth", "s.root", "ldap", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			t.Setenv(vaultTokenEnvKey, tt.token)
+			viper.Set("vault.addr", srv.URL)
+			viper.Set("vault.auth", tt.auth)
+
+			if _, err := newVaultProvider(); (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
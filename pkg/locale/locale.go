@@ -0,0 +1,304 @@
+// Package locale translates the default text osprey writes into issues. Locales are registered by name and
+// selected per service with the locale key in config file; services without one get DefaultLocale.
+package locale
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the locale of services without a locale key, and the fallback of unknown text.
+const DefaultLocale = "en"
+
+// Messages are the texts of a locale.
+type Messages struct {
+	// Log is the heading of the log line in an issue body.
+	Log string
+
+	// Context is the heading of the log lines around it.
+	Context string
+
+	// Fields is the heading of the fields of a structured log line, Key and Value head their columns.
+	Fields, Key, Value string
+
+	// Details is the heading of the details of a finding.
+	Details string
+
+	// Fingerprint labels the fingerprint of a finding.
+	Fingerprint string
+
+	// DetectedAt labels when a finding was detected.
+	DetectedAt string
+
+	// Severity labels the severity of a finding.
+	Severity string
+
+	// Occurrences labels how often an error occurred in one scan.
+	Occurrences string
+
+	// LogsStopped is the body of a "logs stopped" issue, formatted with the log location, how long it has been
+	// silent and its last write time, in that order. Use explicit argument indexes, e.g. %[2]s, to reorder them.
+	LogsStopped string
+
+	// SeenAgain is the comment on the open issue of an error seen again, formatted with the time it was seen and
+	// the number of occurrences so far.
+	SeenAgain string
+
+	// Reopened is the comment on the reopened issue of an error seen again, formatted like SeenAgain.
+	Reopened string
+
+	// DigestTitle is the title of a digest, formatted with the service, the number of errors and the time.
+	DigestTitle string
+
+	// Matches is the heading of the counts of a digest, Match and Count head their columns.
+	Matches, Match, Count string
+
+	// MoreLines notes the lines left out of a digest, formatted with their number.
+	MoreLines string
+
+	// Suppressed is the comment noting the errors left unfiled by the issue caps, formatted with their number.
+	Suppressed string
+
+	// Resolved is the comment on the issue of an error which stopped occurring, formatted with its last occurrence.
+	Resolved string
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[string]Messages)
+)
+
+func init() {
+	Register(DefaultLocale, Messages{
+		Log:         "Log",
+		Context:     "Context",
+		Fields:      "Fields",
+		Key:         "Key",
+		Value:       "Value",
+		Details:     "Details",
+		Fingerprint: "Fingerprint",
+		DetectedAt:  "Detected at",
+		Severity:    "Severity",
+		Occurrences: "Occurrences",
+		LogsStopped: "No new log lines in %s for %s, the last write was at %s. The service may have stopped.",
+		SeenAgain:   "Seen again at %s, %d occurrences.",
+		Reopened:    "Reopened, seen again at %s, %d occurrences.",
+		Resolved:    "Not seen since %s, closing.",
+		Suppressed:  "%d additional errors suppressed.",
+		DigestTitle: "%s: %d errors at %s",
+		Matches:     "Matches",
+		Match:       "Match",
+		Count:       "Count",
+		MoreLines:   "... and %d more lines.",
+	})
+	Register("de", Messages{
+		Log:         "Protokoll",
+		Context:     "Kontext",
+		Fields:      "Felder",
+		Key:         "Schlüssel",
+		Value:       "Wert",
+		Details:     "Details",
+		Fingerprint: "Fingerabdruck",
+		DetectedAt:  "Erkannt am",
+		Severity:    "Schweregrad",
+		Occurrences: "Vorkommen",
+		LogsStopped: "Seit %[2]s keine neuen Protokollzeilen in %[1]s, zuletzt geschrieben am %[3]s. " +
+			"Der Dienst ist möglicherweise angehalten.",
+		SeenAgain:   "Erneut aufgetreten am %s, %d Vorkommen.",
+		Reopened:    "Wieder geöffnet, erneut aufgetreten am %s, %d Vorkommen.",
+		Resolved:    "Seit %s nicht mehr aufgetreten, wird geschlossen.",
+		Suppressed:  "%d weitere Fehler unterdrückt.",
+		DigestTitle: "%s: %d Fehler am %s",
+		Matches:     "Treffer",
+		Match:       "Muster",
+		Count:       "Anzahl",
+		MoreLines:   "... und %d weitere Zeilen.",
+	})
+	Register("es", Messages{
+		Log:         "Registro",
+		Context:     "Contexto",
+		Fields:      "Campos",
+		Key:         "Clave",
+		Value:       "Valor",
+		Details:     "Detalles",
+		Fingerprint: "Huella",
+		DetectedAt:  "Detectado el",
+		Severity:    "Gravedad",
+		Occurrences: "Ocurrencias",
+		LogsStopped: "No hay líneas nuevas en %s desde hace %s, la última escritura fue el %s. " +
+			"Es posible que el servicio se haya detenido.",
+		SeenAgain:   "Visto de nuevo el %s, %d ocurrencias.",
+		Reopened:    "Reabierto, visto de nuevo el %s, %d ocurrencias.",
+		Resolved:    "No se ha visto desde el %s, se cierra.",
+		Suppressed:  "%d errores adicionales suprimidos.",
+		DigestTitle: "%s: %d errores el %s",
+		Matches:     "Coincidencias",
+		Match:       "Patrón",
+		Count:       "Cantidad",
+		MoreLines:   "... y %d líneas más.",
+	})
+	Register("fr", Messages{
+		Log:         "Journal",
+		Context:     "Contexte",
+		Fields:      "Champs",
+		Key:         "Clé",
+		Value:       "Valeur",
+		Details:     "Détails",
+		Fingerprint: "Empreinte",
+		DetectedAt:  "Détecté le",
+		Severity:    "Gravité",
+		Occurrences: "Occurrences",
+		LogsStopped: "Aucune nouvelle ligne dans %s depuis %s, dernière écriture le %s. " +
+			"Le service s'est peut-être arrêté.",
+		SeenAgain:   "Revu le %s, %d occurrences.",
+		Reopened:    "Rouvert, revu le %s, %d occurrences.",
+		Resolved:    "Plus vu depuis le %s, fermeture.",
+		Suppressed:  "%d erreurs supplémentaires ignorées.",
+		DigestTitle: "%s : %d erreurs le %s",
+		Matches:     "Correspondances",
+		Match:       "Motif",
+		Count:       "Nombre",
+		MoreLines:   "... et %d lignes de plus.",
+	})
+	Register("ja", Messages{
+		Log:         "ログ",
+		Context:     "前後のログ",
+		Fields:      "フィールド",
+		Key:         "キー",
+		Value:       "値",
+		Details:     "詳細",
+		Fingerprint: "フィンガープリント",
+		DetectedAt:  "検出日時",
+		Severity:    "重大度",
+		Occurrences: "発生回数",
+		LogsStopped: "%[1]s に %[2]s の間新しいログ行がありません。最終書き込みは %[3]s です。" +
+			"サービスが停止している可能性があります。",
+		SeenAgain:   "%s に再発生しました。発生回数: %d 回",
+		Reopened:    "再オープンしました。%s に再発生しました。発生回数: %d 回",
+		Resolved:    "%s 以降発生していないため、クローズします。",
+		Suppressed:  "ほか %d 件のエラーを抑制しました。",
+		DigestTitle: "%[1]s: %[3]s に %[2]d 件のエラー",
+		Matches:     "一致",
+		Match:       "パターン",
+		Count:       "件数",
+		MoreLines:   "...ほか %d 行",
+	})
+	Register("zh", Messages{
+		Log:         "日志",
+		Context:     "上下文",
+		Fields:      "字段",
+		Key:         "键",
+		Value:       "值",
+		Details:     "详情",
+		Fingerprint: "指纹",
+		DetectedAt:  "检测时间",
+		Severity:    "严重程度",
+		Occurrences: "出现次数",
+		LogsStopped: "%[1]s 已有 %[2]s 没有新的日志行，最后写入时间为 %[3]s。服务可能已停止。",
+		SeenAgain:   "%s 再次出现，共 %d 次。",
+		Reopened:    "已重新打开，%s 再次出现，共 %d 次。",
+		Resolved:    "自 %s 起未再出现，关闭此问题。",
+		Suppressed:  "另有 %d 个错误被抑制。",
+		DigestTitle: "%[1]s：%[3]s 出现 %[2]d 个错误",
+		Matches:     "匹配",
+		Match:       "模式",
+		Count:       "次数",
+		MoreLines:   "……另有 %d 行",
+	})
+}
+
+// Register makes a locale available under the given name, e.g. pt or pt-BR. Texts left empty fall back to
+// DefaultLocale. It panics if the name is taken, since two packages registering the same locale is a programming
+// error.
+func Register(name string, m Messages) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := catalogs[name]; ok {
+		panic(fmt.Sprintf("locale: locale %s is registered twice", name))
+	}
+	catalogs[name] = m
+}
+
+// Lookup returns the messages of a locale. A region, as in de-AT, falls back to its language; an empty name is
+// DefaultLocale.
+func Lookup(name string) (Messages, error) {
+	if name == "" {
+		name = DefaultLocale
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	m, ok := catalogs[name]
+	if !ok {
+		lang := strings.SplitN(strings.Replace(name, "_", "-", 1), "-", 2)[0]
+		if m, ok = catalogs[lang]; !ok {
+			return Messages{}, fmt.Errorf("unknown locale %q, registered are %s", name, strings.Join(names(), ", "))
+		}
+	}
+
+	def := catalogs[DefaultLocale]
+	m.Log = orDefault(m.Log, def.Log)
+	m.Context = orDefault(m.Context, def.Context)
+	m.Fields = orDefault(m.Fields, def.Fields)
+	m.Key = orDefault(m.Key, def.Key)
+	m.Value = orDefault(m.Value, def.Value)
+	m.Details = orDefault(m.Details, def.Details)
+	m.Fingerprint = orDefault(m.Fingerprint, def.Fingerprint)
+	m.DetectedAt = orDefault(m.DetectedAt, def.DetectedAt)
+	m.Severity = orDefault(m.Severity, def.Severity)
+	m.Occurrences = orDefault(m.Occurrences, def.Occurrences)
+	m.LogsStopped = orDefault(m.LogsStopped, def.LogsStopped)
+	m.SeenAgain = orDefault(m.SeenAgain, def.SeenAgain)
+	m.Reopened = orDefault(m.Reopened, def.Reopened)
+	m.Resolved = orDefault(m.Resolved, def.Resolved)
+	m.Suppressed = orDefault(m.Suppressed, def.Suppressed)
+	m.DigestTitle = orDefault(m.DigestTitle, def.DigestTitle)
+	m.Matches = orDefault(m.Matches, def.Matches)
+	m.Match = orDefault(m.Match, def.Match)
+	m.Count = orDefault(m.Count, def.Count)
+	m.MoreLines = orDefault(m.MoreLines, def.MoreLines)
+
+	return m, nil
+}
+
+// For returns the messages of a locale, or of DefaultLocale if it is unknown.
+func For(name string) Messages {
+	m, err := Lookup(name)
+	if err != nil {
+		m, _ = Lookup(DefaultLocale)
+	}
+
+	return m
+}
+
+// Names returns the registered locale names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return names()
+}
+
+// names returns the registered locale names, sorted. mu must be held.
+func names() []string {
+	var names []string
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// orDefault returns text, or def if text is empty.
+func orDefault(text, def string) string {
+	if text == "" {
+		return def
+	}
+
+	return text
+}
@@ -0,0 +1,79 @@
+package locale
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"", "Log", true},
+		{"de", "Protokoll", true},
+		// A region falls back to its language.
+		{"de-AT", "Protokoll", true},
+		{"fr_CA", "Journal", true},
+		{"xx", "", false},
+	}
+	for _, tt := range tests {
+		m, err := Lookup(tt.name)
+		if (err == nil) != tt.ok {
+			t.Fatalf("%q: got %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if m.Log != tt.want {
+			t.Fatalf("%q: got %q, want %q", tt.name, m.Log, tt.want)
+		}
+	}
+}
+
+func TestRegisterFallsBackToDefault(t *testing.T) {
+	mu.RLock()
+	_, ok := catalogs["pt-BR"]
+	mu.RUnlock()
+	if !ok {
+		Register("pt-BR", Messages{Log: "Registro"})
+	}
+
+	m := For("pt-BR")
+	def := For(DefaultLocale)
+	if m.Log != "Registro" || m.Context != def.Context || m.LogsStopped != def.LogsStopped {
+		t.Fatalf("got %+v, want the text it left empty from %s", m, DefaultLocale)
+	}
+	if got := For("xx"); got != def {
+		t.Fatalf("got %+v, want the messages of %s for an unknown locale", got, DefaultLocale)
+	}
+	if names := strings.Join(Names(), ","); !strings.Contains(names, "pt-BR") {
+		t.Fatalf("got %s, want pt-BR registered", names)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("got no panic, want one")
+		}
+	}()
+
+	Register(DefaultLocale, Messages{})
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	// The built-in locales translate every text, so none reads half in English.
+	for _, name := range Names() {
+		if name == DefaultLocale || name == "pt-BR" {
+			continue
+		}
+		mu.RLock()
+		m := catalogs[name]
+		mu.RUnlock()
+		for field, text := range map[string]string{"Log": m.Log, "Context": m.Context, "Details": m.Details,
+			"LogsStopped": m.LogsStopped, "SeenAgain": m.SeenAgain, "DigestTitle": m.DigestTitle} {
+			if text == "" {
+				t.Fatalf("%s: got no %s", name, field)
+			}
+		}
+	}
+}
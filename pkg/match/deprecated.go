@@ -0,0 +1,28 @@
+package match
+
+import "github.com/NBCFB/Iguana2/pkg/parse"
+
+// LineMatcher is the matcher interface from before parsers, matching raw log lines.
+//
+// Deprecated: implement Matcher, the raw line is e.Line. Wrap a LineMatcher with FromLineMatcher meanwhile.
+type LineMatcher interface {
+	// Match reports whether the line is a finding.
+	Match(line string) (f Finding, ok bool)
+}
+
+// FromLineMatcher adapts a LineMatcher to a Matcher, matching the raw lines of the entries.
+//
+// Deprecated: implement Matcher.
+func FromLineMatcher(m LineMatcher) Matcher {
+	return lineMatcher{m}
+}
+
+// lineMatcher is a Matcher calling a LineMatcher.
+type lineMatcher struct {
+	m LineMatcher
+}
+
+// Match implements Matcher.
+func (lm lineMatcher) Match(e *parse.Entry) (Finding, bool) {
+	return lm.m.Match(e.Line)
+}
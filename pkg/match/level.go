@@ -0,0 +1,49 @@
+package match
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"strings"
+)
+
+// defaultLevels are the levels of structured entries which are errors.
+var defaultLevels = []string{"error", "err", "fatal", "panic", "critical", "crit", "alert", "emerg", "emergency"}
+
+// defaultLevelKeys are the fields holding the level of structured entries, tried in order.
+var defaultLevelKeys = []string{"level", "lvl", "severity", "log.level"}
+
+// Level matches structured entries by their level field, e.g. the level of {"level":"error"}. Entries without a
+// level, such as plain lines, are matched by Fallback.
+type Level struct {
+	// Service is the service name.
+	Service string
+
+	// Keys are the fields holding the level, the first one present is used.
+	Keys []string
+
+	// Levels are the levels which are errors, compared case-insensitively.
+	Levels []string
+
+	// Fallback matches the entries without a level, none are matched if it is nil.
+	Fallback Matcher
+}
+
+// Match implements Matcher.
+func (l Level) Match(e *parse.Entry) (Finding, bool) {
+	for _, k := range l.Keys {
+		lvl, ok := e.Fields[k]
+		if !ok {
+			continue
+		}
+		for _, want := range l.Levels {
+			if strings.EqualFold(lvl, want) {
+				return Finding{Service: l.Service, Line: e.Line, Fields: e.Fields, Keyword: lvl}, true
+			}
+		}
+		return Finding{}, false
+	}
+
+	if l.Fallback == nil {
+		return Finding{}, false
+	}
+	return l.Fallback.Match(e)
+}
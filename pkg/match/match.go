@@ -0,0 +1,186 @@
+// Package match decides which log entries are findings. Matchers are registered by name and selected per service
+// with the matcher key in config file, so third parties can plug in their own.
+package match
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/spf13/viper"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultMatcher is the matcher used by services without a matcher key.
+	DefaultMatcher = "keyword"
+
+	defaultErrorKeyword = "error"
+)
+
+// Finding is a log line worth an issue, created by a matcher and delivered by a sink.
+type Finding struct {
+	// Service is the service name.
+	Service string
+
+	// Line is the matched log line.
+	Line string
+
+	// Fields are the parsed fields of the line, nil if its parser has none.
+	Fields map[string]string
+
+	// Before and After are the log lines around the line, read with it, if the service asks for context.
+	Before, After []string
+
+	// Title is the issue title. The pipeline fills it in if the matcher leaves it empty.
+	Title string
+
+	// Body is the issue body. The pipeline fills it in with the line if the matcher leaves it empty.
+	Body string
+
+	// Fingerprint identifies the error across its occurrences. The pipeline fills it in if the matcher leaves it
+	// empty.
+	Fingerprint string
+
+	// Keyword is what the line matched, e.g. the keyword, pattern or level, if its matcher tells.
+	Keyword string
+
+	// Severity is the severity of the finding, empty if the service's severities classify it as none.
+	Severity string
+
+	// Labels are the labels of the issue: the service's and those of its severity.
+	Labels []string
+
+	// Occurrences is how often the error occurred in the scan it was found in, 0 for once.
+	Occurrences int
+
+	// IssueURL links to the issue filed for the finding by the primary sink of a fan-out, for the other sinks, e.g.
+	// a chat message. It is empty before.
+	IssueURL string
+}
+
+// Matcher turns parsed log lines into findings.
+type Matcher interface {
+	// Match returns the finding of a log entry, ok is false if the entry is not one.
+	Match(e *parse.Entry) (f Finding, ok bool)
+}
+
+// Factory creates the matcher of a service.
+type Factory func(svc config.Service) (Matcher, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+func init() {
+	Register(DefaultMatcher, newKeyword)
+}
+
+// newKeyword creates the keyword matcher of a service. Structured entries, e.g. of the json parser, are matched by
+// their level field; other lines by the keywords and regular expression patterns in config file, or "error" if it
+// has neither:
+//
+//	keywords: ["FATAL", "panic:"]
+//	patterns: ['^\[ERROR\]', 'status=5\d\d']
+//	levels: [error, fatal]
+//	level_key: severity
+func newKeyword(svc config.Service) (Matcher, error) {
+	keywords := viper.GetStringSlice(config.Key(svc.Name, "keywords"))
+	for _, k := range keywords {
+		if k == "" {
+			return nil, fmt.Errorf("keywords of %s has an empty keyword, which would match every line", svc.Name)
+		}
+	}
+	patterns, err := CompilePatterns(svc.Name, "pattern", viper.GetStringSlice(config.Key(svc.Name, "patterns")))
+	if err != nil {
+		return nil, err
+	}
+
+	var lines Matcher
+	switch {
+	case len(keywords) == 0 && len(patterns) == 0:
+		lines = Keyword{Service: svc.Name, Keyword: defaultErrorKeyword}
+	case len(patterns) == 0:
+		lines = Keyword{Service: svc.Name, Keywords: keywords}
+	case len(keywords) == 0:
+		lines = Pattern{Service: svc.Name, Patterns: patterns}
+	default:
+		lines = Any{Keyword{Service: svc.Name, Keywords: keywords}, Pattern{Service: svc.Name, Patterns: patterns}}
+	}
+
+	l := Level{Service: svc.Name, Keys: defaultLevelKeys, Levels: defaultLevels, Fallback: lines}
+	if k := viper.GetString(config.Key(svc.Name, "level_key")); k != "" {
+		l.Keys = []string{k}
+	}
+	if lvls := viper.GetStringSlice(config.Key(svc.Name, "levels")); len(lvls) > 0 {
+		l.Levels = lvls
+	}
+
+	return l, nil
+}
+
+// Register makes a matcher available under the given name. It panics if the name is taken, like database/sql
+// drivers, since two packages registering the same matcher is a programming error.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("match: matcher %s is registered twice", name))
+	}
+	factories[name] = f
+}
+
+// New creates the named matcher for a service.
+func New(name string, svc config.Service) (Matcher, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown matcher %q of %s, registered are %s", name, svc.Name, strings.Join(Names(), ", "))
+	}
+
+	return f(svc)
+}
+
+// Names returns the registered matcher names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Keyword matches the lines containing a keyword.
+type Keyword struct {
+	// Service is the service name.
+	Service string
+
+	// Keyword is the text a line must contain, unless it contains one of Keywords.
+	Keyword string
+
+	// Keywords are more texts, a line containing any of them matches.
+	Keywords []string
+}
+
+// Match implements Matcher.
+func (k Keyword) Match(e *parse.Entry) (Finding, bool) {
+	if k.Keyword != "" && strings.Contains(e.Line, k.Keyword) {
+		return Finding{Service: k.Service, Line: e.Line, Fields: e.Fields, Keyword: k.Keyword}, true
+	}
+	for _, kw := range k.Keywords {
+		if strings.Contains(e.Line, kw) {
+			return Finding{Service: k.Service, Line: e.Line, Fields: e.Fields, Keyword: kw}, true
+		}
+	}
+
+	return Finding{}, false
+}
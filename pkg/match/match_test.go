@@ -0,0 +1,104 @@
+package match
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/spf13/viper"
+	"regexp"
+	"testing"
+)
+
+func TestKeywordMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords []string
+		patterns []string
+		levels   []string
+		entry    parse.Entry
+		want     bool
+		keyword  string
+	}{
+		{"default keyword", nil, nil, nil, parse.Entry{Line: "error: db timeout"}, true, "error"},
+		{"default keyword missing", nil, nil, nil, parse.Entry{Line: "boot ok"}, false, ""},
+		{"keywords replace the default", []string{"FATAL"}, nil, nil, parse.Entry{Line: "error: db timeout"}, false,
+			""},
+		{"keyword", []string{"FATAL", "panic:"}, nil, nil, parse.Entry{Line: "panic: nil map"}, true, "panic:"},
+		{"pattern", nil, []string{`status=5\d\d`}, nil, parse.Entry{Line: "GET / status=503"}, true, `status=5\d\d`},
+		{"keyword or pattern", []string{"FATAL"}, []string{`status=5\d\d`}, nil,
+			parse.Entry{Line: "GET / status=502"}, true, `status=5\d\d`},
+		{"error level", nil, nil, nil, parse.Entry{Line: `{"level":"ERROR"}`, Fields: map[string]string{
+			"level": "ERROR"}}, true, "ERROR"},
+		{"info level is not matched by keyword", nil, nil, nil, parse.Entry{Line: `{"level":"info","msg":"error"}`,
+			Fields: map[string]string{"level": "info", "msg": "error"}}, false, ""},
+		{"custom levels", nil, nil, []string{"warn"}, parse.Entry{Line: `{"lvl":"warn"}`,
+			Fields: map[string]string{"lvl": "warn"}}, true, "warn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set(config.Key("apple", "keywords"), tt.keywords)
+			viper.Set(config.Key("apple", "patterns"), tt.patterns)
+			viper.Set(config.Key("apple", "levels"), tt.levels)
+
+			m, err := New(DefaultMatcher, config.Service{Name: "apple"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := tt.entry
+			f, ok := m.Match(&e)
+			if ok != tt.want || f.Keyword != tt.keyword {
+				t.Fatalf("got %v, %q, want %v, %q", ok, f.Keyword, tt.want, tt.keyword)
+			}
+			if ok && (f.Service != "apple" || f.Line != e.Line) {
+				t.Fatalf("got finding %+v of the wrong service or line", f)
+			}
+		})
+	}
+}
+
+func TestKeywordMatcherInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		val  []string
+	}{
+		{"empty keyword", "keywords", []string{"FATAL", ""}},
+		{"invalid pattern", "patterns", []string{"("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set(config.Key("apple", tt.key), tt.val)
+
+			if _, err := New(DefaultMatcher, config.Service{Name: "apple"}); err == nil {
+				t.Fatal("got no error")
+			}
+		})
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	m := Ignore{Matcher: Keyword{Service: "apple", Keyword: "error"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`connection reset`)}}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"error: db timeout", true},
+		{"error: connection reset by peer", false},
+		{"connection reset", false},
+	}
+	for _, tt := range tests {
+		if _, ok := m.Match(&parse.Entry{Line: tt.line}); ok != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.line, ok, tt.want)
+		}
+	}
+}
+
+func TestUnknownMatcher(t *testing.T) {
+	if _, err := New("nope", config.Service{Name: "apple"}); err == nil {
+		t.Fatal("got no error")
+	}
+}
@@ -0,0 +1,79 @@
+package match
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"regexp"
+)
+
+// Pattern matches the lines matching a regular expression.
+type Pattern struct {
+	// Service is the service name.
+	Service string
+
+	// Patterns are the expressions, a line matching any of them matches.
+	Patterns []*regexp.Regexp
+}
+
+// CompilePatterns compiles the patterns of a service, naming the invalid one.
+func CompilePatterns(service, key string, exprs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q of %s is invalid, %s", key, expr, service, err.Error())
+		}
+		res = append(res, re)
+	}
+
+	return res, nil
+}
+
+// Match implements Matcher.
+func (p Pattern) Match(e *parse.Entry) (Finding, bool) {
+	for _, re := range p.Patterns {
+		if re.MatchString(e.Line) {
+			return Finding{Service: p.Service, Line: e.Line, Fields: e.Fields, Keyword: re.String()}, true
+		}
+	}
+
+	return Finding{}, false
+}
+
+// Any matches the entries any of its matchers matches, returning the finding of the first one.
+type Any []Matcher
+
+// Match implements Matcher.
+func (a Any) Match(e *parse.Entry) (Finding, bool) {
+	for _, m := range a {
+		if f, ok := m.Match(e); ok {
+			return f, true
+		}
+	}
+
+	return Finding{}, false
+}
+
+// Ignore drops the findings of a matcher whose line matches one of the patterns, e.g. known-benign errors.
+type Ignore struct {
+	// Matcher finds the findings.
+	Matcher Matcher
+
+	// Patterns are the expressions of the lines to drop.
+	Patterns []*regexp.Regexp
+}
+
+// Match implements Matcher.
+func (ig Ignore) Match(e *parse.Entry) (Finding, bool) {
+	f, ok := ig.Matcher.Match(e)
+	if !ok {
+		return Finding{}, false
+	}
+	for _, re := range ig.Patterns {
+		if re.MatchString(e.Line) {
+			return Finding{}, false
+		}
+	}
+
+	return f, true
+}
@@ -0,0 +1,208 @@
+// Package wasm runs matchers compiled to WebAssembly, sandboxed by wazero: a module sees no files, network,
+// environment or real clock, only the lines it is given, and is limited in memory and time. Importing the package
+// registers the wasm matcher.
+//
+// A module exports its memory and two functions:
+//
+//	alloc(size i32) i32          returns a buffer of size bytes for the line
+//	match(ptr i32, len i32) i32  returns non-zero if the line at ptr is a finding
+//
+// and may export dealloc(ptr i32, size i32) to free the buffer again. WASI imports are available, without
+// arguments, environment or preopened directories, so modules built with the usual toolchains load.
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/spf13/viper"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Name is the name the wasm matcher is registered under.
+	Name = "wasm"
+
+	defaultMatchTimeout = 100 * time.Millisecond
+
+	// memoryLimitPages caps module memory at 16 MiB.
+	memoryLimitPages = 256
+)
+
+var (
+	rtOnce sync.Once
+	rt     wazero.Runtime
+
+	mu       sync.Mutex
+	compiled = make(map[string]wazero.CompiledModule)
+)
+
+func init() {
+	match.Register(Name, New)
+}
+
+// runtime returns the wazero runtime shared by all wasm matchers.
+func runtime() wazero.Runtime {
+	rtOnce.Do(func() {
+		ctx := context.Background()
+		rt = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithMemoryLimitPages(memoryLimitPages).
+			WithCloseOnContextDone(true))
+		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	})
+
+	return rt
+}
+
+// compile compiles a module once, services sharing a module share its compiled code but not its memory.
+func compile(path string) (wazero.CompiledModule, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if cm, ok := compiled[path]; ok {
+		return cm, nil
+	}
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := runtime().CompileModule(context.Background(), dat)
+	if err != nil {
+		return nil, err
+	}
+	compiled[path] = cm
+
+	return cm, nil
+}
+
+// Matcher matches log lines with a WebAssembly module. Every service has its own module instance.
+type Matcher struct {
+	// service is the service name.
+	service string
+
+	// path is the module file.
+	path string
+
+	// timeout bounds a match call, a module running longer is closed.
+	timeout time.Duration
+
+	mu      sync.Mutex
+	mod     api.Module
+	alloc   api.Function
+	match   api.Function
+	dealloc api.Function
+}
+
+// New creates the wasm matcher of a service, reading the module from the service's wasm_module setting:
+//
+//	services:
+//	  apple:
+//	    matcher: wasm
+//	    wasm_module: /usr/local/lib/osprey/panic.wasm
+//	    wasm_timeout: 100ms
+func New(svc config.Service) (match.Matcher, error) {
+	path := viper.GetString(config.Key(svc.Name, "wasm_module"))
+	if path == "" {
+		return nil, fmt.Errorf("wasm matcher of %s needs a wasm_module", svc.Name)
+	}
+
+	m := &Matcher{service: svc.Name, path: path, timeout: viper.GetDuration(config.Key(svc.Name, "wasm_timeout"))}
+	if m.timeout <= 0 {
+		m.timeout = defaultMatchTimeout
+	}
+	if err := m.instantiate(); err != nil {
+		return nil, fmt.Errorf("unable to load wasm module %s of %s, %s", path, svc.Name, err.Error())
+	}
+
+	return m, nil
+}
+
+// instantiate instantiates the module and looks up its exports.
+func (m *Matcher) instantiate() error {
+	cm, err := compile(m.path)
+	if err != nil {
+		return err
+	}
+
+	// Modules are anonymous so every service gets its own instance. Reactor modules are initialized, command
+	// modules' _start is not run since it would exit.
+	mod, err := runtime().InstantiateModule(context.Background(), cm,
+		wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return err
+	}
+
+	m.mod = mod
+	m.alloc = mod.ExportedFunction("alloc")
+	m.match = mod.ExportedFunction("match")
+	m.dealloc = mod.ExportedFunction("dealloc")
+	if m.alloc == nil || m.match == nil || mod.Memory() == nil {
+		mod.Close(context.Background())
+		return fmt.Errorf("module must export memory, alloc and match")
+	}
+
+	return nil
+}
+
+// Match implements match.Matcher. A failing module is logged and the line is taken as no finding, so a broken
+// module does not stop the scan.
+func (m *Matcher) Match(e *parse.Entry) (match.Finding, bool) {
+	ok, err := m.call(e.Line)
+	if err != nil {
+		log.Printf("Unable to match line of %s with wasm module, %s\n", m.service, err.Error())
+		return match.Finding{}, false
+	}
+	if !ok {
+		return match.Finding{}, false
+	}
+
+	return match.Finding{Service: m.service, Line: e.Line, Fields: e.Fields}, true
+}
+
+// call passes a line to the module's match function.
+func (m *Matcher) call(line string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A module is closed once a call runs out of time, the next line gets a fresh instance.
+	if m.mod.IsClosed() {
+		if err := m.instantiate(); err != nil {
+			return false, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	size := uint64(len(line))
+	res, err := m.alloc.Call(ctx, size)
+	if err != nil {
+		return false, err
+	}
+	ptr := res[0]
+	if !m.mod.Memory().Write(uint32(ptr), []byte(line)) {
+		return false, fmt.Errorf("alloc returned a buffer out of memory range")
+	}
+
+	res, err = m.match.Call(ctx, ptr, size)
+	if err != nil {
+		return false, err
+	}
+
+	if m.dealloc != nil {
+		if _, err := m.dealloc.Call(ctx, ptr, size); err != nil {
+			return false, err
+		}
+	}
+
+	return uint32(res[0]) != 0, nil
+}
@@ -0,0 +1,147 @@
+package wasm
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	// matchError is the body of a match function taking lines starting with an E as findings.
+	matchError = []byte{
+		0x00,       // no locals
+		0x20, 0x00, // local.get ptr
+		0x2d, 0x00, 0x00, // i32.load8_u
+		0x41, 0xc5, 0x00, // i32.const 'E'
+		0x46, // i32.eq
+		0x0b, // end
+	}
+
+	// matchSpin is the body of a match function which never returns.
+	matchSpin = []byte{
+		0x00,       // no locals
+		0x03, 0x40, // loop
+		0x0c, 0x00, // br 0
+		0x0b,             // end
+		0x41, 0x00, 0x0b, // i32.const 0, end
+	}
+)
+
+// writeModule writes a module exporting its memory, an alloc returning offset 1024 and a match function of the given
+// body, and returns its path.
+func writeModule(t *testing.T, match []byte) string {
+	t.Helper()
+
+	section := func(id byte, content ...byte) []byte {
+		return append([]byte{id, byte(len(content))}, content...)
+	}
+	alloc := []byte{0x00, 0x41, 0x80, 0x08, 0x0b} // i32.const 1024, end
+	code := append([]byte{0x02, byte(len(alloc))}, alloc...)
+	code = append(append(code, byte(len(match))), match...)
+
+	mod := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	mod = append(mod, section(0x01, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f)...)
+	mod = append(mod, section(0x03, 0x02, 0x00, 0x01)...)
+	mod = append(mod, section(0x05, 0x01, 0x00, 0x01)...)
+	exports := []byte{0x03}
+	for i, name := range []string{"memory", "alloc", "match"} {
+		kind, idx := byte(0x00), byte(i-1)
+		if name == "memory" {
+			kind, idx = 0x02, 0x00
+		}
+		exports = append(append(append(exports, byte(len(name))), name...), kind, idx)
+	}
+	mod = append(mod, section(0x07, exports...)...)
+	mod = append(mod, section(0x0a, code...)...)
+
+	path := filepath.Join(t.TempDir(), "matcher.wasm")
+	if err := os.WriteFile(path, mod, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestMatcher(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	svc := config.Service{Name: "apple"}
+	viper.Set(config.Key(svc.Name, "wasm_module"), writeModule(t, matchError))
+
+	m, err := New(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"ERROR db timeout", true},
+		{"INFO boot ok", false},
+		{"Error: disk full", true},
+	}
+	for _, tt := range tests {
+		f, ok := m.Match(&parse.Entry{Line: tt.line})
+		if ok != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.line, ok, tt.want)
+		}
+		if ok && (f.Line != tt.line || f.Service != svc.Name) {
+			t.Fatalf("%s: got %+v, want the finding of the line", tt.line, f)
+		}
+	}
+}
+
+func TestMatcherTimeout(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	svc := config.Service{Name: "apple"}
+	viper.Set(config.Key(svc.Name, "wasm_module"), writeModule(t, matchSpin))
+	viper.Set(config.Key(svc.Name, "wasm_timeout"), "20ms")
+
+	m, err := New(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A module running out of time is closed and the line taken as no finding, the next line gets a new instance.
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if _, ok := m.Match(&parse.Entry{Line: "ERROR db timeout"}); ok {
+			t.Fatal("got a finding, want none")
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("got a match of %s, want it stopped after the timeout", d)
+		}
+	}
+}
+
+func TestNewInvalidModule(t *testing.T) {
+	notWasm := filepath.Join(t.TempDir(), "matcher.wasm")
+	if err := os.WriteFile(notWasm, []byte("not a module"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"no module", ""},
+		{"missing file", filepath.Join(t.TempDir(), "missing.wasm")},
+		{"not wasm", notWasm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set(config.Key("apple", "wasm_module"), tt.path)
+
+			if _, err := New(config.Service{Name: "apple"}); err == nil {
+				t.Fatal("got nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSON parses JSON object lines, e.g. {"level":"error","msg":"db timeout"}. Nested objects are flattened into
+// dotted field names, such as http.status; other values are kept as their JSON text. Lines which are not JSON
+// objects, such as a panic written to the same file, are entries without fields.
+type JSON struct{}
+
+// Parse implements Parser.
+func (JSON) Parse(line string) (*Entry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return &Entry{Line: line}, true
+	}
+
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return &Entry{Line: line}, true
+	}
+
+	fields := make(map[string]string)
+	flatten(fields, "", obj)
+
+	return &Entry{Line: line, Fields: fields}, true
+}
+
+// flatten adds the values of obj to fields, prefixing nested names with their parents'.
+func flatten(fields map[string]string, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		name := prefix + k
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flatten(fields, name+".", v)
+		case string:
+			fields[name] = v
+		case nil:
+			fields[name] = ""
+		case json.Number, bool:
+			fields[name] = fmt.Sprint(v)
+		default:
+			var b bytes.Buffer
+			enc := json.NewEncoder(&b)
+			enc.SetEscapeHTML(false)
+			enc.Encode(v)
+			fields[name] = strings.TrimSpace(b.String())
+		}
+	}
+}
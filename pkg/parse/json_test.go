@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		fields map[string]string
+	}{
+		{"flat", `{"level":"error","msg":"db timeout","code":504,"ok":false}`,
+			map[string]string{"level": "error", "msg": "db timeout", "code": "504", "ok": "false"}},
+		{"nested", `{"http":{"status":500,"path":"/"},"tags":["a","<b>"],"user":null}`,
+			map[string]string{"http.status": "500", "http.path": "/", "tags": `["a","<b>"]`, "user": ""}},
+		{"panic line", "panic: runtime error", nil},
+		{"invalid", `{"level":`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := JSON{}.Parse(tt.line)
+			if !ok || e.Line != tt.line {
+				t.Fatalf("got %+v, %v, want the line kept", e, ok)
+			}
+			if !reflect.DeepEqual(e.Fields, tt.fields) {
+				t.Fatalf("got %v, want %v", e.Fields, tt.fields)
+			}
+		})
+	}
+}
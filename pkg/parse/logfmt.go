@@ -0,0 +1,62 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Logfmt parses logfmt lines, e.g. level=error msg="db timeout" service=auth. Values may be quoted with Go escapes,
+// a key without a value is kept with an empty one. Lines without a key=value pair, such as a panic written to the
+// same file, are entries without fields.
+type Logfmt struct{}
+
+// Parse implements Parser.
+func (Logfmt) Parse(line string) (*Entry, bool) {
+	fields := make(map[string]string)
+	pairs := 0
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		// The key runs up to an equals sign or a space.
+		i := strings.IndexAny(rest, "= \t")
+		if i < 0 {
+			fields[rest] = ""
+			break
+		}
+		key := rest[:i]
+		if key == "" || strings.ContainsRune(key, '"') {
+			return &Entry{Line: line}, true
+		}
+		if rest[i] != '=' {
+			fields[key] = ""
+			rest = strings.TrimLeft(rest[i:], " \t")
+			continue
+		}
+
+		rest = rest[i+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			q, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return &Entry{Line: line}, true
+			}
+			if value, err = strconv.Unquote(q); err != nil {
+				return &Entry{Line: line}, true
+			}
+			rest = rest[len(q):]
+		} else {
+			j := strings.IndexAny(rest, " \t")
+			if j < 0 {
+				j = len(rest)
+			}
+			value, rest = rest[:j], rest[j:]
+		}
+		fields[key] = value
+		pairs++
+		rest = strings.TrimLeft(rest, " \t")
+	}
+
+	if pairs == 0 {
+		return &Entry{Line: line}, true
+	}
+	return &Entry{Line: line, Fields: fields}, true
+}
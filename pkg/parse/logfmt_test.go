@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogfmt(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		fields map[string]string
+	}{
+		{"quoted", `level=error msg="db \"primary\" timeout" retry`,
+			map[string]string{"level": "error", "msg": `db "primary" timeout`, "retry": ""}},
+		{"plain values", "ts=12:00:01 level=warn disk=95%",
+			map[string]string{"ts": "12:00:01", "level": "warn", "disk": "95%"}},
+		{"panic line", "panic: runtime error", nil},
+		{"unterminated quote", `level=error msg="unterminated`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := Logfmt{}.Parse(tt.line)
+			if !ok || e.Line != tt.line {
+				t.Fatalf("got %+v, %v, want the line kept", e, ok)
+			}
+			if !reflect.DeepEqual(e.Fields, tt.fields) {
+				t.Fatalf("got %v, want %v", e.Fields, tt.fields)
+			}
+		})
+	}
+}
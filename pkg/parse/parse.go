@@ -0,0 +1,96 @@
+// Package parse turns raw log lines into entries, the pipeline stage between a source and a matcher. Parsers are
+// registered by name and selected per service with the parser, or format, key in config file.
+package parse
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultParser is the parser used by services without a parser key.
+const DefaultParser = "plain"
+
+// Entry is a parsed log line.
+type Entry struct {
+	// Line is the raw log line.
+	Line string
+
+	// Fields are the named values parsed out of the line, nil for the plain parser.
+	Fields map[string]string
+}
+
+// Parser parses log lines.
+type Parser interface {
+	// Parse returns the entry of a log line, ok is false if the line is not an entry and is skipped.
+	Parse(line string) (e *Entry, ok bool)
+}
+
+// Factory creates the parser of a service.
+type Factory func(svc config.Service) (Parser, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+func init() {
+	Register(DefaultParser, func(svc config.Service) (Parser, error) {
+		return Plain{}, nil
+	})
+	Register("json", func(svc config.Service) (Parser, error) {
+		return JSON{}, nil
+	})
+	Register("logfmt", func(svc config.Service) (Parser, error) {
+		return Logfmt{}, nil
+	})
+	Register("regex", newRegex)
+}
+
+// Register makes a parser available under the given name. It panics if the name is taken, like database/sql
+// drivers, since two packages registering the same parser is a programming error.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("parse: parser %s is registered twice", name))
+	}
+	factories[name] = f
+}
+
+// New creates the named parser for a service.
+func New(name string, svc config.Service) (Parser, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q of %s, registered are %s", name, svc.Name, strings.Join(Names(), ", "))
+	}
+
+	return f(svc)
+}
+
+// Names returns the registered parser names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Plain takes every line as an entry without fields.
+type Plain struct{}
+
+// Parse implements Parser.
+func (Plain) Parse(line string) (*Entry, bool) {
+	return &Entry{Line: line}, true
+}
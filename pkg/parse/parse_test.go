@@ -0,0 +1,48 @@
+package parse
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set(config.Key("apple", "regex"), `^\[(?P<level>\w+)\]`)
+
+	tests := []struct {
+		name string
+		want Parser
+		ok   bool
+	}{
+		{DefaultParser, Plain{}, true},
+		{"json", JSON{}, true},
+		{"logfmt", Logfmt{}, true},
+		{"regex", nil, true},
+		{"xml", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.name, config.Service{Name: "apple"})
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if tt.want != nil && !reflect.DeepEqual(p, tt.want) {
+				t.Fatalf("got %T, want %T", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering the json parser again did not panic")
+		}
+	}()
+	Register("json", func(svc config.Service) (Parser, error) {
+		return JSON{}, nil
+	})
+}
@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+// Regex parses lines with a regular expression, its named groups becoming the fields, e.g.
+// ^(?P<time>\S+) \[(?P<level>\w+)\] (?P<msg>.*)$ for "12:00:01 [ERROR] db timeout". Lines it does not match are
+// entries without fields, so they are still matched as plain text.
+type Regex struct {
+	// Pattern is the expression, with at least one named group.
+	Pattern *regexp.Regexp
+}
+
+// newRegex creates the regex parser of a service from its regex key in config file:
+//
+//	parser: regex
+//	regex: '^(?P<time>\S+) \[(?P<level>\w+)\] (?P<msg>.*)$'
+func newRegex(svc config.Service) (Parser, error) {
+	expr := viper.GetString(config.Key(svc.Name, "regex"))
+	if expr == "" {
+		return nil, fmt.Errorf("regex parser of %s needs a regex", svc.Name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("regex %q of %s is invalid, %s", expr, svc.Name, err.Error())
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		named = named || name != ""
+	}
+	if !named {
+		return nil, fmt.Errorf("regex %q of %s has no named groups", expr, svc.Name)
+	}
+
+	return Regex{Pattern: re}, nil
+}
+
+// Parse implements Parser.
+func (r Regex) Parse(line string) (*Entry, bool) {
+	m := r.Pattern.FindStringSubmatch(line)
+	if m == nil {
+		return &Entry{Line: line}, true
+	}
+
+	fields := make(map[string]string)
+	for i, name := range r.Pattern.SubexpNames() {
+		if name != "" && i < len(m) {
+			fields[name] = m[i]
+		}
+	}
+
+	return &Entry{Line: line, Fields: fields}, true
+}
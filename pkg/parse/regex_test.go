@@ -0,0 +1,59 @@
+package parse
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRegex(t *testing.T) {
+	r := Regex{Pattern: regexp.MustCompile(`^(?P<time>\S+) \[(?P<level>\w+)\] (?P<msg>.*)$`)}
+	tests := []struct {
+		name   string
+		line   string
+		fields map[string]string
+	}{
+		{"matched", "12:00:01 [ERROR] db timeout", map[string]string{"time": "12:00:01", "level": "ERROR",
+			"msg": "db timeout"}},
+		{"empty group", "12:00:01 [ERROR] ", map[string]string{"time": "12:00:01", "level": "ERROR", "msg": ""}},
+		{"not matched", "panic: runtime error", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := r.Parse(tt.line)
+			if !ok || e.Line != tt.line {
+				t.Fatalf("got %+v, %v, want the line kept", e, ok)
+			}
+			if !reflect.DeepEqual(e.Fields, tt.fields) {
+				t.Fatalf("got %v, want %v", e.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestNewRegex(t *testing.T) {
+	tests := []struct {
+		name  string
+		regex string
+		ok    bool
+	}{
+		{"named groups", `^\[(?P<level>\w+)\] (?P<msg>.*)$`, true},
+		{"missing", "", false},
+		{"invalid", `^(?P<level>\w+`, false},
+		{"no named groups", `^\[(\w+)\]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set(config.Key("apple", "regex"), tt.regex)
+
+			_, err := newRegex(config.Service{Name: "apple"})
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
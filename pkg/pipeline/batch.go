@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/match"
+)
+
+// dedupeBatch keeps the first finding of each error of a scan, keyed on its fingerprint, and counts the others in its
+// Occurrences, so an error logged hundreds of times in one interval is filed once. spans are kept in step with the
+// findings.
+func dedupeBatch(findings []match.Finding, spans [][2]int) ([]match.Finding, [][2]int) {
+	if len(findings) < 2 {
+		return findings, spans
+	}
+
+	first := make(map[string]int, len(findings))
+	var kept []match.Finding
+	var keptSpans [][2]int
+	for i, f := range findings {
+		key := f.Fingerprint
+		if key == "" {
+			key = fingerprint(firstLine(f))
+		}
+		if j, ok := first[key]; ok {
+			kept[j].Occurrences = occurrences(kept[j]) + occurrences(f)
+			continue
+		}
+		first[key] = len(kept)
+		kept = append(kept, f)
+		keptSpans = append(keptSpans, spans[i])
+	}
+
+	return kept, keptSpans
+}
+
+// occurrences returns how often the error of a finding occurred in its scan, at least once.
+func occurrences(f match.Finding) int {
+	if f.Occurrences < 1 {
+		return 1
+	}
+
+	return f.Occurrences
+}
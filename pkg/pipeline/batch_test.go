@@ -0,0 +1,56 @@
+package pipeline_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/pipeline"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func TestBatchDedupe(t *testing.T) {
+	lines := []string{"error: db timeout", "boot ok", "error: db timeout", "error: disk full", "error: db timeout"}
+	tests := []struct {
+		name        string
+		set         bool
+		dedupe      bool
+		want        int
+		occurrences int
+	}{
+		{"default", false, false, 2, 3},
+		{"disabled", true, false, 4, 1},
+		{"enabled", true, true, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			if tt.set {
+				viper.Set(config.Key("apple", "batch_dedupe"), tt.dedupe)
+			}
+
+			svc := config.Service{Name: "apple", Source: ospreytest.RegisterSource(ospreytest.NewSource(lines...))}
+			p, err := pipeline.New(svc, &ospreytest.Sink{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			findings, _, err := p.CollectFrom(context.Background(), source.Position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) != tt.want {
+				t.Fatalf("got %d findings, want %d", len(findings), tt.want)
+			}
+			// A finding without occurrences occurred once.
+			got := findings[0].Occurrences
+			if got < 1 {
+				got = 1
+			}
+			if got != tt.occurrences {
+				t.Fatalf("got %d occurrences of the first error, want %d", got, tt.occurrences)
+			}
+		})
+	}
+}
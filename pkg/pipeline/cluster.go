@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/spf13/viper"
+	"regexp"
+	"strings"
+)
+
+// maxClusters bounds the error classes a service remembers for clustering; errors of new classes beyond it keep
+// their own fingerprints.
+const maxClusters = 1000
+
+var (
+	// uuids, hexes and digits are masked in fingerprints, in that order, so ids, addresses and timestamps do not tell
+	// occurrences of an error apart.
+	uuids  = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	hexes  = regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{8,}\b`)
+	digits = regexp.MustCompile(`[0-9]+`)
+)
+
+// normalize masks what varies between occurrences of an error in its text: UUIDs, hex numbers, e.g. pointer
+// addresses and hashes, and numbers. Hex words without digits, e.g. deadbeef, are kept, since they are more often
+// words than numbers.
+func normalize(text string) string {
+	text = uuids.ReplaceAllString(text, "<uuid>")
+	text = hexes.ReplaceAllStringFunc(text, func(s string) string {
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || strings.ContainsAny(s, "0123456789") {
+			return "<hex>"
+		}
+		return s
+	})
+
+	return digits.ReplaceAllString(text, "#")
+}
+
+// cluster is an error class: the words of its first message and its fingerprint.
+type cluster struct {
+	words       map[string]bool
+	fingerprint string
+}
+
+// clusters groups similar error messages under the fingerprint of the first of them, so one issue represents one
+// error class even if its messages differ in more than ids, e.g. a file name or user.
+type clusters struct {
+	// similarity is the least share of words two normalized messages have in common to be of one class.
+	similarity float64
+
+	// known are the classes seen while osprey runs, oldest first.
+	known []cluster
+}
+
+// newClusters creates the clusters of a service from its cluster section, nil if it has no similarity:
+//
+//	cluster:
+//	  similarity: 0.8
+func newClusters(svc config.Service) (*clusters, error) {
+	sim := viper.GetFloat64(config.Key(svc.Name, "cluster.similarity"))
+	if sim == 0 {
+		return nil, nil
+	}
+	if sim < 0 || sim > 1 {
+		return nil, fmt.Errorf("cluster similarity of %s must be between 0 and 1, not %g", svc.Name, sim)
+	}
+
+	return &clusters{similarity: sim}, nil
+}
+
+// fingerprint returns the fingerprint of the class of a message: that of the most similar known class at least
+// similarity alike, otherwise its own, which starts a new class.
+func (c *clusters) fingerprint(text string) string {
+	norm := normalize(text)
+	fp := state.Fingerprint(norm)
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(norm) {
+		words[w] = true
+	}
+
+	best, bestSim := -1, 0.0
+	for i, k := range c.known {
+		if k.fingerprint == fp {
+			return fp
+		}
+		if s := jaccard(words, k.words); s >= c.similarity && s > bestSim {
+			best, bestSim = i, s
+		}
+	}
+	if best >= 0 {
+		return c.known[best].fingerprint
+	}
+	if len(c.known) < maxClusters {
+		c.known = append(c.known, cluster{words: words, fingerprint: fp})
+	}
+
+	return fp
+}
+
+// jaccard returns the share of the words of a and b they have in common, 1 for two empty sets.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(a)+len(b)-common)
+}
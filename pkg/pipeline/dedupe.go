@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDedupeWindow = time.Hour
+
+	// maxDedupeEntries bounds the findings a dedupe middleware remembers; the oldest are forgotten first.
+	maxDedupeEntries = 10000
+)
+
+// dedupe drops findings delivered within the window before, keyed on their fingerprint, or else their first line;
+// the body is not compared as it differs between occurrences, e.g. in its timestamps and context lines.
+type dedupe struct {
+	mu sync.Mutex
+
+	// window is how long a delivered finding is remembered.
+	window time.Duration
+
+	// seen holds when each finding fingerprint was delivered.
+	seen map[string]time.Time
+}
+
+// newDedupe creates the dedupe middleware of a service, configured in config file:
+//
+//	dedupe:
+//	  window: 1h
+func newDedupe(svc config.Service) (Middleware, error) {
+	d := &dedupe{window: viper.GetDuration(config.Key(svc.Name, "dedupe.window")), seen: make(map[string]time.Time)}
+	if d.window <= 0 {
+		d.window = defaultDedupeWindow
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			key := f.Fingerprint
+			if key == "" {
+				key = fingerprint(firstLine(f))
+			}
+			if at, ok := d.delivered(key); ok {
+				return sink.Delivery{}, fmt.Errorf("%w by dedupe, a duplicate was delivered %s ago", sink.ErrDropped,
+					time.Since(at).Round(time.Second))
+			}
+
+			dlv, err := next.Deliver(ctx, svc, f)
+			if err == nil {
+				d.remember(key)
+			}
+			return dlv, err
+		})
+	}, nil
+}
+
+// delivered returns when the finding of a fingerprint was delivered, if within the window.
+func (d *dedupe) delivered(key string) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	at, ok := d.seen[key]
+	if !ok || time.Since(at) > d.window {
+		return time.Time{}, false
+	}
+
+	return at, true
+}
+
+// remember records the delivery of a finding, forgetting the expired ones.
+func (d *dedupe) remember(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for k, at := range d.seen {
+		if now.Sub(at) > d.window {
+			delete(d.seen, k)
+		} else if oldest == "" || at.Before(d.seen[oldest]) {
+			oldest = k
+		}
+	}
+	if len(d.seen) >= maxDedupeEntries {
+		delete(d.seen, oldest)
+	}
+	d.seen[key] = now
+}
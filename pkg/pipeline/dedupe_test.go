@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func TestDedupeMiddleware(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	mw, err := NewMiddleware("dedupe", config.Service{Name: "apple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivered int
+	var fail error
+	s := Chain(SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+		if fail != nil {
+			return sink.Delivery{}, fail
+		}
+		delivered++
+		return sink.Delivery{Number: delivered}, nil
+	}), mw)
+
+	tests := []struct {
+		name    string
+		finding match.Finding
+		fail    error
+		dropped bool
+		want    int
+	}{
+		{"first", match.Finding{Line: "error: db timeout"}, nil, false, 1},
+		{"duplicate", match.Finding{Line: "error: db timeout"}, nil, true, 1},
+		{"other error", match.Finding{Line: "error: disk full"}, nil, false, 2},
+		{"failed delivery is not remembered", match.Finding{Line: "error: oom"}, errors.New("down"), false, 2},
+		{"retried after failure", match.Finding{Line: "error: oom"}, nil, false, 3},
+		{"fingerprinted", match.Finding{Line: "12:00:01 error: db timeout", Body: "at 12:00:01\nerror: db timeout",
+			Fingerprint: "f00d"}, nil, false, 4},
+		{"same fingerprint, other body", match.Finding{Line: "12:05:09 error: db timeout",
+			Body: "at 12:05:09, 3 occurrences\nerror: db timeout", Fingerprint: "f00d"}, nil, true, 4},
+		{"same body, other fingerprint", match.Finding{Line: "12:05:09 error: db timeout",
+			Body: "at 12:05:09, 3 occurrences\nerror: db timeout", Fingerprint: "beef"}, nil, false, 5},
+	}
+	for _, tt := range tests {
+		fail = tt.fail
+		_, err := s.Deliver(context.Background(), config.Service{Name: "apple"}, tt.finding)
+		if errors.Is(err, sink.ErrDropped) != tt.dropped {
+			t.Fatalf("%s: got %v, want dropped %v", tt.name, err, tt.dropped)
+		}
+		if delivered != tt.want {
+			t.Fatalf("%s: got %d deliveries, want %d", tt.name, delivered, tt.want)
+		}
+	}
+}
+
+func TestDedupeBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		findings    []match.Finding
+		want        []string
+		occurrences []int
+	}{
+		{"single", []match.Finding{{Line: "error: a"}}, []string{"error: a"}, []int{0}},
+		{"identical lines", []match.Finding{{Line: "error: a"}, {Line: "error: b"}, {Line: "error: a"}},
+			[]string{"error: a", "error: b"}, []int{2, 0}},
+		{"fingerprint", []match.Finding{{Line: "error: a 1", Fingerprint: "f"}, {Line: "error: a 2", Fingerprint: "f"}},
+			[]string{"error: a 1"}, []int{2}},
+		{"occurrences add up", []match.Finding{{Line: "error: a", Occurrences: 3}, {Line: "error: a"}},
+			[]string{"error: a"}, []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := make([][2]int, len(tt.findings))
+			for i := range spans {
+				spans[i] = [2]int{i, i + 1}
+			}
+			got, gotSpans := dedupeBatch(tt.findings, spans)
+			if len(got) != len(tt.want) || len(gotSpans) != len(tt.want) {
+				t.Fatalf("got %d findings and %d spans, want %d", len(got), len(gotSpans), len(tt.want))
+			}
+			for i, f := range got {
+				if f.Line != tt.want[i] || f.Occurrences != tt.occurrences[i] {
+					t.Fatalf("got %q x%d at %d, want %q x%d", f.Line, f.Occurrences, i, tt.want[i],
+						tt.occurrences[i])
+				}
+			}
+		})
+	}
+}
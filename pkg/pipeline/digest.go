@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"strings"
+	"time"
+)
+
+// maxDigestLines bounds the lines listed in a digest, the others are only counted.
+const maxDigestLines = 50
+
+// combine combines the findings of one scan into a single finding, titled with their number and counting them by
+// what they matched, occurrences included. Its labels are those of all the findings. It has no fingerprint, since
+// every digest is new.
+func (p *Pipeline) combine(findings []match.Finding) match.Finding {
+	svc := p.Service
+	m := locale.For(svc.Locale)
+
+	// counts keeps the keywords in the order they were first matched.
+	var keywords []string
+	counts := make(map[string]int)
+	d := match.Finding{Service: svc.Name}
+	var lines []string
+	total := 0
+	for _, f := range findings {
+		kw := f.Keyword
+		if kw == "" {
+			kw = "-"
+		}
+		if counts[kw] == 0 {
+			keywords = append(keywords, kw)
+		}
+		counts[kw] += occurrences(f)
+		total += occurrences(f)
+		d.Labels = appendLabels(d.Labels, f.Labels...)
+		if len(lines) < maxDigestLines {
+			lines = append(lines, f.Line)
+		}
+	}
+
+	d.Line = strings.Join(lines, "\n")
+	d.Title = fmt.Sprintf(m.DigestTitle, svc.Name, total, svc.FormatTime(time.Now()))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n| %s | %s |\n| --- | --- |\n", m.Matches, m.Match, m.Count)
+	for _, kw := range keywords {
+		fmt.Fprintf(&b, "| `%s` | %d |\n", tableCell(kw), counts[kw])
+	}
+	fmt.Fprintf(&b, "\n### %s\n\n```\n%s\n```\n", m.Log, d.Line)
+	if more := len(findings) - len(lines); more > 0 {
+		fmt.Fprintf(&b, "\n"+m.MoreLines+"\n", more)
+	}
+	d.Body = b.String()
+
+	return d
+}
@@ -0,0 +1,243 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactEnricher is the name of the built-in enricher masking sensitive data.
+const redactEnricher = "redact"
+
+// defaultEnrichers run for services without an enrichers key.
+var defaultEnrichers = []string{redactEnricher}
+
+// Enricher adds to or rewrites a finding before it is delivered, e.g. to mask data or attach metadata.
+type Enricher interface {
+	// Enrich modifies the finding in place.
+	Enrich(ctx context.Context, f *match.Finding) error
+}
+
+// EnricherFactory creates an enricher of a service.
+type EnricherFactory func(svc config.Service) (Enricher, error)
+
+var (
+	mu        sync.RWMutex
+	enrichers = make(map[string]EnricherFactory)
+)
+
+func init() {
+	RegisterEnricher(redactEnricher, func(svc config.Service) (Enricher, error) {
+		return newRedactor(svc.Name)
+	})
+}
+
+// RegisterEnricher makes an enricher available under the given name. It panics if the name is taken, like
+// database/sql drivers, since two packages registering the same enricher is a programming error.
+func RegisterEnricher(name string, f EnricherFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := enrichers[name]; ok {
+		panic(fmt.Sprintf("pipeline: enricher %s is registered twice", name))
+	}
+	enrichers[name] = f
+}
+
+// NewEnricher creates the named enricher for a service.
+func NewEnricher(name string, svc config.Service) (Enricher, error) {
+	mu.RLock()
+	f, ok := enrichers[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown enricher %q of %s, registered are %s", name, svc.Name,
+			strings.Join(EnricherNames(), ", "))
+	}
+
+	return f(svc)
+}
+
+// EnricherNames returns the registered enricher names, sorted.
+func EnricherNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// maxSummary is the length of the line summary in default titles, in runes.
+const maxSummary = 60
+
+// leadingStamp matches what precedes the first letter of a line, usually its timestamp.
+var leadingStamp = regexp.MustCompile(`^[^\pL]+`)
+
+// messageKeys are the fields holding the message of a structured log line, tried in order.
+var messageKeys = []string{"msg", "message"}
+
+// fillDefaults gives a finding the default title and body if its matcher left them empty. It runs before the
+// configured enrichers, so they always see a complete finding. The title and body are rendered by the service's
+// templates if it has them. The body of a structured line shows its message and a table of the fields, all of them
+// unless fields selects some.
+func (p *Pipeline) fillDefaults(f *match.Finding) {
+	svc := p.Service
+	f.Service = svc.Name
+	if f.Fingerprint == "" {
+		f.Fingerprint = fingerprint(firstLine(*f))
+	}
+	now := time.Now()
+	if f.Title == "" && p.title != nil {
+		if t, err := render(p.title, newIssueData(*f, svc, now)); err != nil {
+			log.Printf("Unable to render title of %s, %s\n", svc.Name, err.Error())
+		} else {
+			f.Title = strings.TrimSpace(t)
+		}
+	}
+	if f.Body == "" && p.body != nil {
+		if b, err := render(p.body, newIssueData(*f, svc, now)); err != nil {
+			log.Printf("Unable to render body of %s, %s\n", svc.Name, err.Error())
+		} else {
+			f.Body = b
+		}
+	}
+	if svc.TitleFormat == config.TitleTimestamp {
+		if f.Title == "" {
+			f.Title = fmt.Sprintf("%s-bug-%s", svc.Name, svc.FormatTime(now))
+		}
+		if f.Body == "" {
+			f.Body = f.Line
+			if c := contextLines(*f); c != "" {
+				f.Body += fmt.Sprintf("\n\n```\n%s\n```", c)
+			}
+		}
+		return
+	}
+
+	first := firstLine(*f)
+	msgKey, msg := message(f.Fields)
+	if f.Title == "" {
+		f.Title = fmt.Sprintf("%s: %s [%s]", svc.Name, summary(first), f.Fingerprint)
+	}
+	if f.Body == "" {
+		m := locale.For(svc.Locale)
+		var b strings.Builder
+		if msgKey != "" {
+			fmt.Fprintf(&b, "### %s\n\n```\n%s\n```\n\n", m.Log, msg)
+		} else {
+			fmt.Fprintf(&b, "### %s\n\n```\n%s\n```\n\n", m.Log, f.Line)
+		}
+		if t := fieldTable(f.Fields, p.fields, msgKey, m); t != "" {
+			fmt.Fprintf(&b, "### %s\n\n%s\n", m.Fields, t)
+		}
+		if c := contextLines(*f); c != "" {
+			fmt.Fprintf(&b, "### %s\n\n```\n%s\n```\n\n", m.Context, c)
+		}
+		fmt.Fprintf(&b, "### %s\n\n- %s: `%s`\n- %s: %s\n", m.Details, m.Fingerprint, f.Fingerprint,
+			m.DetectedAt, svc.FormatTime(now))
+		if f.Occurrences > 1 {
+			fmt.Fprintf(&b, "- %s: %d\n", m.Occurrences, f.Occurrences)
+		}
+		if f.Severity != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", m.Severity, f.Severity)
+		}
+		f.Body = b.String()
+	}
+}
+
+// firstLine returns what identifies a finding: the first line of a grouped stack trace, the message of a structured
+// line.
+func firstLine(f match.Finding) string {
+	if _, msg := message(f.Fields); msg != "" {
+		return msg
+	}
+
+	return strings.SplitN(f.Line, "\n", 2)[0]
+}
+
+// message returns the message field of a structured line and its key, an empty key if it has none.
+func message(fields map[string]string) (string, string) {
+	for _, k := range messageKeys {
+		if v, ok := fields[k]; ok && v != "" {
+			return k, v
+		}
+	}
+
+	return "", ""
+}
+
+// fieldTable renders fields as a Markdown table: the selected ones in order, or else all of them sorted but the
+// message, which the body shows already. It returns "" if none are shown.
+func fieldTable(fields map[string]string, selected []string, msgKey string, m locale.Messages) string {
+	keys := selected
+	if len(keys) == 0 {
+		for k := range fields {
+			if k != msgKey {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+	}
+
+	var rows strings.Builder
+	for _, k := range keys {
+		v, ok := fields[k]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&rows, "| %s | %s |\n", tableCell(k), tableCell(v))
+	}
+	if rows.Len() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("| %s | %s |\n| --- | --- |\n%s", m.Key, m.Value, rows.String())
+}
+
+// tableCell escapes a value for a Markdown table cell.
+func tableCell(s string) string {
+	s = strings.Replace(s, "|", "\\|", -1)
+	s = strings.Replace(s, "\r", "", -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
+
+// contextLines returns the line of a finding between its context lines, empty if it has none.
+func contextLines(f match.Finding) string {
+	if len(f.Before) == 0 && len(f.After) == 0 {
+		return ""
+	}
+
+	lines := append(append(append([]string(nil), f.Before...), f.Line), f.After...)
+	return strings.Join(lines, "\n")
+}
+
+// fingerprint identifies an error across its occurrences, by its normalized text.
+func fingerprint(text string) string {
+	return state.Fingerprint(normalize(text))
+}
+
+// summary shortens a line for a title, dropping its leading timestamp and collapsing its whitespace.
+func summary(line string) string {
+	s := []rune(strings.Join(strings.Fields(leadingStamp.ReplaceAllString(line, "")), " "))
+	if len(s) == 0 {
+		s = []rune(strings.Join(strings.Fields(line), " "))
+	}
+	if len(s) > maxSummary {
+		return string(s[:maxSummary-3]) + "..."
+	}
+
+	return string(s)
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"sort"
+	"strings"
+)
+
+// Middleware wraps a sink with a behavior, e.g. dropping duplicates, so the behavior works with any sink. A
+// middleware deciding not to deliver a finding returns an error wrapping sink.ErrDropped.
+type Middleware func(next sink.Sink) sink.Sink
+
+// MiddlewareFactory creates a middleware of a service. The middleware keeps its state, e.g. what it has delivered,
+// for that service only.
+type MiddlewareFactory func(svc config.Service) (Middleware, error)
+
+// SinkFunc is a function implementing sink.Sink, for middleware written as closures.
+type SinkFunc func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error)
+
+// Deliver implements sink.Sink.
+func (fn SinkFunc) Deliver(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+	return fn(ctx, svc, f)
+}
+
+var middleware = make(map[string]MiddlewareFactory)
+
+func init() {
+	RegisterMiddleware("dedupe", newDedupe)
+	RegisterMiddleware("throttle", newThrottle)
+	RegisterMiddleware("cooldown", newCooldown)
+	RegisterMiddleware("threshold", newThreshold)
+	RegisterMiddleware("retry", newRetry)
+	RegisterMiddleware(redactEnricher, newRedactMiddleware)
+}
+
+// RegisterMiddleware makes a middleware available under the given name. It panics if the name is taken, like
+// database/sql drivers, since two packages registering the same middleware is a programming error.
+func RegisterMiddleware(name string, f MiddlewareFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := middleware[name]; ok {
+		panic(fmt.Sprintf("pipeline: middleware %s is registered twice", name))
+	}
+	middleware[name] = f
+}
+
+// NewMiddleware creates the named middleware for a service.
+func NewMiddleware(name string, svc config.Service) (Middleware, error) {
+	mu.RLock()
+	f, ok := middleware[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware %q of %s, registered are %s", name, svc.Name,
+			strings.Join(MiddlewareNames(), ", "))
+	}
+
+	return f(svc)
+}
+
+// MiddlewareNames returns the registered middleware names, sorted.
+func MiddlewareNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range middleware {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Chain wraps s with the middleware, the first one outermost, so findings go through them in order.
+func Chain(s sink.Sink, mws ...Middleware) sink.Sink {
+	for i := len(mws) - 1; i >= 0; i-- {
+		s = mws[i](s)
+	}
+
+	return s
+}
+
+// newRedactMiddleware masks the line, title and body of findings like the redact enricher, but at the sink, after
+// the secret guard and exec hook have seen the finding.
+func newRedactMiddleware(svc config.Service) (Middleware, error) {
+	r, err := newRedactor(svc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			f.Line = r.redact(f.Line)
+			f.Title = r.redact(f.Title)
+			f.Body = r.redact(f.Body)
+			return next.Deliver(ctx, svc, f)
+		})
+	}, nil
+}
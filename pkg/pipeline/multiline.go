@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+const defaultMultilineMaxLines = 200
+
+// defaultContinuations match the lines of Go panics and goroutine dumps and of Java stack traces: indented lines,
+// blank lines between goroutines, Java frames, elided frames and causes, goroutine headers and creators, and Go
+// function lines such as main.main() or pkg.(*T).Method(0xc000010000).
+var defaultContinuations = []string{
+	`^\s+\S`,
+	`^\s*$`,
+	`^\s*at\s`,
+	`^\s*\.\.\. \d+ (more|common frames omitted)`,
+	`^Caused by:`,
+	`^goroutine \d+ \[`,
+	`^created by `,
+	`^[\w./*()\[\]-]+\(.*\)$`,
+}
+
+// multiline groups the continuation lines following a matched line, such as a stack trace, into its finding.
+type multiline struct {
+	// continuations match the lines continuing a finding.
+	continuations []*regexp.Regexp
+
+	// maxLines bounds the lines of a finding, the rest of a longer trace is dropped.
+	maxLines int
+}
+
+// newMultiline creates the multiline grouping of a service, nil unless it is enabled in config file:
+//
+//	multiline:
+//	  enabled: true
+//	  continuation: ['^\s+', '^Caused by:']
+//	  max_lines: 200
+func newMultiline(svc config.Service) (*multiline, error) {
+	if !viper.GetBool(config.Key(svc.Name, "multiline.enabled")) {
+		return nil, nil
+	}
+
+	exprs := viper.GetStringSlice(config.Key(svc.Name, "multiline.continuation"))
+	if len(exprs) == 0 {
+		exprs = defaultContinuations
+	}
+	res, err := match.CompilePatterns(svc.Name, "multiline continuation", exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	ml := &multiline{continuations: res, maxLines: viper.GetInt(config.Key(svc.Name, "multiline.max_lines"))}
+	if ml.maxLines <= 0 {
+		ml.maxLines = defaultMultilineMaxLines
+	}
+
+	return ml, nil
+}
+
+// continues reports whether a line continues the finding before it.
+func (ml *multiline) continues(line string) bool {
+	for _, re := range ml.continuations {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
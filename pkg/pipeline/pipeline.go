@@ -0,0 +1,378 @@
+// Package pipeline composes the stages a service's logs go through: a source reads the lines, a parser turns them
+// into entries, a matcher picks the findings, enrichers complete them and a sink delivers them. Every stage is
+// selected per service in config file:
+//
+//	services:
+//	  apple:
+//	    source: file
+//	    parser: plain
+//	    matcher: keyword
+//	    enrichers: [redact]
+//	    sink: github
+//	    middleware: [dedupe, throttle]
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/spf13/viper"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// maxContext bounds the context lines kept before and after a finding.
+const maxContext = 100
+
+// Pipeline holds the stages of a service.
+type Pipeline struct {
+	// Service is the service whose logs go through the pipeline.
+	Service config.Service
+
+	// Source reads the logs.
+	Source source.Source
+
+	// Parser turns log lines into entries.
+	Parser parse.Parser
+
+	// Matcher picks the findings among the entries.
+	Matcher match.Matcher
+
+	// Enrichers complete the findings, in order.
+	Enrichers []Enricher
+
+	// Sink delivers the findings, through the middleware of the service, to its sinks.
+	Sink sink.Sink
+
+	// base is the primary sink without the middleware, for the calls besides deliveries.
+	base sink.Sink
+
+	// multiline groups stack traces into their findings, nil unless enabled.
+	multiline *multiline
+
+	// before and after are the numbers of context lines kept around a finding.
+	before, after int
+
+	// fields are the fields of structured lines shown in issue bodies, in order, all of them if nil.
+	fields []string
+
+	// severities classify the findings, most severe first.
+	severities []severity
+
+	// digest combines the findings of a scan into one.
+	digest bool
+
+	// clusters group similar errors under one fingerprint, nil unless the service sets a similarity.
+	clusters *clusters
+
+	// batchDedupe files the identical errors of a scan once, with their number of occurrences.
+	batchDedupe bool
+
+	// title and body render the titles and bodies of the findings, nil for the default ones.
+	title, body *template.Template
+}
+
+// New creates the pipeline of a service from its config. defaultSink is used if the service selects no sink, or the
+// github one. With several sinks, the findings fan out to all of them and the first one closes, comments on and
+// counts the issues. The service's routes send the findings of some severities or keywords to other sinks.
+func New(svc config.Service, defaultSink sink.Sink) (*Pipeline, error) {
+	p := &Pipeline{Service: svc}
+
+	if _, err := locale.Lookup(svc.Locale); err != nil {
+		return nil, fmt.Errorf("%s of %s", err.Error(), svc.Name)
+	}
+
+	sinks := svc.Sinks
+	if len(sinks) == 0 {
+		sinks = []string{svc.Sink}
+	}
+	var err error
+	if p.Sink, p.base, err = fanOut(svc, sinks, defaultSink); err != nil {
+		return nil, err
+	}
+	if p.Sink, err = route(svc, p.Sink, defaultSink); err != nil {
+		return nil, err
+	}
+	if _, ok := p.base.(sink.Resolver); svc.CloseAfter > 0 && !ok {
+		return nil, fmt.Errorf("sink of %s cannot close issues, as close_after needs", svc.Name)
+	}
+	if _, ok := p.base.(sink.OpenCounter); svc.MaxOpenIssues > 0 && !ok {
+		return nil, fmt.Errorf("sink of %s cannot count open issues, as max_open_issues needs", svc.Name)
+	}
+
+	var mws []Middleware
+	for _, name := range svc.Middleware {
+		mw, err := NewMiddleware(name, svc)
+		if err != nil {
+			return nil, err
+		}
+		mws = append(mws, mw)
+	}
+	p.Sink = Chain(p.Sink, mws...)
+
+	if p.Source, err = source.New(orDefault(svc.Source, source.DefaultSource), svc); err != nil {
+		return nil, err
+	}
+	if p.Parser, err = parse.New(orDefault(svc.Parser, parse.DefaultParser), svc); err != nil {
+		return nil, err
+	}
+	if p.Matcher, err = match.New(orDefault(svc.Matcher, match.DefaultMatcher), svc); err != nil {
+		return nil, err
+	}
+	// Ignore patterns apply to any matcher, so known-benign errors never become issues.
+	ignore, err := match.CompilePatterns(svc.Name, "ignore pattern",
+		viper.GetStringSlice(config.Key(svc.Name, "ignore_patterns")))
+	if err != nil {
+		return nil, err
+	}
+	if len(ignore) > 0 {
+		p.Matcher = match.Ignore{Matcher: p.Matcher, Patterns: ignore}
+	}
+	if p.multiline, err = newMultiline(svc); err != nil {
+		return nil, err
+	}
+	p.before = clamp(viper.GetInt(config.Key(svc.Name, "context_before")), 0, maxContext)
+	p.after = clamp(viper.GetInt(config.Key(svc.Name, "context_after")), 0, maxContext)
+	p.fields = viper.GetStringSlice(config.Key(svc.Name, "fields"))
+	p.digest = viper.GetBool(config.Key(svc.Name, "digest"))
+	if p.clusters, err = newClusters(svc); err != nil {
+		return nil, err
+	}
+	p.batchDedupe = !viper.IsSet(config.Key(svc.Name, "batch_dedupe")) ||
+		viper.GetBool(config.Key(svc.Name, "batch_dedupe"))
+	if p.severities, err = newSeverities(svc); err != nil {
+		return nil, err
+	}
+	if p.title, err = newTemplate(svc, "title_template"); err != nil {
+		return nil, err
+	}
+	if p.body, err = newTemplate(svc, "body_template"); err != nil {
+		return nil, err
+	}
+
+	names := svc.Enrichers
+	if names == nil {
+		names = defaultEnrichers
+	}
+	for _, name := range names {
+		e, err := NewEnricher(name, svc)
+		if err != nil {
+			return nil, err
+		}
+		p.Enrichers = append(p.Enrichers, e)
+	}
+
+	return p, nil
+}
+
+// fanOut returns the sink delivering to the named sinks, a fan-out if there are several, and the first of them.
+func fanOut(svc config.Service, names []string, defaultSink sink.Sink) (sink.Sink, sink.Sink, error) {
+	fo := sink.Fanout{}
+	for _, name := range names {
+		sk := defaultSink
+		if name != "" && name != sink.DefaultSink {
+			s, err := sink.New(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			sk = s
+		}
+		if sk == nil {
+			return nil, nil, fmt.Errorf("pipeline of %s needs a sink", svc.Name)
+		}
+		fo.Names = append(fo.Names, orDefault(name, sink.DefaultSink))
+		fo.Sinks = append(fo.Sinks, sk)
+	}
+	if len(fo.Sinks) == 1 {
+		return fo.Sinks[0], fo.Sinks[0], nil
+	}
+
+	return fo, fo.Sinks[0], nil
+}
+
+// route returns the router of the service's routes, which map severities or keywords to the sinks of their
+// findings, delivering the others to sk; sk itself if the service has no routes:
+//
+//	routes:
+//	  warning: [slack]
+//	  error: [github]
+//	  fatal: [github, pagerduty]
+//	  debug: []
+func route(svc config.Service, sk, defaultSink sink.Sink) (sink.Sink, error) {
+	routes := viper.GetStringMapStringSlice(config.Key(svc.Name, "routes"))
+	if len(routes) == 0 {
+		return sk, nil
+	}
+
+	r := sink.Router{Routes: make(map[string]sink.Sink), Default: sk}
+	for key, names := range routes {
+		if len(names) == 0 {
+			r.Routes[strings.ToLower(key)] = nil
+			continue
+		}
+		rs, _, err := fanOut(svc, names, defaultSink)
+		if err != nil {
+			return nil, fmt.Errorf("route %s of %s, %s", key, svc.Name, err.Error())
+		}
+		r.Routes[strings.ToLower(key)] = rs
+	}
+
+	return r, nil
+}
+
+// Collect reads the lines after checkpoint and runs them through the parser, matcher and enrichers. It returns the
+// findings and the checkpoint to resume from; on a read error the lines read so far are still collected. With
+// multiline grouping, the continuation lines following a matched line are appended to its finding rather than
+// matched on their own. Identical errors of the scan are collected once, counting their occurrences, unless
+// batch_dedupe is false, and with clustering similar errors share the fingerprint of their class. In digest mode
+// several findings are combined into one.
+func (p *Pipeline) Collect(ctx context.Context, checkpoint int) (findings []match.Finding, next int, err error) {
+	findings, pos, err := p.CollectFrom(ctx, source.Position{Line: checkpoint})
+	return findings, pos.Line, err
+}
+
+// CollectFrom collects the findings after a position like Collect, returning the position to resume from. Sources
+// which are not Resumers read from its line.
+func (p *Pipeline) CollectFrom(ctx context.Context, pos source.Position) (findings []match.Finding,
+	next source.Position, err error) {
+	return p.CollectSource(ctx, p.Source, pos)
+}
+
+// CollectSource collects the findings of another source than the pipeline's like CollectFrom, e.g. of one of the
+// files a glob pattern matches.
+func (p *Pipeline) CollectSource(ctx context.Context, src source.Source, pos source.Position) (
+	findings []match.Finding, next source.Position, err error) {
+	var lines []string
+	if r, ok := src.(source.Resumer); ok {
+		lines, next, err = r.Resume(pos)
+	} else {
+		lines, next.Line, err = src.Read(pos.Line)
+	}
+
+	// grouped is the number of lines of the last finding while its continuation lines may follow, 0 otherwise.
+	// spans are where the lines of each finding start and end in lines, for their context.
+	grouped := 0
+	var spans [][2]int
+	for i, line := range lines {
+		if grouped > 0 && p.multiline.continues(line) {
+			if grouped < p.multiline.maxLines {
+				f := &findings[len(findings)-1]
+				f.Line += "\n" + line
+				grouped++
+			}
+			spans[len(spans)-1][1] = i + 1
+			continue
+		}
+		grouped = 0
+
+		e, ok := p.Parser.Parse(line)
+		if !ok {
+			continue
+		}
+		f, ok := p.Matcher.Match(e)
+		if !ok {
+			continue
+		}
+		findings = append(findings, f)
+		spans = append(spans, [2]int{i, i + 1})
+		if p.multiline != nil {
+			grouped = 1
+		}
+	}
+	if p.clusters != nil {
+		for i := range findings {
+			if f := &findings[i]; f.Fingerprint == "" {
+				f.Fingerprint = p.clusters.fingerprint(firstLine(*f))
+			}
+		}
+	}
+	if p.batchDedupe {
+		findings, spans = dedupeBatch(findings, spans)
+	}
+
+	for i := range findings {
+		f := &findings[i]
+		f.Line = strings.TrimRight(f.Line, " \t\n")
+		if p.before > 0 {
+			f.Before = append([]string(nil), lines[clamp(spans[i][0]-p.before, 0, len(lines)):spans[i][0]]...)
+		}
+		if p.after > 0 {
+			f.After = append([]string(nil), lines[spans[i][1]:clamp(spans[i][1]+p.after, 0, len(lines))]...)
+		}
+		f.Labels = appendLabels(f.Labels, p.Service.Labels...)
+		classify(f, p.severities)
+		p.fillDefaults(f)
+		for _, en := range p.Enrichers {
+			if err := en.Enrich(ctx, f); err != nil {
+				log.Printf("Unable to enrich finding of %s, %s\n", p.Service.Name, err.Error())
+			}
+		}
+	}
+	if p.digest && len(findings) > 1 {
+		findings = []match.Finding{p.combine(findings)}
+	}
+
+	return findings, next, err
+}
+
+// clamp returns n bounded to [lo, hi].
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+
+	return n
+}
+
+// Deliver delivers a finding through the sink.
+func (p *Pipeline) Deliver(ctx context.Context, f match.Finding) (sink.Delivery, error) {
+	return p.Sink.Deliver(ctx, p.Service, f)
+}
+
+// Resolve closes the issue with the given number through the sink, bypassing the middleware.
+func (p *Pipeline) Resolve(ctx context.Context, number int, comment string) error {
+	r, ok := p.base.(sink.Resolver)
+	if !ok {
+		return fmt.Errorf("sink of %s cannot close issues", p.Service.Name)
+	}
+
+	return r.Resolve(ctx, p.Service, number, comment)
+}
+
+// Comment comments on the issue with the given number through the sink, bypassing the middleware.
+func (p *Pipeline) Comment(ctx context.Context, number int, comment string) error {
+	c, ok := p.base.(sink.Commenter)
+	if !ok {
+		return fmt.Errorf("sink of %s cannot comment on issues", p.Service.Name)
+	}
+
+	return c.Comment(ctx, p.Service, number, comment)
+}
+
+// OpenIssues returns the number of open issues the sink filed for the service.
+func (p *Pipeline) OpenIssues(ctx context.Context) (int, error) {
+	c, ok := p.base.(sink.OpenCounter)
+	if !ok {
+		return 0, fmt.Errorf("sink of %s cannot count open issues", p.Service.Name)
+	}
+
+	return c.OpenIssues(ctx, p.Service)
+}
+
+// orDefault returns name, or def if name is empty.
+func orDefault(name, def string) string {
+	if name == "" {
+		return def
+	}
+
+	return name
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net"
+	"regexp"
+)
+
+const defaultRedactReplace = "[REDACTED]"
+
+// redactRule replaces the matches of a pattern in log lines.
+type redactRule struct {
+	// pattern matches the data to mask.
+	pattern *regexp.Regexp
+
+	// replace is the placeholder matches are replaced with. It may refer to pattern groups, e.g. ${1}.
+	replace string
+
+	// valid optionally confirms a match, so look-alikes such as plain numbers are kept.
+	valid func(match string) bool
+}
+
+// piiPresets are the built-in maskers, enabled by mask_pii.
+var piiPresets = []redactRule{
+	{
+		pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+		replace: "[private-key]",
+	},
+	{
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		replace: "[jwt]",
+	},
+	{
+		pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`),
+		replace: "[aws-key]",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`),
+		replace: "${1}[aws-secret]",
+	},
+	{
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		replace: "[email]",
+	},
+	{
+		pattern: regexp.MustCompile(`\b[2-6](?:\d[ -]?){11,17}\d\b`),
+		replace: "[card]",
+		valid:   luhn,
+	},
+	{
+		pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		replace: "[ip]",
+		valid:   isIP,
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)\b[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}\b`),
+		replace: "[ip]",
+		valid:   isIP,
+	},
+}
+
+// redactor masks sensitive data in log lines before they are posted anywhere. Custom rules are applied first, the
+// PII presets then mask whatever they left.
+type redactor struct {
+	rules []redactRule
+}
+
+// newRedactor creates the redactor of a service based on config file:
+//
+//	redact:
+//	  - pattern: 'user=\w+'
+//	    replace: 'user=[REDACTED]'
+//	mask_pii: true
+//
+// mask_pii defaults to true, so PII is masked unless a service opts out.
+func newRedactor(name string) (*redactor, error) {
+	var custom []struct {
+		Pattern string `mapstructure:"pattern"`
+		Replace string `mapstructure:"replace"`
+	}
+	if err := viper.UnmarshalKey(config.Key(name, "redact"), &custom); err != nil {
+		return nil, fmt.Errorf("invalid redact rules of %s, %s", name, err.Error())
+	}
+
+	r := &redactor{}
+	for _, c := range custom {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern of %s, %s", name, err.Error())
+		}
+		if c.Replace == "" {
+			c.Replace = defaultRedactReplace
+		}
+		r.rules = append(r.rules, redactRule{pattern: re, replace: c.Replace})
+	}
+
+	if !viper.IsSet(config.Key(name, "mask_pii")) || viper.GetBool(config.Key(name, "mask_pii")) {
+		r.rules = append(r.rules, piiPresets...)
+	}
+
+	return r, nil
+}
+
+// Enrich implements Enricher, masking the line, title and body of a finding.
+func (r *redactor) Enrich(ctx context.Context, f *match.Finding) error {
+	f.Line = r.redact(f.Line)
+	f.Title = r.redact(f.Title)
+	f.Body = r.redact(f.Body)
+
+	return nil
+}
+
+// redact returns line with all matches of the rules masked.
+func (r *redactor) redact(line string) string {
+	if r == nil {
+		return line
+	}
+
+	for _, rule := range r.rules {
+		if rule.valid == nil {
+			line = rule.pattern.ReplaceAllString(line, rule.replace)
+			continue
+		}
+		line = rule.pattern.ReplaceAllStringFunc(line, func(m string) string {
+			if !rule.valid(m) {
+				return m
+			}
+			return rule.pattern.ReplaceAllString(m, rule.replace)
+		})
+	}
+
+	return line
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum used by payment card numbers.
+func luhn(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+
+	return n >= 13 && sum%10 == 0
+}
+
+// isIP reports whether s is an IP address.
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
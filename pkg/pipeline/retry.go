@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryInitial  = time.Second
+	defaultRetryMax      = 30 * time.Second
+)
+
+// retry delivers findings again after transient sink failures, waiting exponentially longer between attempts.
+type retry struct {
+	// attempts is the number of retries after the first delivery.
+	attempts int
+
+	// initial is the delay before the first retry, max bounds the delays.
+	initial, max time.Duration
+}
+
+// newRetry creates the retry middleware of a service, configured in config file:
+//
+//	retry:
+//	  attempts: 3
+//	  initial: 1s
+//	  max: 30s
+//
+// Only deliveries failing with sink.ErrSinkUnavailable, e.g. on a 5xx status or network error, are retried, unless
+// the sink's circuit breaker is open; the finding is given up once the attempts are exhausted.
+func newRetry(svc config.Service) (Middleware, error) {
+	r := retry{
+		attempts: defaultRetryAttempts,
+		initial:  viper.GetDuration(config.Key(svc.Name, "retry.initial")),
+		max:      viper.GetDuration(config.Key(svc.Name, "retry.max")),
+	}
+	if viper.IsSet(config.Key(svc.Name, "retry.attempts")) {
+		r.attempts = viper.GetInt(config.Key(svc.Name, "retry.attempts"))
+	}
+	if r.initial <= 0 {
+		r.initial = defaultRetryInitial
+	}
+	if r.max <= 0 {
+		r.max = defaultRetryMax
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			dlv, err := next.Deliver(ctx, svc, f)
+			for i := 0; i < r.attempts && retriable(err); i++ {
+				d := r.delay(i)
+				log.Printf("Unable to deliver finding of %s, retry %d of %d in %s, %s\n", svc.Name, i+1,
+					r.attempts, d.Round(time.Millisecond), err.Error())
+				select {
+				case <-ctx.Done():
+					return sink.Delivery{}, ctx.Err()
+				case <-time.After(d):
+				}
+				dlv, err = next.Deliver(ctx, svc, f)
+			}
+			return dlv, err
+		})
+	}, nil
+}
+
+// retriable reports whether a delivery failing with err may succeed on retry: the sink failed transiently, and not
+// because its circuit breaker is open, which lasts longer than the retries.
+func retriable(err error) bool {
+	return errors.Is(err, sink.ErrSinkUnavailable) && !errors.Is(err, sink.ErrCircuitOpen)
+}
+
+// delay returns the delay before retry i, counted from 0: initial doubled i times up to max, with jitter of up to
+// half of it so the scanners retrying at once spread out.
+func (r retry) delay(i int) time.Duration {
+	d := r.initial
+	for ; i > 0 && d < r.max; i-- {
+		d *= 2
+	}
+	if d > r.max {
+		d = r.max
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
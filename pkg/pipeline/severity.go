@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+// severity is a severity findings are classified into, with the labels of its issues.
+type severity struct {
+	// name is the severity, e.g. fatal.
+	name string
+
+	// patterns match the lines of the severity.
+	patterns []*regexp.Regexp
+
+	// labels are added to the issues of the severity.
+	labels []string
+}
+
+// newSeverities creates the severities of a service based on config file, most severe first since a finding takes
+// the first severity matching its line:
+//
+//	severities:
+//	  - name: fatal
+//	    patterns: ['FATAL', 'panic:']
+//	    labels: [bug, P0]
+//	  - name: error
+//	    patterns: ['ERROR']
+//	    labels: [bug]
+func newSeverities(svc config.Service) ([]severity, error) {
+	var custom []struct {
+		Name     string   `mapstructure:"name"`
+		Patterns []string `mapstructure:"patterns"`
+		Labels   []string `mapstructure:"labels"`
+	}
+	if err := viper.UnmarshalKey(config.Key(svc.Name, "severities"), &custom); err != nil {
+		return nil, fmt.Errorf("invalid severities of %s, %s", svc.Name, err.Error())
+	}
+
+	var sevs []severity
+	for _, c := range custom {
+		if c.Name == "" {
+			return nil, fmt.Errorf("severity of %s needs a name", svc.Name)
+		}
+		if len(c.Patterns) == 0 {
+			return nil, fmt.Errorf("severity %s of %s needs patterns", c.Name, svc.Name)
+		}
+		res, err := match.CompilePatterns(svc.Name, "severity pattern", c.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		sevs = append(sevs, severity{name: c.Name, patterns: res, labels: c.Labels})
+	}
+
+	return sevs, nil
+}
+
+// classify gives a finding the first severity matching its line, and the labels of the severity.
+func classify(f *match.Finding, sevs []severity) {
+	for _, sev := range sevs {
+		for _, re := range sev.patterns {
+			if re.MatchString(f.Line) {
+				f.Severity = sev.name
+				f.Labels = appendLabels(f.Labels, sev.labels...)
+				return
+			}
+		}
+	}
+}
+
+// appendLabels appends the labels not in labels yet.
+func appendLabels(labels []string, more ...string) []string {
+	for _, l := range more {
+		found := false
+		for _, have := range labels {
+			found = found || have == l
+		}
+		if !found {
+			labels = append(labels, l)
+		}
+	}
+
+	return labels
+}
@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// IssueData is what issue templates render, e.g. {{.Service}}: {{.Summary}} [{{.Fingerprint}}].
+type IssueData struct {
+	// Service is the service name.
+	Service string
+
+	// Keyword is what the line matched, e.g. the keyword, pattern or level, if its matcher tells.
+	Keyword string
+
+	// Line is the matched log line, with its stack trace if grouped.
+	Line string
+
+	// FirstLine is the first line of a stack trace, or the message of a structured line.
+	FirstLine string
+
+	// Summary is FirstLine without its leading timestamp, shortened for a title.
+	Summary string
+
+	// Fingerprint identifies the error across its occurrences.
+	Fingerprint string
+
+	// Severity is the severity of the finding, if classified.
+	Severity string
+
+	// Fields are the parsed fields of the line, e.g. {{.Fields.trace_id}}.
+	Fields map[string]string
+
+	// Before and After are the context lines around the line, if the service asks for them.
+	Before, After []string
+
+	// Context is the line between its context lines, empty without context.
+	Context string
+
+	// Labels are the labels of the issue: the service's and those of its severity.
+	Labels []string
+
+	// Occurrences is how often the error occurred in the scan, at least once.
+	Occurrences int
+
+	// Host is the name of the host osprey runs on.
+	Host string
+
+	// Time is when the error was detected, formatted with the service's time_format.
+	Time string
+}
+
+// hostname is the name of the host, read once.
+var hostname, _ = os.Hostname()
+
+// newIssueData returns the template data of a finding detected at now.
+func newIssueData(f match.Finding, svc config.Service, now time.Time) IssueData {
+	first := firstLine(f)
+	return IssueData{
+		Service:     svc.Name,
+		Keyword:     f.Keyword,
+		Line:        f.Line,
+		FirstLine:   first,
+		Summary:     summary(first),
+		Fingerprint: f.Fingerprint,
+		Severity:    f.Severity,
+		Fields:      f.Fields,
+		Before:      f.Before,
+		After:       f.After,
+		Context:     contextLines(f),
+		Labels:      f.Labels,
+		Occurrences: occurrences(f),
+		Host:        hostname,
+		Time:        svc.FormatTime(now),
+	}
+}
+
+// newTemplate parses the template of a service under key in config file, nil if it has none:
+//
+//	title_template: '[{{.Severity}}] {{.Service}}: {{.Summary}} ({{.Fingerprint}})'
+//	body_template: templates/issue.md
+//
+// A single line without actions which has a path separator or a template extension is the path of a template file,
+// relative to the service's base_dir; any other value, e.g. a static 'Production error', is the template itself.
+// Missing fields render empty, so a template can name fields only some lines have.
+func newTemplate(svc config.Service, key string) (*template.Template, error) {
+	text := viper.GetString(config.Key(svc.Name, key))
+	if text == "" {
+		return nil, nil
+	}
+	if templateFile(text) {
+		path := text
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(svc.BaseDir, path)
+		}
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s of %s, %s", key, svc.Name, err.Error())
+		}
+		text = string(dat)
+	}
+
+	t, err := template.New(key).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s of %s, %s", key, svc.Name, err.Error())
+	}
+
+	return t, nil
+}
+
+// templateExts are the extensions of template files.
+var templateExts = map[string]bool{".tmpl": true, ".tpl": true, ".gotmpl": true, ".md": true, ".txt": true, ".html": true}
+
+// templateFile tells whether a template value is the path of a template file rather than the template.
+func templateFile(text string) bool {
+	if strings.Contains(text, "{{") || strings.ContainsAny(text, "\r\n") {
+		return false
+	}
+
+	return strings.ContainsAny(text, `/\`) || templateExts[strings.ToLower(filepath.Ext(text))]
+}
+
+// render executes a template with data.
+func render(t *template.Template, data IssueData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
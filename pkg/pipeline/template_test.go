@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{"templates/issue.md": "file: {{.Service}}", "title.tmpl": "tmpl: {{.Service}}"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{"static title", "Production error", "Production error", true},
+		{"inline template", "{{.Service}}: {{.Summary}}", "apple: db timeout", true},
+		{"multiline static body", "Check the logs.\nThen the dashboards.", "Check the logs.\nThen the dashboards.", true},
+		{"file with separator", "templates/issue.md", "file: apple", true},
+		{"file with extension", "title.tmpl", "tmpl: apple", true},
+		{"missing file", "templates/missing.md", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			svc := config.Service{Name: "apple", BaseDir: dir}
+			viper.Set(config.Key(svc.Name, "title_template"), tt.value)
+
+			tmpl, err := newTemplate(svc, "title_template")
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			got, err := render(tmpl, newIssueData(match.Finding{Line: "db timeout"}, svc, time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
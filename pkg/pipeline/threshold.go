@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThresholdWindow = 10 * time.Minute
+
+	// maxThresholdEntries bounds the errors a threshold middleware counts; the least recent are forgotten first.
+	maxThresholdEntries = 10000
+)
+
+// thresholdRule is how often an error must be seen within a window before it is delivered.
+type thresholdRule struct {
+	// pattern matches the lines of the rule, nil for any line.
+	pattern *regexp.Regexp
+
+	// count is the number of occurrences needed.
+	count int
+
+	// window is the period the occurrences are counted in.
+	window time.Duration
+}
+
+// threshold holds back findings until their error was seen often enough, so transient errors are not filed.
+type threshold struct {
+	mu sync.Mutex
+
+	// rules are the pattern rules in order, then the rule of the other lines if it has a count.
+	rules []thresholdRule
+
+	// seen are the sightings of each error within the window of its rule by fingerprint.
+	seen map[string]*sightings
+}
+
+// sightings are the times an error was seen within a window, oldest first.
+type sightings struct {
+	// window is the window of the error's rule, which its sightings expire after.
+	window time.Duration
+
+	times []time.Time
+
+	// counts are the occurrences seen at each of times, several for a finding of identical lines.
+	counts []int
+}
+
+// newThreshold creates the threshold middleware of a service, configured in config file. The first pattern matching a
+// line sets its rule, count and window default to those of the other lines:
+//
+//	threshold:
+//	  count: 3
+//	  window: 10m
+//	  patterns:
+//	    - pattern: 'connection refused'
+//	      count: 5
+//	      window: 1m
+func newThreshold(svc config.Service) (Middleware, error) {
+	def := thresholdRule{
+		count:  viper.GetInt(config.Key(svc.Name, "threshold.count")),
+		window: viper.GetDuration(config.Key(svc.Name, "threshold.window")),
+	}
+	if def.window <= 0 {
+		def.window = defaultThresholdWindow
+	}
+
+	var custom []struct {
+		Pattern string        `mapstructure:"pattern"`
+		Count   int           `mapstructure:"count"`
+		Window  time.Duration `mapstructure:"window"`
+	}
+	if err := viper.UnmarshalKey(config.Key(svc.Name, "threshold.patterns"), &custom); err != nil {
+		return nil, fmt.Errorf("invalid threshold patterns of %s, %s", svc.Name, err.Error())
+	}
+
+	t := &threshold{seen: make(map[string]*sightings)}
+	for _, c := range custom {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("threshold pattern %q of %s is invalid, %s", c.Pattern, svc.Name, err.Error())
+		}
+		rule := thresholdRule{pattern: re, count: c.Count, window: c.Window}
+		if rule.count <= 0 {
+			rule.count = def.count
+		}
+		if rule.window <= 0 {
+			rule.window = def.window
+		}
+		t.rules = append(t.rules, rule)
+	}
+	if def.count > 0 {
+		t.rules = append(t.rules, def)
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			rule, ok := t.rule(f.Line)
+			if !ok || rule.count <= 1 {
+				return next.Deliver(ctx, svc, f)
+			}
+
+			key := f.Fingerprint
+			if key == "" {
+				key = fingerprint(f.Line)
+			}
+			if n := t.observe(key, rule.window, max(f.Occurrences, 1)); n < rule.count {
+				return sink.Delivery{}, fmt.Errorf("%w by threshold, seen %d of %d times in %s", sink.ErrDropped, n,
+					rule.count, rule.window)
+			}
+
+			dlv, err := next.Deliver(ctx, svc, f)
+			if err == nil {
+				t.reset(key)
+			}
+			return dlv, err
+		})
+	}, nil
+}
+
+// rule returns the rule of a line, ok is false if none applies.
+func (t *threshold) rule(line string) (thresholdRule, bool) {
+	for _, r := range t.rules {
+		if r.pattern == nil || r.pattern.MatchString(line) {
+			return r, true
+		}
+	}
+
+	return thresholdRule{}, false
+}
+
+// observe records n occurrences of an error and returns its occurrences within the window, forgetting the
+// sightings of each error older than the window of its own rule.
+func (t *threshold) observe(key string, window time.Duration, n int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for k, s := range t.seen {
+		s.expire(now)
+		if len(s.times) == 0 {
+			delete(t.seen, k)
+			continue
+		}
+		if oldest == "" || last(s.times).Before(last(t.seen[oldest].times)) {
+			oldest = k
+		}
+	}
+	s, ok := t.seen[key]
+	if !ok {
+		if len(t.seen) >= maxThresholdEntries {
+			delete(t.seen, oldest)
+		}
+		s = &sightings{}
+		t.seen[key] = s
+	}
+	s.window = window
+	s.times, s.counts = append(s.times, now), append(s.counts, n)
+
+	total := 0
+	for _, c := range s.counts {
+		total += c
+	}
+
+	return total
+}
+
+// expire forgets the sightings older than the window.
+func (s *sightings) expire(now time.Time) {
+	i := 0
+	for i < len(s.times) && now.Sub(s.times[i]) > s.window {
+		i++
+	}
+	s.times, s.counts = s.times[i:], s.counts[i:]
+}
+
+// reset forgets the occurrences of a delivered error, so it is counted anew.
+func (t *threshold) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.seen, key)
+}
+
+// last returns the last of times.
+func last(times []time.Time) time.Time {
+	return times[len(times)-1]
+}
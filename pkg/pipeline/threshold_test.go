@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"testing"
+	"time"
+)
+
+func TestThresholdMiddleware(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set(config.Key("apple", "threshold.count"), 3)
+	viper.Set(config.Key("apple", "threshold.patterns"), []map[string]interface{}{
+		{"pattern": "connection refused", "count": 2, "window": "1m"}})
+
+	mw, err := NewMiddleware("threshold", config.Service{Name: "apple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivered int
+	s := Chain(SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+		delivered++
+		return sink.Delivery{Number: delivered}, nil
+	}), mw)
+
+	tests := []struct {
+		name    string
+		finding match.Finding
+		dropped bool
+		want    int
+	}{
+		{"first", match.Finding{Line: "error: db timeout"}, true, 0},
+		{"second", match.Finding{Line: "error: db timeout"}, true, 0},
+		{"pattern rule", match.Finding{Line: "error: connection refused"}, true, 0},
+		{"third", match.Finding{Line: "error: db timeout"}, false, 1},
+		{"counted again", match.Finding{Line: "error: db timeout"}, true, 1},
+		{"pattern rule count", match.Finding{Line: "error: connection refused"}, false, 2},
+		{"identical lines of a batch", match.Finding{Line: "error: disk full", Occurrences: 3}, false, 3},
+	}
+	for _, tt := range tests {
+		_, err := s.Deliver(context.Background(), config.Service{Name: "apple"}, tt.finding)
+		if errors.Is(err, sink.ErrDropped) != tt.dropped {
+			t.Fatalf("%s: got %v, want dropped %v", tt.name, err, tt.dropped)
+		}
+		if delivered != tt.want {
+			t.Fatalf("%s: got %d deliveries, want %d", tt.name, delivered, tt.want)
+		}
+	}
+}
+
+func TestThresholdWindowPerRule(t *testing.T) {
+	th := &threshold{seen: make(map[string]*sightings)}
+	if n := th.observe("db timeout", 10*time.Minute, 1); n != 1 {
+		t.Fatalf("got %d, want 1", n)
+	}
+	// Seen 5 minutes ago, within its own window but not that of the next error.
+	th.seen["db timeout"].times[0] = time.Now().Add(-5 * time.Minute)
+	th.observe("connection refused", time.Minute, 1)
+
+	if n := th.observe("db timeout", 10*time.Minute, 1); n != 2 {
+		t.Fatalf("got %d sightings, want the sighting of 5 minutes ago kept", n)
+	}
+	th.seen["connection refused"].times[0] = time.Now().Add(-2 * time.Minute)
+	if n := th.observe("connection refused", time.Minute, 1); n != 1 {
+		t.Fatalf("got %d sightings, want the sighting of 2 minutes ago expired", n)
+	}
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThrottleRate   = 10
+	defaultThrottlePer    = time.Minute
+	defaultCooldownPeriod = 5 * time.Minute
+)
+
+// throttle drops findings beyond a number of deliveries per period.
+type throttle struct {
+	mu sync.Mutex
+
+	// rate is the number of deliveries allowed per period.
+	rate int
+
+	// per is the period.
+	per time.Duration
+
+	// sent are the times of the deliveries within the last period, oldest first.
+	sent []time.Time
+}
+
+// newThrottle creates the throttle middleware of a service, configured in config file:
+//
+//	throttle:
+//	  rate: 10
+//	  per: 1m
+func newThrottle(svc config.Service) (Middleware, error) {
+	t := &throttle{
+		rate: viper.GetInt(config.Key(svc.Name, "throttle.rate")),
+		per:  viper.GetDuration(config.Key(svc.Name, "throttle.per")),
+	}
+	if t.rate <= 0 {
+		t.rate = defaultThrottleRate
+	}
+	if t.per <= 0 {
+		t.per = defaultThrottlePer
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			if !t.allow() {
+				return sink.Delivery{}, fmt.Errorf("%w by throttle, %d deliveries in the last %s", sink.ErrDropped,
+					t.rate, t.per)
+			}
+
+			dlv, err := next.Deliver(ctx, svc, f)
+			if err == nil {
+				t.record()
+			}
+			return dlv, err
+		})
+	}, nil
+}
+
+// allow reports whether another delivery fits in the current period.
+func (t *throttle) allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-t.per)
+	for len(t.sent) > 0 && t.sent[0].Before(cutoff) {
+		t.sent = t.sent[1:]
+	}
+
+	return len(t.sent) < t.rate
+}
+
+// record records a delivery.
+func (t *throttle) record() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sent = append(t.sent, time.Now())
+}
+
+// cooldown keeps a service quiet for a period after each delivery.
+type cooldown struct {
+	mu sync.Mutex
+
+	// period is how long findings are dropped after a delivery.
+	period time.Duration
+
+	// last is when the last finding was delivered.
+	last time.Time
+}
+
+// newCooldown creates the cooldown middleware of a service, configured in config file:
+//
+//	cooldown:
+//	  period: 5m
+func newCooldown(svc config.Service) (Middleware, error) {
+	c := &cooldown{period: viper.GetDuration(config.Key(svc.Name, "cooldown.period"))}
+	if c.period <= 0 {
+		c.period = defaultCooldownPeriod
+	}
+
+	return func(next sink.Sink) sink.Sink {
+		return SinkFunc(func(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+			c.mu.Lock()
+			left := c.period - time.Since(c.last)
+			c.mu.Unlock()
+			if left > 0 {
+				return sink.Delivery{}, fmt.Errorf("%w by cooldown, %s left", sink.ErrDropped, left.Round(time.Second))
+			}
+
+			dlv, err := next.Deliver(ctx, svc, f)
+			if err == nil {
+				c.mu.Lock()
+				c.last = time.Now()
+				c.mu.Unlock()
+			}
+			return dlv, err
+		})
+	}, nil
+}
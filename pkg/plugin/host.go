@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	pluginv1 "github.com/NBCFB/Iguana2/proto/osprey/plugin/v1"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// Load starts the plugin binaries defined in config file and registers their components under the plugin name,
+// so services select them like built-in ones:
+//
+//	plugins:
+//	  acme:
+//	    path: /usr/local/lib/osprey/osprey-acme
+//	    args: ["-region", "eu"]
+//
+// It returns the number of plugins started. The plugin processes keep running until Close is called.
+func Load() (int, error) {
+	plugins := viper.GetStringMap("plugins")
+	for name := range plugins {
+		path := viper.GetString(fmt.Sprintf("plugins.%s.path", name))
+		if path == "" {
+			Close()
+			return 0, fmt.Errorf("plugin %s needs a path", name)
+		}
+		args := viper.GetStringSlice(fmt.Sprintf("plugins.%s.args", name))
+
+		if err := load(name, path, args); err != nil {
+			Close()
+			return 0, fmt.Errorf("unable to load plugin %s, %s", name, err.Error())
+		}
+	}
+
+	return len(plugins), nil
+}
+
+// load starts a plugin binary and registers the components it provides.
+func load(name, path string, args []string) error {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          pluginSet(nil),
+		Cmd:              exec.Command(path, args...),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		Managed:          true,
+		SyncStderr:       os.Stderr,
+		Logger:           hclog.New(&hclog.LoggerOptions{Name: "plugin." + name, Level: hclog.Warn}),
+	})
+
+	rpc, err := client.Client()
+	if err != nil {
+		return err
+	}
+
+	raw, err := rpc.Dispense(kindInfo)
+	if err != nil {
+		return err
+	}
+	desc, err := raw.(pluginv1.InfoClient).Describe(context.Background(), &pluginv1.DescribeRequest{})
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range desc.GetKinds() {
+		raw, err := rpc.Dispense(kind)
+		if err != nil {
+			return err
+		}
+
+		switch c := raw.(type) {
+		case pluginv1.SourceClient:
+			source.Register(name, func(svc config.Service) (source.Source, error) {
+				return &remoteSource{client: c, svc: svc}, nil
+			})
+		case pluginv1.MatcherClient:
+			match.Register(name, func(svc config.Service) (match.Matcher, error) {
+				return &remoteMatcher{client: c, svc: svc}, nil
+			})
+		case pluginv1.SinkClient:
+			sink.Register(name, func() (sink.Sink, error) {
+				return &remoteSink{client: c}, nil
+			})
+		}
+		log.Printf("plugin %s provides a %s\n", name, kind)
+	}
+
+	return nil
+}
+
+// Close stops all plugin processes.
+func Close() {
+	goplugin.CleanupClients()
+}
+
+// remoteSource is the source of a service served by a plugin.
+type remoteSource struct {
+	client pluginv1.SourceClient
+	svc    config.Service
+}
+
+// Read implements source.Source.
+func (s *remoteSource) Read(checkpoint int) ([]string, int, error) {
+	resp, err := s.client.Read(context.Background(), &pluginv1.ReadRequest{
+		Service:    toServiceInfo(s.svc),
+		Checkpoint: int64(checkpoint),
+	})
+	if err != nil {
+		return nil, checkpoint, fromStatus(err)
+	}
+
+	return resp.GetLines(), int(resp.GetNext()), nil
+}
+
+// remoteMatcher is the matcher of a service served by a plugin.
+type remoteMatcher struct {
+	client pluginv1.MatcherClient
+	svc    config.Service
+}
+
+// Match implements match.Matcher. A failed call is logged and the line is taken as no finding, so a broken plugin
+// does not stop the scan.
+func (m *remoteMatcher) Match(e *parse.Entry) (match.Finding, bool) {
+	resp, err := m.client.Match(context.Background(), &pluginv1.MatchRequest{
+		Service: toServiceInfo(m.svc),
+		Line:    e.Line,
+		Fields:  e.Fields,
+	})
+	if err != nil {
+		log.Printf("Unable to match line of %s, %s\n", m.svc.Name, err.Error())
+		return match.Finding{}, false
+	}
+	if !resp.GetOk() {
+		return match.Finding{}, false
+	}
+
+	return fromProtoFinding(resp.GetFinding()), true
+}
+
+// remoteSink is a sink served by a plugin.
+type remoteSink struct {
+	client pluginv1.SinkClient
+}
+
+// Deliver implements sink.Sink.
+func (s *remoteSink) Deliver(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+	resp, err := s.client.Deliver(ctx, &pluginv1.DeliverRequest{Service: toServiceInfo(svc), Finding: toProtoFinding(f)})
+	if err != nil {
+		return sink.Delivery{}, fromStatus(err)
+	}
+
+	return sink.Delivery{Number: int(resp.GetNumber()), URL: resp.GetUrl()}, nil
+}
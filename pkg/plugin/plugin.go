@@ -0,0 +1,182 @@
+// Package plugin runs sources, matchers and sinks in external binaries, using hashicorp/go-plugin's gRPC protocol,
+// so proprietary integrations can be added without recompiling osprey. The host side loads the binaries listed in
+// config file; a plugin binary calls Serve.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	pluginv1 "github.com/NBCFB/Iguana2/proto/osprey/plugin/v1"
+	goplugin "github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	kindInfo    = "info"
+	kindSource  = "source"
+	kindMatcher = "matcher"
+	kindSink    = "sink"
+)
+
+// Handshake is shared by osprey and its plugins, so osprey only talks to binaries built as its plugins and a plugin
+// run by hand explains itself instead of hanging.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "OSPREY_PLUGIN",
+	MagicCookieValue: "b7e1c3f0-osprey-plugin",
+}
+
+// pluginSet returns the plugins served by a plugin binary, or dispensed by osprey if cfg is nil.
+func pluginSet(cfg *ServeConfig) goplugin.PluginSet {
+	return goplugin.PluginSet{
+		kindInfo:    &grpcPlugin{cfg: cfg, kind: kindInfo},
+		kindSource:  &grpcPlugin{cfg: cfg, kind: kindSource},
+		kindMatcher: &grpcPlugin{cfg: cfg, kind: kindMatcher},
+		kindSink:    &grpcPlugin{cfg: cfg, kind: kindSink},
+	}
+}
+
+// grpcPlugin is a component kind served over gRPC.
+type grpcPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+
+	// cfg holds the components of a plugin binary, nil in osprey.
+	cfg *ServeConfig
+
+	// kind is the component kind.
+	kind string
+}
+
+// GRPCServer registers the component with the plugin's gRPC server.
+func (p *grpcPlugin) GRPCServer(broker *goplugin.GRPCBroker, s *grpc.Server) error {
+	switch p.kind {
+	case kindInfo:
+		pluginv1.RegisterInfoServer(s, &infoServer{cfg: p.cfg})
+	case kindSource:
+		if p.cfg.Source != nil {
+			pluginv1.RegisterSourceServer(s, newSourceServer(p.cfg.Source))
+		}
+	case kindMatcher:
+		if p.cfg.Matcher != nil {
+			pluginv1.RegisterMatcherServer(s, newMatcherServer(p.cfg.Matcher))
+		}
+	case kindSink:
+		if p.cfg.Sink != nil {
+			pluginv1.RegisterSinkServer(s, &sinkServer{factory: p.cfg.Sink})
+		}
+	}
+
+	return nil
+}
+
+// GRPCClient returns the gRPC client of the component.
+func (p *grpcPlugin) GRPCClient(ctx context.Context, broker *goplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	switch p.kind {
+	case kindInfo:
+		return pluginv1.NewInfoClient(c), nil
+	case kindSource:
+		return pluginv1.NewSourceClient(c), nil
+	case kindMatcher:
+		return pluginv1.NewMatcherClient(c), nil
+	case kindSink:
+		return pluginv1.NewSinkClient(c), nil
+	}
+
+	return nil, fmt.Errorf("unknown plugin kind %s", p.kind)
+}
+
+// errorCodes are the gRPC codes the error kinds of components travel as between a plugin and osprey.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{source.ErrLogNotFound, codes.NotFound},
+	{source.ErrLogUnreadable, codes.PermissionDenied},
+	{sink.ErrRateLimited, codes.ResourceExhausted},
+	{sink.ErrSinkUnavailable, codes.Unavailable},
+}
+
+// toStatus converts an error of a plugin component to a gRPC status, keeping its kind.
+func toStatus(err error) error {
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return status.Error(ec.code, err.Error())
+		}
+	}
+
+	return err
+}
+
+// fromStatus wraps the error of a plugin call with the error kind its gRPC code stands for.
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, ec := range errorCodes {
+		if st.Code() == ec.code {
+			return kindError{msg: st.Message(), kind: ec.err}
+		}
+	}
+
+	return err
+}
+
+// kindError is the error message of a plugin call, of the kind of its gRPC code.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+// Error implements error.
+func (e kindError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the error kind, for errors.Is.
+func (e kindError) Unwrap() error {
+	return e.kind
+}
+
+// toServiceInfo converts a service to its proto message.
+func toServiceInfo(svc config.Service) *pluginv1.ServiceInfo {
+	return &pluginv1.ServiceInfo{
+		Name:      svc.Name,
+		Location:  svc.Location,
+		RepoOwner: svc.RepoOwner,
+		RepoName:  svc.RepoName,
+	}
+}
+
+// fromServiceInfo converts a proto message to a service.
+func fromServiceInfo(info *pluginv1.ServiceInfo) config.Service {
+	return config.Service{
+		Name:      info.GetName(),
+		Location:  info.GetLocation(),
+		RepoOwner: info.GetRepoOwner(),
+		RepoName:  info.GetRepoName(),
+	}
+}
+
+// toProtoFinding converts a finding to its proto message.
+func toProtoFinding(f match.Finding) *pluginv1.Finding {
+	return &pluginv1.Finding{Service: f.Service, Line: f.Line, Title: f.Title, Body: f.Body, Fields: f.Fields}
+}
+
+// fromProtoFinding converts a proto message to a finding.
+func fromProtoFinding(f *pluginv1.Finding) match.Finding {
+	return match.Finding{
+		Service: f.GetService(),
+		Line:    f.GetLine(),
+		Fields:  f.GetFields(),
+		Title:   f.GetTitle(),
+		Body:    f.GetBody(),
+	}
+}
@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	pluginv1 "github.com/NBCFB/Iguana2/proto/osprey/plugin/v1"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"strings"
+	"testing"
+)
+
+// lineSource serves lines, or err if set.
+type lineSource struct {
+	lines []string
+	err   error
+}
+
+func (s *lineSource) Read(checkpoint int) ([]string, int, error) {
+	if s.err != nil {
+		return nil, checkpoint, s.err
+	}
+	return s.lines[checkpoint:], len(s.lines), nil
+}
+
+// errorMatcher matches the lines containing "error".
+type errorMatcher struct {
+	svc config.Service
+}
+
+func (m errorMatcher) Match(e *parse.Entry) (match.Finding, bool) {
+	if !strings.Contains(e.Line, "error") {
+		return match.Finding{}, false
+	}
+	return match.Finding{Service: m.svc.Name, Line: e.Line, Title: m.svc.Name + ": " + e.Line}, true
+}
+
+// countingSink numbers the findings it is given, failing with err if set.
+type countingSink struct {
+	n   int
+	err error
+}
+
+func (s *countingSink) Deliver(ctx context.Context, svc config.Service, f match.Finding) (sink.Delivery, error) {
+	if s.err != nil {
+		return sink.Delivery{}, s.err
+	}
+	s.n++
+	return sink.Delivery{Number: s.n, URL: fmt.Sprintf("https://tracker.example.com/%s/%d", svc.RepoName, s.n)}, nil
+}
+
+// dispense serves the components of cfg over an in-memory gRPC connection and returns the client of each kind, as
+// osprey dispenses them from a plugin binary.
+func dispense(t *testing.T, cfg *ServeConfig) map[string]interface{} {
+	t.Helper()
+
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	for _, p := range pluginSet(cfg) {
+		if err := p.(*grpcPlugin).GRPCServer(nil, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///plugin", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	clients := make(map[string]interface{})
+	for kind, p := range pluginSet(nil) {
+		c, err := p.(*grpcPlugin).GRPCClient(context.Background(), nil, conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients[kind] = c
+	}
+
+	return clients
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *ServeConfig
+		want []string
+	}{
+		{"sink only", &ServeConfig{Sink: func() (sink.Sink, error) { return &countingSink{}, nil }}, []string{kindSink}},
+		{"source and matcher", &ServeConfig{
+			Source:  func(svc config.Service) (source.Source, error) { return &lineSource{}, nil },
+			Matcher: func(svc config.Service) (match.Matcher, error) { return errorMatcher{svc}, nil },
+		}, []string{kindSource, kindMatcher}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := dispense(t, tt.cfg)[kindInfo].(pluginv1.InfoClient)
+			desc, err := info.Describe(context.Background(), &pluginv1.DescribeRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(desc.GetKinds(), ","); got != strings.Join(tt.want, ",") {
+				t.Fatalf("got %s, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteComponents(t *testing.T) {
+	src := &lineSource{lines: []string{"boot ok", "error: disk full"}}
+	snk := &countingSink{}
+	clients := dispense(t, &ServeConfig{
+		Source:  func(svc config.Service) (source.Source, error) { return src, nil },
+		Matcher: func(svc config.Service) (match.Matcher, error) { return errorMatcher{svc}, nil },
+		Sink:    func() (sink.Sink, error) { return snk, nil },
+	})
+	svc := config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple"}
+	rs := &remoteSource{client: clients[kindSource].(pluginv1.SourceClient), svc: svc}
+	rm := &remoteMatcher{client: clients[kindMatcher].(pluginv1.MatcherClient), svc: svc}
+	rk := &remoteSink{client: clients[kindSink].(pluginv1.SinkClient)}
+
+	lines, next, err := rs.Read(1)
+	if err != nil || next != 2 || len(lines) != 1 || lines[0] != "error: disk full" {
+		t.Fatalf("got %v, %d, %v, want the line after the checkpoint", lines, next, err)
+	}
+	if _, ok := rm.Match(&parse.Entry{Line: "boot ok"}); ok {
+		t.Fatal("got a finding, want none")
+	}
+	f, ok := rm.Match(&parse.Entry{Line: lines[0]})
+	if !ok || f.Title != "apple: error: disk full" {
+		t.Fatalf("got %+v, %v, want the finding of the plugin's matcher", f, ok)
+	}
+	dlv, err := rk.Deliver(context.Background(), svc, f)
+	if err != nil || dlv.Number != 1 || dlv.URL != "https://tracker.example.com/apple/1" {
+		t.Fatalf("got %+v, %v, want the delivery of the plugin's sink", dlv, err)
+	}
+}
+
+func TestRemoteErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"log not found", fmt.Errorf("%w, /var/log/app.log", source.ErrLogNotFound), source.ErrLogNotFound},
+		{"rate limited", fmt.Errorf("%w, retry in 1m", sink.ErrRateLimited), sink.ErrRateLimited},
+		{"sink unavailable", sink.ErrSinkUnavailable, sink.ErrSinkUnavailable},
+		{"other", errors.New("boom"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := dispense(t, &ServeConfig{
+				Source: func(svc config.Service) (source.Source, error) { return &lineSource{err: tt.err}, nil },
+				Sink:   func() (sink.Sink, error) { return &countingSink{err: tt.err}, nil },
+			})
+			svc := config.Service{Name: "apple"}
+			_, _, srcErr := (&remoteSource{client: clients[kindSource].(pluginv1.SourceClient), svc: svc}).Read(0)
+			_, sinkErr := (&remoteSink{client: clients[kindSink].(pluginv1.SinkClient)}).Deliver(
+				context.Background(), svc, match.Finding{})
+
+			// The kind of an error survives the plugin boundary, so osprey backs off or queues as it does for
+			// built-in components.
+			for _, err := range []error{srcErr, sinkErr} {
+				if err == nil || !strings.Contains(err.Error(), tt.err.Error()) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				if tt.kind != nil && !errors.Is(err, tt.kind) {
+					t.Fatalf("got %v, want an error of kind %v", err, tt.kind)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadNeedsPath(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("plugins.acme.args", []string{"-region", "eu"})
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "needs a path") {
+		t.Fatalf("got %v, want an error without a path", err)
+	}
+}
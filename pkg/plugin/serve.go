@@ -0,0 +1,194 @@
+package plugin
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/parse"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	pluginv1 "github.com/NBCFB/Iguana2/proto/osprey/plugin/v1"
+	goplugin "github.com/hashicorp/go-plugin"
+	"os"
+	"sync"
+	"time"
+)
+
+// ServeConfig holds the components of a plugin binary. Any of them may be nil.
+type ServeConfig struct {
+	// Source creates the source of a service using the plugin as its source.
+	Source source.Factory
+
+	// Matcher creates the matcher of a service using the plugin as its matcher.
+	Matcher match.Factory
+
+	// Sink creates the sink of the plugin, once on first use.
+	Sink sink.Factory
+}
+
+// Serve serves the components of a plugin binary to osprey. It is called from the plugin's main function and
+// returns when osprey stops the plugin.
+func Serve(cfg *ServeConfig) {
+	go exitWithParent()
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         pluginSet(cfg),
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
+
+// exitWithParent exits the plugin once osprey is gone, e.g. after a crash or a fatal error at startup, since
+// go-plugin only stops plugins osprey cleans up itself. The plugin is reparented when osprey dies.
+func exitWithParent() {
+	ppid := os.Getppid()
+	for range time.Tick(time.Second) {
+		if os.Getppid() != ppid {
+			os.Exit(0)
+		}
+	}
+}
+
+// infoServer describes a plugin binary.
+type infoServer struct {
+	pluginv1.UnimplementedInfoServer
+
+	cfg *ServeConfig
+}
+
+// Describe implements pluginv1.InfoServer.
+func (s *infoServer) Describe(ctx context.Context, req *pluginv1.DescribeRequest) (*pluginv1.DescribeResponse, error) {
+	resp := &pluginv1.DescribeResponse{}
+	if s.cfg.Source != nil {
+		resp.Kinds = append(resp.Kinds, kindSource)
+	}
+	if s.cfg.Matcher != nil {
+		resp.Kinds = append(resp.Kinds, kindMatcher)
+	}
+	if s.cfg.Sink != nil {
+		resp.Kinds = append(resp.Kinds, kindSink)
+	}
+
+	return resp, nil
+}
+
+// sourceServer serves the sources of a plugin binary, one per service.
+type sourceServer struct {
+	pluginv1.UnimplementedSourceServer
+
+	factory source.Factory
+
+	mu      sync.Mutex
+	sources map[string]source.Source
+}
+
+// newSourceServer creates a source server creating sources with the given factory.
+func newSourceServer(f source.Factory) *sourceServer {
+	return &sourceServer{factory: f, sources: make(map[string]source.Source)}
+}
+
+// Read implements pluginv1.SourceServer.
+func (s *sourceServer) Read(ctx context.Context, req *pluginv1.ReadRequest) (*pluginv1.ReadResponse, error) {
+	src, err := s.source(fromServiceInfo(req.GetService()))
+	if err != nil {
+		return nil, err
+	}
+
+	lines, next, err := src.Read(int(req.GetCheckpoint()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pluginv1.ReadResponse{Lines: lines, Next: int64(next)}, nil
+}
+
+// source returns the source of a service, creating it on first use.
+func (s *sourceServer) source(svc config.Service) (source.Source, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if src, ok := s.sources[svc.Name]; ok {
+		return src, nil
+	}
+	src, err := s.factory(svc)
+	if err != nil {
+		return nil, err
+	}
+	s.sources[svc.Name] = src
+
+	return src, nil
+}
+
+// matcherServer serves the matchers of a plugin binary, one per service.
+type matcherServer struct {
+	pluginv1.UnimplementedMatcherServer
+
+	factory match.Factory
+
+	mu       sync.Mutex
+	matchers map[string]match.Matcher
+}
+
+// newMatcherServer creates a matcher server creating matchers with the given factory.
+func newMatcherServer(f match.Factory) *matcherServer {
+	return &matcherServer{factory: f, matchers: make(map[string]match.Matcher)}
+}
+
+// Match implements pluginv1.MatcherServer.
+func (s *matcherServer) Match(ctx context.Context, req *pluginv1.MatchRequest) (*pluginv1.MatchResponse, error) {
+	m, err := s.matcher(fromServiceInfo(req.GetService()))
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := m.Match(&parse.Entry{Line: req.GetLine(), Fields: req.GetFields()})
+	if !ok {
+		return &pluginv1.MatchResponse{}, nil
+	}
+
+	return &pluginv1.MatchResponse{Ok: true, Finding: toProtoFinding(f)}, nil
+}
+
+// matcher returns the matcher of a service, creating it on first use.
+func (s *matcherServer) matcher(svc config.Service) (match.Matcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.matchers[svc.Name]; ok {
+		return m, nil
+	}
+	m, err := s.factory(svc)
+	if err != nil {
+		return nil, err
+	}
+	s.matchers[svc.Name] = m
+
+	return m, nil
+}
+
+// sinkServer serves the sink of a plugin binary.
+type sinkServer struct {
+	pluginv1.UnimplementedSinkServer
+
+	factory sink.Factory
+
+	once sync.Once
+	sink sink.Sink
+	err  error
+}
+
+// Deliver implements pluginv1.SinkServer.
+func (s *sinkServer) Deliver(ctx context.Context, req *pluginv1.DeliverRequest) (*pluginv1.DeliverResponse, error) {
+	s.once.Do(func() {
+		s.sink, s.err = s.factory()
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	dlv, err := s.sink.Deliver(ctx, fromServiceInfo(req.GetService()), fromProtoFinding(req.GetFinding()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pluginv1.DeliverResponse{Number: int64(dlv.Number), Url: dlv.URL}, nil
+}
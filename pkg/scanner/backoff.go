@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"time"
+)
+
+const (
+	minLogBackoff = 10 * time.Second
+	maxLogBackoff = 5 * time.Minute
+)
+
+// logBackoff spaces out the scans of a log file which is missing, e.g. before the service first starts, or
+// unreadable, doubling the delay up to maxLogBackoff.
+type logBackoff struct {
+	// delay is the current delay, 0 while the log file is readable.
+	delay time.Duration
+
+	// until is when the log file is tried again.
+	until time.Time
+}
+
+// waiting reports whether the next attempt is still due.
+func (b *logBackoff) waiting(now time.Time) bool {
+	return now.Before(b.until)
+}
+
+// fail records an attempt failing for want of the log file and returns the delay before the next one.
+func (b *logBackoff) fail(now time.Time) time.Duration {
+	switch {
+	case b.delay == 0:
+		b.delay = minLogBackoff
+	case b.delay < maxLogBackoff:
+		b.delay *= 2
+		if b.delay > maxLogBackoff {
+			b.delay = maxLogBackoff
+		}
+	}
+	b.until = now.Add(b.delay)
+
+	return b.delay
+}
+
+// reset ends the backoff and reports whether there was one.
+func (b *logBackoff) reset() bool {
+	backingOff := b.delay != 0
+	*b = logBackoff{}
+
+	return backingOff
+}
+
+// logUnavailable reports whether err is the log file missing or unreadable, which the backoff is for.
+func logUnavailable(err error) bool {
+	return errors.Is(err, source.ErrLogNotFound) || errors.Is(err, source.ErrLogUnreadable)
+}
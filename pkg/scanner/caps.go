@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"log"
+	"time"
+)
+
+// issueBudget returns how many issues a scan may file or comment on under the service's max_issues_per_interval and
+// max_open_issues, -1 for any number. An uncountable number of open issues does not stop filing.
+func (s *Scanner) issueBudget(ctx context.Context) int {
+	budget := -1
+	if s.service.MaxIssuesPerInterval > 0 {
+		budget = s.service.MaxIssuesPerInterval
+	}
+	if s.service.MaxOpenIssues <= 0 {
+		return budget
+	}
+
+	open, err := s.pipeline.OpenIssues(ctx)
+	if err != nil {
+		log.Printf("Unable to count open issues of %s, %s\n", s.service.Name, err.Error())
+		return budget
+	}
+	left := s.service.MaxOpenIssues - open
+	if left < 0 {
+		left = 0
+	}
+	if budget < 0 || left < budget {
+		budget = left
+	}
+
+	return budget
+}
+
+// suppress records a finding left unfiled by the issue caps.
+func (s *Scanner) suppress(mf match.Finding) {
+	s.findings.Add(Finding{Time: time.Now(), Service: s.service.Name, Line: mf.Line, Severity: mf.Severity,
+		Error: "suppressed, issue cap reached"})
+	s.statsd.Count(s.service.Name, "issues.suppressed", 1)
+}
+
+// summarizeSuppressed comments the number of findings left unfiled by the issue caps on the last issue, so they are
+// noted once rather than lost silently.
+func (s *Scanner) summarizeSuppressed(ctx context.Context, n int) {
+	log.Printf("%d additional errors of %s suppressed\n", n, s.service.Name)
+	if s.dryRun || s.lastIssue == 0 {
+		return
+	}
+
+	comment := fmt.Sprintf(locale.For(s.service.Locale).Suppressed, n)
+	if err := s.pipeline.Comment(ctx, s.lastIssue, comment); err != nil {
+		log.Printf("Unable to comment on issue #%d of %s, %s\n", s.lastIssue, s.service.Name, err.Error())
+	}
+}
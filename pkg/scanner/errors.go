@@ -0,0 +1,42 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+)
+
+// Error kinds, as reported in Status.LastErrorKind.
+const (
+	KindLogNotFound     = "log_not_found"
+	KindLogUnreadable   = "log_unreadable"
+	KindStateCorrupt    = "state_corrupt"
+	KindStateLocked     = "state_locked"
+	KindRateLimited     = "rate_limited"
+	KindSinkUnavailable = "sink_unavailable"
+)
+
+// errorKinds maps the sentinel errors to their kinds.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{source.ErrLogNotFound, KindLogNotFound},
+	{source.ErrLogUnreadable, KindLogUnreadable},
+	{state.ErrStateCorrupt, KindStateCorrupt},
+	{state.ErrStateLocked, KindStateLocked},
+	{sink.ErrRateLimited, KindRateLimited},
+	{sink.ErrSinkUnavailable, KindSinkUnavailable},
+}
+
+// ErrorKind returns the kind of an error wrapping one of the sentinel errors, or an empty string.
+func ErrorKind(err error) string {
+	for _, ek := range errorKinds {
+		if errors.Is(err, ek.err) {
+			return ek.kind
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const defaultExecTimeout = 10 * time.Second
+
+// execHook runs an external command for every finding of a service, deciding whether its issue is filed.
+type execHook struct {
+	// command is the program and its arguments.
+	command []string
+
+	// timeout bounds a run of the command.
+	timeout time.Duration
+}
+
+// execInput is the finding as the hook reads it on stdin.
+type execInput struct {
+	Time     time.Time `json:"time"`
+	Service  string    `json:"service"`
+	Line     string    `json:"line"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	Severity string    `json:"severity,omitempty"`
+}
+
+// newExecHook creates the exec hook of a service based on config file. It returns nil if the service has none.
+//
+//	exec:
+//	  command: ["/usr/local/bin/triage", "--team", "payments"]
+//	  timeout: 10s
+func newExecHook(name string) (*execHook, error) {
+	command := viper.GetStringSlice(config.Key(name, "exec.command"))
+	if len(command) == 0 {
+		return nil, nil
+	}
+	if command[0] == "" {
+		return nil, fmt.Errorf("exec hook of %s has an empty command", name)
+	}
+
+	h := &execHook{command: command, timeout: viper.GetDuration(config.Key(name, "exec.timeout"))}
+	if h.timeout <= 0 {
+		h.timeout = defaultExecTimeout
+	}
+
+	return h, nil
+}
+
+// allow runs the hook with the finding as JSON on stdin. The issue is filed if the command exits with 0 and
+// suppressed for any other exit code. A hook which cannot be run or times out is logged and the issue is filed, so
+// a broken hook does not silently drop findings.
+func (h *execHook) allow(ctx context.Context, f match.Finding) bool {
+	if h == nil {
+		return true
+	}
+
+	in, err := json.Marshal(execInput{Time: time.Now(), Service: f.Service, Line: f.Line, Title: f.Title, Body: f.Body,
+		Severity: f.Severity})
+	if err != nil {
+		log.Printf("Unable to run exec hook of %s, %s\n", f.Service, err.Error())
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err == nil {
+		return true
+	}
+	if _, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+		return false
+	}
+	log.Printf("Unable to run exec hook of %s, %s\n", f.Service, err.Error())
+
+	return true
+}
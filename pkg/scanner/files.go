@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"log"
+	"os"
+)
+
+// logFile is one of the log files of a service whose location is a glob pattern, read and anchored on its own.
+type logFile struct {
+	src *source.File
+
+	// state is the anchor file of the log file.
+	state *state.Anchor
+}
+
+// pattern returns the glob pattern of the service's log files and whether it may match several, a pattern or
+// directory location of the file source.
+func (s *Scanner) pattern() (string, bool) {
+	if s.service.Source != "" && s.service.Source != source.DefaultSource {
+		return s.service.Location, false
+	}
+
+	return source.Pattern(s.service.Location, s.service.FilePattern)
+}
+
+// scanFiles scans the log files the pattern matches, globbed again on every scan so new files are picked up, each
+// from its own anchor. A new file is read from the start, unless it is the file another one was rotated out of,
+// which it resumes from. With signed state, the anchors of the files matched by the first glob must exist, like
+// that of a single log file. The files of failed reads are logged and left for the next scan. The scanners of the
+// files of a directory location which were deleted are retired with their anchors.
+func (s *Scanner) scanFiles(ctx context.Context, pattern string) ([]match.Finding, int, error) {
+	paths, err := source.Glob(pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(paths) == 0 {
+		return nil, 0, fmt.Errorf("%w, no file matches %s", source.ErrLogNotFound, pattern)
+	}
+
+	matched := make(map[string]bool)
+	positions := make(map[string]source.Position)
+	var added []string
+	for _, p := range paths {
+		matched[p] = true
+		lf, ok := s.files[p]
+		if !ok {
+			lf = &logFile{src: &source.File{Path: p, Pattern: pattern},
+				state: state.NewFileAnchor(s.stateDir, s.service.Name, p, s.stateKey)}
+			s.files[p] = lf
+			if s.globbed {
+				log.Printf("Scanning new log file %s of %s\n", p, s.service.Name)
+				// A file created while osprey runs starts its anchor, which signed state otherwise refuses to.
+				if _, err := lf.state.Init(); err != nil {
+					log.Printf("Unable to create the anchor of %s of %s, %s\n", p, s.service.Name, err.Error())
+				}
+			}
+		}
+		pos, err := lf.state.LoadPosition()
+		if err != nil {
+			log.Printf("Unable to scan %s of %s, %s\n", p, s.service.Name, err.Error())
+			continue
+		}
+		positions[p] = pos
+		if pos == (source.Position{}) {
+			added = append(added, p)
+		}
+	}
+	// Files no longer matched are forgotten, their anchors are kept should they match again, unless they were
+	// deleted from a directory location.
+	dir := source.IsDir(s.service.Location)
+	for p, lf := range s.files {
+		if matched[p] {
+			continue
+		}
+		delete(s.files, p)
+		if _, err := os.Stat(p); dir && os.IsNotExist(err) && !s.dryRun {
+			if err := os.Remove(lf.state.Path); err != nil && !os.IsNotExist(err) {
+				log.Printf("Unable to remove the anchor of %s of %s, %s\n", p, s.service.Name, err.Error())
+			}
+			log.Printf("Log file %s of %s was deleted, its scanner is retired\n", p, s.service.Name)
+		}
+	}
+	s.globbed = true
+	for _, p := range added {
+		if from, ok := s.rotatedFrom(p, positions); ok {
+			positions[p] = positions[from]
+			log.Printf("%s of %s was rotated to %s, resuming it there\n", from, s.service.Name, p)
+		}
+	}
+
+	var findings []match.Finding
+	lines := 0
+	for _, p := range paths {
+		pos, ok := positions[p]
+		if !ok {
+			continue
+		}
+		lf := s.files[p]
+		// The findings read before an error are kept.
+		fs, n, err := s.scanFrom(ctx, lf.state, lf.src, pos)
+		if err != nil {
+			log.Printf("Unable to scan %s of %s, %s\n", p, s.service.Name, err.Error())
+		}
+		findings = append(findings, fs...)
+		lines += n
+	}
+
+	return findings, lines, nil
+}
+
+// rotatedFrom returns the path of the file whose anchor is of the file now at path, by its device and inode.
+func (s *Scanner) rotatedFrom(path string, positions map[string]source.Position) (string, bool) {
+	dev, ino, err := source.Identify(path)
+	if err != nil || (dev == 0 && ino == 0) {
+		return "", false
+	}
+	for p, pos := range positions {
+		if p != path && pos.Offset > 0 && pos.Device == dev && pos.Inode == ino {
+			return p, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRecentFindings is the default number of findings kept by a finding log.
+	DefaultRecentFindings = 100
+)
+
+// Finding is a log line matched by a scanner.
+type Finding struct {
+	// Time is when the line was matched.
+	Time time.Time `json:"time"`
+
+	// Service is the service whose log contains the line.
+	Service string `json:"service"`
+
+	// Line is the matched log line.
+	Line string `json:"line"`
+
+	// Severity is the severity of the line, if the service classifies it.
+	Severity string `json:"severity,omitempty"`
+
+	// IssueURL is the url of the issue created for the finding, if any.
+	IssueURL string `json:"issue_url,omitempty"`
+
+	// Error is why no issue was created for the finding, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// FindingLog keeps the most recent findings of all scanners in memory. A finding log is shared by all scanners.
+type FindingLog struct {
+	mu sync.Mutex
+
+	// size is the maximal number of findings kept.
+	size int
+
+	// findings holds findings from oldest to newest.
+	findings []Finding
+
+	// subs receive every added finding.
+	subs map[chan Finding]struct{}
+}
+
+// NewFindingLog returns a finding log keeping at most size findings.
+func NewFindingLog(size int) *FindingLog {
+	if size <= 0 {
+		size = DefaultRecentFindings
+	}
+
+	return &FindingLog{size: size}
+}
+
+// Add adds a finding, dropping the oldest one if the log is full.
+func (l *FindingLog) Add(f Finding) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.findings = append(l.findings, f)
+	if n := len(l.findings); n > l.size {
+		l.findings = append(l.findings[:0:0], l.findings[n-l.size:]...)
+	}
+
+	// Never block a scanner on a slow subscriber, drop the finding for it instead.
+	for ch := range l.subs {
+		select {
+		case ch <- f:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every finding added from now on, and a function to cancel the subscription.
+func (l *FindingLog) Subscribe() (<-chan Finding, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.subs == nil {
+		l.subs = make(map[chan Finding]struct{})
+	}
+	ch := make(chan Finding, l.size)
+	l.subs[ch] = struct{}{}
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subs, ch)
+	}
+}
+
+// Recent returns findings from newest to oldest, only for the given service if it is not empty.
+func (l *FindingLog) Recent(service string) []Finding {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var fs []Finding
+	for i := len(l.findings) - 1; i >= 0; i-- {
+		if service == "" || l.findings[i].Service == service {
+			fs = append(fs, l.findings[i])
+		}
+	}
+
+	return fs
+}
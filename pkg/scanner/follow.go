@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// followDelay is how long the scan of a written log file waits for more writes, so a burst of lines is scanned once.
+const followDelay = 200 * time.Millisecond
+
+// watched is a log file location watched for its scanner.
+type watched struct {
+	s *Scanner
+
+	// pattern is the path of the log file, or the glob pattern of its files.
+	pattern string
+
+	// ops are the changes of a file which queue a scan.
+	ops fsnotify.Op
+}
+
+// follow queues the scans of the scanners whose services follow their log files whenever the files are written or
+// created, and those of directory locations whenever a file is created or deleted in them, until ctx is done. It
+// watches the directories of the files, so a file created or replaced after osprey started, e.g. by log rotation,
+// is followed as well, or any file a glob pattern matches in its directory. Files which cannot be watched, e.g. those
+// of a pattern matching several directories, are scanned every interval only.
+func follow(ctx context.Context, scanners []*Scanner, queue chan *Scanner) {
+	var ws []watched
+	for _, s := range scanners {
+		svc := s.Service()
+		dir := source.IsDir(svc.Location)
+		if !svc.Follow && !dir {
+			continue
+		}
+		if svc.Source != "" && svc.Source != source.DefaultSource {
+			if svc.Follow {
+				log.Printf("Unable to follow log of %s, the %s source cannot be followed\n", svc.Name, svc.Source)
+			}
+			continue
+		}
+		pattern, _ := s.pattern()
+		w := watched{s: s, pattern: filepath.Clean(pattern), ops: fsnotify.Create}
+		if svc.Follow {
+			w.ops |= fsnotify.Write
+		}
+		if dir {
+			w.ops |= fsnotify.Remove | fsnotify.Rename
+		}
+		ws = append(ws, w)
+	}
+	if len(ws) == 0 {
+		return
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Unable to follow logs, scanning them every interval, %s\n", err.Error())
+		return
+	}
+	defer fw.Close()
+	dirs := make(map[string]bool)
+	for _, w := range ws {
+		dir := filepath.Dir(w.pattern)
+		if dirs[dir] {
+			continue
+		}
+		if source.IsPattern(dir) {
+			log.Printf("Unable to follow logs of %s, scanning them every interval, %s matches several directories\n",
+				w.s.Name(), w.pattern)
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			log.Printf("Unable to follow logs in %s, scanning them every interval, %s\n", dir, err.Error())
+			continue
+		}
+		dirs[dir] = true
+	}
+
+	// dirty are the scanners whose files changed since the last scans were queued, due when they are queued.
+	dirty := make(map[*Scanner]bool)
+	var due <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			s, ok := changed(ws, filepath.Clean(ev.Name), ev.Op)
+			if !ok {
+				continue
+			}
+			if len(dirty) == 0 {
+				due = time.After(followDelay)
+			}
+			dirty[s] = true
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Unable to follow logs, %s\n", err.Error())
+		case <-due:
+			due = nil
+			for s := range dirty {
+				delete(dirty, s)
+				if s.IsPaused() {
+					continue
+				}
+				select {
+				case queue <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// changed returns the scanner a change of a file queues, the one of its path or of a glob pattern matching it.
+func changed(ws []watched, name string, op fsnotify.Op) (*Scanner, bool) {
+	for _, w := range ws {
+		if op&w.ops == 0 {
+			continue
+		}
+		if w.pattern == name {
+			return w.s, true
+		}
+		if ok, _ := filepath.Match(w.pattern, name); ok && source.IsPattern(w.pattern) {
+			return w.s, true
+		}
+	}
+
+	return nil, false
+}
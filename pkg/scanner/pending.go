@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"log"
+	"time"
+)
+
+const (
+	// maxPending bounds the findings queued while the sink is rate limited or unavailable; the oldest are dropped
+	// first.
+	maxPending = 1000
+
+	// defaultRateLimitPause is how long deliveries pause after a rate limit of a sink not telling its reset.
+	defaultRateLimitPause = time.Minute
+
+	// unavailablePause is how long deliveries pause after the sink could not be reached.
+	unavailablePause = 30 * time.Second
+)
+
+// retryAt returns when a delivery failing with err may be made again: after the reset of a rate limit, or a pause if
+// the sink is unavailable. ok is false if err is neither, since a retry would fail the same way.
+func retryAt(err error) (time.Time, bool) {
+	if reset, ok := sink.RetryAt(err); ok {
+		return reset, true
+	}
+	if errors.Is(err, sink.ErrRateLimited) {
+		return time.Now().Add(defaultRateLimitPause), true
+	}
+	if errors.Is(err, sink.ErrSinkUnavailable) {
+		return time.Now().Add(unavailablePause), true
+	}
+
+	return time.Time{}, false
+}
+
+// queue keeps findings for delivery once the sink takes them again, rather than losing them.
+func (s *Scanner) queue(reset time.Time, findings ...match.Finding) {
+	if reset.After(s.pendingUntil) {
+		s.pendingUntil = reset
+	}
+	s.pending = append(s.pending, findings...)
+	if over := len(s.pending) - maxPending; over > 0 {
+		log.Printf("Too many findings of %s queued, dropping %d of them\n", s.service.Name, over)
+		s.statsd.Count(s.service.Name, "issues.failed", over)
+		s.pending = append([]match.Finding(nil), s.pending[over:]...)
+	}
+}
+
+// withPending returns the findings to deliver: the queued ones, oldest first, then found. While the sink's pause
+// lasts, found are queued as well and none are returned.
+func (s *Scanner) withPending(found []match.Finding, now time.Time) []match.Finding {
+	s.loadSpool()
+	if now.Before(s.pendingUntil) {
+		if len(found) > 0 {
+			s.queue(s.pendingUntil, found...)
+			log.Printf("%d findings of %s queued until %s, the sink is paused\n", len(s.pending),
+				s.service.Name, s.pendingUntil.Format(time.RFC3339))
+		}
+		return nil
+	}
+	if len(s.pending) == 0 {
+		return found
+	}
+
+	findings := append(s.pending, found...)
+	s.pending = nil
+	return findings
+}
+
+// loadSpool queues the findings spooled by a former run once, so they are delivered first.
+func (s *Scanner) loadSpool() {
+	if s.spool == nil || s.spoolLoaded {
+		return
+	}
+
+	findings, err := s.spool.Load()
+	if err != nil {
+		log.Printf("Unable to read spooled findings of %s, %s\n", s.service.Name, err.Error())
+		return
+	}
+	s.spoolLoaded, s.spooled = true, len(findings)
+	if len(findings) > 0 {
+		log.Printf("%d spooled findings of %s to deliver\n", len(findings), s.service.Name)
+		s.pending = append(findings, s.pending...)
+	}
+}
+
+// saveSpool saves the queued findings to the spool, if they changed since it was read or last saved.
+func (s *Scanner) saveSpool() {
+	if s.spool == nil || !s.spoolLoaded || len(s.pending) == 0 && s.spooled == 0 {
+		return
+	}
+
+	if err := s.spool.Save(s.pending); err != nil {
+		log.Printf("Unable to spool findings of %s, %s\n", s.service.Name, err.Error())
+		return
+	}
+	s.spooled = len(s.pending)
+}
@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"log"
+	"time"
+)
+
+// touch notes that the error of a fingerprint was seen, in issue number if it is known. It does nothing unless the
+// service closes the issues of quiet errors.
+func (s *Scanner) touch(fingerprint string, number int) {
+	if s.seen == nil || fingerprint == "" || !s.loadSeen() {
+		return
+	}
+
+	iss := s.seenIssues[fingerprint]
+	if number > 0 {
+		iss.Number = number
+	}
+	iss.LastSeen = time.Now()
+	s.seenIssues[fingerprint] = iss
+	if err := s.seen.Save(s.seenIssues); err != nil {
+		log.Printf("Unable to save seen errors of %s, %s\n", s.service.Name, err.Error())
+	}
+}
+
+// closeQuiet closes the issues of the errors not seen for the service's close_after duration, with a comment
+// noting the last occurrence.
+func (s *Scanner) closeQuiet(ctx context.Context) {
+	if s.seen == nil || !s.loadSeen() {
+		return
+	}
+
+	changed := false
+	for fp, iss := range s.seenIssues {
+		if time.Since(iss.LastSeen) < s.service.CloseAfter {
+			continue
+		}
+		if iss.Number > 0 {
+			comment := fmt.Sprintf(locale.For(s.service.Locale).Resolved, s.service.FormatTime(iss.LastSeen))
+			if err := s.pipeline.Resolve(ctx, iss.Number, comment); err != nil {
+				log.Printf("Unable to close issue #%d of %s, %s\n", iss.Number, s.service.Name, err.Error())
+				continue
+			}
+			log.Printf("Closed issue #%d of %s, its error was last seen at %s\n", iss.Number, s.service.Name,
+				s.service.FormatTime(iss.LastSeen))
+		}
+		delete(s.seenIssues, fp)
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := s.seen.Save(s.seenIssues); err != nil {
+		log.Printf("Unable to save seen errors of %s, %s\n", s.service.Name, err.Error())
+	}
+}
+
+// loadSeen reads the .seen file once, reporting whether the seen errors are available.
+func (s *Scanner) loadSeen() bool {
+	if s.seenIssues != nil {
+		return true
+	}
+
+	issues, err := s.seen.Load()
+	if err != nil {
+		log.Printf("Unable to read seen errors of %s, %s\n", s.service.Name, err.Error())
+		return false
+	}
+	s.seenIssues = issues
+	return true
+}
@@ -0,0 +1,20 @@
+package scanner
+
+// Report is the outcome of one scan of a service, as printed by osprey once and osprey check.
+type Report struct {
+	// Service is the service name.
+	Service string `json:"service"`
+
+	// LinesScanned is the number of new log lines read.
+	LinesScanned int `json:"lines_scanned"`
+
+	// Findings is the number of findings in those lines.
+	Findings int `json:"findings"`
+
+	// IssuesCreated is the number of issues filed, or that would be filed in a dry run. Findings blocked,
+	// suppressed or dropped by middleware are not counted.
+	IssuesCreated int `json:"issues_created"`
+
+	// Errors are the errors of the scan and of failed deliveries, empty if there were none.
+	Errors []string `json:"errors"`
+}
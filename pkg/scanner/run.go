@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run scans the scanners every interval with cap(queue) workers until ctx is done, then returns ctx.Err(). A scan
+// can be triggered between ticks by sending its scanner to queue; paused scanners are skipped on ticks only. The
+// scanners of services following their log files are also queued as soon as the files are written, and those of
+// directory locations as soon as a file is created or deleted in them.
+func Run(ctx context.Context, scanners []*Scanner, queue chan *Scanner, interval time.Duration) error {
+	workerN := cap(queue)
+	if workerN < 1 {
+		workerN = 1
+	}
+
+	// Start workers. Scanners are passed by pointer so their statistics survive between ticks.
+	for i := 1; i <= workerN; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case s := <-queue:
+					// execute the job
+					if err := s.Execute(ctx); err != nil {
+						log.Printf("%s.\n", err.Error())
+					}
+				}
+			}
+		}()
+	}
+
+	go follow(ctx, scanners, queue)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		// Push scanners to queue
+		for _, s := range scanners {
+			if s.IsPaused() {
+				continue
+			}
+			select {
+			case queue <- s:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,556 @@
+// Package scanner scans the log files of services for errors and files an issue for each error found. It is the
+// pipeline the osprey command runs, and can be embedded in other Go programs:
+//
+//	gh, err := sink.NewGitHub(ctx, ts)
+//	...
+//	s, err := scanner.New(svc, scanner.Deps{Sink: gh})
+//	...
+//	err = s.Execute(ctx)
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/pipeline"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scanner defines log file scanner.
+type Scanner struct {
+	// mu serializes the scans of this scanner, e.g. a scan triggered through the admin API and a scheduled one.
+	mu sync.Mutex
+
+	// service contains service log info.
+	service config.Service
+
+	// pipeline reads, matches and delivers the findings of the service.
+	pipeline *pipeline.Pipeline
+
+	// state is the .igu file of this service.
+	state *state.Anchor
+
+	// stateDir and stateKey are the directory and key of the state files.
+	stateDir string
+	stateKey []byte
+
+	// files are the log files of a service whose location is a glob pattern or a directory, by path. globbed is set
+	// once they were first matched, so the new ones are logged.
+	files   map[string]*logFile
+	globbed bool
+
+	// audit records every issue created by this scanner. An audit log is shared.
+	audit *state.AuditLog
+
+	// stats holds rolling statistics of this scanner.
+	stats *serviceStats
+
+	// slo tracks detection latency and scan success of this scanner.
+	slo *SLOTracker
+
+	// statsd pushes metrics of this scanner, if configured. A statsd client is shared.
+	statsd *telemetry.Statsd
+
+	// events receives the internal events of this scanner, if configured. An event stream is shared.
+	events *telemetry.EventStream
+
+	// bus delivers the internal events of this scanner to in-process subscribers. A bus is shared.
+	bus *telemetry.Bus
+
+	// findings keeps the recent findings of this scanner. A finding log is shared.
+	findings *FindingLog
+
+	// guard keeps leaked credentials out of issues. A secret guard is shared.
+	guard *SecretGuard
+
+	// hook decides whether a finding's issue is filed, nil if the service has no exec hook.
+	hook *execHook
+
+	// paused is set to 1 while the scanner is paused.
+	paused int32
+
+	// staleReported is set once a "logs stopped" issue is raised for the current silent period.
+	staleReported bool
+
+	// staleSince is the last write time of the log file when the "logs stopped" issue was raised.
+	staleSince time.Time
+
+	// seen is the .seen file of this service, nil unless the issues of quiet errors are closed.
+	seen *state.Seen
+
+	// seenIssues are the issues of seen, by fingerprint, nil until read.
+	seenIssues map[string]state.SeenIssue
+
+	// lastIssue is the number of the last issue filed or commented on, 0 if none yet.
+	lastIssue int
+
+	// backoff spaces out the scans while the log file is missing or unreadable.
+	backoff logBackoff
+
+	// pending are the findings queued while the sink is rate limited or unavailable, until pendingUntil.
+	pending      []match.Finding
+	pendingUntil time.Time
+
+	// spool keeps pending across restarts, nil in a dry run. spoolLoaded is set once it is read, spooled is the
+	// number of findings it holds.
+	spool       *state.Spool
+	spoolLoaded bool
+	spooled     int
+
+	// dryRun leaves the issues unfiled and the anchor where it is.
+	dryRun bool
+}
+
+// Deps holds what scanners share. Nil telemetry and audit are skipped.
+type Deps struct {
+	// Sink delivers the findings of services without a sink key. It is required unless every service selects a
+	// registered sink.
+	Sink sink.Sink
+
+	// Audit records every created issue, if set.
+	Audit *state.AuditLog
+
+	// Findings keeps the recent findings. A finding log of DefaultRecentFindings is created if nil.
+	Findings *FindingLog
+
+	// Statsd pushes metrics, if set.
+	Statsd *telemetry.Statsd
+
+	// Events receives the internal events, if set.
+	Events *telemetry.EventStream
+
+	// Bus delivers the internal events in-process. telemetry.DefaultBus is used if nil.
+	Bus *telemetry.Bus
+
+	// Guard keeps leaked credentials out of issues. The secret_guard config is used if nil.
+	Guard *SecretGuard
+
+	// SLO holds the SLO targets. The slo config is used if zero.
+	SLO SLOConfig
+
+	// StateDir is the directory of the .igu state files. state.dir is used if empty.
+	StateDir string
+
+	// StateKey signs the state files if set.
+	StateKey []byte
+
+	// DryRun scans without filing issues or moving the anchors, e.g. to check a config.
+	DryRun bool
+}
+
+// New creates the scanner of a service.
+func New(svc config.Service, d Deps) (*Scanner, error) {
+	p, err := pipeline.New(svc, d.Sink)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Findings == nil {
+		d.Findings = NewFindingLog(DefaultRecentFindings)
+	}
+	if d.Guard == nil {
+		guard, err := NewSecretGuard()
+		if err != nil {
+			return nil, err
+		}
+		d.Guard = guard
+	}
+	if d.SLO == (SLOConfig{}) {
+		d.SLO = ReadSLOConfig()
+	}
+	if d.StateDir == "" {
+		d.StateDir = config.StateDir()
+	}
+	if d.Bus == nil {
+		d.Bus = telemetry.DefaultBus
+	}
+
+	hook, err := newExecHook(svc.Name)
+	if err != nil {
+		return nil, err
+	}
+	var seen *state.Seen
+	if svc.CloseAfter > 0 {
+		seen = state.NewSeen(d.StateDir, svc.Name)
+	}
+	var spool *state.Spool
+	if !d.DryRun {
+		spool = state.NewSpool(d.StateDir, svc.Name)
+	}
+
+	return &Scanner{
+		service:  svc,
+		pipeline: p,
+		state:    state.NewAnchor(d.StateDir, svc.Name, d.StateKey),
+		stateDir: d.StateDir,
+		stateKey: d.StateKey,
+		files:    make(map[string]*logFile),
+		audit:    d.Audit,
+		stats:    &serviceStats{},
+		slo:      NewSLOTracker(d.SLO),
+		statsd:   d.Statsd,
+		events:   d.Events,
+		bus:      d.Bus,
+		findings: d.Findings,
+		guard:    d.Guard,
+		hook:     hook,
+		dryRun:   d.DryRun,
+		seen:     seen,
+		spool:    spool,
+	}, nil
+}
+
+// FromConfig creates a scanner for each service defined in config file. The guard and SLO targets are read once
+// and shared by all scanners.
+func FromConfig(d Deps) ([]*Scanner, error) {
+	svcs, err := config.Services()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Guard == nil {
+		guard, err := NewSecretGuard()
+		if err != nil {
+			return nil, err
+		}
+		d.Guard = guard
+	}
+	if d.Findings == nil {
+		d.Findings = NewFindingLog(DefaultRecentFindings)
+	}
+	if d.SLO == (SLOConfig{}) {
+		d.SLO = ReadSLOConfig()
+	}
+
+	var scanners []*Scanner
+	for _, svc := range svcs {
+		s, err := New(svc, d)
+		if err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, s)
+	}
+
+	return scanners, nil
+}
+
+// Name returns the service name.
+func (s *Scanner) Name() string {
+	return s.service.Name
+}
+
+// Service returns the service scanned.
+func (s *Scanner) Service() config.Service {
+	return s.service
+}
+
+// Status returns the current status of the scanner.
+func (s *Scanner) Status() Status {
+	st := s.stats.snapshot(s.service.Name)
+	st.Paused = s.IsPaused()
+	st.SLO = s.slo.Snapshot()
+
+	return st
+}
+
+// TotalFindings returns the number of findings since start.
+func (s *Scanner) TotalFindings() int {
+	return s.stats.totalFindings()
+}
+
+// SLO returns the SLO tracker of the scanner.
+func (s *Scanner) SLO() *SLOTracker {
+	return s.slo
+}
+
+// Execute executes the scanning job for the given service.
+func (s *Scanner) Execute(ctx context.Context) error {
+	_, err := s.Scan(ctx)
+	return err
+}
+
+// Scan executes the scanning job like Execute and reports its outcome.
+func (s *Scanner) Scan(ctx context.Context) (Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rep := Report{Service: s.service.Name, Errors: []string{}}
+
+	// A missing or unreadable log is retried with backoff instead of failing every tick.
+	if s.backoff.waiting(time.Now()) {
+		return rep, nil
+	}
+
+	start := time.Now()
+	s.emit(telemetry.Event{Type: telemetry.EventScanStarted, Service: s.service.Name})
+
+	findings, lines, scanErr := s.scan(ctx)
+	rep.LinesScanned, rep.Findings = lines, len(findings)
+	d := time.Since(start)
+	s.stats.observe(d, len(findings), scanErr)
+	s.slo.observeScan(scanErr == nil)
+	s.statsd.Count(s.service.Name, "scans", 1)
+	s.statsd.Timing(s.service.Name, "scan.duration", d)
+	s.statsd.Count(s.service.Name, "findings", len(findings))
+	failed := scanErr != nil
+	if failed {
+		s.statsd.Count(s.service.Name, "scan.failures", 1)
+		s.emit(telemetry.Event{Type: telemetry.EventScanFailed, Service: s.service.Name,
+			DurationMs: telemetry.DurationMs(d), Error: scanErr.Error()})
+		rep.Errors = append(rep.Errors, scanErr.Error())
+		// A missing log is retried with backoff rather than failing the scan.
+		if logUnavailable(scanErr) {
+			retry := s.backoff.fail(time.Now())
+			log.Printf("Unable to read log of %s, retrying in %s, %s\n", s.service.Name, retry, scanErr.Error())
+			scanErr = nil
+		}
+	} else {
+		if s.backoff.reset() {
+			log.Printf("Log of %s is readable again, scanning resumed\n", s.service.Name)
+		}
+		s.emit(telemetry.Event{Type: telemetry.EventScanFinished, Service: s.service.Name,
+			DurationMs: telemetry.DurationMs(d), Findings: len(findings)})
+	}
+
+	if n := len(findings); n > 0 {
+		log.Printf("%d new errors detected\n", n)
+	}
+	// Findings queued while the sink was rate limited or unavailable go first, and new ones wait while it still is.
+	// The queue is spooled, so a restart does not lose it.
+	if findings = s.withPending(findings, time.Now()); len(findings) > 0 {
+		budget, suppressed := s.issueBudget(ctx), 0
+		for i, f := range findings {
+			if budget == 0 {
+				s.suppress(f)
+				suppressed++
+				continue
+			}
+			filed, err := s.deliver(ctx, f, start)
+			if reset, ok := retryAt(err); ok {
+				s.queue(reset, findings[i:]...)
+				log.Printf("%d findings of %s queued until %s, %s\n", len(findings)-i, s.service.Name,
+					reset.Format(time.RFC3339), err.Error())
+				break
+			}
+			if filed {
+				rep.IssuesCreated++
+				budget--
+			}
+			if err != nil {
+				rep.Errors = append(rep.Errors, err.Error())
+			}
+		}
+		if suppressed > 0 {
+			s.summarizeSuppressed(ctx, suppressed)
+		}
+	}
+	s.saveSpool()
+
+	if !s.dryRun && !failed {
+		s.checkStale(ctx)
+		s.closeQuiet(ctx)
+	}
+
+	return rep, scanErr
+}
+
+// deliver creates the issue of a finding and records the outcome. start is when the scan which found it started.
+// It reports whether the issue was filed, or would be in a dry run, and the error of a failed delivery.
+func (s *Scanner) deliver(ctx context.Context, mf match.Finding, start time.Time) (bool, error) {
+	kinds, block := s.guard.inspect(&mf)
+	f := Finding{Time: time.Now(), Service: s.service.Name, Line: mf.Line, Severity: mf.Severity}
+	s.emit(telemetry.Event{Type: telemetry.EventFindingMatched, Service: s.service.Name, Line: f.Line})
+
+	if len(kinds) > 0 {
+		alert := secretAlert(kinds)
+		log.Printf("%s service: %s\n", alert, s.service.Name)
+		s.statsd.Count(s.service.Name, "secrets.detected", 1)
+		s.emit(telemetry.Event{Type: telemetry.EventSecretDetected, Service: s.service.Name, Line: f.Line,
+			Error: alert})
+		if block {
+			f.Error = "blocked, " + alert
+			s.findings.Add(f)
+			s.statsd.Count(s.service.Name, "issues.blocked", 1)
+			return false, nil
+		}
+	}
+
+	if !s.hook.allow(ctx, mf) {
+		f.Error = "suppressed by exec hook"
+		s.findings.Add(f)
+		s.statsd.Count(s.service.Name, "issues.suppressed", 1)
+		return false, nil
+	}
+
+	if s.dryRun {
+		f.Error = "not filed, dry run"
+		s.findings.Add(f)
+		return true, nil
+	}
+
+	dlv, err := s.pipeline.Deliver(ctx, mf)
+	if errors.Is(err, sink.ErrDropped) {
+		s.touch(mf.Fingerprint, 0)
+		f.Error = err.Error()
+		s.findings.Add(f)
+		s.statsd.Count(s.service.Name, "issues.dropped", 1)
+		return false, nil
+	}
+	if reset, ok := retryAt(err); ok {
+		f.Error = "queued until " + reset.Format(time.RFC3339) + ", " + err.Error()
+		s.findings.Add(f)
+		s.statsd.Count(s.service.Name, "issues.queued", 1)
+		s.emit(telemetry.Event{Type: telemetry.EventDeliveryFailed, Service: s.service.Name, Line: f.Line,
+			Error: f.Error})
+		return false, err
+	}
+	if err != nil {
+		log.Printf("%s\n", err.Error())
+		f.Error = err.Error()
+		s.findings.Add(f)
+		s.slo.observeMiss()
+		s.statsd.Count(s.service.Name, "issues.failed", 1)
+		s.emit(telemetry.Event{Type: telemetry.EventDeliveryFailed, Service: s.service.Name, Line: f.Line,
+			Error: err.Error()})
+		return false, err
+	}
+
+	// Lines without a timestamp are taken as logged when the scan started, a lower bound of the latency.
+	logged, ok := lineTime(f.Line)
+	if !ok {
+		logged = start
+	}
+	s.slo.observeDetection(time.Since(logged))
+	event, metric := telemetry.EventIssueCreated, "issues.created"
+	if dlv.Updated {
+		event, metric = telemetry.EventIssueUpdated, "issues.updated"
+	}
+	s.statsd.Count(s.service.Name, metric, 1)
+	s.statsd.Timing(s.service.Name, "detection_latency", time.Since(logged))
+
+	f.IssueURL = dlv.URL
+	if dlv.Number > 0 {
+		s.lastIssue = dlv.Number
+	}
+	s.findings.Add(f)
+	s.record(dlv, mf)
+	s.touch(mf.Fingerprint, dlv.Number)
+	s.emit(telemetry.Event{
+		Type:        event,
+		Service:     s.service.Name,
+		Line:        f.Line,
+		IssueNumber: dlv.Number,
+		IssueURL:    f.IssueURL,
+	})
+
+	return true, nil
+}
+
+// emit writes an event to the event stream and publishes it on the bus.
+func (s *Scanner) emit(ev telemetry.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	s.events.Emit(ev)
+	s.bus.Publish(ev)
+}
+
+// Pause stops the scanner from being scheduled until it is resumed.
+func (s *Scanner) Pause() {
+	atomic.StoreInt32(&s.paused, 1)
+}
+
+// Resume schedules a paused scanner again.
+func (s *Scanner) Resume() {
+	atomic.StoreInt32(&s.paused, 0)
+}
+
+// IsPaused reports whether the scanner is paused.
+func (s *Scanner) IsPaused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
+// record appends a delivered finding to the audit log, under the fingerprint it was delivered with.
+func (s *Scanner) record(dlv sink.Delivery, f match.Finding) {
+	if s.audit == nil {
+		return
+	}
+	// The fingerprint is that of the issue, so the record can be found by it; a finding of an enricher chain
+	// without the fingerprint enricher falls back to a hash of its line.
+	if f.Fingerprint == "" {
+		f.Fingerprint = state.Fingerprint(f.Line)
+	}
+
+	err := s.audit.Append(state.AuditRecord{
+		Time:        time.Now(),
+		Service:     s.service.Name,
+		Repo:        fmt.Sprintf("%s/%s", s.service.RepoOwner, s.service.RepoName),
+		Number:      dlv.Number,
+		URL:         dlv.URL,
+		Fingerprint: f.Fingerprint,
+	})
+	if err != nil {
+		log.Printf("Unable to write audit log, %s\n", err.Error())
+	}
+}
+
+// scan runs the logs from the last visited line to the end through the pipeline. It returns the findings and the
+// number of lines scanned.
+func (s *Scanner) scan(ctx context.Context) ([]match.Finding, int, error) {
+	// Hold the state file for the whole scan, so another osprey process cannot scan the same lines.
+	unlock, err := s.state.Lock()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	if pattern, ok := s.pattern(); ok {
+		return s.scanFiles(ctx, pattern)
+	}
+
+	// Read latest author info.
+	anchor, err := s.state.LoadPosition()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s.scanFrom(ctx, s.state, s.pipeline.Source, anchor)
+}
+
+// scanFrom runs the lines of a source after the anchor through the pipeline and saves the new anchor in its state
+// file. It returns the findings and the number of lines scanned. A read which fails after some lines still returns
+// their findings and saves the anchor they reached, along with the error, so they are neither lost nor filed twice.
+func (s *Scanner) scanFrom(ctx context.Context, a *state.Anchor, src source.Source,
+	anchor source.Position) ([]match.Finding, int, error) {
+	findings, newAnchor, err := s.pipeline.CollectSource(ctx, src, anchor)
+	// A source which failed before reading a line returns no position.
+	if err != nil && newAnchor == (source.Position{}) {
+		return findings, 0, err
+	}
+	lines := newAnchor.Line - anchor.Line
+	// A rotated or truncated log is read from the start.
+	if anchor.Offset > 0 && (lines < 0 || newAnchor.Device != anchor.Device || newAnchor.Inode != anchor.Inode) {
+		lines = newAnchor.Line
+	}
+	if lines < 0 {
+		lines = 0
+	}
+	// The anchor may also move back, clamped by the source to a truncated log.
+	if newAnchor != anchor && !s.dryRun {
+		if err := a.SavePosition(newAnchor); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return findings, lines, err
+}
@@ -0,0 +1,156 @@
+package scanner_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/scanner"
+	"github.com/NBCFB/Iguana2/pkg/state"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanDeliversFindings(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	src := ospreytest.NewSource("boot ok", "error: disk full")
+	rec := &ospreytest.Sink{}
+	s := ospreytest.NewScanner(t, config.Service{Name: "apple"}, src, rec)
+
+	tests := []struct {
+		name   string
+		append []string
+		want   []string
+	}{
+		{"first scan", nil, []string{"error: disk full"}},
+		{"nothing new", nil, nil},
+		{"new lines only", []string{"error: db timeout", "request ok"}, []string{"error: db timeout"}},
+	}
+	for _, tt := range tests {
+		rec.Reset()
+		src.Append(tt.append...)
+		if err := s.Execute(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := rec.Findings()
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %d findings, want %d", tt.name, len(got), len(tt.want))
+		}
+		for i, f := range got {
+			if f.Line != tt.want[i] || f.Service != "apple" {
+				t.Fatalf("%s: got %q of %s, want %q", tt.name, f.Line, f.Service, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestScanBlocksSecrets(t *testing.T) {
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{"", 0},
+		{"block", 0},
+		{"redact", 1},
+	}
+	for _, tt := range tests {
+		t.Run("mode "+tt.mode, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("secret_guard.mode", tt.mode)
+
+			token := "ghp_" + strings.Repeat("a", 36)
+			rec := &ospreytest.Sink{}
+			s := ospreytest.NewScanner(t, config.Service{Name: "apple"},
+				ospreytest.NewSource("error: auth "+token+" refused"), rec)
+			if err := s.Execute(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := rec.Findings()
+			if len(got) != tt.want {
+				t.Fatalf("got %d findings, want %d", len(got), tt.want)
+			}
+			for _, f := range got {
+				if strings.Contains(f.Line+f.Title+f.Body, token) {
+					t.Fatalf("the token was delivered in %+v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestScanAuditsFingerprint(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	rec := &ospreytest.Sink{}
+	audit := state.NewAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	s, err := scanner.New(config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple",
+		Source: ospreytest.RegisterSource(ospreytest.NewSource("12:00:01 error: db timeout"))},
+		scanner.Deps{Sink: rec, StateDir: t.TempDir(), Audit: audit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := audit.Query(state.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := rec.Findings()
+	if len(recs) != 1 || len(delivered) != 1 {
+		t.Fatalf("got %d records of %d findings, want 1", len(recs), len(delivered))
+	}
+	if recs[0].Fingerprint == "" || recs[0].Fingerprint != delivered[0].Fingerprint {
+		t.Fatalf("got fingerprint %q, want that of the issue, %q", recs[0].Fingerprint, delivered[0].Fingerprint)
+	}
+}
+
+// failingSource returns its lines along with err, as a log whose read fails after some lines.
+type failingSource struct {
+	lines []string
+	err   error
+}
+
+func (s *failingSource) Read(checkpoint int) ([]string, int, error) {
+	if checkpoint > len(s.lines) {
+		checkpoint = len(s.lines)
+	}
+	return s.lines[checkpoint:], len(s.lines), s.err
+}
+
+func TestScanDeliversFindingsBeforeReadError(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	src := &failingSource{lines: []string{"boot ok", "error: disk full"}, err: errors.New("connection reset")}
+	rec := &ospreytest.Sink{}
+	s := ospreytest.NewScanner(t, config.Service{Name: "apple"}, src, rec)
+
+	tests := []struct {
+		name string
+		err  error
+		ok   bool
+		want int
+	}{
+		{"failed read", src.err, false, 1},
+		// The anchor the failed read reached was saved, so its lines are not filed twice.
+		{"next read", nil, true, 0},
+	}
+	for _, tt := range tests {
+		rec.Reset()
+		src.err = tt.err
+		if err := s.Execute(context.Background()); (err == nil) != tt.ok {
+			t.Fatalf("%s: got %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if got := rec.Findings(); len(got) != tt.want {
+			t.Fatalf("%s: got %d findings, want %d", tt.name, len(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	secretGuardBlock  = "block"
+	secretGuardRedact = "redact"
+	secretGuardOff    = "off"
+)
+
+// secretPattern is a high-confidence secret pattern.
+type secretPattern struct {
+	// kind names the secret, e.g. github_token.
+	kind string
+
+	re *regexp.Regexp
+}
+
+// secretPatterns are the patterns checked before an issue is posted. They are kept specific, a match is very
+// likely a real credential.
+var secretPatterns = []secretPattern{
+	{kind: "private_key", re: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----|$)`)},
+	{kind: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`)},
+	{kind: "slack_token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{kind: "stripe_key", re: regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}`)},
+	{kind: "google_api_key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+}
+
+// SecretGuard checks issues for leaked credentials before they are posted. A guard is shared.
+type SecretGuard struct {
+	// mode is block (default) to not post such issues at all, redact to post them with placeholders, or off.
+	mode string
+}
+
+// NewSecretGuard creates the secret guard based on config file.
+func NewSecretGuard() (*SecretGuard, error) {
+	g := &SecretGuard{mode: viper.GetString("secret_guard.mode")}
+	switch g.mode {
+	case "":
+		g.mode = secretGuardBlock
+	case secretGuardBlock, secretGuardRedact, secretGuardOff:
+	default:
+		return nil, fmt.Errorf("unknown secret_guard.mode %q, use block, redact or off", g.mode)
+	}
+
+	return g, nil
+}
+
+// inspect checks the title and body of a finding, replacing every secret found with a placeholder. It returns the
+// kinds of secrets found and whether the issue must be blocked. The finding is modified in place, so a blocked one
+// can still be recorded locally without the secret.
+func (g *SecretGuard) inspect(f *match.Finding) (kinds []string, block bool) {
+	if g == nil || g.mode == secretGuardOff {
+		return nil, false
+	}
+
+	found := make(map[string]bool)
+	scrub := func(s *string) {
+		for _, p := range secretPatterns {
+			if p.re.MatchString(*s) {
+				found[p.kind] = true
+				*s = p.re.ReplaceAllString(*s, "[redacted "+p.kind+"]")
+			}
+		}
+	}
+	scrub(&f.Line)
+	scrub(&f.Title)
+	scrub(&f.Body)
+
+	for k := range found {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	return kinds, len(kinds) > 0 && g.mode == secretGuardBlock
+}
+
+// secretAlert describes the secrets found in an issue.
+func secretAlert(kinds []string) string {
+	return fmt.Sprintf("possible secret in issue (%s)", strings.Join(kinds, ", "))
+}
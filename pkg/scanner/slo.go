@@ -0,0 +1,277 @@
+package scanner
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/telemetry"
+	"github.com/spf13/viper"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSLOLatencyTarget = 5 * time.Minute
+	defaultSLOObjective     = 0.99
+	defaultSLOWindow        = 24 * time.Hour
+)
+
+// LatencyBuckets are the upper bounds in seconds of the detection latency histogram.
+var LatencyBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// logTimeLayouts are the timestamp layouts recognized at the start of a log line. Fractional seconds are
+// accepted by the parser even though the layouts do not spell them out.
+var logTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+// SLOConfig holds the service level objectives osprey reports on itself.
+type SLOConfig struct {
+	// LatencyTarget is how fast a logged error should reach github.
+	LatencyTarget time.Duration
+
+	// Objective is the fraction of findings and scans expected to be good, e.g. 0.99.
+	Objective float64
+
+	// Window is the rolling window SLO ratios are computed over.
+	Window time.Duration
+}
+
+// ReadSLOConfig reads the SLO settings from config file.
+func ReadSLOConfig() SLOConfig {
+	cfg := SLOConfig{
+		LatencyTarget: viper.GetDuration("slo.latency_target"),
+		Objective:     viper.GetFloat64("slo.objective"),
+		Window:        viper.GetDuration("slo.window"),
+	}
+	if cfg.LatencyTarget <= 0 {
+		cfg.LatencyTarget = defaultSLOLatencyTarget
+	}
+	if cfg.Objective <= 0 || cfg.Objective >= 1 {
+		cfg.Objective = defaultSLOObjective
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultSLOWindow
+	}
+
+	return cfg
+}
+
+// sloBucket counts good and total events within one minute.
+type sloBucket struct {
+	minute int64
+	good   int
+	total  int
+}
+
+// windowCounter counts good and total events over a rolling window, with one bucket per minute.
+type windowCounter struct {
+	window  time.Duration
+	buckets []sloBucket
+}
+
+// add counts an event.
+func (c *windowCounter) add(now time.Time, good bool) {
+	c.trim(now)
+
+	m := now.Unix() / 60
+	if n := len(c.buckets); n == 0 || c.buckets[n-1].minute != m {
+		c.buckets = append(c.buckets, sloBucket{minute: m})
+	}
+	b := &c.buckets[len(c.buckets)-1]
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// trim drops buckets outside the window.
+func (c *windowCounter) trim(now time.Time) {
+	oldest := now.Add(-c.window).Unix() / 60
+	i := 0
+	for i < len(c.buckets) && c.buckets[i].minute < oldest {
+		i++
+	}
+	c.buckets = c.buckets[i:]
+}
+
+// ratio returns the fraction of good events within the window, 1 if there are none.
+func (c *windowCounter) ratio(now time.Time) (float64, int) {
+	c.trim(now)
+
+	var good, total int
+	for _, b := range c.buckets {
+		good += b.good
+		total += b.total
+	}
+	if total == 0 {
+		return 1, 0
+	}
+
+	return float64(good) / float64(total), total
+}
+
+// SLOTracker tracks detection latency and scan success of a scanner against its objectives.
+type SLOTracker struct {
+	mu sync.Mutex
+
+	cfg SLOConfig
+
+	// scans counts successful scans within the window.
+	scans windowCounter
+
+	// detections counts findings which reached github within the latency target.
+	detections windowCounter
+
+	// latencyCounts is the cumulative detection latency histogram, one count per latency bucket plus +Inf.
+	latencyCounts []uint64
+
+	// latencySum is the sum of all observed detection latencies in seconds.
+	latencySum float64
+
+	// latencyCount is the number of observed detection latencies.
+	latencyCount uint64
+}
+
+// SLOStatus is a point-in-time view of a scanner's SLOs.
+type SLOStatus struct {
+	Objective           float64 `json:"objective"`
+	Window              string  `json:"window"`
+	LatencyTarget       string  `json:"latency_target"`
+	ScanSuccessRatio    float64 `json:"scan_success_ratio"`
+	ScanBurnRate        float64 `json:"scan_burn_rate"`
+	DetectionGoodRatio  float64 `json:"detection_good_ratio"`
+	DetectionBurnRate   float64 `json:"detection_burn_rate"`
+	DetectionsInWindow  int     `json:"detections_in_window"`
+	AvgDetectionLatency string  `json:"avg_detection_latency,omitempty"`
+}
+
+// NewSLOTracker returns a tracker for the given objectives.
+func NewSLOTracker(cfg SLOConfig) *SLOTracker {
+	return &SLOTracker{
+		cfg:           cfg,
+		scans:         windowCounter{window: cfg.Window},
+		detections:    windowCounter{window: cfg.Window},
+		latencyCounts: make([]uint64, len(LatencyBuckets)+1),
+	}
+}
+
+// observeScan records the outcome of a scan.
+func (t *SLOTracker) observeScan(ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.scans.add(time.Now(), ok)
+}
+
+// observeDetection records the latency from a line being logged to its issue being created.
+func (t *SLOTracker) observeDetection(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if latency < 0 {
+		latency = 0
+	}
+	t.detections.add(time.Now(), latency <= t.cfg.LatencyTarget)
+
+	sec := latency.Seconds()
+	t.latencySum += sec
+	t.latencyCount++
+	i := 0
+	for i < len(LatencyBuckets) && sec > LatencyBuckets[i] {
+		i++
+	}
+	t.latencyCounts[i]++
+}
+
+// observeMiss records a finding which failed to reach github.
+func (t *SLOTracker) observeMiss() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.detections.add(time.Now(), false)
+}
+
+// Snapshot returns the current SLO status.
+func (t *SLOTracker) Snapshot() SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	scanRatio, _ := t.scans.ratio(now)
+	detRatio, detN := t.detections.ratio(now)
+
+	st := SLOStatus{
+		Objective:          t.cfg.Objective,
+		Window:             t.cfg.Window.String(),
+		LatencyTarget:      t.cfg.LatencyTarget.String(),
+		ScanSuccessRatio:   scanRatio,
+		ScanBurnRate:       t.burnRate(scanRatio),
+		DetectionGoodRatio: detRatio,
+		DetectionBurnRate:  t.burnRate(detRatio),
+		DetectionsInWindow: detN,
+	}
+	if t.latencyCount > 0 {
+		avg := time.Duration(t.latencySum / float64(t.latencyCount) * float64(time.Second))
+		st.AvgDetectionLatency = avg.Truncate(time.Millisecond).String()
+	}
+
+	return st
+}
+
+// Histogram returns the detection latency histogram counts per bucket, the latency sum in seconds and the count.
+func (t *SLOTracker) Histogram() ([]uint64, float64, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]uint64(nil), t.latencyCounts...), t.latencySum, t.latencyCount
+}
+
+// burnRate returns how fast the error budget is consumed; 1 means exactly on budget.
+func (t *SLOTracker) burnRate(goodRatio float64) float64 {
+	return (1 - goodRatio) / (1 - t.cfg.Objective)
+}
+
+// lineTime returns the timestamp at the start of a log line, if it has one.
+func lineTime(line string) (time.Time, bool) {
+	fields := strings.Fields(strings.NewReplacer("[", " ", "]", " ").Replace(line))
+
+	var candidates []string
+	if len(fields) > 0 {
+		candidates = append(candidates, fields[0])
+	}
+	if len(fields) > 1 {
+		candidates = append(candidates, fields[0]+" "+fields[1])
+	}
+
+	for _, c := range candidates {
+		for _, layout := range logTimeLayouts {
+			if t, err := time.ParseInLocation(layout, c, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// LatencyTarget returns how fast a logged error should reach github.
+func (t *SLOTracker) LatencyTarget() time.Duration {
+	return t.cfg.LatencyTarget
+}
+
+// PushSLOGauges periodically pushes the SLO gauges of all scanners to statsd. It never returns.
+func PushSLOGauges(c *telemetry.Statsd, scanners []*Scanner) {
+	t := time.NewTicker(c.Interval())
+	for range t.C {
+		for _, s := range scanners {
+			slo := s.slo.Snapshot()
+			c.Gauge(s.service.Name, "slo.scan_success_ratio", slo.ScanSuccessRatio)
+			c.Gauge(s.service.Name, "slo.scan_burn_rate", slo.ScanBurnRate)
+			c.Gauge(s.service.Name, "slo.detection_good_ratio", slo.DetectionGoodRatio)
+			c.Gauge(s.service.Name, "slo.detection_burn_rate", slo.DetectionBurnRate)
+		}
+	}
+}
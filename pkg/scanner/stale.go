@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"os"
+	"time"
+)
+
+// checkStale raises a "logs stopped" issue once the log file has not been written for the service's stale_after
+// duration. Only one issue is raised per silent period; the check re-arms as soon as the file is written again. A
+// failed or rate limited delivery is tried again on the next scan.
+func (s *Scanner) checkStale(ctx context.Context) {
+	if s.service.StaleAfter <= 0 {
+		return
+	}
+
+	lastWrite, err := s.lastWrite()
+	if err != nil {
+		// A missing or unreadable file is already reported as a scan error.
+		return
+	}
+
+	if lastWrite.After(s.staleSince) {
+		s.staleReported = false
+	}
+
+	silent := time.Since(lastWrite)
+	if silent < s.service.StaleAfter || s.staleReported {
+		return
+	}
+
+	t := staleTitle(s.service)
+	body := fmt.Sprintf(locale.For(s.service.Locale).LogsStopped, s.service.Location, silent.Truncate(time.Second),
+		s.service.FormatTime(lastWrite))
+	if _, err := s.deliver(ctx, match.Finding{Service: s.service.Name, Line: body, Title: t, Body: body,
+		Labels: s.service.Labels}, time.Now()); err != nil {
+		return
+	}
+
+	s.staleReported = true
+	s.staleSince = lastWrite
+}
+
+// lastWrite returns the last write time of the log file, the latest of the files of a pattern or directory.
+func (s *Scanner) lastWrite() (time.Time, error) {
+	paths := []string{s.service.Location}
+	if pattern, ok := s.pattern(); ok {
+		var err error
+		if paths, err = source.Glob(pattern); err != nil {
+			return time.Time{}, err
+		}
+	}
+	var last time.Time
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	if last.IsZero() {
+		return last, os.ErrNotExist
+	}
+
+	return last, nil
+}
+
+// staleTitle returns the "logs stopped" issue title of a service.
+func staleTitle(svc config.Service) string {
+	return fmt.Sprintf("%s-logs-stopped-%s", svc.Name, svc.FormatTime(time.Now()))
+}
@@ -0,0 +1,46 @@
+package scanner_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaleIssueRetriedAfterFailedDelivery(t *testing.T) {
+	loc := filepath.Join(t.TempDir(), "apple.log")
+	if err := os.WriteFile(loc, []byte("boot ok\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(loc, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &ospreytest.Sink{Err: errors.New("tracker is down")}
+	s := ospreytest.NewScanner(t, config.Service{Name: "apple", Location: loc, StaleAfter: time.Hour},
+		ospreytest.NewSource("boot ok"), rec)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"failed delivery", errors.New("tracker is down"), 0},
+		{"retried", nil, 1},
+		{"reported once per silent period", nil, 1},
+	}
+	for _, tt := range tests {
+		rec.Err = tt.err
+		if _, err := s.Scan(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := len(rec.Findings()); got != tt.want {
+			t.Fatalf("%s: got %d stale issues, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package scanner
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	findingsWindow = time.Hour
+)
+
+// serviceStats holds rolling statistics of a scanner.
+type serviceStats struct {
+	mu sync.Mutex
+
+	// scans is the number of completed scans.
+	scans int
+
+	// failures is the number of scans which returned an error.
+	failures int
+
+	// totalDuration is the sum of all scan durations.
+	totalDuration time.Duration
+
+	// findings holds the time of every finding within the findings window.
+	findings []time.Time
+
+	// findingsTotal is the number of findings since start.
+	findingsTotal int
+
+	// lastScan is when the last scan finished.
+	lastScan time.Time
+
+	// lastSuccess is when the last successful scan finished.
+	lastSuccess time.Time
+
+	// lastError is the error of the last failed scan.
+	lastError string
+
+	// lastErrorKind is the kind of lastError, empty if it has none.
+	lastErrorKind string
+
+	// lastErrorTime is when the last failed scan finished.
+	lastErrorTime time.Time
+}
+
+// Health of a service, as reported in Status.Health.
+const (
+	HealthUnknown       = "unknown"
+	HealthOK            = "ok"
+	HealthWaitingForLog = "waiting_for_log"
+	HealthLogUnreadable = "log_unreadable"
+	HealthFailing       = "failing"
+)
+
+// Status is a point-in-time view of a scanner's statistics.
+type Status struct {
+	Name          string    `json:"name"`
+	Paused        bool      `json:"paused"`
+	Health        string    `json:"health"`
+	Scans         int       `json:"scans"`
+	Failures      int       `json:"failures"`
+	AvgDuration   string    `json:"avg_duration"`
+	FindingsHour  int       `json:"findings_last_hour"`
+	Lag           string    `json:"lag"`
+	LastScan      time.Time `json:"last_scan"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorKind string    `json:"last_error_kind,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+	SLO           SLOStatus `json:"slo"`
+
+	// avgDuration and lag back AvgDuration and Lag for the gRPC API. lag is negative if never scanned.
+	avgDuration time.Duration
+	lag         time.Duration
+}
+
+// AverageDuration returns the average scan duration.
+func (st Status) AverageDuration() time.Duration {
+	return st.avgDuration
+}
+
+// LagDuration returns how long ago the log was last scanned successfully, negative if it never was.
+func (st Status) LagDuration() time.Duration {
+	return st.lag
+}
+
+// observe records a finished scan.
+func (st *serviceStats) observe(d time.Duration, findings int, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	st.scans++
+	st.totalDuration += d
+	st.lastScan = now
+	for i := 0; i < findings; i++ {
+		st.findings = append(st.findings, now)
+	}
+	st.findingsTotal += findings
+	st.trim(now)
+
+	if err != nil {
+		st.failures++
+		st.lastError = err.Error()
+		st.lastErrorKind = ErrorKind(err)
+		st.lastErrorTime = now
+		return
+	}
+	st.lastSuccess = now
+}
+
+// health returns the health of the service from its last scan.
+func (st *serviceStats) health() string {
+	switch {
+	case st.scans == 0:
+		return HealthUnknown
+	case !st.lastErrorTime.After(st.lastSuccess):
+		return HealthOK
+	case st.lastErrorKind == KindLogNotFound:
+		return HealthWaitingForLog
+	case st.lastErrorKind == KindLogUnreadable:
+		return HealthLogUnreadable
+	default:
+		return HealthFailing
+	}
+}
+
+// totalFindings returns the number of findings since start.
+func (st *serviceStats) totalFindings() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.findingsTotal
+}
+
+// trim drops findings older than the findings window.
+func (st *serviceStats) trim(now time.Time) {
+	i := 0
+	for i < len(st.findings) && now.Sub(st.findings[i]) > findingsWindow {
+		i++
+	}
+	st.findings = st.findings[i:]
+}
+
+// snapshot returns the current status of a service.
+func (st *serviceStats) snapshot(name string) Status {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	st.trim(now)
+
+	status := Status{
+		Name:          name,
+		Scans:         st.scans,
+		Failures:      st.failures,
+		FindingsHour:  len(st.findings),
+		LastScan:      st.lastScan,
+		LastError:     st.lastError,
+		LastErrorKind: st.lastErrorKind,
+		LastErrorTime: st.lastErrorTime,
+		Health:        st.health(),
+		lag:           -1,
+	}
+	if st.scans > 0 {
+		status.avgDuration = st.totalDuration / time.Duration(st.scans)
+		status.AvgDuration = status.avgDuration.String()
+	}
+	// Lag is how long ago the log was last scanned successfully.
+	if !st.lastSuccess.IsZero() {
+		status.lag = now.Sub(st.lastSuccess)
+		status.Lag = status.lag.Truncate(time.Second).String()
+	}
+
+	return status
+}
+
+// Statuses returns the status of all scanners sorted by service name.
+func Statuses(scanners []*Scanner) []Status {
+	var sts []Status
+	for _, s := range scanners {
+		sts = append(sts, s.Status())
+	}
+	sort.Slice(sts, func(i, j int) bool { return sts[i].Name < sts[j].Name })
+
+	return sts
+}
@@ -0,0 +1,246 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/aws"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxSNSSubject bounds the subject of an SNS message, which email subscriptions get.
+const maxSNSSubject = 100
+
+func init() {
+	Register("sns", share(func() (Sink, error) { return NewSNS() }))
+	Register("sqs", share(func() (Sink, error) { return NewSQS() }))
+}
+
+// awsClients are the AWS clients of a sink by region, created on first use.
+type awsClients struct {
+	hc *http.Client
+
+	mu sync.Mutex
+
+	clients map[string]*aws.Client
+}
+
+// newAWSClients returns the clients of a sink, calling AWS through the proxy and the TLS settings of tls.aws.
+func newAWSClients() (*awsClients, error) {
+	hc, err := transport.NewHTTPClient("aws", aws.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &awsClients{hc: hc, clients: make(map[string]*aws.Client)}, nil
+}
+
+// client returns the client of a region.
+func (a *awsClients) client(region string) *aws.Client {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if c, ok := a.clients[region]; ok {
+		return c
+	}
+	c := aws.NewClient(region, a.hc)
+	a.clients[region] = c
+
+	return c
+}
+
+// awsError wraps the error of an AWS call with ErrRateLimited or ErrSinkUnavailable if it is of those kinds: a
+// throttled or failed request, or one that could not be sent.
+func awsError(err error) error {
+	var apiErr *aws.APIError
+	switch {
+	case err == nil:
+		return nil
+	case !errors.As(err, &apiErr):
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	case apiErr.StatusCode == http.StatusBadRequest && strings.Contains(apiErr.Error(), "Throttl"):
+		return fmt.Errorf("%w, %w", ErrRateLimited, err)
+	}
+
+	return httpError(apiErr.StatusCode, err)
+}
+
+// messageAttributes adds the string attributes of a finding, its service and severity, to the params of a call
+// naming the n-th attribute with prefix, e.g. MessageAttribute.%d.
+func messageAttributes(params url.Values, prefix string, svc config.Service, f match.Finding) {
+	attrs := [][2]string{{"service", svc.Name}, {"severity", f.Severity}, {"fingerprint", f.Fingerprint}}
+	n := 0
+	for _, a := range attrs {
+		if a[1] == "" {
+			continue
+		}
+		n++
+		p := fmt.Sprintf(prefix, n)
+		params.Set(p+".Name", a[0])
+		params.Set(p+".Value.DataType", "String")
+		params.Set(p+".Value.StringValue", a[1])
+	}
+}
+
+// fifo sets the message group of a message to a FIFO topic or queue, the service, and its deduplication ID, the
+// hash of its body.
+func fifo(params url.Values, svc config.Service, body []byte) {
+	sum := sha256.Sum256(body)
+	params.Set("MessageGroupId", svc.Name)
+	params.Set("MessageDeduplicationId", hex.EncodeToString(sum[:]))
+}
+
+// SNS publishes findings as JSON events, like the webhook sink's default documents, to the SNS topic of the
+// service's sns.topic_arn:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, sns]
+//	    sns:
+//	      topic_arn: arn:aws:sns:eu-west-1:123456789012:osprey-findings
+//
+// The messages have the service, severity and fingerprint as attributes, for subscription filter policies. The
+// credentials are those of the aws secret providers: the environment, the ECS task role or the EC2 instance role.
+type SNS struct {
+	clients *awsClients
+
+	// guard refuses deliveries while SNS is throttling or down.
+	guard *guard
+}
+
+// NewSNS returns the SNS sink of config file.
+func NewSNS() (*SNS, error) {
+	clients, err := newAWSClients()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SNS{clients: clients, guard: newGuard("sns")}, nil
+}
+
+// Deliver implements Sink.
+func (s *SNS) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	arn := setting(svc, "sns.topic_arn")
+	tks := strings.Split(arn, ":")
+	if len(tks) < 6 || tks[0] != "arn" {
+		return Delivery{}, fmt.Errorf("sns sink of %s needs the topic arn sns.topic_arn", svc.Name)
+	}
+	body, err := json.Marshal(newFindingEvent(svc, f))
+	if err != nil {
+		return Delivery{}, err
+	}
+	params := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {arn},
+		"Message":  {string(body)},
+	}
+	if subject := snsSubject(f.Title); subject != "" {
+		params.Set("Subject", subject)
+	}
+	messageAttributes(params, "MessageAttributes.entry.%d", svc, f)
+	if strings.HasSuffix(arn, ".fifo") {
+		fifo(params, svc, body)
+	}
+
+	if err := s.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err = awsError(s.clients.client(tks[3]).CallQuery(ctx, "sns", setting(svc, "sns.endpoint"), params, nil))
+	s.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// snsSubject returns a title as the subject of an SNS message, which must be printable ASCII of up to 100
+// characters.
+func snsSubject(title string) string {
+	var b strings.Builder
+	for _, r := range title {
+		if b.Len() == maxSNSSubject {
+			break
+		}
+		if r >= ' ' && r <= '~' {
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// SQS sends findings as JSON events, like the webhook sink's default documents, to the SQS queue of the service's
+// sqs.queue_url:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, sqs]
+//	    sqs:
+//	      queue_url: https://sqs.eu-west-1.amazonaws.com/123456789012/osprey-findings
+//
+// The messages have the service, severity and fingerprint as attributes. The credentials are those of the SNS sink.
+type SQS struct {
+	clients *awsClients
+
+	// guard refuses deliveries while SQS is throttling or down.
+	guard *guard
+}
+
+// NewSQS returns the SQS sink of config file.
+func NewSQS() (*SQS, error) {
+	clients, err := newAWSClients()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQS{clients: clients, guard: newGuard("sqs")}, nil
+}
+
+// Deliver implements Sink.
+func (s *SQS) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	queue := setting(svc, "sqs.queue_url")
+	u, err := url.Parse(queue)
+	if queue == "" || err != nil || u.Host == "" {
+		return Delivery{}, fmt.Errorf("sqs sink of %s needs the queue url sqs.queue_url", svc.Name)
+	}
+	body, err := json.Marshal(newFindingEvent(svc, f))
+	if err != nil {
+		return Delivery{}, err
+	}
+	params := url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {"2012-11-05"},
+		"QueueUrl":    {queue},
+		"MessageBody": {string(body)},
+	}
+	messageAttributes(params, "MessageAttribute.%d", svc, f)
+	if strings.HasSuffix(u.Path, ".fifo") {
+		fifo(params, svc, body)
+	}
+
+	region := viper.GetString("aws.region")
+	if tks := strings.Split(u.Host, "."); len(tks) > 3 && tks[0] == "sqs" {
+		region = tks[1]
+	}
+	endpoint := setting(svc, "sqs.endpoint")
+	if endpoint == "" {
+		endpoint = u.Scheme + "://" + u.Host + "/"
+	}
+
+	if err := s.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err = awsError(s.clients.client(region).CallQuery(ctx, "sqs", endpoint, params, nil))
+	s.guard.record(err)
+
+	return Delivery{}, err
+}
@@ -0,0 +1,152 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// awsConfig sets the AWS credentials of the environment and the config of service apple, calling a fake AWS API.
+func awsConfig(t *testing.T, conf map[string]string) *apiServer {
+	t.Helper()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDOSPREY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "s3cret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	for k, v := range conf {
+		viper.Set(config.Key("apple", k), strings.Replace(v, "$URL", srv.URL, 1))
+	}
+
+	return srv
+}
+
+// decodeQuery decodes the params of an AWS Query protocol call.
+func decodeQuery(t *testing.T, c apiCall) url.Values {
+	t.Helper()
+
+	params, err := url.ParseQuery(c.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return params
+}
+
+func TestSNSDeliver(t *testing.T) {
+	tests := []struct {
+		name string
+		arn  string
+		fifo bool
+	}{
+		{"standard topic", "arn:aws:sns:eu-west-1:123456789012:osprey", false},
+		{"fifo topic", "arn:aws:sns:eu-west-1:123456789012:osprey.fifo", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := awsConfig(t, map[string]string{"sns.topic_arn": tt.arn, "sns.endpoint": "$URL/"})
+			srv.handle("POST /", http.StatusOK, "<PublishResponse/>")
+			s, err := sink.NewSNS()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{
+				Title: "db timeout\n", Severity: "error", Fingerprint: "f00d"}); err != nil {
+				t.Fatal(err)
+			}
+			call := srv.call(t, "POST /")
+			if got := call.header.Get("Authorization"); !strings.Contains(got, "AKIDOSPREY/") ||
+				!strings.Contains(got, "/eu-west-1/sns/aws4_request") {
+				t.Fatalf("got authorization %q, want it signed for sns in the region of the topic", got)
+			}
+			params := decodeQuery(t, call)
+			if params.Get("Action") != "Publish" || params.Get("TopicArn") != tt.arn || params.Get("Subject") != "db timeout" ||
+				!strings.Contains(params.Get("Message"), `"fingerprint":"f00d"`) {
+				t.Fatalf("got %v, want the finding published to the topic", params)
+			}
+			if got := params.Get("MessageAttributes.entry.2.Value.StringValue"); got != "error" {
+				t.Fatalf("got severity attribute %q, want error", got)
+			}
+			if got := params.Get("MessageGroupId") == "apple"; got != tt.fifo {
+				t.Fatalf("got message group %q, want one %v", params.Get("MessageGroupId"), tt.fifo)
+			}
+		})
+	}
+}
+
+func TestSQSDeliver(t *testing.T) {
+	srv := awsConfig(t, map[string]string{"sqs.queue_url": "https://sqs.eu-west-1.amazonaws.com/123456789012/osprey",
+		"sqs.endpoint": "$URL/"})
+	srv.handle("POST /", http.StatusOK, "<SendMessageResponse/>")
+	s, err := sink.NewSQS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Deliver(context.Background(), config.Service{Name: "apple"},
+		match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	call := srv.call(t, "POST /")
+	if got := call.header.Get("Authorization"); !strings.Contains(got, "/eu-west-1/sqs/aws4_request") {
+		t.Fatalf("got authorization %q, want it signed for sqs in the region of the queue", got)
+	}
+	params := decodeQuery(t, call)
+	if params.Get("Action") != "SendMessage" || !strings.Contains(params.Get("MessageBody"), `"title":"db timeout"`) {
+		t.Fatalf("got %v, want the finding sent to the queue", params)
+	}
+	if got := params.Get("MessageAttribute.1.Value.StringValue"); got != "apple" {
+		t.Fatalf("got service attribute %q, want apple", got)
+	}
+}
+
+func TestAWSErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		sns    bool
+		conf   map[string]string
+		status int
+		kind   error
+	}{
+		{"sns needs a topic", true, map[string]string{"sns.topic_arn": "osprey"}, http.StatusOK, nil},
+		{"sqs needs a queue", false, map[string]string{"sqs.queue_url": "osprey"}, http.StatusOK, nil},
+		{"throttled", true, map[string]string{"sns.topic_arn": "arn:aws:sns:eu-west-1:123456789012:osprey",
+			"sns.endpoint": "$URL/"}, http.StatusBadRequest, sink.ErrRateLimited},
+		{"down", false, map[string]string{"sqs.queue_url": "https://sqs.eu-west-1.amazonaws.com/123456789012/osprey",
+			"sqs.endpoint": "$URL/"}, http.StatusServiceUnavailable, sink.ErrSinkUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := awsConfig(t, tt.conf)
+			srv.handle("POST /", tt.status, "<ErrorResponse><Error><Code>Throttling</Code></Error></ErrorResponse>")
+			var s sink.Sink
+			var err error
+			if tt.sns {
+				s, err = sink.NewSNS()
+			} else {
+				s, err = sink.NewSQS()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{Title: "db timeout"})
+			if err == nil {
+				t.Fatal("got nil, want an error")
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
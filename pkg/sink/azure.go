@@ -0,0 +1,318 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SecretAzureDevOpsToken is the name of the azure devops personal access token secret, read for services
+	// without a token of their own.
+	SecretAzureDevOpsToken = "azure_devops_token"
+
+	defaultAzureDevOpsURL = "https://dev.azure.com/"
+
+	defaultAzureWorkItemType = "Bug"
+
+	// azureAPIVersion is the version of the azure devops REST API called, supported since Azure DevOps Server 2022.
+	azureAPIVersion = "7.0"
+)
+
+// azureClosedStates are the work item states of resolved work items in the built-in processes.
+var azureClosedStates = []string{"Closed", "Done", "Resolved", "Removed"}
+
+// hiddenComments are the hidden comments osprey keeps in issue bodies.
+var hiddenComments = regexp.MustCompile(`<!-- osprey:[^>]*-->`)
+
+func init() {
+	Register("azure_devops", shareTracker("azure_devops", func() (Tracker, error) { return NewAzureDevOps() }))
+}
+
+// AzureDevOps is a Tracker filing work items, bugs by default, in Azure Boards projects, of Azure DevOps Services
+// or of an Azure DevOps Server whose collection url azure_devops.base_url in config file names:
+//
+//	azure_devops:
+//	  organization: acme
+//	services:
+//	  apple:
+//	    tracker: azure_devops
+//	    azure_devops:
+//	      project: Apple
+//	      item_type: Bug
+//
+// The token is a personal access token with the work items read and write scope, that of the service's token keys
+// or the azure_devops_token secret of the credentials provider.
+type AzureDevOps struct {
+	api *restClient
+
+	tokens *trackerTokens
+
+	// closeState and reopenState are the states work items are closed and reopened with.
+	closeState, reopenState string
+}
+
+// NewAzureDevOps returns the azure devops tracker of config file.
+func NewAzureDevOps() (*AzureDevOps, error) {
+	a := &AzureDevOps{
+		tokens:      &trackerTokens{tracker: "azure_devops", secret: SecretAzureDevOpsToken},
+		closeState:  viper.GetString("azure_devops.close_state"),
+		reopenState: viper.GetString("azure_devops.reopen_state"),
+	}
+	if a.closeState == "" {
+		a.closeState = "Closed"
+	}
+	if a.reopenState == "" {
+		a.reopenState = "Active"
+	}
+	base := viper.GetString("azure_devops.base_url")
+	if base == "" {
+		base = defaultAzureDevOpsURL
+	}
+	api, err := newRESTClient("azure_devops", base, a.authorize)
+	if err != nil {
+		return nil, err
+	}
+	a.api = api
+
+	return a, nil
+}
+
+// authorize sets the token of the service on a request.
+func (a *AzureDevOps) authorize(req *http.Request, svc config.Service) error {
+	tk, err := a.tokens.token(svc)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("", tk)
+
+	return nil
+}
+
+// azurePatch is a JSON patch of a work item's fields.
+type azurePatch []azureOp
+
+// azureOp is an operation of a JSON patch.
+type azureOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// ContentType implements contentTyper.
+func (azurePatch) ContentType() string {
+	return "application/json-patch+json"
+}
+
+// set returns the patch setting a field as well.
+func (p azurePatch) set(field string, value interface{}) azurePatch {
+	return append(p, azureOp{Op: "add", Path: "/fields/" + field, Value: value})
+}
+
+// azureWorkItem is a work item of the azure devops API.
+type azureWorkItem struct {
+	ID     int                    `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// project returns the path of the service's project in the API, or an error if it has none.
+func (a *AzureDevOps) project(svc config.Service) (string, error) {
+	org := viper.GetString("azure_devops.organization")
+	p := viper.GetString(config.Key(svc.Name, "azure_devops.project"))
+	if org == "" || p == "" {
+		return "", fmt.Errorf("azure devops tracker of %s needs azure_devops.organization and azure_devops.project",
+			svc.Name)
+	}
+
+	return url.PathEscape(org) + "/" + url.PathEscape(p), nil
+}
+
+// workItemType returns the type of the service's work items.
+func workItemType(svc config.Service) string {
+	if t := viper.GetString(config.Key(svc.Name, "azure_devops.item_type")); t != "" {
+		return t
+	}
+
+	return defaultAzureWorkItemType
+}
+
+// bodyField returns the field of the body of the service's work items: the repro steps of bugs, the description of
+// other types.
+func bodyField(svc config.Service) string {
+	if strings.EqualFold(workItemType(svc), "Bug") {
+		return "Microsoft.VSTS.TCM.ReproSteps"
+	}
+
+	return "System.Description"
+}
+
+// ticket returns the work item as a Ticket.
+func (a *AzureDevOps) ticket(svc config.Service, project string, wi azureWorkItem) Ticket {
+	str := func(field string) string {
+		s, _ := wi.Fields[field].(string)
+		return s
+	}
+	t := Ticket{Number: wi.ID, URL: a.editURL(project, wi.ID), Title: str("System.Title"),
+		Body: str(bodyField(svc))}
+	for _, s := range azureClosedStates {
+		if strings.EqualFold(str("System.State"), s) {
+			t.Closed = true
+		}
+	}
+
+	return t
+}
+
+// editURL returns the url of a work item in the azure devops UI.
+func (a *AzureDevOps) editURL(project string, id int) string {
+	u, err := a.api.base.Parse(project + "/_workitems/edit/" + strconv.Itoa(id))
+	if err != nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+// azureHTML returns markdown text as the HTML the fields take, preformatted, keeping the hidden comments of osprey.
+func azureHTML(text string) string {
+	hidden := hiddenComments.FindAllString(text, -1)
+	text = strings.TrimRight(hiddenComments.ReplaceAllString(text, ""), "\n")
+
+	return "<pre>" + html.EscapeString(text) + "</pre>" + strings.Join(hidden, "")
+}
+
+// Create implements Tracker. The labels become tags and the work item is assigned to the first assignee, e.g. an
+// email; milestones are not supported.
+func (a *AzureDevOps) Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error) {
+	p, err := a.project(svc)
+	if err != nil {
+		return Ticket{}, err
+	}
+	patch := azurePatch{}.set("System.Title", iss.Title).set(bodyField(svc), azureHTML(iss.Body))
+	if len(iss.Labels) > 0 {
+		patch = patch.set("System.Tags", strings.Join(iss.Labels, "; "))
+	}
+	if len(iss.Assignees) > 0 {
+		patch = patch.set("System.AssignedTo", iss.Assignees[0])
+	}
+	path := fmt.Sprintf("%s/_apis/wit/workitems/$%s?api-version=%s", p, url.PathEscape(workItemType(svc)),
+		azureAPIVersion)
+	var created azureWorkItem
+	if _, err := a.api.do(ctx, svc, http.MethodPost, path, patch, &created); err != nil {
+		return Ticket{}, err
+	}
+
+	return a.ticket(svc, p, created), nil
+}
+
+// query returns the IDs of the service's work items matching a WIQL condition, the most recently changed first.
+func (a *AzureDevOps) query(ctx context.Context, svc config.Service, cond string, top int) ([]int, error) {
+	p, err := a.project(svc)
+	if err != nil {
+		return nil, err
+	}
+	wiql := fmt.Sprintf("SELECT [System.Id] FROM WorkItems WHERE [System.TeamProject] = @project "+
+		"AND [System.WorkItemType] = '%s' AND %s ORDER BY [System.ChangedDate] DESC",
+		strings.Replace(workItemType(svc), "'", "''", -1), cond)
+	path := fmt.Sprintf("%s/_apis/wit/wiql?api-version=%s", p, azureAPIVersion)
+	if top > 0 {
+		path += "&$top=" + strconv.Itoa(top)
+	}
+	var res struct {
+		WorkItems []struct {
+			ID int `json:"id"`
+		} `json:"workItems"`
+	}
+	if _, err := a.api.do(ctx, svc, http.MethodPost, path, map[string]string{"query": wiql}, &res); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(res.WorkItems))
+	for _, wi := range res.WorkItems {
+		ids = append(ids, wi.ID)
+	}
+
+	return ids, nil
+}
+
+// openCond is the WIQL condition of the work items which are not resolved.
+func openCond() string {
+	return "[System.State] NOT IN ('" + strings.Join(azureClosedStates, "', '") + "')"
+}
+
+// Find implements Tracker.
+func (a *AzureDevOps) Find(ctx context.Context, svc config.Service, fingerprint string,
+	closed bool) ([]Ticket, error) {
+	cond := fmt.Sprintf("[%s] CONTAINS WORDS '%s'", bodyField(svc), strings.Replace(fingerprint, "'", "''", -1))
+	if !closed {
+		cond += " AND " + openCond()
+	}
+	ids, err := a.query(ctx, svc, cond, 10)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	p, _ := a.project(svc)
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.Itoa(id))
+	}
+	q := url.Values{"ids": {strings.Join(strs, ",")}, "fields": {"System.Title,System.State," + bodyField(svc)},
+		"api-version": {azureAPIVersion}}
+	var res struct {
+		Value []azureWorkItem `json:"value"`
+	}
+	if _, err := a.api.do(ctx, svc, http.MethodGet, p+"/_apis/wit/workitems?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(res.Value))
+	for _, wi := range res.Value {
+		tickets = append(tickets, a.ticket(svc, p, wi))
+	}
+
+	return tickets, nil
+}
+
+// update patches the fields of a work item.
+func (a *AzureDevOps) update(ctx context.Context, svc config.Service, id int, patch azurePatch) error {
+	p, err := a.project(svc)
+	if err != nil {
+		return err
+	}
+	_, err = a.api.do(ctx, svc, http.MethodPatch,
+		fmt.Sprintf("%s/_apis/wit/workitems/%d?api-version=%s", p, id, azureAPIVersion), patch, nil)
+	return err
+}
+
+// Edit implements Tracker. The body is that of a found work item, already HTML.
+func (a *AzureDevOps) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	return a.update(ctx, svc, number, azurePatch{}.set(bodyField(svc), body))
+}
+
+// SetClosed implements Tracker, moving the work item to azure_devops.close_state, Closed by default, or
+// azure_devops.reopen_state, Active by default.
+func (a *AzureDevOps) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	state := a.reopenState
+	if closed {
+		state = a.closeState
+	}
+
+	return a.update(ctx, svc, number, azurePatch{}.set("System.State", state))
+}
+
+// AddComment implements Tracker, adding the text to the discussion of the work item.
+func (a *AzureDevOps) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	return "", a.update(ctx, svc, number, azurePatch{}.set("System.History", azureHTML(text)))
+}
+
+// CountOpen implements Tracker.
+func (a *AzureDevOps) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	ids, err := a.query(ctx, svc, fmt.Sprintf("[%s] CONTAINS WORDS 'osprey:fingerprint' AND %s", bodyField(svc),
+		openCond()), 0)
+	return len(ids), err
+}
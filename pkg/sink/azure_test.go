@@ -0,0 +1,153 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newAzureDevOps returns an azure devops tracker calling a fake API, with the project Apple of organization acme
+// and the token ado-apple for service apple.
+func newAzureDevOps(t *testing.T) (*sink.AzureDevOps, *apiServer) {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("azure_devops.base_url", srv.URL)
+	viper.Set("azure_devops.organization", "acme")
+	viper.Set(config.Key("apple", "azure_devops.project"), "Apple")
+	viper.Set(config.Key("apple", "token"), "ado-apple")
+
+	a, err := sink.NewAzureDevOps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, srv
+}
+
+// patchFields returns the fields a JSON patch of a call sets.
+func patchFields(t *testing.T, call apiCall) map[string]interface{} {
+	t.Helper()
+
+	var ops []struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(call.body), &ops); err != nil {
+		t.Fatalf("got body %q, want a JSON patch: %v", call.body, err)
+	}
+	fields := make(map[string]interface{})
+	for _, op := range ops {
+		fields[strings.TrimPrefix(op.Path, "/fields/")] = op.Value
+	}
+
+	return fields
+}
+
+func TestAzureDevOpsCreate(t *testing.T) {
+	a, srv := newAzureDevOps(t)
+	srv.handle("POST /acme/Apple/_apis/wit/workitems/$Bug", http.StatusOK,
+		`{"id": 42, "fields": {"System.Title": "apple: db timeout", "System.State": "New"}}`)
+
+	tk, err := a.Create(context.Background(), config.Service{Name: "apple"}, sink.Issue{
+		Title: "apple: db timeout", Body: "error: <db> timeout\n\n" + sink.FingerprintComment("f00d"),
+		Labels: []string{"bug", "osprey"}, Assignees: []string{"alice@acme.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Number != 42 || tk.Closed || tk.URL != srv.URL+"/acme/Apple/_workitems/edit/42" {
+		t.Fatalf("got %+v, want the new work item 42", tk)
+	}
+
+	call := srv.call(t, "POST /acme/Apple/_apis/wit/workitems/$Bug")
+	if got := call.header.Get("Content-Type"); got != "application/json-patch+json" {
+		t.Fatalf("got content type %q, want a JSON patch", got)
+	}
+	fields := patchFields(t, call)
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"System.Title", "apple: db timeout"},
+		// The body is preformatted HTML, keeping the hidden comments, in the repro steps of a bug.
+		{"Microsoft.VSTS.TCM.ReproSteps", "<pre>error: &lt;db&gt; timeout</pre>" + sink.FingerprintComment("f00d")},
+		{"System.Tags", "bug; osprey"},
+		{"System.AssignedTo", "alice@acme.example"},
+	}
+	for _, tt := range tests {
+		if fields[tt.field] != tt.want {
+			t.Fatalf("%s: got %v, want %q", tt.field, fields[tt.field], tt.want)
+		}
+	}
+}
+
+func TestAzureDevOpsFind(t *testing.T) {
+	a, srv := newAzureDevOps(t)
+	srv.handle("POST /acme/Apple/_apis/wit/wiql", http.StatusOK, `{"workItems": [{"id": 42}, {"id": 7}]}`)
+	srv.handle("GET /acme/Apple/_apis/wit/workitems", http.StatusOK, `{"value": [
+		{"id": 42, "fields": {"System.State": "Active", "Microsoft.VSTS.TCM.ReproSteps": "f00d"}},
+		{"id": 7, "fields": {"System.State": "Resolved", "Microsoft.VSTS.TCM.ReproSteps": "f00d"}}]}`)
+	svc := config.Service{Name: "apple"}
+
+	tickets, err := a.Find(context.Background(), svc, "f00d", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 2 || tickets[0].Closed || !tickets[1].Closed || tickets[0].Body != "f00d" {
+		t.Fatalf("got %+v, want the active work item 42 and the resolved 7", tickets)
+	}
+	query, _ := srv.call(t, "POST /acme/Apple/_apis/wit/wiql").decode(t)["query"].(string)
+	if !strings.Contains(query, "[Microsoft.VSTS.TCM.ReproSteps] CONTAINS WORDS 'f00d'") ||
+		strings.Contains(query, "NOT IN") {
+		t.Fatalf("got query %q, want all work items searched for the fingerprint", query)
+	}
+	if q := srv.call(t, "GET /acme/Apple/_apis/wit/workitems").query; !strings.Contains(q, "ids=42%2C7") {
+		t.Fatalf("got query %q, want the found work items", q)
+	}
+
+	if n, err := a.CountOpen(context.Background(), svc); err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want 2", n, err)
+	}
+}
+
+func TestAzureDevOpsSetClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   map[string]string
+		closed bool
+		want   string
+	}{
+		{"close", nil, true, "Closed"},
+		{"reopen", nil, false, "Active"},
+		{"close state", map[string]string{"azure_devops.close_state": "Done"}, true, "Done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srv := newAzureDevOps(t)
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			a, err := sink.NewAzureDevOps()
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.handle("PATCH /acme/Apple/_apis/wit/workitems/42", http.StatusOK, `{"id": 42}`)
+
+			if err := a.SetClosed(context.Background(), config.Service{Name: "apple"}, 42, tt.closed); err != nil {
+				t.Fatal(err)
+			}
+			fields := patchFields(t, srv.call(t, "PATCH /acme/Apple/_apis/wit/workitems/42"))
+			if fields["System.State"] != tt.want {
+				t.Fatalf("got state %v, want %s", fields["System.State"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// SecretBitbucketToken is the name of the bitbucket app password secret, read for services without a token of
+	// their own.
+	SecretBitbucketToken = "bitbucket_token"
+
+	defaultBitbucketURL = "https://api.bitbucket.org/2.0/"
+
+	// bitbucketOpen is the query condition of the issues which are not resolved.
+	bitbucketOpen = `(state = "new" OR state = "open" OR state = "on hold")`
+)
+
+func init() {
+	Register("bitbucket", shareTracker("bitbucket", func() (Tracker, error) { return NewBitbucket() }))
+}
+
+// Bitbucket is a Tracker filing issues in the issue trackers of Bitbucket Cloud repositories:
+//
+//	bitbucket:
+//	  user: osprey-bot
+//	services:
+//	  apple:
+//	    tracker: bitbucket
+//	    bitbucket:
+//	      workspace: acme
+//	      repo_slug: apple
+//
+// The workspace and repository slug default to repo_owner and repo_name. The token is an app password of the user,
+// or an access token without one, that of the service's token keys or the bitbucket_token secret of the credentials
+// provider.
+type Bitbucket struct {
+	api *restClient
+
+	tokens *trackerTokens
+
+	// user is the user of the app password, empty for an access token.
+	user string
+}
+
+// NewBitbucket returns the bitbucket tracker of config file.
+func NewBitbucket() (*Bitbucket, error) {
+	b := &Bitbucket{
+		tokens: &trackerTokens{tracker: "bitbucket", secret: SecretBitbucketToken},
+		user:   viper.GetString("bitbucket.user"),
+	}
+	base := viper.GetString("bitbucket.base_url")
+	if base == "" {
+		base = defaultBitbucketURL
+	}
+	api, err := newRESTClient("bitbucket", base, b.authorize)
+	if err != nil {
+		return nil, err
+	}
+	b.api = api
+
+	return b, nil
+}
+
+// authorize sets the token of the service on a request.
+func (b *Bitbucket) authorize(req *http.Request, svc config.Service) error {
+	tk, err := b.tokens.token(svc)
+	if err != nil {
+		return err
+	}
+	if b.user != "" {
+		req.SetBasicAuth(b.user, tk)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+tk)
+	}
+
+	return nil
+}
+
+// bitbucketIssue is an issue of the bitbucket API.
+type bitbucketIssue struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Links bitbucketLinks `json:"links"`
+}
+
+// bitbucketLinks are the links of an issue or comment.
+type bitbucketLinks struct {
+	HTML struct {
+		Href string `json:"href"`
+	} `json:"html"`
+}
+
+// ticket returns the issue as a Ticket.
+func (iss bitbucketIssue) ticket() Ticket {
+	closed := true
+	switch iss.State {
+	case "new", "open", "on hold":
+		closed = false
+	}
+
+	return Ticket{Number: iss.ID, URL: iss.Links.HTML.Href, Title: iss.Title, Body: iss.Content.Raw, Closed: closed}
+}
+
+// repo returns the path of the service's repository in the API, or an error if it has none.
+func (b *Bitbucket) repo(svc config.Service) (string, error) {
+	ws := viper.GetString(config.Key(svc.Name, "bitbucket.workspace"))
+	if ws == "" {
+		ws = svc.RepoOwner
+	}
+	slug := viper.GetString(config.Key(svc.Name, "bitbucket.repo_slug"))
+	if slug == "" {
+		slug = svc.RepoName
+	}
+	if ws == "" || slug == "" {
+		return "", fmt.Errorf("bitbucket tracker of %s needs bitbucket.workspace and bitbucket.repo_slug, or "+
+			"repo_owner and repo_name", svc.Name)
+	}
+
+	return "repositories/" + url.PathEscape(ws) + "/" + url.PathEscape(slug), nil
+}
+
+// Create implements Tracker, filing a bug. Bitbucket issues have no labels and milestones are not supported; the
+// issue is assigned to the first assignee, an account ID.
+func (b *Bitbucket) Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error) {
+	repo, err := b.repo(svc)
+	if err != nil {
+		return Ticket{}, err
+	}
+	req := map[string]interface{}{
+		"title":   iss.Title,
+		"kind":    "bug",
+		"content": map[string]string{"raw": iss.Body},
+	}
+	if len(iss.Assignees) > 0 {
+		req["assignee"] = map[string]string{"account_id": iss.Assignees[0]}
+	}
+	var created bitbucketIssue
+	if _, err := b.api.do(ctx, svc, http.MethodPost, repo+"/issues", req, &created); err != nil {
+		return Ticket{}, err
+	}
+
+	return created.ticket(), nil
+}
+
+// search returns the issues of the service's repository matching a query and their total.
+func (b *Bitbucket) search(ctx context.Context, svc config.Service, cond string,
+	pagelen int) ([]bitbucketIssue, int, error) {
+	repo, err := b.repo(svc)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := url.Values{"q": {cond}, "sort": {"-updated_on"}, "pagelen": {strconv.Itoa(pagelen)}}
+	var res struct {
+		Values []bitbucketIssue `json:"values"`
+		Size   int              `json:"size"`
+	}
+	if _, err := b.api.do(ctx, svc, http.MethodGet, repo+"/issues?"+q.Encode(), nil, &res); err != nil {
+		return nil, 0, err
+	}
+
+	return res.Values, res.Size, nil
+}
+
+// Find implements Tracker.
+func (b *Bitbucket) Find(ctx context.Context, svc config.Service, fingerprint string,
+	closed bool) ([]Ticket, error) {
+	cond := fmt.Sprintf("content.raw ~ %q", fingerprint)
+	if !closed {
+		cond += " AND " + bitbucketOpen
+	}
+	found, _, err := b.search(ctx, svc, cond, 10)
+	if err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(found))
+	for _, iss := range found {
+		tickets = append(tickets, iss.ticket())
+	}
+
+	return tickets, nil
+}
+
+// Edit implements Tracker.
+func (b *Bitbucket) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	repo, err := b.repo(svc)
+	if err != nil {
+		return err
+	}
+	_, err = b.api.do(ctx, svc, http.MethodPut, fmt.Sprintf("%s/issues/%d", repo, number),
+		map[string]interface{}{"content": map[string]string{"raw": body}}, nil)
+	return err
+}
+
+// SetClosed implements Tracker, resolving or reopening the issue.
+func (b *Bitbucket) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	state := "open"
+	if closed {
+		state = "resolved"
+	}
+	repo, err := b.repo(svc)
+	if err != nil {
+		return err
+	}
+	_, err = b.api.do(ctx, svc, http.MethodPut, fmt.Sprintf("%s/issues/%d", repo, number),
+		map[string]string{"state": state}, nil)
+	return err
+}
+
+// AddComment implements Tracker.
+func (b *Bitbucket) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	repo, err := b.repo(svc)
+	if err != nil {
+		return "", err
+	}
+	var c struct {
+		Links bitbucketLinks `json:"links"`
+	}
+	_, err = b.api.do(ctx, svc, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", repo, number),
+		map[string]interface{}{"content": map[string]string{"raw": text}}, &c)
+	return c.Links.HTML.Href, err
+}
+
+// CountOpen implements Tracker.
+func (b *Bitbucket) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	_, size, err := b.search(ctx, svc, `content.raw ~ "osprey:fingerprint" AND `+bitbucketOpen, 1)
+	return size, err
+}
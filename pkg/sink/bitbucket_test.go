@@ -0,0 +1,115 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newBitbucket returns a bitbucket tracker calling a fake API, with the token bb-apple for service apple.
+func newBitbucket(t *testing.T, user string) (*sink.Bitbucket, *apiServer) {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("bitbucket.base_url", srv.URL+"/2.0")
+	viper.Set("bitbucket.user", user)
+	viper.Set(config.Key("apple", "token"), "bb-apple")
+
+	b, err := sink.NewBitbucket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b, srv
+}
+
+func TestBitbucketCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		conf map[string]string
+		svc  config.Service
+		path string
+		auth string
+	}{
+		{"repo of the service", "osprey-bot", nil, config.Service{Name: "apple", RepoOwner: "acme", RepoName: "apple"},
+			"/2.0/repositories/acme/apple/issues", "Basic "},
+		{"workspace and slug", "", map[string]string{config.Key("apple", "bitbucket.workspace"): "platform",
+			config.Key("apple", "bitbucket.repo_slug"): "apple-api"}, config.Service{Name: "apple"},
+			"/2.0/repositories/platform/apple-api/issues", "Bearer bb-apple"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, srv := newBitbucket(t, tt.user)
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			srv.handle("POST "+tt.path, http.StatusCreated, `{"id": 8, "state": "new",
+				"links": {"html": {"href": "https://bitbucket.org/acme/apple/issues/8"}}}`)
+
+			tk, err := b.Create(context.Background(), tt.svc, sink.Issue{Title: "apple: db timeout",
+				Body: "error: db timeout", Assignees: []string{"557058:alice"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Number != 8 || tk.Closed || tk.URL != "https://bitbucket.org/acme/apple/issues/8" {
+				t.Fatalf("got %+v, want the new issue 8", tk)
+			}
+			call := srv.call(t, "POST "+tt.path)
+			if got := call.header.Get("Authorization"); !strings.HasPrefix(got, tt.auth) {
+				t.Fatalf("got authorization %q, want %q", got, tt.auth)
+			}
+			body := call.decode(t)
+			if content, _ := body["content"].(map[string]interface{}); body["kind"] != "bug" ||
+				content["raw"] != "error: db timeout" {
+				t.Fatalf("got %v, want a bug with the body as its content", body)
+			}
+			if a, _ := body["assignee"].(map[string]interface{}); a["account_id"] != "557058:alice" {
+				t.Fatalf("got assignee %v, want alice's account", body["assignee"])
+			}
+		})
+	}
+}
+
+func TestBitbucketFind(t *testing.T) {
+	b, srv := newBitbucket(t, "")
+	srv.handle("GET /2.0/repositories/acme/apple/issues", http.StatusOK, `{"size": 2, "values": [
+		{"id": 8, "state": "on hold", "content": {"raw": "f00d"}},
+		{"id": 3, "state": "resolved", "content": {"raw": "f00d"}}]}`)
+	svc := config.Service{Name: "apple", RepoOwner: "acme", RepoName: "apple"}
+
+	tickets, err := b.Find(context.Background(), svc, "f00d", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// On hold issues are not resolved.
+	if len(tickets) != 2 || tickets[0].Closed || !tickets[1].Closed {
+		t.Fatalf("got %+v, want the open issue 8 and the resolved issue 3", tickets)
+	}
+	q, err := url.ParseQuery(srv.call(t, "GET /2.0/repositories/acme/apple/issues").query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Get("q") != `content.raw ~ "f00d"` || q.Get("sort") != "-updated_on" {
+		t.Fatalf("got query %v, want a search of all issues for the fingerprint", q)
+	}
+
+	if n, err := b.CountOpen(context.Background(), svc); err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want the size of the search", n, err)
+	}
+}
+
+func TestBitbucketNeedsRepo(t *testing.T) {
+	b, _ := newBitbucket(t, "")
+
+	if _, err := b.Create(context.Background(), config.Service{Name: "apple"}, sink.Issue{}); err == nil {
+		t.Fatal("got nil, want an error without a workspace and repository")
+	}
+}
@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = time.Minute
+)
+
+// ErrCircuitOpen is returned, wrapped with ErrSinkUnavailable, by a sink refusing deliveries without trying them
+// since its endpoint failed repeatedly.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// breaker is a circuit breaker of a sink's endpoint. It opens after consecutive failures, failing deliveries fast
+// so the scanners spool their findings rather than wait on a dead endpoint, and lets a single probe through every
+// cooldown, closing again once one succeeds.
+type breaker struct {
+	mu sync.Mutex
+
+	// name names the endpoint in logs.
+	name string
+
+	// threshold is the number of consecutive failures opening the breaker.
+	threshold int
+
+	// cooldown is the time between probes while open.
+	cooldown time.Duration
+
+	// failures is the number of consecutive failures.
+	failures int
+
+	// openedAt is when the breaker opened or last probed, zero while closed.
+	openedAt time.Time
+
+	// probing is set while a probe is under way.
+	probing bool
+}
+
+// guard refuses the deliveries of a sink without trying them while the sink is rate limited or its circuit breaker
+// is open. A nil guard refuses none.
+type guard struct {
+	mu sync.Mutex
+
+	// name names the sink in errors.
+	name string
+
+	// limitedUntil is when the rate limit the sink last reported resets.
+	limitedUntil time.Time
+
+	// breaker fails deliveries fast while the sink is down, nil to try every delivery.
+	breaker *breaker
+}
+
+// newGuard returns the guard of a sink, its circuit breaker set by <name>.breaker.failures and
+// <name>.breaker.cooldown in config file.
+func newGuard(name string) *guard {
+	return &guard{name: name, breaker: newBreaker(name, viper.GetInt(name+".breaker.failures"),
+		viper.GetDuration(name+".breaker.cooldown"))}
+}
+
+// allow returns a RateLimitError until the rate limit resets, or an error wrapping ErrCircuitOpen while the breaker
+// is open, if a delivery may not be tried now.
+func (g *guard) allow() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	until := g.limitedUntil
+	g.mu.Unlock()
+	if time.Now().Before(until) {
+		return &RateLimitError{Reset: until, Err: fmt.Errorf("%s rate limit in effect", g.name)}
+	}
+
+	return g.breaker.allow()
+}
+
+// record records the outcome of a delivery allowed by allow.
+func (g *guard) record(err error) {
+	if g == nil {
+		return
+	}
+	g.breaker.record(err)
+	if reset, ok := RetryAt(err); ok {
+		g.mu.Lock()
+		if reset.After(g.limitedUntil) {
+			g.limitedUntil = reset
+		}
+		g.mu.Unlock()
+	}
+}
+
+// newBreaker returns a breaker of an endpoint opening after threshold failures, nil if threshold is negative.
+func newBreaker(name string, threshold int, cooldown time.Duration) *breaker {
+	if threshold < 0 {
+		return nil
+	}
+	if threshold == 0 {
+		threshold = defaultBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	return &breaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns an error wrapping ErrCircuitOpen if a delivery may not be tried now. A nil breaker allows all.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return fmt.Errorf("%w, %w of %s, %d consecutive failures", ErrSinkUnavailable, ErrCircuitOpen, b.name,
+			b.failures)
+	}
+	b.probing = true
+
+	return nil
+}
+
+// record records the outcome of a delivery allowed by allow. Only the endpoint failing counts, e.g. a rejected
+// request shows it is reachable.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+	if errors.Is(err, ErrSinkUnavailable) {
+		b.failures++
+		if probe || b.failures >= b.threshold {
+			if !probe {
+				log.Printf("%s failed %d times in a row, pausing deliveries for %s\n", b.name, b.failures,
+					b.cooldown)
+			}
+			b.openedAt = time.Now()
+		}
+		return
+	}
+
+	if !b.openedAt.IsZero() {
+		log.Printf("%s is reachable again, deliveries resumed\n", b.name)
+	}
+	b.failures, b.openedAt = 0, time.Time{}
+}
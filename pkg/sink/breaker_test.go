@@ -0,0 +1,153 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	down := fmt.Errorf("%w, connection refused", ErrSinkUnavailable)
+	rejected := errors.New("422 validation failed")
+	b := newBreaker("test", 2, 20*time.Millisecond)
+
+	// Each step is tried if the breaker allows it, with the outcome of err.
+	tests := []struct {
+		name  string
+		wait  time.Duration
+		err   error
+		allow bool
+	}{
+		{"closed", 0, down, true},
+		{"a rejected request resets the failures", 0, rejected, true},
+		{"first failure", 0, down, true},
+		{"second failure opens", 0, down, true},
+		{"open", 0, nil, false},
+		{"probe after the cooldown fails", 30 * time.Millisecond, down, true},
+		{"open again", 0, nil, false},
+		{"probe succeeds", 30 * time.Millisecond, nil, true},
+		{"closed again", 0, down, true},
+	}
+	for _, tt := range tests {
+		time.Sleep(tt.wait)
+		err := b.allow()
+		if (err == nil) != tt.allow {
+			t.Fatalf("%s: got %v, want allowed %v", tt.name, err, tt.allow)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrSinkUnavailable) {
+				t.Fatalf("%s: got %v, want ErrCircuitOpen wrapped with ErrSinkUnavailable", tt.name, err)
+			}
+			continue
+		}
+		b.record(tt.err)
+	}
+}
+
+func TestBreakerProbesOnce(t *testing.T) {
+	b := newBreaker("test", 1, time.Millisecond)
+	b.record(fmt.Errorf("%w, down", ErrSinkUnavailable))
+	time.Sleep(5 * time.Millisecond)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("got %v, want the probe allowed", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want one probe at a time", err)
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	if b := newBreaker("test", -1, 0); b != nil {
+		t.Fatal("got a breaker, want none for a negative threshold")
+	}
+	var b *breaker
+	b.record(ErrSinkUnavailable)
+	if err := b.allow(); err != nil {
+		t.Fatalf("got %v, want every delivery allowed", err)
+	}
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	g := &guard{name: "test"}
+	reset := time.Now().Add(time.Hour)
+	g.record(&RateLimitError{Reset: reset, Err: errors.New("403 rate limited")})
+
+	err := g.allow()
+	if got, ok := RetryAt(err); !ok || !got.Equal(reset) {
+		t.Fatalf("got %v, want a RateLimitError until %s", err, reset)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+
+	// An earlier reset does not shorten the limit in effect.
+	g.record(&RateLimitError{Reset: time.Now().Add(-time.Minute), Err: errors.New("429")})
+	if _, ok := RetryAt(g.allow()); !ok {
+		t.Fatal("the rate limit was lifted early")
+	}
+
+	g = &guard{name: "test"}
+	g.record(&RateLimitError{Reset: time.Now().Add(-time.Second), Err: errors.New("429")})
+	if err := g.allow(); err != nil {
+		t.Fatalf("got %v, want deliveries allowed once the limit reset", err)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	epoch := time.Now().Add(10 * time.Minute).Unix()
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		limited bool
+		within  time.Duration
+	}{
+		{"ok", http.StatusOK, nil, false, 0},
+		{"forbidden", http.StatusForbidden, nil, false, 0},
+		{"server error", http.StatusBadGateway, map[string]string{"Retry-After": "5"}, false, 0},
+		{"retry after", http.StatusForbidden, map[string]string{"Retry-After": "30"}, true, 30 * time.Second},
+		{"primary limit", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset": strconv.FormatInt(epoch, 10)}, true, 10 * time.Minute},
+		{"too many requests", http.StatusTooManyRequests, nil, true, defaultRateLimitPause},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+			reset, ok := rateLimitReset(resp)
+			if ok != tt.limited {
+				t.Fatalf("got %v, want %v", ok, tt.limited)
+			}
+			if ok && (time.Until(reset) > tt.within || time.Until(reset) < tt.within-5*time.Second) {
+				t.Fatalf("got reset in %s, want about %s", time.Until(reset), tt.within)
+			}
+		})
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	base := errors.New("call failed")
+	tests := []struct {
+		status      int
+		limited     bool
+		unavailable bool
+	}{
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusInternalServerError, false, true},
+		{http.StatusServiceUnavailable, false, true},
+		{http.StatusUnprocessableEntity, false, false},
+	}
+	for _, tt := range tests {
+		err := httpError(tt.status, base)
+		if errors.Is(err, ErrRateLimited) != tt.limited || errors.Is(err, ErrSinkUnavailable) != tt.unavailable ||
+			!errors.Is(err, base) {
+			t.Errorf("%d: got %v", tt.status, err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package sink
+
+import (
+	"context"
+	"github.com/google/go-github/github"
+)
+
+// Create files an issue in a repository.
+//
+// Deprecated: use Deliver, which builds the issue from a finding and the service's repository.
+func (g *GitHub) Create(ctx context.Context, owner, repo string, issReq *github.IssueRequest) (*github.Issue, error) {
+	iss, _, err := g.Client.Issues.Create(ctx, owner, repo, issReq)
+	return iss, err
+}
@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SecretDiscordWebhook is the name of the secret holding the discord webhook url, unless a service names another
+	// one with discord.webhook_secret.
+	SecretDiscordWebhook = "discord_webhook_url"
+
+	// maxDiscordTitle and maxDiscordExcerpt bound the title and the log line of an embed, discord allows 256 and 4096
+	// characters.
+	maxDiscordTitle   = 250
+	maxDiscordExcerpt = 4000
+
+	// discordRed is the color of the embeds.
+	discordRed = 0xE74C3C
+)
+
+func init() {
+	Register("discord", share(func() (Sink, error) { return NewDiscord() }))
+}
+
+// Discord posts findings to a discord channel as embeds, through the webhook whose url is the discord_webhook_url
+// secret, or the secret discord.webhook_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, discord]
+//	    discord:
+//	      webhook_secret: apple_discord_webhook
+type Discord struct {
+	hook *restClient
+
+	// guard refuses deliveries while discord is rate limited or down.
+	guard *guard
+}
+
+// NewDiscord returns the discord sink of config file.
+func NewDiscord() (*Discord, error) {
+	hook, err := newRESTClient("discord", "https://discord.com/api/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Discord{hook: hook, guard: newGuard("discord")}, nil
+}
+
+// Deliver implements Sink.
+func (d *Discord) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := d.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err := d.post(ctx, svc, f)
+	d.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// post posts the embed of a finding to the service's webhook.
+func (d *Discord) post(ctx context.Context, svc config.Service, f match.Finding) error {
+	name := setting(svc, "discord.webhook_secret")
+	if name == "" {
+		name = SecretDiscordWebhook
+	}
+	url, err := secret(name)
+	if err != nil {
+		return err
+	}
+	_, err = d.hook.do(ctx, svc, http.MethodPost, url, discordMessage(svc, f), nil)
+
+	return err
+}
+
+// discordMessage returns the message of a finding: an embed of its title, linking to its issue if there is one, the
+// log line in a code block and the service, severity and fingerprint. Mentions in the log line are not notified.
+func discordMessage(svc config.Service, f match.Finding) map[string]interface{} {
+	field := func(name, value string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "value": value, "inline": true}
+	}
+	fields := []interface{}{field("Service", svc.Name)}
+	if f.Severity != "" {
+		fields = append(fields, field("Severity", f.Severity))
+	}
+	if f.Fingerprint != "" {
+		fields = append(fields, field("Fingerprint", "`"+f.Fingerprint+"`"))
+	}
+	// A zero width space keeps the backticks of the line from closing the code block.
+	line := strings.Replace(excerpt(f, maxDiscordExcerpt), "```", "`\u200b``", -1)
+	embed := map[string]interface{}{
+		"title":       truncate(f.Title, maxDiscordTitle),
+		"description": "```\n" + line + "\n```",
+		"color":       discordRed,
+		"fields":      fields,
+	}
+	if f.IssueURL != "" {
+		embed["url"] = f.IssueURL
+	}
+
+	return map[string]interface{}{
+		"embeds":           []interface{}{embed},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+}
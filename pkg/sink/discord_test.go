@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscordDeliver(t *testing.T) {
+	var path string
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	t.Setenv("OSPREY_SECRET_DISCORD_WEBHOOK_URL", srv.URL+"/hooks/default")
+	t.Setenv("OSPREY_SECRET_APPLE_DISCORD_HOOK", srv.URL+"/hooks/apple")
+	f := match.Finding{Title: "db timeout", Line: "error: db timeout"}
+
+	tests := []struct {
+		name   string
+		conf   map[string]string
+		status int
+		ok     bool
+		path   string
+	}{
+		{"default webhook", nil, http.StatusNoContent, true, "/hooks/default"},
+		{"webhook secret", map[string]string{"services.apple.discord.webhook_secret": "apple_discord_hook"},
+			http.StatusNoContent, true, "/hooks/apple"},
+		{"unknown secret", map[string]string{"services.apple.discord.webhook_secret": "pear_discord_hook"},
+			http.StatusNoContent, false, ""},
+		{"refused", nil, http.StatusBadRequest, false, "/hooks/default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			s, err := NewDiscord()
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, status = "", tt.status
+
+			_, err = s.Deliver(context.Background(), config.Service{Name: "apple"}, f)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if path != tt.path {
+				t.Fatalf("got %q, want %q", path, tt.path)
+			}
+		})
+	}
+}
+
+func TestDiscordMessage(t *testing.T) {
+	f := match.Finding{Title: "db timeout", Line: "error: ```@everyone```", Fingerprint: "f00d",
+		IssueURL: "https://example.com/3"}
+	b, err := json.Marshal(discordMessage(config.Service{Name: "apple"}, f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Embeds []struct {
+			URL         string        `json:"url"`
+			Description string        `json:"description"`
+			Fields      []interface{} `json:"fields"`
+		} `json:"embeds"`
+		AllowedMentions struct {
+			Parse []string `json:"parse"`
+		} `json:"allowed_mentions"`
+	}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatal(err)
+	}
+	embed := msg.Embeds[0]
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"issue link", embed.URL, f.IssueURL},
+		{"fields", len(embed.Fields), 2},
+		{"code block", embed.Description, "```\nerror: `\u200b``@everyone`\u200b``\n```"},
+		{"mentions", msg.AllowedMentions.Parse == nil, false},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretSMTPPassword is the name of the secret holding the password of email.user.
+	SecretSMTPPassword = "smtp_password"
+
+	// maxEmailFindings bounds the findings listed in a digest, the others are only counted.
+	maxEmailFindings = 100
+
+	// maxEmailExcerpt bounds the log line of a finding in a digest.
+	maxEmailExcerpt = 2000
+
+	// smtpTimeout bounds the sending of a digest.
+	smtpTimeout = 30 * time.Second
+)
+
+// emailSecurities are the email.security settings: STARTTLS, TLS from the start of the connection, or none.
+var emailSecurities = []string{"starttls", "tls", "none"}
+
+func init() {
+	Register("email", share(func() (Sink, error) { return NewEmail() }))
+}
+
+// Email mails each service's recipients a digest of the findings delivered since the last one, every email.interval
+// or the scan interval by default, through the SMTP server of config file:
+//
+//	email:
+//	  host: smtp.example.com
+//	  from: osprey@example.com
+//	  user: osprey
+//	services:
+//	  apple:
+//	    sinks: [github, email]
+//	    email:
+//	      to: [apple-oncall@example.com]
+//
+// The password of the user is the smtp_password secret. Connections use STARTTLS on port 587 unless email.security
+// is tls, on port 465, or none, with the TLS settings of tls.email. A digest the server cannot take is kept for the
+// next one.
+type Email struct {
+	host, from, user, security string
+
+	port int
+
+	mu sync.Mutex
+
+	// pending are the findings held back for each service, keyed on its name.
+	pending map[string]*emailDigest
+
+	// guard refuses sending while the server is down.
+	guard *guard
+}
+
+// emailDigest holds the findings of a service for its next digest.
+type emailDigest struct {
+	svc config.Service
+
+	findings []match.Finding
+
+	// more is the number of findings beyond maxEmailFindings.
+	more int
+}
+
+// NewEmail returns the email sink of config file and starts sending its digests.
+func NewEmail() (*Email, error) {
+	e := &Email{
+		host:     viper.GetString("email.host"),
+		from:     viper.GetString("email.from"),
+		user:     viper.GetString("email.user"),
+		security: strings.ToLower(viper.GetString("email.security")),
+		port:     viper.GetInt("email.port"),
+		pending:  make(map[string]*emailDigest),
+		guard:    newGuard("email"),
+	}
+	if e.host == "" || e.from == "" {
+		return nil, errors.New("email sink needs email.host and email.from")
+	}
+	if e.security == "" {
+		e.security = "starttls"
+	}
+	if !contains(emailSecurities, e.security) {
+		return nil, fmt.Errorf("unknown email.security %q, use %s", e.security, strings.Join(emailSecurities, ", "))
+	}
+	if e.port == 0 {
+		e.port = 587
+		if e.security == "tls" {
+			e.port = 465
+		}
+	}
+
+	interval := viper.GetDuration("email.interval")
+	if interval <= 0 {
+		interval = time.Duration(viper.GetInt("interval")) * time.Second
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go e.run(interval)
+
+	return e, nil
+}
+
+// contains returns whether a list has the string.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// run flushes the digests every interval.
+func (e *Email) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := e.Flush(context.Background()); err != nil {
+			log.Printf("Unable to send email digests, %s\n", err.Error())
+		}
+	}
+}
+
+// Deliver implements Sink, holding the finding back for the service's next digest.
+func (e *Email) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if len(settingList(svc, "email.to")) == 0 {
+		return Delivery{}, fmt.Errorf("email sink of %s needs email.to", svc.Name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hold(&emailDigest{svc: svc, findings: []match.Finding{f}})
+
+	return Delivery{}, nil
+}
+
+// hold adds the findings of a digest to the pending ones of its service. Call with mu held.
+func (e *Email) hold(d *emailDigest) {
+	p, ok := e.pending[d.svc.Name]
+	if !ok {
+		p = &emailDigest{svc: d.svc}
+		e.pending[d.svc.Name] = p
+	}
+	p.svc, p.more = d.svc, p.more+d.more
+	for _, f := range d.findings {
+		if len(p.findings) < maxEmailFindings {
+			p.findings = append(p.findings, f)
+		} else {
+			p.more++
+		}
+	}
+}
+
+// Flush implements Flusher, sending the digest of every service with pending findings. A digest which cannot be
+// sent is kept for the next flush.
+func (e *Email) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = make(map[string]*emailDigest)
+	e.mu.Unlock()
+
+	names := make([]string, 0, len(pending))
+	for name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		d := pending[name]
+		err := e.guard.allow()
+		if err == nil {
+			err = e.send(ctx, d)
+			e.guard.record(err)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("digest of %s, %w", name, err))
+			// Keep the findings ahead of those delivered meanwhile.
+			e.mu.Lock()
+			later := e.pending[name]
+			delete(e.pending, name)
+			e.hold(d)
+			if later != nil {
+				e.hold(later)
+			}
+			e.mu.Unlock()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// send mails a digest to the recipients of its service. Errors of the connection and temporary SMTP failures wrap
+// ErrSinkUnavailable.
+func (e *Email) send(ctx context.Context, d *emailDigest) error {
+	msg, err := e.message(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	err = e.deliver(ctx, settingList(d.svc, "email.to"), msg)
+	var perr *textproto.Error
+	if err != nil && (!errors.As(err, &perr) || perr.Code < 500) {
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+
+	return err
+}
+
+// deliver sends a message to the recipients over a new connection to the server.
+func (e *Email) deliver(ctx context.Context, to []string, msg []byte) error {
+	cfg, err := transport.TLSConfig("email")
+	if err != nil {
+		return fmt.Errorf("invalid TLS settings for email, %s", err.Error())
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = e.host
+	}
+
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if e.security == "tls" {
+		conn = tls.Client(conn, cfg)
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if e.security == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS, set email.security to tls or none", addr)
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	}
+	if e.user != "" {
+		password, err := secret(SecretSMTPPassword)
+		if err != nil {
+			return err
+		}
+		if err := c.Auth(smtp.PlainAuth("", e.user, password, e.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message returns the mail of a digest: for each finding its title, severity, fingerprint, issue url and log line,
+// in plain text.
+func (e *Email) message(d *emailDigest) ([]byte, error) {
+	m := locale.For(d.svc.Locale)
+	total := d.more
+	for _, f := range d.findings {
+		total++
+		if f.Occurrences > 1 {
+			total += f.Occurrences - 1
+		}
+	}
+
+	var text strings.Builder
+	for _, f := range d.findings {
+		fmt.Fprintf(&text, "%s\n", f.Title)
+		var meta []string
+		if f.Severity != "" {
+			meta = append(meta, f.Severity)
+		}
+		if f.Fingerprint != "" {
+			meta = append(meta, f.Fingerprint)
+		}
+		if f.IssueURL != "" {
+			meta = append(meta, f.IssueURL)
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(&text, "%s\n", strings.Join(meta, " · "))
+		}
+		for _, line := range strings.Split(excerpt(f, maxEmailExcerpt), "\n") {
+			fmt.Fprintf(&text, "    %s\n", line)
+		}
+		text.WriteString("\n")
+	}
+	if d.more > 0 {
+		fmt.Fprintf(&text, m.MoreLines+"\n", d.more)
+	}
+
+	var b bytes.Buffer
+	subject := fmt.Sprintf(m.DigestTitle, d.svc.Name, total, d.svc.FormatTime(time.Now()))
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", e.from,
+		strings.Join(settingList(d.svc, "email.to"), ", "), mime.QEncoding.Encode("utf-8", subject),
+		time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.Replace(text.String(), "\n", "\r\n", -1))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
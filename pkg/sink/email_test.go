@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a fake SMTP server answering the data of a mail with code.
+type smtpServer struct {
+	addr *net.TCPAddr
+
+	mu       sync.Mutex
+	code     int
+	messages []string
+}
+
+// newSMTPServer starts a fake SMTP server taking every mail.
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &smtpServer{addr: l.Addr().(*net.TCPAddr), code: 250}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// serve talks SMTP on a connection.
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+		case "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			code := s.code
+			if code == 250 {
+				s.messages = append(s.messages, msg.String())
+			}
+			s.mu.Unlock()
+			reply(strconv.Itoa(code) + " done")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// setCode sets the reply to the data of the next mails.
+func (s *smtpServer) setCode(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code = code
+}
+
+// received returns the mails taken.
+func (s *smtpServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestEmailFlush(t *testing.T) {
+	srv := newSMTPServer(t)
+	e := &Email{host: "127.0.0.1", port: srv.addr.Port, from: "osprey@example.com", security: "none",
+		pending: make(map[string]*emailDigest)}
+	svc := config.Service{Name: "apple"}
+	e.hold(&emailDigest{svc: svc, findings: []match.Finding{{Title: "db timeout", Line: "error: db timeout"}}})
+
+	tests := []struct {
+		name     string
+		code     int
+		ok       bool
+		received int
+	}{
+		{"temporary failure", 451, false, 0},
+		{"permanent failure", 554, false, 0},
+		{"sent", 250, true, 1},
+		{"nothing pending", 250, true, 1},
+	}
+	for _, tt := range tests {
+		srv.setCode(tt.code)
+		// The findings of a failed digest are kept for the next flush, not dropped.
+		if err := e.Flush(context.Background()); (err == nil) != tt.ok {
+			t.Fatalf("%s: got %v, want ok %v", tt.name, err, tt.ok)
+		}
+		got := srv.received()
+		if len(got) != tt.received {
+			t.Fatalf("%s: got %d mails, want %d", tt.name, len(got), tt.received)
+		}
+	}
+	if got := srv.received(); !strings.Contains(got[0], "error: db timeout") {
+		t.Fatalf("got %q, want the digest to list the finding", got[0])
+	}
+}
@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"log"
+)
+
+// Fanout delivers each finding to several sinks, e.g. a github issue and a chat message. The first sink is the
+// primary one: its delivery is the fan-out's, and the others are only delivered to once it took the finding, with
+// the url of its issue in the finding's IssueURL. The others failing is logged rather than returned, so a retry does
+// not file the issue twice.
+type Fanout struct {
+	// Names name the sinks in logs, in the order of Sinks.
+	Names []string
+
+	// Sinks are the sinks, the primary one first.
+	Sinks []Sink
+}
+
+// Deliver implements Sink.
+func (fo Fanout) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if len(fo.Sinks) == 0 {
+		return Delivery{}, errors.New("fan-out has no sinks")
+	}
+	dlv, err := fo.Sinks[0].Deliver(ctx, svc, f)
+	if err != nil {
+		return dlv, err
+	}
+
+	if f.IssueURL == "" {
+		f.IssueURL = dlv.URL
+	}
+	for i, sk := range fo.Sinks[1:] {
+		if _, err := sk.Deliver(ctx, svc, f); err != nil && !errors.Is(err, ErrDropped) {
+			log.Printf("Unable to deliver finding of %s to %s, %s\n", svc.Name, fo.name(i+1), err.Error())
+		}
+	}
+
+	return dlv, nil
+}
+
+// name returns the name of the i-th sink.
+func (fo Fanout) name(i int) string {
+	if i < len(fo.Names) {
+		return fo.Names[i]
+	}
+
+	return "sink"
+}
@@ -0,0 +1,193 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SecretGiteaToken is the name of the gitea token secret, read for services without a token of their own.
+const SecretGiteaToken = "gitea_token"
+
+func init() {
+	Register("gitea", shareTracker("gitea", func() (Tracker, error) { return NewGitea() }))
+}
+
+// Gitea is a Tracker filing issues in the repositories of a Gitea or Forgejo instance, whose API gitea.base_url in
+// config file names:
+//
+//	gitea:
+//	  base_url: https://git.example.com/api/v1/
+//	services:
+//	  apple:
+//	    tracker: gitea
+//	    repo_owner: platform
+//	    repo_name: apple
+//
+// The token is that of the service's token keys, or the gitea_token secret of the credentials provider.
+type Gitea struct {
+	api *restClient
+
+	tokens *trackerTokens
+}
+
+// NewGitea returns the gitea tracker of config file.
+func NewGitea() (*Gitea, error) {
+	g := &Gitea{tokens: &trackerTokens{tracker: "gitea", secret: SecretGiteaToken}}
+	base := viper.GetString("gitea.base_url")
+	if base == "" {
+		return nil, errors.New("gitea tracker needs gitea.base_url")
+	}
+	api, err := newRESTClient("gitea", base, g.authorize)
+	if err != nil {
+		return nil, err
+	}
+	g.api = api
+
+	return g, nil
+}
+
+// authorize sets the token of the service on a request.
+func (g *Gitea) authorize(req *http.Request, svc config.Service) error {
+	tk, err := g.tokens.token(svc)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+tk)
+
+	return nil
+}
+
+// giteaIssue is an issue of the gitea API.
+type giteaIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+}
+
+// ticket returns the issue as a Ticket.
+func (iss giteaIssue) ticket() Ticket {
+	return Ticket{Number: iss.Number, URL: iss.HTMLURL, Title: iss.Title, Body: iss.Body,
+		Closed: iss.State == "closed"}
+}
+
+// repo returns the path of the service's repository in the API.
+func (g *Gitea) repo(svc config.Service) string {
+	return "repos/" + url.PathEscape(svc.RepoOwner) + "/" + url.PathEscape(svc.RepoName)
+}
+
+// Create implements Tracker. Labels the repository does not have are left out, since gitea takes label IDs.
+func (g *Gitea) Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error) {
+	req := map[string]interface{}{"title": iss.Title, "body": iss.Body}
+	if len(iss.Labels) > 0 {
+		ids, err := g.labelIDs(ctx, svc, iss.Labels)
+		if err != nil {
+			return Ticket{}, err
+		}
+		req["labels"] = ids
+	}
+	if len(iss.Assignees) > 0 {
+		req["assignees"] = iss.Assignees
+	}
+	if iss.Milestone > 0 {
+		req["milestone"] = iss.Milestone
+	}
+	var created giteaIssue
+	if _, err := g.api.do(ctx, svc, http.MethodPost, g.repo(svc)+"/issues", req, &created); err != nil {
+		return Ticket{}, err
+	}
+
+	return created.ticket(), nil
+}
+
+// labelIDs returns the IDs of the repository's labels with the names.
+func (g *Gitea) labelIDs(ctx context.Context, svc config.Service, names []string) ([]int, error) {
+	var labels []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if _, err := g.api.do(ctx, svc, http.MethodGet, g.repo(svc)+"/labels?limit=50", nil, &labels); err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, name := range names {
+		for _, l := range labels {
+			if strings.EqualFold(l.Name, name) {
+				ids = append(ids, l.ID)
+				break
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// Find implements Tracker.
+func (g *Gitea) Find(ctx context.Context, svc config.Service, fingerprint string, closed bool) ([]Ticket, error) {
+	q := url.Values{"type": {"issues"}, "q": {fingerprint}, "state": {"open"}, "limit": {"10"}}
+	if closed {
+		q.Set("state", "all")
+	}
+	var found []giteaIssue
+	if _, err := g.api.do(ctx, svc, http.MethodGet, g.repo(svc)+"/issues?"+q.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(found))
+	for _, iss := range found {
+		tickets = append(tickets, iss.ticket())
+	}
+
+	return tickets, nil
+}
+
+// Edit implements Tracker.
+func (g *Gitea) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	_, err := g.api.do(ctx, svc, http.MethodPatch, fmt.Sprintf("%s/issues/%d", g.repo(svc), number),
+		map[string]string{"body": body}, nil)
+	return err
+}
+
+// SetClosed implements Tracker.
+func (g *Gitea) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	state := "open"
+	if closed {
+		state = "closed"
+	}
+	_, err := g.api.do(ctx, svc, http.MethodPatch, fmt.Sprintf("%s/issues/%d", g.repo(svc), number),
+		map[string]string{"state": state}, nil)
+	return err
+}
+
+// AddComment implements Tracker.
+func (g *Gitea) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	var c struct {
+		HTMLURL string `json:"html_url"`
+	}
+	_, err := g.api.do(ctx, svc, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", g.repo(svc), number),
+		map[string]string{"body": text}, &c)
+	return c.HTMLURL, err
+}
+
+// CountOpen implements Tracker, from the total gitea tells in the X-Total-Count header.
+func (g *Gitea) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	q := url.Values{"type": {"issues"}, "q": {"osprey:fingerprint"}, "state": {"open"}, "limit": {"1"}}
+	var found []giteaIssue
+	h, err := g.api.do(ctx, svc, http.MethodGet, g.repo(svc)+"/issues?"+q.Encode(), nil, &found)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(h.Get("X-Total-Count"))
+	if err != nil {
+		return 0, fmt.Errorf("gitea did not tell the number of open issues of %s", svc.Name)
+	}
+
+	return n, nil
+}
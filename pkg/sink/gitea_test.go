@@ -0,0 +1,101 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newGitea returns a gitea tracker calling a fake API, with the token gitea-apple for service apple.
+func newGitea(t *testing.T) (*sink.Gitea, *apiServer) {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("gitea.base_url", srv.URL+"/api/v1")
+	viper.Set(config.Key("apple", "token"), "gitea-apple")
+
+	g, err := sink.NewGitea()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, srv
+}
+
+func TestGiteaCreate(t *testing.T) {
+	g, srv := newGitea(t)
+	srv.handle("GET /api/v1/repos/platform/apple/labels", http.StatusOK,
+		`[{"id": 1, "name": "Bug"}, {"id": 2, "name": "osprey"}]`)
+	srv.handle("POST /api/v1/repos/platform/apple/issues", http.StatusCreated,
+		`{"number": 5, "html_url": "https://git.example.com/platform/apple/issues/5", "state": "open"}`)
+	svc := config.Service{Name: "apple", RepoOwner: "platform", RepoName: "apple"}
+
+	tk, err := g.Create(context.Background(), svc, sink.Issue{Title: "apple: db timeout",
+		Labels: []string{"bug", "unknown"}, Assignees: []string{"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Number != 5 || tk.Closed {
+		t.Fatalf("got %+v, want the open issue 5", tk)
+	}
+	call := srv.call(t, "POST /api/v1/repos/platform/apple/issues")
+	if got := call.header.Get("Authorization"); got != "token gitea-apple" {
+		t.Fatalf("got authorization %q, want the token of the service", got)
+	}
+	// Gitea takes label IDs, the labels the repository does not have are left out.
+	body := call.decode(t)
+	if labels, _ := body["labels"].([]interface{}); len(labels) != 1 || labels[0] != float64(1) {
+		t.Fatalf("got labels %v, want the ID of Bug", body["labels"])
+	}
+	if a, _ := body["assignees"].([]interface{}); len(a) != 1 || a[0] != "alice" {
+		t.Fatalf("got assignees %v, want alice", body["assignees"])
+	}
+}
+
+func TestGiteaReopen(t *testing.T) {
+	g, srv := newGitea(t)
+	s := sink.NewTrackerSink("gitea", g)
+	srv.handle("GET /api/v1/repos/platform/apple/issues", http.StatusOK, `[{"number": 5, "state": "closed",
+		"html_url": "https://git.example.com/platform/apple/issues/5", "body": "`+sink.FingerprintComment("f00d")+`"}]`)
+	srv.handle("PATCH /api/v1/repos/platform/apple/issues/5", http.StatusCreated, `{}`)
+	srv.handle("POST /api/v1/repos/platform/apple/issues/5/comments", http.StatusCreated,
+		`{"html_url": "https://git.example.com/platform/apple/issues/5#issuecomment-9"}`)
+	svc := config.Service{Name: "apple", RepoOwner: "platform", RepoName: "apple", SearchDuplicates: true,
+		Reopen: true}
+
+	dlv, err := s.Deliver(context.Background(), svc, match.Finding{Title: "apple: db timeout", Fingerprint: "f00d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dlv.Updated || dlv.Number != 5 || !strings.HasSuffix(dlv.URL, "#issuecomment-9") {
+		t.Fatalf("got %+v, want issue 5 updated with a comment", dlv)
+	}
+	if q := srv.call(t, "GET /api/v1/repos/platform/apple/issues").query; !strings.Contains(q, "state=all") {
+		t.Fatalf("got query %q, want closed issues searched too", q)
+	}
+	if got := srv.call(t, "PATCH /api/v1/repos/platform/apple/issues/5").decode(t)["state"]; got != "open" {
+		t.Fatalf("got state %v, want the issue reopened", got)
+	}
+}
+
+func TestGiteaCountOpen(t *testing.T) {
+	g, srv := newGitea(t)
+	srv.handleWithHeader("GET /api/v1/repos/platform/apple/issues", http.StatusOK,
+		map[string]string{"X-Total-Count": "4"}, `[]`)
+	svc := config.Service{Name: "apple", RepoOwner: "platform", RepoName: "apple"}
+
+	if n, err := g.CountOpen(context.Background(), svc); err != nil || n != 4 {
+		t.Fatalf("got %d, %v, want 4", n, err)
+	}
+	srv.handle("GET /api/v1/repos/platform/apple/issues", http.StatusOK, `[]`)
+	if _, err := g.CountOpen(context.Background(), svc); err == nil {
+		t.Fatal("got nil, want an error without a total")
+	}
+}
@@ -0,0 +1,368 @@
+// Package sink delivers the findings of osprey's scanners, e.g. as github issues.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	"github.com/NBCFB/Iguana2/pkg/locale"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/google/go-github/github"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub files issues in github repositories. A github sink is shared by all scanners.
+type GitHub struct {
+	// Client is the github API service client, of the services without a token of their own.
+	Client *github.Client
+
+	// Credentials supply the token_secret of services, e.g. from Vault, nil if there is no credentials provider.
+	Credentials credentials.Provider
+
+	mu sync.Mutex
+
+	// clients are the clients of the services with a token of their own, by service name.
+	clients map[string]*github.Client
+
+	// guard refuses deliveries while github is rate limited or down, nil to try every delivery.
+	guard *guard
+}
+
+// NewGitHub returns a github sink authenticating with the given token source, e.g. a
+// credentials.RotatingTokenSource. It calls github.com unless github.base_url in config file names the API of a
+// GitHub Enterprise Server:
+//
+//	github:
+//	  base_url: https://github.corp.example/api/v3/
+//	  upload_url: https://github.corp.example/api/uploads/
+//
+// The upload url defaults to the base url with api/v3 replaced by api/uploads. Deliveries go through a circuit
+// breaker, opening after github.breaker.failures consecutive failures to reach github, 5 by default or -1 to never
+// open, and probing every github.breaker.cooldown, 1m by default.
+func NewGitHub(ctx context.Context, ts oauth2.TokenSource) (*GitHub, error) {
+	c, err := newGitHubClient(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHub{Client: c, guard: newGuard("github")}, nil
+}
+
+// newGitHubClient returns a github API client authenticating with the token source.
+func newGitHubClient(ctx context.Context, ts oauth2.TokenSource) (*github.Client, error) {
+	// The oauth2 client wraps the proxy and TLS aware client, so github calls honor these settings.
+	hc, err := transport.NewHTTPClient("github", 0)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, hc)
+	tc := oauth2.NewClient(ctx, ts)
+
+	base := viper.GetString("github.base_url")
+	if base == "" {
+		return github.NewClient(tc), nil
+	}
+	upload := viper.GetString("github.upload_url")
+	if upload == "" {
+		upload = strings.Replace(base, "/api/v3", "/api/uploads", 1)
+	}
+	c, err := github.NewEnterpriseClient(base, upload, tc)
+	if err != nil {
+		return nil, fmt.Errorf("github base_url %q or upload_url %q is invalid, %s", base, upload, err.Error())
+	}
+
+	return c, nil
+}
+
+// Deliver implements Sink, filing the finding as an issue in the service's repository. The fingerprint of the finding
+// is kept in a hidden comment of the issue, and a finding is dropped, or commented on the issue with on_duplicate:
+// comment, if an open issue already has its fingerprint. With reopen, a closed issue with the fingerprint is
+// reopened and commented on. If the search fails the issue is filed anyway, since a duplicate is better than a lost
+// error, unless it is rate limited.
+//
+// Once github reports a rate limit, primary or secondary, deliveries fail with a RateLimitError without calling it
+// until the limit resets, so the scanners queue their findings rather than hammer the API. While the circuit breaker
+// is open they fail with ErrCircuitOpen likewise.
+func (g *GitHub) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := g.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	dlv, err := g.deliver(ctx, svc, f)
+	g.guard.record(err)
+
+	return dlv, err
+}
+
+// deliver delivers a finding like Deliver, regardless of the rate limit.
+func (g *GitHub) deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	cl, err := g.client(svc)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if f.Fingerprint != "" && svc.SearchDuplicates {
+		dup, err := findDuplicate(ctx, cl, svc, f.Fingerprint, svc.Reopen)
+		if err != nil && errors.Is(gitHubError(err), ErrRateLimited) {
+			return Delivery{}, gitHubError(err)
+		} else if err != nil {
+			log.Printf("Unable to search duplicates of %s, %s\n", svc.Name, gitHubError(err).Error())
+		} else if dup != nil && dup.GetState() == "closed" {
+			return comment(ctx, cl, svc, dup, f, true)
+		} else if dup != nil && svc.OnDuplicate == config.DuplicateComment {
+			return comment(ctx, cl, svc, dup, f, false)
+		} else if dup != nil {
+			return Delivery{}, fmt.Errorf("%w, issue #%d is open for fingerprint %s", ErrDropped, dup.GetNumber(),
+				f.Fingerprint)
+		}
+	}
+
+	body := f.Body
+	if f.Fingerprint != "" {
+		body = strings.TrimRight(body, "\n") + "\n\n" + FingerprintComment(f.Fingerprint)
+	}
+	issReq := &github.IssueRequest{Title: &f.Title, Body: &body}
+	if len(f.Labels) > 0 {
+		issReq.Labels = &f.Labels
+	}
+	if len(svc.Assignees) > 0 {
+		issReq.Assignees = &svc.Assignees
+	}
+	if svc.Milestone > 0 {
+		issReq.Milestone = &svc.Milestone
+	}
+	iss, _, err := cl.Issues.Create(ctx, svc.RepoOwner, svc.RepoName, issReq)
+	if err != nil {
+		return Delivery{}, gitHubError(err)
+	}
+
+	return Delivery{Number: iss.GetNumber(), URL: iss.GetHTMLURL()}, nil
+}
+
+// Resolve implements Resolver, commenting on the issue and closing it.
+func (g *GitHub) Resolve(ctx context.Context, svc config.Service, number int, comment string) error {
+	if err := g.Comment(ctx, svc, number, comment); err != nil {
+		return err
+	}
+	cl, err := g.client(svc)
+	if err != nil {
+		return err
+	}
+	_, _, err = cl.Issues.Edit(ctx, svc.RepoOwner, svc.RepoName, number,
+		&github.IssueRequest{State: github.String("closed")})
+	if err != nil {
+		return gitHubError(err)
+	}
+
+	return nil
+}
+
+// Comment implements Commenter.
+func (g *GitHub) Comment(ctx context.Context, svc config.Service, number int, comment string) error {
+	cl, err := g.client(svc)
+	if err != nil {
+		return err
+	}
+	_, _, err = cl.Issues.CreateComment(ctx, svc.RepoOwner, svc.RepoName, number,
+		&github.IssueComment{Body: &comment})
+	if err != nil {
+		return gitHubError(err)
+	}
+
+	return nil
+}
+
+// OpenIssues implements OpenCounter, counting the open issues of the service's repository with a fingerprint
+// comment.
+func (g *GitHub) OpenIssues(ctx context.Context, svc config.Service) (int, error) {
+	cl, err := g.client(svc)
+	if err != nil {
+		return 0, err
+	}
+	q := fmt.Sprintf(`repo:%s/%s is:issue is:open "osprey:fingerprint"`, svc.RepoOwner, svc.RepoName)
+	res, _, err := cl.Search.Issues(ctx, q, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	if err != nil {
+		return 0, gitHubError(err)
+	}
+
+	return res.GetTotal(), nil
+}
+
+// FingerprintComment returns the hidden comment an issue body keeps a fingerprint in.
+func FingerprintComment(fingerprint string) string {
+	return fmt.Sprintf("<!-- osprey:fingerprint %s -->", fingerprint)
+}
+
+// occurrences is the hidden comment of an issue body counting the occurrences of its error.
+var occurrences = regexp.MustCompile(`<!-- osprey:occurrences (\d+) -->`)
+
+// countOccurrences returns an issue body with the occurrences counted in its hidden comment, 1 for the filed one,
+// increased by those the finding seen again counts from its scan, and their new number.
+func countOccurrences(body string, f match.Finding) (string, int) {
+	n := 1
+	if m := occurrences.FindStringSubmatch(body); m != nil {
+		n, _ = strconv.Atoi(m[1])
+	}
+	if f.Occurrences > 1 {
+		n += f.Occurrences
+	} else {
+		n++
+	}
+	counter := fmt.Sprintf("<!-- osprey:occurrences %d -->", n)
+	if occurrences.MatchString(body) {
+		return occurrences.ReplaceAllLiteralString(body, counter), n
+	}
+
+	return strings.TrimRight(body, "\n") + "\n" + counter, n
+}
+
+// seenAgain returns the comment on the issue of a finding seen again, n being its number of occurrences.
+func seenAgain(svc config.Service, f match.Finding, n int, reopen bool) string {
+	msg := locale.For(svc.Locale).SeenAgain
+	if reopen {
+		msg = locale.For(svc.Locale).Reopened
+	}
+
+	return fmt.Sprintf(msg, svc.FormatTime(time.Now()), n) + fmt.Sprintf("\n\n```\n%s\n```", f.Line)
+}
+
+// comment comments on the issue of a finding seen again, reopening it if asked to. The occurrences are counted in a
+// hidden comment of the issue body.
+func comment(ctx context.Context, cl *github.Client, svc config.Service, iss *github.Issue, f match.Finding,
+	reopen bool) (Delivery, error) {
+	body, n := countOccurrences(iss.GetBody(), f)
+	edit := &github.IssueRequest{Body: &body}
+	if reopen {
+		edit.State = github.String("open")
+	}
+	if _, _, err := cl.Issues.Edit(ctx, svc.RepoOwner, svc.RepoName, iss.GetNumber(), edit); err != nil {
+		return Delivery{}, gitHubError(err)
+	}
+
+	text := seenAgain(svc, f, n, reopen)
+	c, _, err := cl.Issues.CreateComment(ctx, svc.RepoOwner, svc.RepoName, iss.GetNumber(),
+		&github.IssueComment{Body: &text})
+	if err != nil {
+		return Delivery{}, gitHubError(err)
+	}
+
+	url := c.GetHTMLURL()
+	if url == "" {
+		url = iss.GetHTMLURL()
+	}
+	return Delivery{Number: iss.GetNumber(), URL: url, Updated: true}, nil
+}
+
+// findDuplicate returns an open issue of the service's repository with the fingerprint, or with closed the most
+// recently updated closed one if none is open, nil if there is none. The matches of the search are checked, since
+// it also finds issues merely mentioning the fingerprint's words.
+func findDuplicate(ctx context.Context, cl *github.Client, svc config.Service, fingerprint string,
+	closed bool) (*github.Issue, error) {
+	q := fmt.Sprintf(`repo:%s/%s is:issue is:open "%s"`, svc.RepoOwner, svc.RepoName, fingerprint)
+	if closed {
+		q = fmt.Sprintf(`repo:%s/%s is:issue "%s"`, svc.RepoOwner, svc.RepoName, fingerprint)
+	}
+	res, _, err := cl.Search.Issues(ctx, q, &github.SearchOptions{Sort: "updated", Order: "desc",
+		ListOptions: github.ListOptions{PerPage: 10}})
+	if err != nil {
+		return nil, err
+	}
+	var found *github.Issue
+	for i := range res.Issues {
+		iss := &res.Issues[i]
+		if !strings.Contains(iss.GetBody(), FingerprintComment(fingerprint)) &&
+			!strings.Contains(iss.GetTitle(), fingerprint) {
+			continue
+		}
+		if iss.GetState() != "closed" {
+			return iss, nil
+		}
+		if found == nil {
+			found = iss
+		}
+	}
+
+	return found, nil
+}
+
+// gitHubError wraps a github API error with ErrRateLimited or ErrSinkUnavailable if it is one of those kinds. A rate
+// limit is returned as a RateLimitError, resetting as its response tells.
+func gitHubError(err error) error {
+	var rle *github.RateLimitError
+	if errors.As(err, &rle) {
+		return &RateLimitError{Reset: rle.Rate.Reset.Time, Err: err}
+	}
+	var abuse *github.AbuseRateLimitError
+	if errors.As(err, &abuse) {
+		reset := time.Now().Add(defaultRateLimitPause)
+		if abuse.RetryAfter != nil {
+			reset = time.Now().Add(*abuse.RetryAfter)
+		}
+		return &RateLimitError{Reset: reset, Err: err}
+	}
+
+	var resp *github.ErrorResponse
+	if errors.As(err, &resp) && resp.Response != nil {
+		if reset, ok := rateLimitReset(resp.Response); ok {
+			return &RateLimitError{Reset: reset, Err: err}
+		}
+		return httpError(resp.Response.StatusCode, err)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+
+	return err
+}
+
+// defaultRateLimitPause is how long deliveries pause after a rate limit response telling no reset time.
+const defaultRateLimitPause = time.Minute
+
+// rateLimitReset returns when the rate limit of a response resets, ok is false if it is not a rate limit response.
+// Secondary rate limits send Retry-After, in seconds; primary ones X-RateLimit-Reset, in epoch seconds, once
+// X-RateLimit-Remaining is 0.
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	limited := resp.StatusCode == http.StatusTooManyRequests
+	if resp.StatusCode != http.StatusForbidden && !limited {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second), true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if epoch, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(epoch, 0), true
+		}
+		limited = true
+	}
+	if !limited {
+		return time.Time{}, false
+	}
+
+	return time.Now().Add(defaultRateLimitPause), true
+}
+
+// httpError wraps the error of an HTTP call which returned status with ErrRateLimited or ErrSinkUnavailable if the
+// status is one of those kinds.
+func httpError(status int, err error) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return fmt.Errorf("%w, %w", ErrRateLimited, err)
+	case status >= 500:
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+
+	return err
+}
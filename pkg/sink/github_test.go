@@ -0,0 +1,86 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"net/http"
+	"testing"
+)
+
+// newGitHub returns a github sink, with its circuit breaker, filing issues on a fake server.
+func newGitHub(t *testing.T) (*sink.GitHub, *ospreytest.GitHubServer) {
+	t.Helper()
+
+	srv := ospreytest.NewGitHubServer()
+	t.Cleanup(srv.Close)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("github.base_url", srv.URL+"/")
+	viper.Set("github.breaker.failures", 2)
+	viper.Set("github.breaker.cooldown", "1h")
+
+	g, err := sink.NewGitHub(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, srv
+}
+
+func TestGitHubDuplicates(t *testing.T) {
+	g, srv := newGitHub(t)
+	svc := config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple", SearchDuplicates: true}
+	f := match.Finding{Title: "apple-error", Body: "error: db timeout", Fingerprint: "f00d"}
+
+	tests := []struct {
+		name        string
+		onDuplicate string
+		dropped     bool
+		issues      int
+		comments    int
+	}{
+		{"filed", "", false, 1, 0},
+		{"duplicate dropped", "", true, 1, 0},
+		{"duplicate commented", config.DuplicateComment, false, 1, 1},
+	}
+	for _, tt := range tests {
+		svc.OnDuplicate = tt.onDuplicate
+		_, err := g.Deliver(context.Background(), svc, f)
+		if errors.Is(err, sink.ErrDropped) != tt.dropped || (err != nil && !tt.dropped) {
+			t.Fatalf("%s: got %v, want dropped %v", tt.name, err, tt.dropped)
+		}
+		issues := srv.Issues("owner", "apple")
+		if len(issues) != tt.issues || len(issues[0].Comments) != tt.comments {
+			t.Fatalf("%s: got %d issues, %d comments, want %d, %d", tt.name, len(issues), len(issues[0].Comments),
+				tt.issues, tt.comments)
+		}
+	}
+}
+
+func TestGitHubCircuitBreaker(t *testing.T) {
+	g, srv := newGitHub(t)
+	svc := config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple"}
+	f := match.Finding{Title: "apple-error", Body: "error: db timeout"}
+
+	srv.Status = http.StatusBadGateway
+	for i := 0; i < 2; i++ {
+		if _, err := g.Deliver(context.Background(), svc, f); !errors.Is(err, sink.ErrSinkUnavailable) {
+			t.Fatalf("delivery %d: got %v, want ErrSinkUnavailable", i+1, err)
+		}
+	}
+
+	// github is back, but the breaker stays open until its cooldown.
+	srv.Status = 0
+	if _, err := g.Deliver(context.Background(), svc, f); !errors.Is(err, sink.ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if n := len(srv.Issues("owner", "apple")); n != 0 {
+		t.Fatalf("got %d issues, want none filed while the breaker is open", n)
+	}
+}
@@ -0,0 +1,225 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SecretGitLabToken is the name of the gitlab token secret, read for services without a token of their own.
+	SecretGitLabToken = "gitlab_token"
+
+	defaultGitLabURL = "https://gitlab.com/api/v4/"
+)
+
+func init() {
+	Register("gitlab", shareTracker("gitlab", func() (Tracker, error) { return NewGitLab() }))
+}
+
+// GitLab is a Tracker filing issues in GitLab projects, on gitlab.com unless gitlab.base_url in config file names
+// the API of a self-managed instance:
+//
+//	gitlab:
+//	  base_url: https://gitlab.corp.example/api/v4/
+//	services:
+//	  apple:
+//	    tracker: gitlab
+//	    gitlab:
+//	      project: 1234
+//
+// The project is its ID or path, repo_owner/repo_name by default. The token is that of the service's token keys,
+// or the gitlab_token secret of the credentials provider.
+type GitLab struct {
+	api *restClient
+
+	tokens *trackerTokens
+}
+
+// NewGitLab returns the gitlab tracker of config file.
+func NewGitLab() (*GitLab, error) {
+	g := &GitLab{tokens: &trackerTokens{tracker: "gitlab", secret: SecretGitLabToken}}
+	base := viper.GetString("gitlab.base_url")
+	if base == "" {
+		base = defaultGitLabURL
+	}
+	api, err := newRESTClient("gitlab", base, g.authorize)
+	if err != nil {
+		return nil, err
+	}
+	g.api = api
+
+	return g, nil
+}
+
+// authorize sets the token of the service on a request.
+func (g *GitLab) authorize(req *http.Request, svc config.Service) error {
+	tk, err := g.tokens.token(svc)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", tk)
+
+	return nil
+}
+
+// gitLabIssue is an issue of the gitlab API.
+type gitLabIssue struct {
+	IID         int    `json:"iid"`
+	WebURL      string `json:"web_url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+}
+
+// ticket returns the issue as a Ticket.
+func (iss gitLabIssue) ticket() Ticket {
+	return Ticket{Number: iss.IID, URL: iss.WebURL, Title: iss.Title, Body: iss.Description,
+		Closed: iss.State == "closed"}
+}
+
+// project returns the escaped path of the service's project in the API, or an error if it has none.
+func (g *GitLab) project(svc config.Service) (string, error) {
+	p := viper.GetString(config.Key(svc.Name, "gitlab.project"))
+	if p == "" && svc.RepoOwner != "" && svc.RepoName != "" {
+		p = svc.RepoOwner + "/" + svc.RepoName
+	}
+	if p == "" {
+		return "", fmt.Errorf("gitlab tracker of %s needs gitlab.project, or repo_owner and repo_name", svc.Name)
+	}
+
+	return "projects/" + url.PathEscape(p), nil
+}
+
+// Create implements Tracker. The assignees are looked up by their usernames and the milestone is its ID.
+func (g *GitLab) Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error) {
+	p, err := g.project(svc)
+	if err != nil {
+		return Ticket{}, err
+	}
+	req := map[string]interface{}{"title": iss.Title, "description": iss.Body}
+	if len(iss.Labels) > 0 {
+		req["labels"] = strings.Join(iss.Labels, ",")
+	}
+	if len(iss.Assignees) > 0 {
+		ids, err := g.userIDs(ctx, svc, iss.Assignees)
+		if err != nil {
+			return Ticket{}, err
+		}
+		req["assignee_ids"] = ids
+	}
+	if iss.Milestone > 0 {
+		req["milestone_id"] = iss.Milestone
+	}
+	var created gitLabIssue
+	if _, err := g.api.do(ctx, svc, http.MethodPost, p+"/issues", req, &created); err != nil {
+		return Ticket{}, err
+	}
+
+	return created.ticket(), nil
+}
+
+// userIDs returns the IDs of the users with the usernames, leaving out unknown ones.
+func (g *GitLab) userIDs(ctx context.Context, svc config.Service, usernames []string) ([]int, error) {
+	var ids []int
+	for _, name := range usernames {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		if _, err := g.api.do(ctx, svc, http.MethodGet, "users?username="+url.QueryEscape(name), nil,
+			&users); err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// Find implements Tracker.
+func (g *GitLab) Find(ctx context.Context, svc config.Service, fingerprint string, closed bool) ([]Ticket, error) {
+	q := url.Values{"search": {fingerprint}, "in": {"description"}, "order_by": {"updated_at"},
+		"sort": {"desc"}, "per_page": {"10"}}
+	if !closed {
+		q.Set("state", "opened")
+	}
+	p, err := g.project(svc)
+	if err != nil {
+		return nil, err
+	}
+	var found []gitLabIssue
+	if _, err := g.api.do(ctx, svc, http.MethodGet, p+"/issues?"+q.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(found))
+	for _, iss := range found {
+		tickets = append(tickets, iss.ticket())
+	}
+
+	return tickets, nil
+}
+
+// Edit implements Tracker.
+func (g *GitLab) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	p, err := g.project(svc)
+	if err != nil {
+		return err
+	}
+	_, err = g.api.do(ctx, svc, http.MethodPut, fmt.Sprintf("%s/issues/%d", p, number),
+		map[string]string{"description": body}, nil)
+	return err
+}
+
+// SetClosed implements Tracker.
+func (g *GitLab) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	event := "reopen"
+	if closed {
+		event = "close"
+	}
+	p, err := g.project(svc)
+	if err != nil {
+		return err
+	}
+	_, err = g.api.do(ctx, svc, http.MethodPut, fmt.Sprintf("%s/issues/%d", p, number),
+		map[string]string{"state_event": event}, nil)
+	return err
+}
+
+// AddComment implements Tracker. Notes have no url of their own in the API, so none is returned.
+func (g *GitLab) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	p, err := g.project(svc)
+	if err != nil {
+		return "", err
+	}
+	_, err = g.api.do(ctx, svc, http.MethodPost, fmt.Sprintf("%s/issues/%d/notes", p, number),
+		map[string]string{"body": text}, nil)
+	return "", err
+}
+
+// CountOpen implements Tracker, from the total gitlab tells in the X-Total header.
+func (g *GitLab) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	q := url.Values{"search": {"osprey:fingerprint"}, "in": {"description"}, "state": {"opened"},
+		"per_page": {"1"}}
+	p, err := g.project(svc)
+	if err != nil {
+		return 0, err
+	}
+	var found []gitLabIssue
+	h, err := g.api.do(ctx, svc, http.MethodGet, p+"/issues?"+q.Encode(), nil, &found)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(h.Get("X-Total"))
+	if err != nil {
+		return 0, fmt.Errorf("gitlab did not tell the number of open issues of %s", svc.Name)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,136 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newGitLab returns a gitlab tracker calling a fake API, with the token glpat-apple for service apple.
+func newGitLab(t *testing.T) (*sink.GitLab, *apiServer) {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("gitlab.base_url", srv.URL+"/api/v4")
+	viper.Set(config.Key("apple", "token"), "glpat-apple")
+
+	g, err := sink.NewGitLab()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g, srv
+}
+
+func TestGitLabCreate(t *testing.T) {
+	g, srv := newGitLab(t)
+	srv.handle("GET /api/v4/users", http.StatusOK, `[{"id": 7}]`)
+	srv.handle("POST /api/v4/projects/owner%2Fapple/issues", http.StatusCreated,
+		`{"iid": 3, "web_url": "https://gitlab.example.com/owner/apple/-/issues/3"}`)
+	svc := config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple"}
+
+	tk, err := g.Create(context.Background(), svc, sink.Issue{Title: "apple: db timeout", Body: "error: db timeout",
+		Labels: []string{"bug", "osprey"}, Assignees: []string{"alice"}, Milestone: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Number != 3 || tk.URL != "https://gitlab.example.com/owner/apple/-/issues/3" {
+		t.Fatalf("got %+v, want issue 3", tk)
+	}
+
+	call := srv.call(t, "POST /api/v4/projects/owner%2Fapple/issues")
+	if got := call.header.Get("PRIVATE-TOKEN"); got != "glpat-apple" {
+		t.Fatalf("got token %q, want that of the service", got)
+	}
+	body := call.decode(t)
+	tests := []struct {
+		field string
+		want  interface{}
+	}{
+		{"title", "apple: db timeout"},
+		{"labels", "bug,osprey"},
+		{"milestone_id", float64(2)},
+	}
+	for _, tt := range tests {
+		if body[tt.field] != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.field, body[tt.field], tt.want)
+		}
+	}
+	if ids, _ := body["assignee_ids"].([]interface{}); len(ids) != 1 || ids[0] != float64(7) {
+		t.Fatalf("got assignees %v, want the id of alice", body["assignee_ids"])
+	}
+	if q := srv.call(t, "GET /api/v4/users").query; q != "username=alice" {
+		t.Fatalf("got query %q, want the username", q)
+	}
+}
+
+func TestGitLabDuplicates(t *testing.T) {
+	g, srv := newGitLab(t)
+	s := sink.NewTrackerSink("gitlab", g)
+	srv.handle("GET /api/v4/projects/1234/issues", http.StatusOK,
+		`[{"iid": 3, "state": "opened", "description": "error: db timeout\n\n`+sink.FingerprintComment("f00d")+`"}]`)
+	viper.Set(config.Key("apple", "gitlab.project"), "1234")
+	svc := config.Service{Name: "apple", SearchDuplicates: true}
+
+	_, err := s.Deliver(context.Background(), svc, match.Finding{Title: "apple: db timeout", Fingerprint: "f00d"})
+	if !errors.Is(err, sink.ErrDropped) {
+		t.Fatalf("got %v, want ErrDropped", err)
+	}
+	q := srv.call(t, "GET /api/v4/projects/1234/issues").query
+	if !strings.Contains(q, "search=f00d") || !strings.Contains(q, "state=opened") {
+		t.Fatalf("got query %q, want a search of the open issues for the fingerprint", q)
+	}
+}
+
+func TestGitLabCountOpen(t *testing.T) {
+	g, srv := newGitLab(t)
+	srv.handleWithHeader("GET /api/v4/projects/owner%2Fapple/issues", http.StatusOK,
+		map[string]string{"X-Total": "12"}, `[]`)
+
+	n, err := g.CountOpen(context.Background(), config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple"})
+	if err != nil || n != 12 {
+		t.Fatalf("got %d, %v, want 12", n, err)
+	}
+}
+
+func TestGitLabErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		svc     config.Service
+		kind    error
+		limited bool
+	}{
+		{"unavailable", http.StatusBadGateway, nil, config.Service{Name: "apple", RepoOwner: "owner",
+			RepoName: "apple"}, sink.ErrSinkUnavailable, false},
+		{"rate limited", http.StatusTooManyRequests, map[string]string{"Retry-After": "30"},
+			config.Service{Name: "apple", RepoOwner: "owner", RepoName: "apple"}, nil, true},
+		{"no project", http.StatusCreated, nil, config.Service{Name: "apple"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, srv := newGitLab(t)
+			srv.handleWithHeader("POST /api/v4/projects/owner%2Fapple/issues", tt.status, tt.header, `{}`)
+
+			_, err := g.Create(context.Background(), tt.svc, sink.Issue{Title: "apple: db timeout"})
+			if err == nil {
+				t.Fatal("got nil, want an error")
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Fatalf("got %v, want %v", err, tt.kind)
+			}
+			if _, ok := sink.RetryAt(err); ok != tt.limited {
+				t.Fatalf("got %v, want rate limited %v", err, tt.limited)
+			}
+		})
+	}
+}
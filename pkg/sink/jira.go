@@ -0,0 +1,272 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SecretJiraToken is the name of the jira token secret, read for services without a token of their own.
+	SecretJiraToken = "jira_token"
+
+	defaultJiraIssueType = "Bug"
+
+	// jiraDone is the status category of resolved issues.
+	jiraDone = "done"
+)
+
+func init() {
+	Register("jira", shareTracker("jira", func() (Tracker, error) { return NewJira() }))
+}
+
+// Jira is a Tracker filing issues in Jira projects, of Jira Cloud or a Jira Server or Data Center at jira.base_url in
+// config file:
+//
+//	jira:
+//	  base_url: https://acme.atlassian.net
+//	  user: osprey@acme.example
+//	services:
+//	  apple:
+//	    tracker: jira
+//	    jira:
+//	      project: APPLE
+//	      issue_type: Bug
+//
+// With a user, as Jira Cloud needs, the token is its API token; without one it is a personal access token of Jira
+// Server. The token is that of the service's token keys, or the jira_token secret of the credentials provider.
+type Jira struct {
+	api *restClient
+
+	tokens *trackerTokens
+
+	// user is the user of the API token, empty for a personal access token.
+	user string
+
+	// closeTransition and reopenTransition name the transitions closing and reopening issues, empty for the first
+	// one into, or out of, the done status category.
+	closeTransition, reopenTransition string
+}
+
+// NewJira returns the jira tracker of config file.
+func NewJira() (*Jira, error) {
+	j := &Jira{
+		tokens:           &trackerTokens{tracker: "jira", secret: SecretJiraToken},
+		user:             viper.GetString("jira.user"),
+		closeTransition:  viper.GetString("jira.close_transition"),
+		reopenTransition: viper.GetString("jira.reopen_transition"),
+	}
+	base := viper.GetString("jira.base_url")
+	if base == "" {
+		return nil, errors.New("jira tracker needs jira.base_url")
+	}
+	api, err := newRESTClient("jira", base, j.authorize)
+	if err != nil {
+		return nil, err
+	}
+	j.api = api
+
+	return j, nil
+}
+
+// authorize sets the token of the service on a request.
+func (j *Jira) authorize(req *http.Request, svc config.Service) error {
+	tk, err := j.tokens.token(svc)
+	if err != nil {
+		return err
+	}
+	if j.user != "" {
+		req.SetBasicAuth(j.user, tk)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+tk)
+	}
+
+	return nil
+}
+
+// jiraIssue is an issue of the jira API.
+type jiraIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Status      struct {
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"status"`
+	} `json:"fields"`
+}
+
+// ticket returns the issue as a Ticket, numbered by its ID.
+func (j *Jira) ticket(iss jiraIssue) Ticket {
+	n, _ := strconv.Atoi(iss.ID)
+	return Ticket{Number: n, URL: j.browseURL(iss.Key), Title: iss.Fields.Summary, Body: iss.Fields.Description,
+		Closed: iss.Fields.Status.StatusCategory.Key == jiraDone}
+}
+
+// browseURL returns the url of the issue with the key in the jira UI.
+func (j *Jira) browseURL(key string) string {
+	u := *j.api.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/browse/" + key
+
+	return u.String()
+}
+
+// project returns the key of the service's project.
+func (j *Jira) project(svc config.Service) (string, error) {
+	p := viper.GetString(config.Key(svc.Name, "jira.project"))
+	if p == "" {
+		return "", fmt.Errorf("jira tracker of %s needs jira.project", svc.Name)
+	}
+
+	return p, nil
+}
+
+// Create implements Tracker. Spaces in labels, which jira does not allow, become underscores. The issue is assigned
+// to the first assignee, an account ID on Jira Cloud or a user name on Jira Server; milestones are not supported.
+func (j *Jira) Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error) {
+	p, err := j.project(svc)
+	if err != nil {
+		return Ticket{}, err
+	}
+	issueType := viper.GetString(config.Key(svc.Name, "jira.issue_type"))
+	if issueType == "" {
+		issueType = defaultJiraIssueType
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": p},
+		"issuetype":   map[string]string{"name": issueType},
+		"summary":     iss.Title,
+		"description": iss.Body,
+	}
+	if len(iss.Labels) > 0 {
+		labels := make([]string, 0, len(iss.Labels))
+		for _, l := range iss.Labels {
+			labels = append(labels, strings.Join(strings.Fields(l), "_"))
+		}
+		fields["labels"] = labels
+	}
+	if len(iss.Assignees) > 0 {
+		if j.user != "" {
+			fields["assignee"] = map[string]string{"accountId": iss.Assignees[0]}
+		} else {
+			fields["assignee"] = map[string]string{"name": iss.Assignees[0]}
+		}
+	}
+	var created jiraIssue
+	if _, err := j.api.do(ctx, svc, http.MethodPost, "rest/api/2/issue",
+		map[string]interface{}{"fields": fields}, &created); err != nil {
+		return Ticket{}, err
+	}
+	n, _ := strconv.Atoi(created.ID)
+
+	return Ticket{Number: n, URL: j.browseURL(created.Key), Title: iss.Title, Body: iss.Body}, nil
+}
+
+// search returns the issues of the service's project matching a JQL condition and their total.
+func (j *Jira) search(ctx context.Context, svc config.Service, cond string, max int) ([]jiraIssue, int, error) {
+	p, err := j.project(svc)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := url.Values{
+		"jql":        {fmt.Sprintf("project = %q AND %s ORDER BY updated DESC", p, cond)},
+		"fields":     {"summary,description,status"},
+		"maxResults": {strconv.Itoa(max)},
+	}
+	var res struct {
+		Issues []jiraIssue `json:"issues"`
+		Total  int         `json:"total"`
+	}
+	if _, err := j.api.do(ctx, svc, http.MethodGet, "rest/api/2/search?"+q.Encode(), nil, &res); err != nil {
+		return nil, 0, err
+	}
+
+	return res.Issues, res.Total, nil
+}
+
+// Find implements Tracker.
+func (j *Jira) Find(ctx context.Context, svc config.Service, fingerprint string, closed bool) ([]Ticket, error) {
+	cond := fmt.Sprintf("text ~ %q", fingerprint)
+	if !closed {
+		cond += " AND statusCategory != Done"
+	}
+	found, _, err := j.search(ctx, svc, cond, 10)
+	if err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(found))
+	for _, iss := range found {
+		tickets = append(tickets, j.ticket(iss))
+	}
+
+	return tickets, nil
+}
+
+// Edit implements Tracker.
+func (j *Jira) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	_, err := j.api.do(ctx, svc, http.MethodPut, fmt.Sprintf("rest/api/2/issue/%d", number),
+		map[string]interface{}{"fields": map[string]string{"description": body}}, nil)
+	return err
+}
+
+// SetClosed implements Tracker, by the transition jira.close_transition or jira.reopen_transition names, or else the
+// first one of the issue into, or out of, the done status category.
+func (j *Jira) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	var res struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	path := fmt.Sprintf("rest/api/2/issue/%d/transitions", number)
+	if _, err := j.api.do(ctx, svc, http.MethodGet, path, nil, &res); err != nil {
+		return err
+	}
+	name := j.reopenTransition
+	if closed {
+		name = j.closeTransition
+	}
+	for _, t := range res.Transitions {
+		ok := strings.EqualFold(t.Name, name)
+		if name == "" {
+			ok = (t.To.StatusCategory.Key == jiraDone) == closed
+		}
+		if ok {
+			_, err := j.api.do(ctx, svc, http.MethodPost, path,
+				map[string]interface{}{"transition": map[string]string{"id": t.ID}}, nil)
+			return err
+		}
+	}
+
+	if closed {
+		return fmt.Errorf("jira issue %d of %s has no transition closing it", number, svc.Name)
+	}
+	return fmt.Errorf("jira issue %d of %s has no transition reopening it", number, svc.Name)
+}
+
+// AddComment implements Tracker.
+func (j *Jira) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	_, err := j.api.do(ctx, svc, http.MethodPost, fmt.Sprintf("rest/api/2/issue/%d/comment", number),
+		map[string]string{"body": text}, nil)
+	return "", err
+}
+
+// CountOpen implements Tracker.
+func (j *Jira) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	_, total, err := j.search(ctx, svc, `text ~ "\"osprey:fingerprint\"" AND statusCategory != Done`, 0)
+	return total, err
+}
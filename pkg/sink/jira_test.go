@@ -0,0 +1,154 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// jiraTransitions are the transitions of a jira issue, of an in progress and a done status.
+const jiraTransitions = `{"transitions": [
+	{"id": "11", "name": "Start", "to": {"statusCategory": {"key": "indeterminate"}}},
+	{"id": "31", "name": "Resolve", "to": {"statusCategory": {"key": "done"}}}]}`
+
+// newJira returns a jira tracker calling a fake API, with the project APPLE and token jira-apple for service apple.
+func newJira(t *testing.T, conf map[string]string) (*sink.Jira, *apiServer) {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("jira.base_url", srv.URL)
+	viper.Set(config.Key("apple", "token"), "jira-apple")
+	viper.Set(config.Key("apple", "jira.project"), "APPLE")
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+
+	j, err := sink.NewJira()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return j, srv
+}
+
+func TestJiraCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		auth     string
+		assignee string
+	}{
+		{"cloud", "osprey@acme.example", "Basic ", "accountId"},
+		{"server", "", "Bearer jira-apple", "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, srv := newJira(t, map[string]string{"jira.user": tt.user})
+			srv.handle("POST /rest/api/2/issue", http.StatusCreated, `{"id": "10042", "key": "APPLE-7"}`)
+
+			tk, err := j.Create(context.Background(), config.Service{Name: "apple"}, sink.Issue{
+				Title: "apple: db timeout", Body: "error: db timeout", Labels: []string{"high severity"},
+				Assignees: []string{"alice"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tk.Number != 10042 || tk.URL != srv.URL+"/browse/APPLE-7" {
+				t.Fatalf("got %+v, want issue 10042 browsed as APPLE-7", tk)
+			}
+
+			call := srv.call(t, "POST /rest/api/2/issue")
+			if got := call.header.Get("Authorization"); !strings.HasPrefix(got, tt.auth) {
+				t.Fatalf("got authorization %q, want %q", got, tt.auth)
+			}
+			fields, _ := call.decode(t)["fields"].(map[string]interface{})
+			if p, _ := fields["project"].(map[string]interface{}); p["key"] != "APPLE" {
+				t.Fatalf("got project %v, want APPLE", fields["project"])
+			}
+			if it, _ := fields["issuetype"].(map[string]interface{}); it["name"] != "Bug" {
+				t.Fatalf("got issue type %v, want Bug", fields["issuetype"])
+			}
+			// Jira does not allow spaces in labels.
+			if labels, _ := fields["labels"].([]interface{}); len(labels) != 1 || labels[0] != "high_severity" {
+				t.Fatalf("got labels %v, want high_severity", fields["labels"])
+			}
+			if a, _ := fields["assignee"].(map[string]interface{}); a[tt.assignee] != "alice" {
+				t.Fatalf("got assignee %v, want alice by %s", fields["assignee"], tt.assignee)
+			}
+		})
+	}
+}
+
+func TestJiraFind(t *testing.T) {
+	j, srv := newJira(t, nil)
+	srv.handle("GET /rest/api/2/search", http.StatusOK, `{"total": 1, "issues": [{"id": "10042", "key": "APPLE-7",
+		"fields": {"summary": "apple: db timeout", "description": "f00d", "status": {"statusCategory": {"key": "done"}}}}]}`)
+
+	tickets, err := j.Find(context.Background(), config.Service{Name: "apple"}, "f00d", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 1 || tickets[0].Number != 10042 || !tickets[0].Closed {
+		t.Fatalf("got %+v, want the closed issue 10042", tickets)
+	}
+	q, err := url.ParseQuery(srv.call(t, "GET /rest/api/2/search").query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `project = "APPLE" AND text ~ "f00d" AND statusCategory != Done ORDER BY updated DESC`
+	if q.Get("jql") != want {
+		t.Fatalf("got jql %q, want %q", q.Get("jql"), want)
+	}
+}
+
+func TestJiraSetClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   map[string]string
+		closed bool
+		want   string
+	}{
+		{"close", nil, true, "31"},
+		{"reopen", nil, false, "11"},
+		{"named transition", map[string]string{"jira.close_transition": "start"}, true, "11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, srv := newJira(t, tt.conf)
+			srv.handle("GET /rest/api/2/issue/10042/transitions", http.StatusOK, jiraTransitions)
+			srv.handle("POST /rest/api/2/issue/10042/transitions", http.StatusNoContent, "")
+
+			if err := j.SetClosed(context.Background(), config.Service{Name: "apple"}, 10042, tt.closed); err != nil {
+				t.Fatal(err)
+			}
+			call := srv.call(t, "POST /rest/api/2/issue/10042/transitions")
+			tr, _ := call.decode(t)["transition"].(map[string]interface{})
+			if tr["id"] != tt.want {
+				t.Fatalf("got transition %v, want %s", tr["id"], tt.want)
+			}
+		})
+	}
+}
+
+func TestJiraNeedsConfig(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	if _, err := sink.NewJira(); err == nil {
+		t.Fatal("got nil, want an error without base_url")
+	}
+	viper.Set("jira.base_url", "https://acme.atlassian.net")
+	j, err := sink.NewJira()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Create(context.Background(), config.Service{Name: "apple"}, sink.Issue{}); err == nil {
+		t.Fatal("got nil, want an error without jira.project")
+	}
+}
@@ -0,0 +1,431 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretMQTTPassword is the name of the secret holding the password of mqtt.user.
+	SecretMQTTPassword = "mqtt_password"
+
+	defaultMQTTURL = "tcp://localhost:1883"
+
+	// mqttTimeout bounds connecting to the broker and a publish.
+	mqttTimeout = 10 * time.Second
+
+	// mqttKeepAlive is the keep alive the client tells the broker, which drops connections idle for longer; the
+	// next publish then connects again.
+	mqttKeepAlive = 60
+
+	// mqttMaxPacket is the largest remaining length of an MQTT packet.
+	mqttMaxPacket = 268435455
+)
+
+// MQTT control packet types.
+const (
+	mqttConnect  = 1
+	mqttConnAck  = 2
+	mqttPublish  = 3
+	mqttPubAck   = 4
+	mqttPubRec   = 5
+	mqttPubRel   = 6
+	mqttPubComp  = 7
+	mqttPingReq  = 12
+	mqttPingResp = 13
+)
+
+// mqttRefusals are the reasons of the return codes of a CONNACK refusing a connection.
+var mqttRefusals = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+func init() {
+	Register("mqtt", share(func() (Sink, error) { return NewMQTT() }))
+}
+
+// MQTT publishes findings as JSON events, like the webhook sink's default documents, with MQTT 3.1.1 to the broker
+// mqtt.url in config file names, on the topic osprey/findings/<service> unless the service has an mqtt.topic:
+//
+//	mqtt:
+//	  url: ssl://broker.local:8883
+//	  user: osprey
+//	  qos: 1
+//	services:
+//	  apple:
+//	    sinks: [mqtt]
+//	    mqtt:
+//	      topic: site-12/alerts/apple
+//
+// The password of the user is the mqtt_password secret. Connections use TLS if the url's scheme is ssl, tls or
+// mqtts, with the TLS settings of tls.mqtt. With QoS 1 a publish waits for the broker's PUBACK, with 2 for its
+// PUBCOMP, and with 0, the default, for the answer to a PINGREQ sent after it, so a dead connection is noticed.
+type MQTT struct {
+	url *url.URL
+
+	// secure tells whether connections use TLS.
+	secure bool
+
+	clientID string
+
+	// retain tells whether the broker keeps the last event of each topic for new subscribers.
+	retain bool
+
+	mu sync.Mutex
+
+	// conn is the connection to the broker, nil until connected, and r reads from it.
+	conn net.Conn
+	r    *bufio.Reader
+
+	// id is the identifier of the last packet published with QoS 1 or 2.
+	id uint16
+
+	// guard refuses deliveries while the broker is down.
+	guard *guard
+}
+
+// mqttError is an error of the broker which another connection would not fix, e.g. a refused login.
+type mqttError struct {
+	msg string
+}
+
+// Error implements error.
+func (e *mqttError) Error() string {
+	return "mqtt broker error, " + e.msg
+}
+
+// NewMQTT returns the MQTT sink of config file.
+func NewMQTT() (*MQTT, error) {
+	raw := viper.GetString("mqtt.url")
+	if raw == "" {
+		raw = defaultMQTTURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt.url %q is invalid, want tcp://host:port or ssl://host:port", raw)
+	}
+	m := &MQTT{url: u, retain: viper.GetBool("mqtt.retain"), guard: newGuard("mqtt")}
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		m.secure, port = true, "8883"
+	default:
+		return nil, fmt.Errorf("mqtt.url %q is invalid, want tcp://host:port or ssl://host:port", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	m.clientID = viper.GetString("mqtt.client_id")
+	if m.clientID == "" {
+		// The broker drops the other connection of a client ID, so each device needs its own.
+		m.clientID = "osprey"
+		if host, err := os.Hostname(); err == nil && host != "" {
+			m.clientID += "-" + host
+		}
+	}
+
+	return m, nil
+}
+
+// Deliver implements Sink.
+func (m *MQTT) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	topic := setting(svc, "mqtt.topic")
+	if topic == "" {
+		topic = "osprey/findings/" + svc.Name
+	}
+	if strings.ContainsAny(topic, "+#\x00") {
+		return Delivery{}, fmt.Errorf("mqtt topic %q of %s is invalid, it has a wildcard", topic, svc.Name)
+	}
+	qos := 0
+	if v := setting(svc, "mqtt.qos"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 2 {
+			return Delivery{}, fmt.Errorf("mqtt qos %q of %s is invalid, want 0, 1 or 2", v, svc.Name)
+		}
+		qos = n
+	}
+	body, err := json.Marshal(newFindingEvent(svc, f))
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err := m.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err = m.publish(ctx, topic, byte(qos), body)
+	m.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// publish publishes an event, connecting first if needed. A connection the broker dropped while it was idle is
+// replaced once, so with QoS 1 or 2 the event may be published twice. Errors other than those of mqttError wrap
+// ErrSinkUnavailable.
+func (m *MQTT) publish(ctx context.Context, topic string, qos byte, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reused := m.conn != nil
+	err := m.try(ctx, topic, qos, body)
+	var merr *mqttError
+	if err != nil && reused && !errors.As(err, &merr) {
+		m.close()
+		err = m.try(ctx, topic, qos, body)
+	}
+	if err != nil && !errors.As(err, &merr) {
+		m.close()
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+
+	return err
+}
+
+// try publishes an event and waits for the broker to acknowledge it. Call with mu held.
+func (m *MQTT) try(ctx context.Context, topic string, qos byte, body []byte) error {
+	if m.conn == nil {
+		if err := m.connect(ctx); err != nil {
+			return err
+		}
+	}
+	m.conn.SetDeadline(deadline(ctx, mqttTimeout))
+
+	vh := mqttString(nil, topic)
+	var id uint16
+	if qos > 0 {
+		m.id++
+		if m.id == 0 {
+			m.id = 1
+		}
+		id = m.id
+		vh = binary.BigEndian.AppendUint16(vh, id)
+	}
+	if len(vh)+len(body) > mqttMaxPacket {
+		return &mqttError{msg: fmt.Sprintf("event of %d bytes is too large", len(body))}
+	}
+	flags := qos << 1
+	if m.retain {
+		flags |= 1
+	}
+	pkt := mqttPacket(mqttPublish<<4|flags, append(vh, body...))
+
+	switch qos {
+	case 0:
+		if _, err := m.conn.Write(append(pkt, mqttPingReq<<4, 0)); err != nil {
+			return err
+		}
+		return m.await(mqttPingResp, 0)
+	case 1:
+		if _, err := m.conn.Write(pkt); err != nil {
+			return err
+		}
+		return m.await(mqttPubAck, id)
+	default:
+		if _, err := m.conn.Write(pkt); err != nil {
+			return err
+		}
+		if err := m.await(mqttPubRec, id); err != nil {
+			return err
+		}
+		rel := mqttPacket(mqttPubRel<<4|2, binary.BigEndian.AppendUint16(nil, id))
+		if _, err := m.conn.Write(rel); err != nil {
+			return err
+		}
+		return m.await(mqttPubComp, id)
+	}
+}
+
+// await reads the packets of the broker until one of the type acknowledging the packet id, skipping the
+// acknowledgements of earlier publishes. Call with mu held.
+func (m *MQTT) await(typ byte, id uint16) error {
+	for {
+		t, data, err := m.read()
+		if err != nil {
+			return err
+		}
+		if t>>4 != typ {
+			continue
+		}
+		if typ == mqttPingResp || (len(data) >= 2 && binary.BigEndian.Uint16(data) == id) {
+			return nil
+		}
+	}
+}
+
+// read reads a packet of the broker, returning its first byte and its remaining bytes. Call with mu held.
+func (m *MQTT) read() (byte, []byte, error) {
+	t, err := m.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		b, err := m.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mul
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("malformed mqtt packet length")
+		}
+		mul *= 128
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(m.r, data); err != nil {
+		return 0, nil, err
+	}
+
+	return t, data, nil
+}
+
+// connect connects to the broker with a clean session, over TLS if asked to, and logs in. Call with mu held.
+func (m *MQTT) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: mqttTimeout}
+	conn, err := d.DialContext(ctx, "tcp", m.url.Host)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline(ctx, mqttTimeout))
+
+	if m.secure {
+		cfg, err := transport.TLSConfig("mqtt")
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("invalid TLS settings for mqtt, %s", err.Error())
+		}
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = m.url.Hostname()
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tc
+	}
+
+	pkt, err := m.connectPacket()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	m.conn, m.r = conn, bufio.NewReader(conn)
+	if _, err := conn.Write(pkt); err != nil {
+		m.close()
+		return err
+	}
+	t, data, err := m.read()
+	if err != nil {
+		m.close()
+		return err
+	}
+	if t>>4 != mqttConnAck || len(data) < 2 {
+		m.close()
+		return fmt.Errorf("%s is not an mqtt broker", m.url.Host)
+	}
+	if code := data[1]; code != 0 {
+		m.close()
+		reason, ok := mqttRefusals[code]
+		if !ok {
+			reason = "return code " + strconv.Itoa(int(code))
+		}
+		if code == 3 {
+			return errors.New("mqtt broker refused the connection, " + reason)
+		}
+		return &mqttError{msg: "connection refused, " + reason}
+	}
+
+	return nil
+}
+
+// connectPacket returns the CONNECT packet of the client, with the credentials of config file or the url.
+func (m *MQTT) connectPacket() ([]byte, error) {
+	user := viper.GetString("mqtt.user")
+	if user == "" && m.url.User != nil {
+		user = m.url.User.Username()
+	}
+	var flags byte = 0x02
+	payload := mqttString(nil, m.clientID)
+	if user != "" {
+		pass, ok := "", false
+		if m.url.User != nil {
+			pass, ok = m.url.User.Password()
+		}
+		if !ok {
+			var err error
+			if pass, err = secret(SecretMQTTPassword); err != nil {
+				return nil, err
+			}
+		}
+		flags |= 0x80 | 0x40
+		payload = mqttString(mqttString(payload, user), pass)
+	}
+
+	vh := mqttString(nil, "MQTT")
+	vh = append(vh, 4, flags)
+	vh = binary.BigEndian.AppendUint16(vh, mqttKeepAlive)
+
+	return mqttPacket(mqttConnect<<4, append(vh, payload...)), nil
+}
+
+// mqttString appends a length prefixed UTF-8 string to b.
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket returns a packet of the first byte and the remaining bytes, with their length in between.
+func mqttPacket(first byte, rest []byte) []byte {
+	pkt := make([]byte, 0, len(rest)+5)
+	pkt = append(pkt, first)
+	n := len(rest)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(pkt, rest...)
+}
+
+// close closes the connection. Call with mu held.
+func (m *MQTT) close() {
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.conn, m.r = nil, nil
+}
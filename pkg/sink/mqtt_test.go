@@ -0,0 +1,318 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mqttBroker is a fake MQTT 3.1.1 broker, refusing the logins of user banned.
+type mqttBroker struct {
+	addr string
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	connects []mqttConnect
+	pubs     []mqttPub
+}
+
+// mqttConnect is the CONNECT packet of a client.
+type mqttConnect struct {
+	clientID, user, pass string
+}
+
+// mqttPub is a message published to an mqttBroker.
+type mqttPub struct {
+	topic   string
+	qos     byte
+	retain  bool
+	payload string
+}
+
+// newMQTTBroker starts a fake MQTT broker.
+func newMQTTBroker(t *testing.T) *mqttBroker {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &mqttBroker{addr: l.Addr().String()}
+	t.Cleanup(func() {
+		l.Close()
+		b.dropAll()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+// serve talks MQTT on a connection.
+func (b *mqttBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	str := func(data []byte) (string, []byte) {
+		n := int(binary.BigEndian.Uint16(data))
+		return string(data[2 : 2+n]), data[2+n:]
+	}
+	for {
+		first, data, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+		switch first >> 4 {
+		case 1:
+			// The variable header is the protocol name, level, flags and keep alive.
+			flags := data[7]
+			var c mqttConnect
+			rest := data[10:]
+			c.clientID, rest = str(rest)
+			if flags&0x80 != 0 {
+				c.user, rest = str(rest)
+			}
+			if flags&0x40 != 0 {
+				c.pass, _ = str(rest)
+			}
+			b.mu.Lock()
+			b.connects = append(b.connects, c)
+			b.mu.Unlock()
+			code := byte(0)
+			if c.user == "banned" {
+				code = 4
+			}
+			conn.Write([]byte{0x20, 2, 0, code})
+		case 3:
+			p := mqttPub{qos: first >> 1 & 3, retain: first&1 != 0}
+			var rest []byte
+			p.topic, rest = str(data)
+			var id []byte
+			if p.qos > 0 {
+				id, rest = rest[:2], rest[2:]
+			}
+			p.payload = string(rest)
+			b.mu.Lock()
+			b.pubs = append(b.pubs, p)
+			b.mu.Unlock()
+			switch p.qos {
+			case 1:
+				conn.Write(append([]byte{0x40, 2}, id...))
+			case 2:
+				conn.Write(append([]byte{0x50, 2}, id...))
+			}
+		case 6:
+			conn.Write(append([]byte{0x70, 2}, data[:2]...))
+		case 12:
+			conn.Write([]byte{0xd0, 0})
+		}
+	}
+}
+
+// readMQTTPacket reads a packet, returning its first byte and its remaining bytes.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mul := 0, 1
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(c&0x7f) * mul
+		if c&0x80 == 0 {
+			break
+		}
+		mul *= 128
+	}
+	data := make([]byte, n)
+	_, err = io.ReadFull(r, data)
+
+	return first, data, err
+}
+
+// dropAll closes the connections of the clients, as a restarting broker does.
+func (b *mqttBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+// published returns the messages published so far.
+func (b *mqttBroker) published() []mqttPub {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]mqttPub(nil), b.pubs...)
+}
+
+// connected returns the CONNECT packets of the clients so far.
+func (b *mqttBroker) connected() []mqttConnect {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]mqttConnect(nil), b.connects...)
+}
+
+// newMQTT returns an MQTT sink publishing to a fake broker as client osprey-test.
+func newMQTT(t *testing.T, conf map[string]interface{}) (*sink.MQTT, *mqttBroker) {
+	t.Helper()
+
+	b := newMQTTBroker(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("mqtt.url", "tcp://"+b.addr)
+	viper.Set("mqtt.client_id", "osprey-test")
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+	m, err := sink.NewMQTT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, b
+}
+
+func TestMQTTDeliver(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+		want mqttPub
+	}{
+		{"qos 0", nil, mqttPub{topic: "osprey/findings/apple"}},
+		{"qos 1", map[string]interface{}{config.Key("apple", "mqtt.qos"): "1"},
+			mqttPub{topic: "osprey/findings/apple", qos: 1}},
+		{"qos 2", map[string]interface{}{"mqtt.qos": "2"}, mqttPub{topic: "osprey/findings/apple", qos: 2}},
+		{"topic and retain", map[string]interface{}{config.Key("apple", "mqtt.topic"): "site-12/alerts/apple",
+			"mqtt.retain": true}, mqttPub{topic: "site-12/alerts/apple", retain: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, b := newMQTT(t, tt.conf)
+
+			if _, err := m.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout", Fingerprint: "f00d"}); err != nil {
+				t.Fatal(err)
+			}
+			pubs := b.published()
+			if len(pubs) != 1 || !strings.Contains(pubs[0].payload, `"fingerprint":"f00d"`) {
+				t.Fatalf("got %v, want the finding published", pubs)
+			}
+			if got := pubs[0]; got.topic != tt.want.topic || got.qos != tt.want.qos || got.retain != tt.want.retain {
+				t.Fatalf("got %s with qos %d and retain %v, want %s with qos %d and retain %v", got.topic, got.qos,
+					got.retain, tt.want.topic, tt.want.qos, tt.want.retain)
+			}
+			if c := b.connected()[0]; c.clientID != "osprey-test" {
+				t.Fatalf("got client ID %q, want osprey-test", c.clientID)
+			}
+		})
+	}
+}
+
+func TestMQTTLogin(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_MQTT_PASSWORD", "s3cret")
+
+	tests := []struct {
+		name    string
+		user    string
+		ok      bool
+		connect mqttConnect
+	}{
+		{"anonymous", "", true, mqttConnect{clientID: "osprey-test"}},
+		{"user", "osprey", true, mqttConnect{clientID: "osprey-test", user: "osprey", pass: "s3cret"}},
+		{"refused", "banned", false, mqttConnect{clientID: "osprey-test", user: "banned", pass: "s3cret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, b := newMQTT(t, map[string]interface{}{"mqtt.user": tt.user})
+
+			_, err := m.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{Title: "db timeout"})
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			// A refused login is not fixed by connecting again, so it does not make the broker unavailable.
+			if errors.Is(err, sink.ErrSinkUnavailable) {
+				t.Fatalf("got %v, want a login error", err)
+			}
+			if got := b.connected(); len(got) != 1 || got[0] != tt.connect {
+				t.Fatalf("got %+v, want %+v", got, tt.connect)
+			}
+		})
+	}
+}
+
+func TestMQTTInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+	}{
+		{"wildcard topic", map[string]interface{}{config.Key("apple", "mqtt.topic"): "alerts/+"}},
+		{"qos", map[string]interface{}{config.Key("apple", "mqtt.qos"): "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, b := newMQTT(t, tt.conf)
+
+			if _, err := m.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout"}); err == nil {
+				t.Fatal("got nil, want an error")
+			}
+			if len(b.connected()) != 0 {
+				t.Fatalf("got %v, want no connection", b.connected())
+			}
+		})
+	}
+}
+
+func TestMQTTReconnect(t *testing.T) {
+	m, b := newMQTT(t, map[string]interface{}{"mqtt.qos": "1"})
+	svc := config.Service{Name: "apple"}
+
+	if _, err := m.Deliver(context.Background(), svc, match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	b.dropAll()
+	// The connection the broker dropped while idle is replaced rather than failing the delivery.
+	if _, err := m.Deliver(context.Background(), svc, match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(b.published()); got != 2 || len(b.connected()) != 2 {
+		t.Fatalf("got %d messages over %d connections, want 2 over 2", got, len(b.connected()))
+	}
+}
+
+func TestNewMQTTInvalidURL(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	tests := []string{"http://broker.local", "tcp://"}
+	for _, raw := range tests {
+		viper.Set("mqtt.url", raw)
+		if _, err := sink.NewMQTT(); err == nil {
+			t.Fatalf("%s: got nil, want the url refused", raw)
+		}
+	}
+}
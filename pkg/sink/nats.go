@@ -0,0 +1,304 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"github.com/spf13/viper"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretNATSPassword is the name of the secret holding the password of nats.user.
+	SecretNATSPassword = "nats_password"
+
+	defaultNATSURL = "nats://localhost:4222"
+
+	// natsTimeout bounds connecting to the server and a publish.
+	natsTimeout = 10 * time.Second
+)
+
+func init() {
+	Register("nats", share(func() (Sink, error) { return NewNATS() }))
+}
+
+// NATS publishes findings as JSON events, like the webhook sink's default documents, on a subject of the NATS
+// server nats.url in config file names, osprey.findings.<service> unless the service has a nats.subject:
+//
+//	nats:
+//	  url: tls://nats.internal.example:4222
+//	  user: osprey
+//	services:
+//	  apple:
+//	    sinks: [github, nats]
+//	    nats:
+//	      subject: alerts.apple
+//
+// The password of the user is the nats_password secret, or the client authenticates with the token of the secret
+// nats.token_secret names. Connections use TLS if the url's scheme is tls or the server requires it, with the TLS
+// settings of tls.nats. A publish waits for the server to have taken the event.
+type NATS struct {
+	url *url.URL
+
+	mu sync.Mutex
+
+	// conn is the connection to the server, nil until connected, and r reads from it.
+	conn net.Conn
+	r    *bufio.Reader
+
+	// maxPayload is the largest event the server takes.
+	maxPayload int
+
+	// guard refuses deliveries while the server is down.
+	guard *guard
+}
+
+// natsError is an error the server told, e.g. a permissions violation.
+type natsError struct {
+	msg string
+}
+
+// Error implements error.
+func (e *natsError) Error() string {
+	return "nats server error, " + e.msg
+}
+
+// NewNATS returns the NATS sink of config file.
+func NewNATS() (*NATS, error) {
+	raw := viper.GetString("nats.url")
+	if raw == "" {
+		raw = defaultNATSURL
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Hostname() == "" {
+		return nil, fmt.Errorf("nats.url %q is invalid, want nats://host:port or tls://host:port", raw)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	return &NATS{url: u, guard: newGuard("nats")}, nil
+}
+
+// Deliver implements Sink.
+func (n *NATS) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	subject := setting(svc, "nats.subject")
+	if subject == "" {
+		subject = "osprey.findings." + svc.Name
+	}
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return Delivery{}, fmt.Errorf("nats subject %q of %s is invalid", subject, svc.Name)
+	}
+	body, err := json.Marshal(newFindingEvent(svc, f))
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err := n.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err = n.publish(ctx, subject, body)
+	n.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// publish publishes an event, connecting first if needed. A connection the server dropped while it was idle is
+// replaced once. Errors other than those the server told wrap ErrSinkUnavailable.
+func (n *NATS) publish(ctx context.Context, subject string, body []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reused := n.conn != nil
+	err := n.try(ctx, subject, body)
+	var nerr *natsError
+	if err != nil && reused && !errors.As(err, &nerr) {
+		n.close()
+		err = n.try(ctx, subject, body)
+	}
+	if err != nil && !errors.As(err, &nerr) {
+		n.close()
+		return fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+
+	return err
+}
+
+// try publishes an event and waits for the server to acknowledge it. Call with mu held.
+func (n *NATS) try(ctx context.Context, subject string, body []byte) error {
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if n.maxPayload > 0 && len(body) > n.maxPayload {
+		return &natsError{msg: fmt.Sprintf("event of %d bytes exceeds the max_payload of %d", len(body),
+			n.maxPayload)}
+	}
+	n.conn.SetDeadline(deadline(ctx, natsTimeout))
+
+	msg := make([]byte, 0, len(body)+len(subject)+32)
+	msg = append(msg, fmt.Sprintf("PUB %s %d\r\n", subject, len(body))...)
+	msg = append(msg, body...)
+	msg = append(msg, "\r\nPING\r\n"...)
+	if _, err := n.conn.Write(msg); err != nil {
+		return err
+	}
+
+	return n.pong()
+}
+
+// deadline returns the deadline of ctx, or the time after timeout if it has none or a later one.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	dl := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(dl) {
+		return d
+	}
+
+	return dl
+}
+
+// pong reads the messages of the server until the PONG answering a PING, answering its own PINGs, and returns the
+// error the server told meanwhile if any. The server answers the PING after an error it keeps the connection for,
+// so the next publish does not take that PONG for its own. Call with mu held.
+func (n *NATS) pong() error {
+	var told error
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			if told != nil {
+				return told
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PONG":
+			return told
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR") && told == nil:
+			told = &natsError{msg: strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'")}
+		}
+	}
+}
+
+// connect connects to the server, upgrading to TLS if asked to and authenticating. Call with mu held.
+func (n *NATS) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: natsTimeout}
+	conn, err := d.DialContext(ctx, "tcp", n.url.Host)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline(ctx, natsTimeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("%s is not a nats server", n.url.Host)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		MaxPayload  int  `json:"max_payload"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		conn.Close()
+		return fmt.Errorf("unable to read the INFO of %s, %s", n.url.Host, err.Error())
+	}
+
+	secure := info.TLSRequired || n.url.Scheme == "tls"
+	if secure {
+		cfg, err := transport.TLSConfig("nats")
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("invalid TLS settings for nats, %s", err.Error())
+		}
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = n.url.Hostname()
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+
+	opts, err := n.connectOptions(secure)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn, n.r, n.maxPayload = conn, r, info.MaxPayload
+	if _, err := conn.Write([]byte("CONNECT " + string(opts) + "\r\nPING\r\n")); err != nil {
+		n.close()
+		return err
+	}
+	if err := n.pong(); err != nil {
+		n.close()
+		return err
+	}
+
+	return nil
+}
+
+// connectOptions returns the options of the CONNECT message, with the credentials of config file or the url.
+func (n *NATS) connectOptions(secure bool) ([]byte, error) {
+	opts := map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": secure,
+		"name":         "osprey",
+		"lang":         "go",
+		"version":      "1",
+	}
+	user := viper.GetString("nats.user")
+	if user == "" && n.url.User != nil {
+		user = n.url.User.Username()
+	}
+	if user != "" {
+		pass, ok := "", false
+		if n.url.User != nil {
+			pass, ok = n.url.User.Password()
+		}
+		if !ok {
+			var err error
+			if pass, err = secret(SecretNATSPassword); err != nil {
+				return nil, err
+			}
+		}
+		opts["user"], opts["pass"] = user, pass
+	} else if name := viper.GetString("nats.token_secret"); name != "" {
+		token, err := secret(name)
+		if err != nil {
+			return nil, err
+		}
+		opts["auth_token"] = token
+	}
+
+	return json.Marshal(opts)
+}
+
+// close closes the connection. Call with mu held.
+func (n *NATS) close() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.conn, n.r = nil, nil
+}
@@ -0,0 +1,276 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// natsServer is a fake NATS server, refusing the publishes on subjects starting with denied.
+type natsServer struct {
+	addr string
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	connects []map[string]interface{}
+	pubs     []natsPub
+}
+
+// natsPub is a message published to a natsServer.
+type natsPub struct {
+	subject string
+	payload string
+}
+
+// newNATSServer starts a fake NATS server taking payloads of up to 1024 bytes.
+func newNATSServer(t *testing.T) *natsServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &natsServer{addr: l.Addr().String()}
+	t.Cleanup(func() {
+		l.Close()
+		s.dropAll()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+// serve talks the NATS protocol on a connection.
+func (s *natsServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	io.WriteString(conn, `INFO {"server_id": "fake", "max_payload": 1024}`+"\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch op {
+		case "CONNECT":
+			var opts map[string]interface{}
+			json.Unmarshal([]byte(args), &opts)
+			s.mu.Lock()
+			s.connects = append(s.connects, opts)
+			s.mu.Unlock()
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			tks := strings.Fields(args)
+			n, _ := strconv.Atoi(tks[len(tks)-1])
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if strings.HasPrefix(tks[0], "denied") {
+				io.WriteString(conn, "-ERR 'Permissions Violation for Publish to \""+tks[0]+"\"'\r\n")
+				continue
+			}
+			s.mu.Lock()
+			s.pubs = append(s.pubs, natsPub{subject: tks[0], payload: string(payload[:n])})
+			s.mu.Unlock()
+		}
+	}
+}
+
+// dropAll closes the connections of the clients, as a restarting server does.
+func (s *natsServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// published returns the messages published so far.
+func (s *natsServer) published() []natsPub {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsPub(nil), s.pubs...)
+}
+
+// connected returns the options of the clients' CONNECT messages so far.
+func (s *natsServer) connected() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.connects...)
+}
+
+// newNATS returns a NATS sink publishing to a fake server.
+func newNATS(t *testing.T, conf map[string]string) (*sink.NATS, *natsServer) {
+	t.Helper()
+
+	srv := newNATSServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("nats.url", "nats://"+srv.addr)
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+	n, err := sink.NewNATS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n, srv
+}
+
+func TestNATSDeliver(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    map[string]string
+		subject string
+	}{
+		{"default subject", nil, "osprey.findings.apple"},
+		{"subject of the service", map[string]string{config.Key("apple", "nats.subject"): "alerts.apple"},
+			"alerts.apple"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, srv := newNATS(t, tt.conf)
+
+			if _, err := n.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout", Fingerprint: "f00d"}); err != nil {
+				t.Fatal(err)
+			}
+			pubs := srv.published()
+			if len(pubs) != 1 || pubs[0].subject != tt.subject ||
+				!strings.Contains(pubs[0].payload, `"fingerprint":"f00d"`) {
+				t.Fatalf("got %v, want the finding published on %s", pubs, tt.subject)
+			}
+		})
+	}
+}
+
+func TestNATSAuth(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_NATS_PASSWORD", "s3cret")
+	t.Setenv("OSPREY_SECRET_NATS_TOKEN", "t0ken")
+
+	tests := []struct {
+		name string
+		conf map[string]string
+		want map[string]interface{}
+	}{
+		{"none", nil, map[string]interface{}{"user": nil, "pass": nil, "auth_token": nil}},
+		{"user", map[string]string{"nats.user": "osprey"}, map[string]interface{}{"user": "osprey", "pass": "s3cret"}},
+		{"token", map[string]string{"nats.token_secret": "nats_token"}, map[string]interface{}{"auth_token": "t0ken"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, srv := newNATS(t, tt.conf)
+
+			if _, err := n.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout"}); err != nil {
+				t.Fatal(err)
+			}
+			opts := srv.connected()[0]
+			for k, want := range tt.want {
+				if opts[k] != want {
+					t.Fatalf("got %s %v, want %v", k, opts[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNATSErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		subject     string
+		title       string
+		unavailable bool
+	}{
+		{"invalid subject", "alerts apple", "db timeout", false},
+		{"permissions violation", "denied.apple", "db timeout", false},
+		{"payload too large", "alerts.apple", strings.Repeat("x", 2048), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, srv := newNATS(t, map[string]string{config.Key("apple", "nats.subject"): tt.subject})
+
+			_, err := n.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{Title: tt.title})
+			if err == nil || errors.Is(err, sink.ErrSinkUnavailable) != tt.unavailable {
+				t.Fatalf("got %v, want an error, unavailable %v", err, tt.unavailable)
+			}
+			if len(srv.published()) != 0 {
+				t.Fatalf("got %v, want nothing published", srv.published())
+			}
+		})
+	}
+}
+
+func TestNATSReconnect(t *testing.T) {
+	n, srv := newNATS(t, nil)
+	svc := config.Service{Name: "apple"}
+
+	if _, err := n.Deliver(context.Background(), svc, match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	srv.dropAll()
+	// The connection the server dropped while idle is replaced rather than failing the delivery.
+	if _, err := n.Deliver(context.Background(), svc, match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(srv.published()); got != 2 || len(srv.connected()) != 2 {
+		t.Fatalf("got %d messages over %d connections, want 2 over 2", got, len(srv.connected()))
+	}
+}
+
+func TestNATSUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("nats.url", "nats://"+addr)
+	n, err := sink.NewNATS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = n.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{Title: "db timeout"})
+	if !errors.Is(err, sink.ErrSinkUnavailable) {
+		t.Fatalf("got %v, want %v", err, sink.ErrSinkUnavailable)
+	}
+}
+
+func TestNewNATSInvalidURL(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("nats.url", "http://nats.internal.example")
+
+	if _, err := sink.NewNATS(); err == nil {
+		t.Fatal("got nil, want the url refused")
+	}
+}
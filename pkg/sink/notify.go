@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerpt returns the log line of a finding for a message, truncated to max bytes.
+func excerpt(f match.Finding, max int) string {
+	return truncate(strings.TrimRight(f.Line, "\n"), max)
+}
+
+// truncate returns the first max bytes of a text with an ellipsis if it is longer, cut on a character boundary.
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+
+	return text[:cut] + "…"
+}
+
+// secret returns a secret of the sinks' credentials provider.
+func secret(name string) (string, error) {
+	creds, err := sinkCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	return creds.Secret(name)
+}
+
+// setting returns a setting of a notification sink for a service: its own, e.g. services.apple.slack.channel, or
+// else the sink's, e.g. slack.channel.
+func setting(svc config.Service, key string) string {
+	if v := viper.GetString(config.Key(svc.Name, key)); v != "" {
+		return v
+	}
+
+	return viper.GetString(key)
+}
+
+// settingList returns a list setting of a notification sink for a service, its own or else the sink's, like setting.
+func settingList(svc config.Service, key string) []string {
+	if v := viper.GetStringSlice(config.Key(svc.Name, key)); len(v) > 0 {
+		return v
+	}
+
+	return viper.GetStringSlice(key)
+}
+
+// settingMap returns a map setting of a notification sink for a service, its own or else the sink's, like setting.
+func settingMap(svc config.Service, key string) map[string]string {
+	if v := viper.GetStringMapString(config.Key(svc.Name, key)); len(v) > 0 {
+		return v
+	}
+
+	return viper.GetStringMapString(key)
+}
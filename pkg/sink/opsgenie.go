@@ -0,0 +1,179 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SecretOpsgenieAPIKey is the name of the secret holding the key of the opsgenie API integration, unless a
+	// service names another one with opsgenie.key_secret.
+	SecretOpsgenieAPIKey = "opsgenie_api_key"
+
+	defaultOpsgenieURL = "https://api.opsgenie.com/"
+
+	// maxOpsgenieMessage, maxOpsgenieAlias and maxOpsgenieDescription bound the fields of an alert, opsgenie allows
+	// 130, 512 and 15000 characters.
+	maxOpsgenieMessage     = 125
+	maxOpsgenieAlias       = 500
+	maxOpsgenieDescription = 14000
+)
+
+// opsgeniePriorities are the default priorities of the findings' severities.
+var opsgeniePriorities = map[string]string{
+	"fatal":    "P1",
+	"critical": "P1",
+	"panic":    "P1",
+	"error":    "P3",
+	"warning":  "P4",
+	"warn":     "P4",
+	"info":     "P5",
+}
+
+// opsgenieResponderTypes are the types of responders an alert can be routed to.
+var opsgenieResponderTypes = []string{"team", "user", "escalation", "schedule"}
+
+func init() {
+	Register("opsgenie", share(func() (Sink, error) { return NewOpsgenie() }))
+}
+
+// Opsgenie creates opsgenie alerts for findings, through the API integration whose key is the opsgenie_api_key
+// secret, or the secret opsgenie.key_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, opsgenie]
+//	    opsgenie:
+//	      responders: ["team:platform", "schedule:apple-oncall"]
+//	      priorities: {fatal: P1, error: P2}
+//
+// The alias of an alert is the service and the fingerprint of its finding, so an error seen again counts on its
+// open alert rather than creating another.
+type Opsgenie struct {
+	api *restClient
+
+	// guard refuses deliveries while opsgenie is rate limited or down.
+	guard *guard
+}
+
+// NewOpsgenie returns the opsgenie sink of config file, calling opsgenie.base_url if set, e.g.
+// https://api.eu.opsgenie.com/ for accounts in the EU.
+func NewOpsgenie() (*Opsgenie, error) {
+	base := viper.GetString("opsgenie.base_url")
+	if base == "" {
+		base = defaultOpsgenieURL
+	}
+	api, err := newRESTClient("opsgenie", base, func(req *http.Request, svc config.Service) error {
+		name := setting(svc, "opsgenie.key_secret")
+		if name == "" {
+			name = SecretOpsgenieAPIKey
+		}
+		key, err := secret(name)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "GenieKey "+key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Opsgenie{api: api, guard: newGuard("opsgenie")}, nil
+}
+
+// Deliver implements Sink.
+func (o *Opsgenie) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := o.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	alert, err := opsgenieAlert(svc, f)
+	if err != nil {
+		return Delivery{}, err
+	}
+	_, err = o.api.do(ctx, svc, http.MethodPost, "v2/alerts", alert, nil)
+	o.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// opsgeniePriority returns the priority of a finding: that of its severity in the service's opsgenie.priorities, or
+// else in opsgenie.priorities, or else the default one of the severity, or else opsgenie.priority, P3 by default.
+func opsgeniePriority(svc config.Service, f match.Finding) string {
+	sev := strings.ToLower(f.Severity)
+	for _, key := range []string{config.Key(svc.Name, "opsgenie.priorities"), "opsgenie.priorities"} {
+		if p, ok := viper.GetStringMapString(key)[sev]; ok && sev != "" {
+			return strings.ToUpper(p)
+		}
+	}
+	if p, ok := opsgeniePriorities[sev]; ok {
+		return p
+	}
+	if p := setting(svc, "opsgenie.priority"); p != "" {
+		return strings.ToUpper(p)
+	}
+
+	return "P3"
+}
+
+// opsgenieResponders returns the responders of the service's alerts, from type:name entries such as team:platform.
+func opsgenieResponders(svc config.Service) ([]interface{}, error) {
+	var responders []interface{}
+	for _, r := range settingList(svc, "opsgenie.responders") {
+		typ, name, ok := strings.Cut(r, ":")
+		if !ok || !contains(opsgenieResponderTypes, typ) || name == "" {
+			return nil, fmt.Errorf("invalid opsgenie responder %q of %s, use %s followed by a colon and a name",
+				r, svc.Name, strings.Join(opsgenieResponderTypes, ", "))
+		}
+		key := "name"
+		if typ == "user" {
+			key = "username"
+		}
+		responders = append(responders, map[string]string{"type": typ, key: name})
+	}
+
+	return responders, nil
+}
+
+// opsgenieAlert returns the alert of a finding: its title, the log line, its labels as tags and the link to its
+// issue among the details.
+func opsgenieAlert(svc config.Service, f match.Finding) (map[string]interface{}, error) {
+	responders, err := opsgenieResponders(svc)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]string{"service": svc.Name}
+	if f.Severity != "" {
+		details["severity"] = f.Severity
+	}
+	if f.Fingerprint != "" {
+		details["fingerprint"] = f.Fingerprint
+	}
+	if f.IssueURL != "" {
+		details["issue"] = f.IssueURL
+	}
+	alert := map[string]interface{}{
+		"message":     truncate(f.Title, maxOpsgenieMessage),
+		"description": excerpt(f, maxOpsgenieDescription),
+		"entity":      svc.Name,
+		"source":      "osprey",
+		"priority":    opsgeniePriority(svc, f),
+		"details":     details,
+	}
+	if f.Fingerprint != "" {
+		alert["alias"] = truncate(svc.Name+":"+f.Fingerprint, maxOpsgenieAlias)
+	}
+	if len(responders) > 0 {
+		alert["responders"] = responders
+	}
+	if len(f.Labels) > 0 {
+		alert["tags"] = f.Labels
+	}
+
+	return alert, nil
+}
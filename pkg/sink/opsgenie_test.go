@@ -0,0 +1,95 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"testing"
+)
+
+// newOpsgenie returns an opsgenie sink calling a fake API, with the API key og-default.
+func newOpsgenie(t *testing.T, conf map[string]interface{}) (*sink.Opsgenie, *apiServer) {
+	t.Helper()
+
+	t.Setenv("OSPREY_SECRET_OPSGENIE_API_KEY", "og-default")
+	srv := newAPIServer(t)
+	srv.handle("POST /v2/alerts", http.StatusAccepted, `{"result": "Request will be processed"}`)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("opsgenie.base_url", srv.URL)
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+	o, err := sink.NewOpsgenie()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return o, srv
+}
+
+func TestOpsgenieDeliver(t *testing.T) {
+	o, srv := newOpsgenie(t, map[string]interface{}{
+		config.Key("apple", "opsgenie.responders"): []string{"team:platform", "user:alice@example.com"},
+	})
+	f := match.Finding{Title: "db timeout", Severity: "fatal", Fingerprint: "f00d", Labels: []string{"bug"}}
+
+	if _, err := o.Deliver(context.Background(), config.Service{Name: "apple"}, f); err != nil {
+		t.Fatal(err)
+	}
+	call := srv.call(t, "POST /v2/alerts")
+	if got := call.header.Get("Authorization"); got != "GenieKey og-default" {
+		t.Fatalf("got authorization %q, want the API key", got)
+	}
+	alert := call.decode(t)
+	if alert["alias"] != "apple:f00d" || alert["priority"] != "P1" {
+		t.Fatalf("got alias %v and priority %v, want apple:f00d and P1", alert["alias"], alert["priority"])
+	}
+	responders, _ := alert["responders"].([]interface{})
+	if len(responders) != 2 || responders[1].(map[string]interface{})["username"] != "alice@example.com" {
+		t.Fatalf("got responders %v, want the team and the user", alert["responders"])
+	}
+}
+
+func TestOpsgenieInvalidResponder(t *testing.T) {
+	o, _ := newOpsgenie(t, map[string]interface{}{config.Key("apple", "opsgenie.responders"): []string{"platform"}})
+
+	if _, err := o.Deliver(context.Background(), config.Service{Name: "apple"},
+		match.Finding{Title: "db timeout"}); err == nil {
+		t.Fatal("got nil, want the responder refused")
+	}
+}
+
+func TestOpsgeniePriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		severity string
+		want     string
+	}{
+		{"default of the severity", nil, "warn", "P4"},
+		{"priorities of the service", map[string]interface{}{
+			config.Key("apple", "opsgenie.priorities"): map[string]string{"error": "p2"},
+			"opsgenie.priorities":                      map[string]string{"error": "P1"}}, "ERROR", "P2"},
+		{"priorities", map[string]interface{}{"opsgenie.priorities": map[string]string{"error": "P1"}}, "error",
+			"P1"},
+		{"priority", map[string]interface{}{"opsgenie.priority": "p5"}, "oops", "P5"},
+		{"default", nil, "", "P3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, srv := newOpsgenie(t, tt.conf)
+
+			if _, err := o.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout", Severity: tt.severity}); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.call(t, "POST /v2/alerts").decode(t)["priority"]; got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SecretPagerDutyRoutingKey is the name of the secret holding the integration key of the pagerduty service,
+	// unless a service names another one with pagerduty.key_secret.
+	SecretPagerDutyRoutingKey = "pagerduty_routing_key"
+
+	defaultPagerDutyURL = "https://events.pagerduty.com/"
+
+	// maxPagerDutySummary and maxPagerDutyDedupKey bound the summary and the dedup key of an event, pagerduty allows
+	// 1024 and 255 characters.
+	maxPagerDutySummary  = 1000
+	maxPagerDutyDedupKey = 250
+
+	// maxPagerDutyLine bounds the log line in the details of an event.
+	maxPagerDutyLine = 4000
+)
+
+// pagerDutySeverities map the severities of findings to those of the events API.
+var pagerDutySeverities = map[string]string{
+	"fatal":    "critical",
+	"critical": "critical",
+	"panic":    "critical",
+	"error":    "error",
+	"warning":  "warning",
+	"warn":     "warning",
+	"info":     "info",
+}
+
+func init() {
+	Register("pagerduty", share(func() (Sink, error) { return NewPagerDuty() }))
+}
+
+// PagerDuty triggers pagerduty incidents for findings through the Events API v2, in the pagerduty service whose
+// integration key is the pagerduty_routing_key secret, or the secret pagerduty.key_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, pagerduty]
+//	    pagerduty:
+//	      key_secret: apple_pagerduty_key
+//
+// The dedup key of an event is the service and the fingerprint of its finding, so an error seen again is added to
+// its open incident rather than paging anew.
+type PagerDuty struct {
+	api *restClient
+
+	// guard refuses deliveries while pagerduty is rate limited or down.
+	guard *guard
+}
+
+// NewPagerDuty returns the pagerduty sink of config file, sending to pagerduty.base_url if set, e.g.
+// https://events.eu.pagerduty.com/ for accounts in the EU service region.
+func NewPagerDuty() (*PagerDuty, error) {
+	base := viper.GetString("pagerduty.base_url")
+	if base == "" {
+		base = defaultPagerDutyURL
+	}
+	api, err := newRESTClient("pagerduty", base, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PagerDuty{api: api, guard: newGuard("pagerduty")}, nil
+}
+
+// Deliver implements Sink.
+func (p *PagerDuty) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := p.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err := p.trigger(ctx, svc, f)
+	p.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// trigger sends the trigger event of a finding.
+func (p *PagerDuty) trigger(ctx context.Context, svc config.Service, f match.Finding) error {
+	name := setting(svc, "pagerduty.key_secret")
+	if name == "" {
+		name = SecretPagerDutyRoutingKey
+	}
+	key, err := secret(name)
+	if err != nil {
+		return err
+	}
+	_, err = p.api.do(ctx, svc, http.MethodPost, "v2/enqueue", pagerDutyEvent(svc, f, key), nil)
+
+	return err
+}
+
+// pagerDutySeverity returns the event severity of a finding: that of its severity's name, or else
+// pagerduty.severity, error by default.
+func pagerDutySeverity(svc config.Service, f match.Finding) string {
+	if sev, ok := pagerDutySeverities[strings.ToLower(f.Severity)]; ok {
+		return sev
+	}
+	if sev := setting(svc, "pagerduty.severity"); sev != "" {
+		return sev
+	}
+
+	return "error"
+}
+
+// pagerDutyEvent returns the trigger event of a finding, linking to its issue if there is one.
+func pagerDutyEvent(svc config.Service, f match.Finding, routingKey string) map[string]interface{} {
+	details := map[string]interface{}{"service": svc.Name, "line": excerpt(f, maxPagerDutyLine)}
+	if f.Fingerprint != "" {
+		details["fingerprint"] = f.Fingerprint
+	}
+	if f.Occurrences > 1 {
+		details["occurrences"] = f.Occurrences
+	}
+	ev := map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"client":       "osprey",
+		"payload": map[string]interface{}{
+			"summary":        truncate(f.Title, maxPagerDutySummary),
+			"source":         svc.Name,
+			"severity":       pagerDutySeverity(svc, f),
+			"custom_details": details,
+		},
+	}
+	if f.Fingerprint != "" {
+		ev["dedup_key"] = truncate(svc.Name+":"+f.Fingerprint, maxPagerDutyDedupKey)
+	}
+	if f.IssueURL != "" {
+		ev["client_url"] = f.IssueURL
+		ev["links"] = []interface{}{map[string]string{"href": f.IssueURL, "text": f.Title}}
+	}
+
+	return ev
+}
@@ -0,0 +1,77 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"testing"
+)
+
+func TestPagerDutyDeliver(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_PAGERDUTY_ROUTING_KEY", "pd-default")
+	t.Setenv("OSPREY_SECRET_APPLE_PAGERDUTY_KEY", "pd-apple")
+
+	tests := []struct {
+		name     string
+		conf     map[string]string
+		f        match.Finding
+		key      string
+		severity string
+		dedup    interface{}
+	}{
+		{"default key", nil, match.Finding{Title: "db timeout", Severity: "FATAL", Fingerprint: "f00d"}, "pd-default",
+			"critical", "apple:f00d"},
+		{"key secret", map[string]string{"services.apple.pagerduty.key_secret": "apple_pagerduty_key"},
+			match.Finding{Title: "db timeout", Severity: "warn"}, "pd-apple", "warning", nil},
+		{"severity of the config", map[string]string{"pagerduty.severity": "info"},
+			match.Finding{Title: "db timeout", Severity: "oops"}, "pd-default", "info", nil},
+		{"default severity", nil, match.Finding{Title: "db timeout"}, "pd-default", "error", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newAPIServer(t)
+			srv.handle("POST /v2/enqueue", http.StatusAccepted, `{"status": "success"}`)
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("pagerduty.base_url", srv.URL)
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			p, err := sink.NewPagerDuty()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := p.Deliver(context.Background(), config.Service{Name: "apple"}, tt.f); err != nil {
+				t.Fatal(err)
+			}
+			ev := srv.call(t, "POST /v2/enqueue").decode(t)
+			payload, _ := ev["payload"].(map[string]interface{})
+			if ev["routing_key"] != tt.key || payload["severity"] != tt.severity || ev["dedup_key"] != tt.dedup {
+				t.Fatalf("got key %v, severity %v and dedup key %v, want %v, %v and %v", ev["routing_key"],
+					payload["severity"], ev["dedup_key"], tt.key, tt.severity, tt.dedup)
+			}
+		})
+	}
+}
+
+func TestPagerDutyRefused(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_PAGERDUTY_ROUTING_KEY", "pd-default")
+	srv := newAPIServer(t)
+	srv.handle("POST /v2/enqueue", http.StatusBadRequest, `{"status": "invalid event"}`)
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("pagerduty.base_url", srv.URL)
+	p, err := sink.NewPagerDuty()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Deliver(context.Background(), config.Service{Name: "apple"},
+		match.Finding{Title: "db timeout"}); err == nil {
+		t.Fatal("got nil, want the event refused")
+	}
+}
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/transport"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// restTimeout bounds a call of a tracker's API.
+	restTimeout = 30 * time.Second
+
+	// maxErrorBody bounds the part of an error response's body told in the error.
+	maxErrorBody = 512
+)
+
+// restClient calls the JSON API of a tracker, through the proxy and TLS settings of its endpoint. Failed calls
+// return errors wrapping ErrSinkUnavailable on 5xx statuses and network errors, and a RateLimitError on rate limit
+// responses.
+type restClient struct {
+	// name is the tracker's endpoint, e.g. gitlab.
+	name string
+
+	// base is the url of the API the paths of the calls are relative to.
+	base *url.URL
+
+	hc *http.Client
+
+	// auth authenticates a request of a service.
+	auth func(req *http.Request, svc config.Service) error
+}
+
+// newRESTClient returns the client of a tracker's API at base.
+func newRESTClient(name, base string, auth func(req *http.Request, svc config.Service) error) (*restClient,
+	error) {
+	u, err := url.Parse(strings.TrimRight(base, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("%s base_url %q is invalid, %s", name, base, err.Error())
+	}
+	hc, err := transport.NewHTTPClient(name, restTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restClient{name: name, base: u, hc: hc, auth: auth}, nil
+}
+
+// contentTyper is implemented by request bodies of a JSON media type of their own, e.g. a JSON patch.
+type contentTyper interface {
+	// ContentType returns the media type of the body.
+	ContentType() string
+}
+
+// rawBody is a request body sent as it is rather than as JSON, e.g. a sentry envelope.
+type rawBody struct {
+	contentType string
+
+	data []byte
+}
+
+// ContentType implements contentTyper.
+func (b rawBody) ContentType() string {
+	return b.contentType
+}
+
+// do calls the API of a service with the method on path, relative to the base url, sending in as JSON unless it is
+// nil or a rawBody and decoding the response into out unless it is nil. It returns the headers of the response.
+func (c *restClient) do(ctx context.Context, svc config.Service, method, path string, in,
+	out interface{}) (http.Header, error) {
+	u, err := c.base.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if raw, ok := in.(rawBody); ok {
+		body = bytes.NewReader(raw.data)
+	} else if in != nil {
+		dat, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(dat)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if ct, ok := in.(contentTyper); ok {
+		req.Header.Set("Content-Type", ct.ContentType())
+	} else if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		if err := c.auth(req, svc); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %w", ErrSinkUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		dat, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err := fmt.Errorf("%s %s %s: %d %s", c.name, method, u.Path, resp.StatusCode,
+			strings.TrimSpace(string(dat)))
+		if reset, ok := rateLimitReset(resp); ok {
+			return nil, &RateLimitError{Reset: reset, Err: err}
+		}
+		return nil, httpError(resp.StatusCode, err)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("unable to decode %s response, %s", c.name, err.Error())
+		}
+	}
+
+	return resp.Header, nil
+}
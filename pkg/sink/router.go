@@ -0,0 +1,39 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"strings"
+)
+
+// Router delivers each finding to the sink of its route, chosen by its severity, or else by what it matched, e.g.
+// warnings to a chat and fatal errors to a pager and github. Findings without a route go to the default sink.
+type Router struct {
+	// Routes are the sinks of the routes, keyed on the lowercase severity or keyword. A nil sink drops the findings
+	// of its route.
+	Routes map[string]Sink
+
+	// Default delivers the findings without a route.
+	Default Sink
+}
+
+// Deliver implements Sink.
+func (r Router) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	for _, key := range []string{f.Severity, f.Keyword} {
+		if key == "" {
+			continue
+		}
+		sk, ok := r.Routes[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		if sk == nil {
+			return Delivery{}, fmt.Errorf("%w, the route %s of %s has no sinks", ErrDropped, key, svc.Name)
+		}
+		return sk.Deliver(ctx, svc, f)
+	}
+
+	return r.Default.Deliver(ctx, svc, f)
+}
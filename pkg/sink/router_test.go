@@ -0,0 +1,53 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"github.com/NBCFB/Iguana2/ospreytest"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	chat, pager, github := &ospreytest.Sink{}, &ospreytest.Sink{}, &ospreytest.Sink{}
+	r := sink.Router{Routes: map[string]sink.Sink{"warning": chat, "fatal": pager, "oom": pager, "debug": nil},
+		Default: github}
+
+	tests := []struct {
+		name    string
+		f       match.Finding
+		want    *ospreytest.Sink
+		dropped bool
+	}{
+		{"severity", match.Finding{Title: "slow query", Severity: "warning"}, chat, false},
+		{"severity of another case", match.Finding{Title: "db down", Severity: "FATAL"}, pager, false},
+		{"keyword", match.Finding{Title: "killed", Keyword: "OOM"}, pager, false},
+		{"severity before keyword", match.Finding{Title: "killed", Severity: "warning", Keyword: "oom"}, chat, false},
+		{"no route", match.Finding{Title: "db timeout", Severity: "error", Keyword: "timeout"}, github, false},
+		{"no severity nor keyword", match.Finding{Title: "db timeout"}, github, false},
+		{"dropped", match.Finding{Title: "retrying", Severity: "debug"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, s := range []*ospreytest.Sink{chat, pager, github} {
+				s.Reset()
+			}
+
+			_, err := r.Deliver(context.Background(), config.Service{Name: "apple"}, tt.f)
+			if got := errors.Is(err, sink.ErrDropped); got != tt.dropped {
+				t.Fatalf("got %v, want dropped %v", err, tt.dropped)
+			}
+			for _, s := range []*ospreytest.Sink{chat, pager, github} {
+				want := 0
+				if s == tt.want {
+					want = 1
+				}
+				if got := len(s.Findings()); got != want {
+					t.Fatalf("got %d findings on a sink, want %d", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SecretSentryDSN is the name of the secret holding the DSN of the sentry project, unless a service names another
+	// one with sentry.dsn_secret.
+	SecretSentryDSN = "sentry_dsn"
+
+	// maxSentryMessage bounds the message of an event.
+	maxSentryMessage = 8000
+)
+
+// sentryLevels map the severities of findings to the levels of sentry events.
+var sentryLevels = map[string]string{
+	"fatal":    "fatal",
+	"critical": "fatal",
+	"panic":    "fatal",
+	"error":    "error",
+	"warning":  "warning",
+	"warn":     "warning",
+	"info":     "info",
+	"debug":    "debug",
+}
+
+var (
+	// javaFrame matches the frames of java stack traces, e.g. at com.acme.Apple.run(Apple.java:42).
+	javaFrame = regexp.MustCompile(`^\s*at ([\w$.<>/]+)\.([\w$<>]+)\(([^:)]*)(?::(\d+))?\)`)
+
+	// pythonFrame matches the frames of python tracebacks, e.g. File "apple.py", line 42, in run.
+	pythonFrame = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+), in (\S+)`)
+
+	// goFunc and goFile match the two lines of the frames of go stack traces, e.g. main.run(...) and
+	// /src/apple/main.go:42 +0x1d.
+	goFunc = regexp.MustCompile(`^([\w./*()\-]+)\(.*\)$`)
+	goFile = regexp.MustCompile(`^\s+(\S+\.go):(\d+)`)
+)
+
+// hostName is the name of the host, the server_name of events.
+var hostName, _ = os.Hostname()
+
+func init() {
+	Register("sentry", share(func() (Sink, error) { return NewSentry() }))
+}
+
+// Sentry sends findings to a sentry project as events, through the DSN which is the sentry_dsn secret, or the secret
+// sentry.dsn_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, sentry]
+//	    sentry:
+//	      dsn_secret: apple_sentry_dsn
+//	      environment: production
+//
+// An event is grouped on the fingerprint of its finding. A stack trace grouped by multiline becomes the stack trace
+// of its exception if its frames are those of java, python or go.
+type Sentry struct {
+	mu sync.Mutex
+
+	// clients call the sentry servers, keyed on the DSN.
+	clients map[string]*restClient
+
+	// guard refuses deliveries while sentry is rate limited or down.
+	guard *guard
+}
+
+// NewSentry returns the sentry sink of config file.
+func NewSentry() (*Sentry, error) {
+	return &Sentry{clients: make(map[string]*restClient), guard: newGuard("sentry")}, nil
+}
+
+// sentryDSN is a parsed DSN.
+type sentryDSN struct {
+	// api is the url of the sentry API, e.g. https://o1.ingest.sentry.io/api/.
+	api string
+
+	key, project, raw string
+}
+
+// parseSentryDSN parses a DSN such as https://<key>@o1.ingest.sentry.io/<project>.
+func parseSentryDSN(dsn string) (sentryDSN, error) {
+	u, err := url.Parse(strings.TrimSpace(dsn))
+	if err != nil || u.User == nil || u.Host == "" {
+		return sentryDSN{}, fmt.Errorf("invalid sentry DSN, want https://<key>@<host>/<project>")
+	}
+	path := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	project := path[i+1:]
+	if project == "" {
+		return sentryDSN{}, fmt.Errorf("invalid sentry DSN, it names no project")
+	}
+	api := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	if i > 0 {
+		api.Path += path[:i] + "/"
+	}
+	api.Path += "api/"
+
+	return sentryDSN{api: api.String(), key: u.User.Username(), project: project, raw: strings.TrimSpace(dsn)}, nil
+}
+
+// Deliver implements Sink.
+func (s *Sentry) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	name := setting(svc, "sentry.dsn_secret")
+	if name == "" {
+		name = SecretSentryDSN
+	}
+	raw, err := secret(name)
+	if err != nil {
+		return Delivery{}, err
+	}
+	dsn, err := parseSentryDSN(raw)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("%s of %s", err.Error(), svc.Name)
+	}
+	c, err := s.client(dsn)
+	if err != nil {
+		return Delivery{}, err
+	}
+	env, err := sentryEnvelope(dsn, svc, f)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err := s.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	_, err = c.do(ctx, svc, http.MethodPost, dsn.project+"/envelope/", env, nil)
+	s.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// client returns the client of the API of a DSN.
+func (s *Sentry) client(dsn sentryDSN) (*restClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.clients[dsn.raw]; ok {
+		return c, nil
+	}
+	c, err := newRESTClient("sentry", dsn.api, func(req *http.Request, _ config.Service) error {
+		req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=osprey, sentry_key="+dsn.key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.clients[dsn.raw] = c
+
+	return c, nil
+}
+
+// sentryEnvelope returns the envelope of the event of a finding.
+func sentryEnvelope(dsn sentryDSN, svc config.Service, f match.Finding) (rawBody, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return rawBody{}, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	event, err := json.Marshal(sentryEvent(svc, f, hex.EncodeToString(id), now))
+	if err != nil {
+		return rawBody{}, err
+	}
+
+	var b bytes.Buffer
+	hdr, _ := json.Marshal(map[string]string{"event_id": hex.EncodeToString(id), "dsn": dsn.raw, "sent_at": now})
+	b.Write(hdr)
+	fmt.Fprintf(&b, "\n{\"type\":\"event\",\"length\":%d}\n", len(event))
+	b.Write(event)
+	b.WriteString("\n")
+
+	return rawBody{contentType: "application/x-sentry-envelope", data: b.Bytes()}, nil
+}
+
+// sentryEvent returns the event of a finding: its title as the message, or an exception with the frames of its
+// stack trace if it has one, tagged with the service and severity.
+func sentryEvent(svc config.Service, f match.Finding, id, timestamp string) map[string]interface{} {
+	level, ok := sentryLevels[strings.ToLower(f.Severity)]
+	if !ok {
+		level = "error"
+	}
+	tags := map[string]string{"service": svc.Name}
+	if f.Severity != "" {
+		tags["severity"] = f.Severity
+	}
+	extra := map[string]interface{}{"line": excerpt(f, maxSentryMessage)}
+	if f.IssueURL != "" {
+		extra["issue_url"] = f.IssueURL
+	}
+	if f.Occurrences > 1 {
+		extra["occurrences"] = f.Occurrences
+	}
+	ev := map[string]interface{}{
+		"event_id":    id,
+		"timestamp":   timestamp,
+		"platform":    "other",
+		"level":       level,
+		"logger":      "osprey",
+		"server_name": hostName,
+		"message":     map[string]string{"formatted": truncate(f.Title, maxSentryMessage)},
+		"tags":        tags,
+		"extra":       extra,
+	}
+	if env := setting(svc, "sentry.environment"); env != "" {
+		ev["environment"] = env
+	}
+	if f.Fingerprint != "" {
+		ev["fingerprint"] = []string{f.Fingerprint}
+	}
+	if frames := stackFrames(f.Line); len(frames) > 0 {
+		typ := f.Keyword
+		if typ == "" {
+			typ = "Error"
+		}
+		ev["exception"] = map[string]interface{}{"values": []interface{}{map[string]interface{}{
+			"type":       typ,
+			"value":      truncate(exceptionValue(f.Line), maxSentryMessage),
+			"stacktrace": map[string]interface{}{"frames": frames},
+		}}}
+	}
+
+	return ev
+}
+
+// exceptionValue returns the line of a stack trace telling its error: the first one, or the last one of a python
+// traceback.
+func exceptionValue(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if strings.HasPrefix(lines[0], "Traceback ") {
+		return strings.TrimSpace(lines[len(lines)-1])
+	}
+
+	return strings.TrimSpace(lines[0])
+}
+
+// stackFrames returns the frames of the java, python or go stack trace in a finding's lines, the outermost first as
+// sentry wants them, nil if they have none.
+func stackFrames(text string) []interface{} {
+	lines := strings.Split(text, "\n")
+	var frames []interface{}
+	reverse := true
+	for i, line := range lines {
+		if m := javaFrame.FindStringSubmatch(line); m != nil {
+			fr := map[string]interface{}{"module": m[1], "function": m[2], "filename": m[3]}
+			if n, err := strconv.Atoi(m[4]); err == nil {
+				fr["lineno"] = n
+			}
+			frames = append(frames, fr)
+		} else if m := pythonFrame.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			frames = append(frames, map[string]interface{}{"filename": m[1], "lineno": n, "function": m[3]})
+			reverse = false
+		} else if m := goFunc.FindStringSubmatch(line); m != nil && i+1 < len(lines) {
+			if fm := goFile.FindStringSubmatch(lines[i+1]); fm != nil {
+				n, _ := strconv.Atoi(fm[2])
+				frames = append(frames, map[string]interface{}{"function": m[1], "filename": fm[1], "lineno": n})
+			}
+		}
+	}
+	if reverse {
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	}
+
+	return frames
+}
@@ -0,0 +1,134 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// deliverSentry delivers a finding of service apple to a fake sentry project 42 and returns its envelope's event.
+func deliverSentry(t *testing.T, conf map[string]string, f match.Finding) map[string]interface{} {
+	t.Helper()
+
+	srv := newAPIServer(t)
+	srv.handle("POST /sentry/api/42/envelope/", http.StatusOK, `{"id": "f00d"}`)
+	t.Setenv("OSPREY_SECRET_SENTRY_DSN", strings.Replace(srv.URL, "//", "//pubkey@", 1)+"/sentry/42")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+	s, err := sink.NewSentry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Deliver(context.Background(), config.Service{Name: "apple"}, f); err != nil {
+		t.Fatal(err)
+	}
+	call := srv.call(t, "POST /sentry/api/42/envelope/")
+	if got := call.header.Get("X-Sentry-Auth"); !strings.Contains(got, "sentry_key=pubkey") {
+		t.Fatalf("got auth %q, want the key of the DSN", got)
+	}
+	// An envelope is its header, the header of the event and the event, a line each.
+	lines := strings.Split(strings.TrimSuffix(call.body, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %q, want an envelope of 3 lines", call.body)
+	}
+	var ev map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &ev); err != nil {
+		t.Fatal(err)
+	}
+
+	return ev
+}
+
+func TestSentryDeliver(t *testing.T) {
+	ev := deliverSentry(t, map[string]string{config.Key("apple", "sentry.environment"): "production"},
+		match.Finding{Title: "db timeout", Line: "error: db timeout", Severity: "CRITICAL", Fingerprint: "f00d"})
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"level", ev["level"], "fatal"},
+		{"environment", ev["environment"], "production"},
+		{"fingerprint", ev["fingerprint"].([]interface{})[0], "f00d"},
+		{"message", ev["message"].(map[string]interface{})["formatted"], "db timeout"},
+		{"exception", ev["exception"], nil},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSentryStackTrace(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		value string
+		// outermost is the function of the first frame.
+		outermost string
+		frames    int
+	}{
+		{"java", "java.lang.IllegalStateException: closed\n\tat com.acme.Db.query(Db.java:42)\n" +
+			"\tat com.acme.Apple.run(Apple.java:7)", "java.lang.IllegalStateException: closed", "run", 2},
+		{"python", "Traceback (most recent call last):\n  File \"apple.py\", line 7, in run\n" +
+			"  File \"db.py\", line 42, in query\nValueError: closed", "ValueError: closed", "run", 2},
+		{"go", "panic: closed\n\ngoroutine 1 [running]:\nmain.query(...)\n\t/src/apple/db.go:42 +0x1d\n" +
+			"main.run()\n\t/src/apple/main.go:7 +0x2a", "panic: closed", "main.run", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := deliverSentry(t, nil, match.Finding{Title: "closed", Line: tt.line})
+
+			exc, _ := ev["exception"].(map[string]interface{})
+			if exc == nil {
+				t.Fatalf("got %v, want an exception", ev)
+			}
+			val := exc["values"].([]interface{})[0].(map[string]interface{})
+			frames := val["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+			if val["value"] != tt.value || len(frames) != tt.frames {
+				t.Fatalf("got %v with %d frames, want %v with %d", val["value"], len(frames), tt.value, tt.frames)
+			}
+			if got := frames[0].(map[string]interface{})["function"]; got != tt.outermost {
+				t.Fatalf("got outermost frame %v, want %v", got, tt.outermost)
+			}
+		})
+	}
+}
+
+func TestSentryInvalidDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{"no key", "https://o1.ingest.sentry.io/42"},
+		{"no project", "https://pubkey@o1.ingest.sentry.io/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OSPREY_SECRET_SENTRY_DSN", tt.dsn)
+			viper.Reset()
+			defer viper.Reset()
+			s, err := sink.NewSentry()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Deliver(context.Background(), config.Service{Name: "apple"},
+				match.Finding{Title: "db timeout"}); err == nil {
+				t.Fatal("got nil, want the DSN refused")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSink names the sink of services without a sink key: the github sink of the osprey command, or the default
+// sink of an embedding program.
+const DefaultSink = "github"
+
+// Sink delivers findings, e.g. as github issues. A sink is shared by the scanners using it, so Deliver is told the
+// service a finding belongs to.
+type Sink interface {
+	// Deliver delivers a finding of a service.
+	Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error)
+}
+
+// Delivery describes a delivered finding.
+type Delivery struct {
+	// Number is the issue number, 0 if the sink has none.
+	Number int
+
+	// URL links to the delivered finding, empty if the sink has none.
+	URL string
+
+	// Updated is whether an existing issue was updated, e.g. commented on, rather than a new one filed.
+	Updated bool
+}
+
+// Resolver is implemented by sinks which can close the issue of a delivered finding, e.g. once its error stopped
+// occurring.
+type Resolver interface {
+	// Resolve closes the issue of a service with the given number, commenting why.
+	Resolve(ctx context.Context, svc config.Service, number int, comment string) error
+}
+
+// Commenter is implemented by sinks which can comment on the issue of a delivered finding.
+type Commenter interface {
+	// Comment comments on the issue of a service with the given number.
+	Comment(ctx context.Context, svc config.Service, number int, comment string) error
+}
+
+// OpenCounter is implemented by sinks which can count the open issues they filed for a service.
+type OpenCounter interface {
+	// OpenIssues returns the number of open issues filed for a service.
+	OpenIssues(ctx context.Context, svc config.Service) (int, error)
+}
+
+// Flusher is implemented by sinks holding findings back to deliver them together, e.g. in an email digest.
+type Flusher interface {
+	// Flush delivers the findings held back.
+	Flush(ctx context.Context) error
+}
+
+// ErrDropped is returned, possibly wrapped, by a sink deciding not to deliver a finding, e.g. a duplicate. A
+// dropped finding is not a failed delivery.
+var ErrDropped = errors.New("dropped")
+
+// ErrRateLimited is returned, wrapped, when a sink refuses deliveries for a while because too many were made.
+var ErrRateLimited = errors.New("sink rate limit exceeded")
+
+// ErrSinkUnavailable is returned, wrapped, when a sink cannot be reached or fails on its side, so a later retry
+// may succeed.
+var ErrSinkUnavailable = errors.New("sink unavailable")
+
+// RateLimitError is returned by a sink which is rate limited and knows until when. It wraps ErrRateLimited.
+type RateLimitError struct {
+	// Reset is when the sink accepts deliveries again.
+	Reset time.Time
+
+	// Err is the error of the sink.
+	Err error
+}
+
+// Error implements error.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s until %s, %s", ErrRateLimited.Error(), e.Reset.Format(time.RFC3339), e.Err.Error())
+}
+
+// Unwrap returns ErrRateLimited and the error of the sink.
+func (e *RateLimitError) Unwrap() []error {
+	return []error{ErrRateLimited, e.Err}
+}
+
+// RetryAt returns when a delivery rate limited with err may be made again, ok is false if err is not a
+// RateLimitError.
+func RetryAt(err error) (reset time.Time, ok bool) {
+	var rle *RateLimitError
+	if !errors.As(err, &rle) {
+		return time.Time{}, false
+	}
+
+	return rle.Reset, true
+}
+
+// Factory creates a sink.
+type Factory func() (Sink, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+
+	// flushers are the shared sinks created so far which hold findings back.
+	flushers []Flusher
+)
+
+// Register makes a sink available under the given name, for services selecting it with the sink key in config
+// file. It panics if the name is taken, like database/sql drivers, since two packages registering the same sink is
+// a programming error.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("sink: sink %s is registered twice", name))
+	}
+	factories[name] = f
+}
+
+// share returns a factory of the sink f creates once, on the first call succeeding, so all services share it.
+func share(f Factory) Factory {
+	var smu sync.Mutex
+	var shared Sink
+
+	return func() (Sink, error) {
+		smu.Lock()
+		defer smu.Unlock()
+
+		if shared == nil {
+			sk, err := f()
+			if err != nil {
+				return nil, err
+			}
+			shared = sk
+			if fl, ok := sk.(Flusher); ok {
+				mu.Lock()
+				flushers = append(flushers, fl)
+				mu.Unlock()
+			}
+		}
+		return shared, nil
+	}
+}
+
+// Flush flushes the shared sinks created so far which hold findings back, e.g. before osprey exits, returning their
+// errors joined.
+func Flush(ctx context.Context) error {
+	mu.RLock()
+	fls := append([]Flusher(nil), flushers...)
+	mu.RUnlock()
+
+	var errs []error
+	for _, fl := range fls {
+		if err := fl.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// New creates the named sink.
+func New(name string) (Sink, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q, registered are %s", name, strings.Join(Names(), ", "))
+	}
+
+	return f()
+}
+
+// Names returns the registered sink names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
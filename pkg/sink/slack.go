@@ -0,0 +1,143 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SecretSlackWebhook is the name of the secret holding the slack incoming webhook url, unless a service names
+	// another one with slack.webhook_secret.
+	SecretSlackWebhook = "slack_webhook_url"
+
+	// SecretSlackToken is the name of the slack bot token secret, used by services posting to a channel.
+	SecretSlackToken = "slack_token"
+
+	slackAPIURL = "https://slack.com/api/"
+
+	// maxSlackExcerpt bounds the log line of a message, slack allows 3000 characters per section.
+	maxSlackExcerpt = 2800
+)
+
+func init() {
+	Register("slack", share(func() (Sink, error) { return NewSlack() }))
+}
+
+// Slack posts findings to slack, with the log line and a link to the issue filed for it by the primary sink of a
+// fan-out:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, slack]
+//	    slack:
+//	      channel: "#apple-alerts"
+//
+// A service with a channel, its own or slack.channel in config file, posts to it as the bot of the slack_token
+// secret; one without posts to the incoming webhook whose url is the slack_webhook_url secret, or the secret
+// slack.webhook_secret names.
+type Slack struct {
+	// api calls the slack web API as the bot, hook the incoming webhooks.
+	api, hook *restClient
+
+	// guard refuses deliveries while slack is rate limited or down.
+	guard *guard
+}
+
+// NewSlack returns the slack sink of config file.
+func NewSlack() (*Slack, error) {
+	api, err := newRESTClient("slack", slackAPIURL, func(req *http.Request, _ config.Service) error {
+		tk, err := secret(SecretSlackToken)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+tk)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	hook, err := newRESTClient("slack", slackAPIURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Slack{api: api, hook: hook, guard: newGuard("slack")}, nil
+}
+
+// Deliver implements Sink.
+func (s *Slack) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := s.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err := s.post(ctx, svc, f)
+	s.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// post posts the message of a finding to the service's channel or webhook.
+func (s *Slack) post(ctx context.Context, svc config.Service, f match.Finding) error {
+	msg := slackMessage(svc, f)
+	channel := setting(svc, "slack.channel")
+	if channel == "" {
+		name := setting(svc, "slack.webhook_secret")
+		if name == "" {
+			name = SecretSlackWebhook
+		}
+		url, err := secret(name)
+		if err != nil {
+			return err
+		}
+		_, err = s.hook.do(ctx, svc, http.MethodPost, url, msg, nil)
+		return err
+	}
+
+	msg["channel"] = channel
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if _, err := s.api.do(ctx, svc, http.MethodPost, "chat.postMessage", msg, &res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return fmt.Errorf("slack refused the message of %s to %s, %s", svc.Name, channel, res.Error)
+	}
+
+	return nil
+}
+
+// slackEscaper escapes the control characters of slack's mrkdwn.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage returns the message of a finding: its title, linking to its issue if there is one, the log line and
+// the service, severity and fingerprint.
+func slackMessage(svc config.Service, f match.Finding) map[string]interface{} {
+	title := "*" + slackEscaper.Replace(f.Title) + "*"
+	if f.IssueURL != "" {
+		title = fmt.Sprintf("*<%s|%s>*", f.IssueURL, slackEscaper.Replace(f.Title))
+	}
+	meta := []string{svc.Name}
+	if f.Severity != "" {
+		meta = append(meta, f.Severity)
+	}
+	if f.Fingerprint != "" {
+		meta = append(meta, "`"+f.Fingerprint+"`")
+	}
+	mrkdwn := func(text string) map[string]string { return map[string]string{"type": "mrkdwn", "text": text} }
+
+	return map[string]interface{}{
+		"text": f.Title,
+		"blocks": []interface{}{
+			map[string]interface{}{"type": "section", "text": mrkdwn(title)},
+			map[string]interface{}{"type": "section",
+				"text": mrkdwn("```" + slackEscaper.Replace(excerpt(f, maxSlackExcerpt)) + "```")},
+			map[string]interface{}{"type": "context",
+				"elements": []interface{}{mrkdwn(strings.Join(meta, " · "))}},
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSlackDeliver(t *testing.T) {
+	var got struct {
+		path, auth string
+		msg        map[string]interface{}
+	}
+	reply := `{"ok": true}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path, got.auth = r.URL.Path, r.Header.Get("Authorization")
+		got.msg = nil
+		json.NewDecoder(r.Body).Decode(&got.msg)
+		w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+	t.Setenv("OSPREY_SECRET_SLACK_WEBHOOK_URL", srv.URL+"/hooks/default")
+	t.Setenv("OSPREY_SECRET_APPLE_SLACK_HOOK", srv.URL+"/hooks/apple")
+	t.Setenv("OSPREY_SECRET_SLACK_TOKEN", "xoxb-osprey")
+	f := match.Finding{Title: "apple: db <timeout>", Line: "error: db timeout", Severity: "high",
+		Fingerprint: "f00d", IssueURL: "https://github.com/owner/apple/issues/3"}
+
+	tests := []struct {
+		name    string
+		conf    map[string]string
+		reply   string
+		ok      bool
+		path    string
+		channel interface{}
+	}{
+		{"default webhook", nil, "ok", true, "/hooks/default", nil},
+		{"webhook secret", map[string]string{"services.apple.slack.webhook_secret": "apple_slack_hook"}, "ok",
+			true, "/hooks/apple", nil},
+		{"channel", map[string]string{"slack.channel": "#alerts"}, `{"ok": true}`, true, "/chat.postMessage",
+			"#alerts"},
+		{"channel of the service", map[string]string{"slack.channel": "#alerts",
+			"services.apple.slack.channel": "#apple"}, `{"ok": true}`, true, "/chat.postMessage", "#apple"},
+		{"refused", map[string]string{"slack.channel": "#alerts"}, `{"ok": false, "error": "not_in_channel"}`,
+			false, "/chat.postMessage", "#alerts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			s, err := NewSlack()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.api.base, _ = url.Parse(srv.URL + "/")
+			reply = tt.reply
+
+			_, err = s.Deliver(context.Background(), config.Service{Name: "apple"}, f)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if got.path != tt.path || got.msg["channel"] != tt.channel {
+				t.Fatalf("got %s to %v, want %s to %v", got.path, got.msg["channel"], tt.path, tt.channel)
+			}
+			// The bot token is only sent to the web API, not to webhooks.
+			if wantAuth := tt.channel != nil; (got.auth == "Bearer xoxb-osprey") != wantAuth {
+				t.Fatalf("got authorization %q, want the bot token %v", got.auth, wantAuth)
+			}
+		})
+	}
+}
+
+func TestSlackMessage(t *testing.T) {
+	msg := slackMessage(config.Service{Name: "apple"}, match.Finding{Title: "apple: a < b & c",
+		Line: "error: a < b", Severity: "high", Fingerprint: "f00d", IssueURL: "https://example.com/3"})
+
+	blocks := msg["blocks"].([]interface{})
+	text := func(block map[string]interface{}) string {
+		if elems, ok := block["elements"].([]interface{}); ok {
+			block = map[string]interface{}{"text": elems[0]}
+		}
+		return block["text"].(map[string]string)["text"]
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"title linking to the issue", "*<https://example.com/3|apple: a &lt; b &amp; c>*"},
+		{"log line", "```error: a &lt; b```"},
+		{"meta", "apple · high · `f00d`"},
+	}
+	for i, tt := range tests {
+		if got := text(blocks[i].(map[string]interface{})); got != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
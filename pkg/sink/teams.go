@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"net/http"
+)
+
+const (
+	// SecretTeamsWebhook is the name of the secret holding the teams webhook url, unless a service names another one
+	// with teams.webhook_secret.
+	SecretTeamsWebhook = "teams_webhook_url"
+
+	// maxTeamsExcerpt bounds the log line of a card, teams refuses messages over 28KB.
+	maxTeamsExcerpt = 8000
+)
+
+func init() {
+	Register("teams", share(func() (Sink, error) { return NewTeams() }))
+}
+
+// Teams posts findings to a Microsoft Teams channel as Adaptive Cards, through the webhook of a Workflows or
+// incoming webhook connector whose url is the teams_webhook_url secret, or the secret teams.webhook_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, teams]
+//	    teams:
+//	      webhook_secret: apple_teams_webhook
+type Teams struct {
+	hook *restClient
+
+	// guard refuses deliveries while teams is rate limited or down.
+	guard *guard
+}
+
+// NewTeams returns the teams sink of config file.
+func NewTeams() (*Teams, error) {
+	hook, err := newRESTClient("teams", "https://teams.microsoft.com/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Teams{hook: hook, guard: newGuard("teams")}, nil
+}
+
+// Deliver implements Sink.
+func (t *Teams) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := t.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err := t.post(ctx, svc, f)
+	t.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// post posts the card of a finding to the service's webhook.
+func (t *Teams) post(ctx context.Context, svc config.Service, f match.Finding) error {
+	name := setting(svc, "teams.webhook_secret")
+	if name == "" {
+		name = SecretTeamsWebhook
+	}
+	url, err := secret(name)
+	if err != nil {
+		return err
+	}
+	_, err = t.hook.do(ctx, svc, http.MethodPost, url, teamsMessage(svc, f), nil)
+
+	return err
+}
+
+// teamsMessage returns the message of a finding: an Adaptive Card of its title, service, severity and fingerprint,
+// the log line and a button opening its issue if there is one.
+func teamsMessage(svc config.Service, f match.Finding) map[string]interface{} {
+	facts := []interface{}{map[string]string{"title": "Service", "value": svc.Name}}
+	if f.Severity != "" {
+		facts = append(facts, map[string]string{"title": "Severity", "value": f.Severity})
+	}
+	if f.Fingerprint != "" {
+		facts = append(facts, map[string]string{"title": "Fingerprint", "value": f.Fingerprint})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": f.Title, "weight": "Bolder", "size": "Medium",
+				"wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+			map[string]interface{}{"type": "TextBlock", "text": excerpt(f, maxTeamsExcerpt),
+				"fontType": "Monospace", "wrap": true},
+		},
+	}
+	if f.IssueURL != "" {
+		card["actions"] = []interface{}{
+			map[string]string{"type": "Action.OpenUrl", "title": "View issue", "url": f.IssueURL},
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
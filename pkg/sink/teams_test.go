@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTeamsDeliver(t *testing.T) {
+	var path string
+	status := http.StatusAccepted
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	t.Setenv("OSPREY_SECRET_TEAMS_WEBHOOK_URL", srv.URL+"/hooks/default")
+	t.Setenv("OSPREY_SECRET_APPLE_TEAMS_HOOK", srv.URL+"/hooks/apple")
+	f := match.Finding{Title: "db timeout", Line: "error: db timeout"}
+
+	tests := []struct {
+		name   string
+		conf   map[string]string
+		status int
+		ok     bool
+		path   string
+	}{
+		{"default webhook", nil, http.StatusAccepted, true, "/hooks/default"},
+		{"webhook secret", map[string]string{"services.apple.teams.webhook_secret": "apple_teams_hook"},
+			http.StatusAccepted, true, "/hooks/apple"},
+		{"unknown secret", map[string]string{"services.apple.teams.webhook_secret": "pear_teams_hook"},
+			http.StatusAccepted, false, ""},
+		{"refused", nil, http.StatusBadRequest, false, "/hooks/default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			s, err := NewTeams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, status = "", tt.status
+
+			_, err = s.Deliver(context.Background(), config.Service{Name: "apple"}, f)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if path != tt.path {
+				t.Fatalf("got %q, want %q", path, tt.path)
+			}
+		})
+	}
+}
+
+func TestTeamsMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       match.Finding
+		facts   int
+		actions bool
+	}{
+		{"bare", match.Finding{Title: "db timeout"}, 1, false},
+		{"full", match.Finding{Title: "db timeout", Severity: "high", Fingerprint: "f00d",
+			IssueURL: "https://example.com/3"}, 3, true},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(teamsMessage(config.Service{Name: "apple"}, tt.f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg struct {
+			Attachments []struct {
+				Content struct {
+					Body []struct {
+						Text  string        `json:"text"`
+						Facts []interface{} `json:"facts"`
+					} `json:"body"`
+					Actions []interface{} `json:"actions"`
+				} `json:"content"`
+			} `json:"attachments"`
+		}
+		if err := json.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		card := msg.Attachments[0].Content
+		if card.Body[0].Text != tt.f.Title || len(card.Body[1].Facts) != tt.facts {
+			t.Fatalf("%s: got %q with %d facts, want %q with %d", tt.name, card.Body[0].Text,
+				len(card.Body[1].Facts), tt.f.Title, tt.facts)
+		}
+		if (card.Actions != nil) != tt.actions {
+			t.Fatalf("%s: got actions %v, want %v", tt.name, card.Actions, tt.actions)
+		}
+	}
+}
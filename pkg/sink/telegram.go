@@ -0,0 +1,155 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/spf13/viper"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// SecretTelegramToken is the name of the secret holding the token of the telegram bot, unless a service names
+	// another one with telegram.token_secret.
+	SecretTelegramToken = "telegram_token"
+
+	telegramAPIURL = "https://api.telegram.org/"
+
+	// maxTelegramTitle and maxTelegramExcerpt bound the title and the log line of a message, telegram allows 4096
+	// characters per message.
+	maxTelegramTitle   = 500
+	maxTelegramExcerpt = 3000
+)
+
+func init() {
+	Register("telegram", share(func() (Sink, error) { return NewTelegram() }))
+}
+
+// Telegram sends findings to a telegram chat, e.g. a group of the on-call engineers, as the bot whose token is the
+// telegram_token secret, or the secret telegram.token_secret names:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, telegram]
+//	    telegram:
+//	      chat_id: "-1001234567890"
+//
+// The bot must be a member of the chat.
+type Telegram struct {
+	api *restClient
+
+	// guard refuses deliveries while telegram is rate limited or down.
+	guard *guard
+}
+
+// NewTelegram returns the telegram sink of config file, calling the bot API server telegram.base_url names if set,
+// e.g. a local one.
+func NewTelegram() (*Telegram, error) {
+	base := viper.GetString("telegram.base_url")
+	if base == "" {
+		base = telegramAPIURL
+	}
+	api, err := newRESTClient("telegram", base, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Telegram{api: api, guard: newGuard("telegram")}, nil
+}
+
+// Deliver implements Sink.
+func (t *Telegram) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := t.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	err := t.send(ctx, svc, f)
+	t.guard.record(err)
+
+	return Delivery{}, err
+}
+
+// send sends the message of a finding to the service's chat.
+func (t *Telegram) send(ctx context.Context, svc config.Service, f match.Finding) error {
+	chat := setting(svc, "telegram.chat_id")
+	if chat == "" {
+		return fmt.Errorf("telegram sink of %s needs telegram.chat_id", svc.Name)
+	}
+	name := setting(svc, "telegram.token_secret")
+	if name == "" {
+		name = SecretTelegramToken
+	}
+	token, err := secret(name)
+	if err != nil {
+		return err
+	}
+
+	msg := map[string]interface{}{
+		"chat_id":                  chat,
+		"text":                     telegramMessage(svc, f),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	var res struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	// The token is part of the path, so it is kept out of the errors, and the path is relative so its colon does not
+	// make it a url scheme.
+	_, err = t.api.do(ctx, svc, http.MethodPost, "./bot"+url.PathEscape(token)+"/sendMessage", msg, &res)
+	if err != nil {
+		return &redactedError{err: err, secret: token}
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram refused the message of %s to %s, %s", svc.Name, chat, res.Description)
+	}
+
+	return nil
+}
+
+// telegramMessage returns the message of a finding in telegram's HTML: its title, the service, severity and
+// fingerprint, the log line and a link to its issue if there is one.
+func telegramMessage(svc config.Service, f match.Finding) string {
+	meta := []string{html.EscapeString(svc.Name)}
+	if f.Severity != "" {
+		meta = append(meta, html.EscapeString(f.Severity))
+	}
+	if f.Fingerprint != "" {
+		meta = append(meta, "<code>"+html.EscapeString(f.Fingerprint)+"</code>")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b>\n%s\n<pre>%s</pre>", html.EscapeString(truncate(f.Title, maxTelegramTitle)),
+		strings.Join(meta, " · "), html.EscapeString(excerpt(f, maxTelegramExcerpt)))
+	if f.IssueURL != "" {
+		fmt.Fprintf(&b, "\n<a href=\"%s\">%s</a>", html.EscapeString(f.IssueURL), html.EscapeString(f.IssueURL))
+	}
+
+	return b.String()
+}
+
+// redactedError is an error whose message must not tell a secret, e.g. one of a call with the secret in its url.
+type redactedError struct {
+	err error
+
+	secret string
+}
+
+// Error implements error.
+func (e *redactedError) Error() string {
+	msg := e.err.Error()
+	if e.secret != "" {
+		msg = strings.Replace(msg, url.PathEscape(e.secret), "<redacted>", -1)
+		msg = strings.Replace(msg, e.secret, "<redacted>", -1)
+	}
+
+	return msg
+}
+
+// Unwrap returns the error.
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,92 @@
+package sink_test
+
+import (
+	"context"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newTelegram returns a telegram sink calling a fake bot API, as the bot 123:abc or the bot 456:def of secret
+// apple_telegram_token, sending the findings of service apple to chat -100123.
+func newTelegram(t *testing.T, conf map[string]string) (*sink.Telegram, *apiServer) {
+	t.Helper()
+
+	t.Setenv("OSPREY_SECRET_TELEGRAM_TOKEN", "123:abc")
+	t.Setenv("OSPREY_SECRET_APPLE_TELEGRAM_TOKEN", "456:def")
+	srv := newAPIServer(t)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("telegram.base_url", srv.URL)
+	viper.Set(config.Key("apple", "telegram.chat_id"), "-100123")
+	for k, v := range conf {
+		viper.Set(k, v)
+	}
+	tg, err := sink.NewTelegram()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tg, srv
+}
+
+func TestTelegramDeliver(t *testing.T) {
+	tests := []struct {
+		name  string
+		conf  map[string]string
+		route string
+	}{
+		{"default token", nil, "POST /bot123:abc/sendMessage"},
+		{"token secret", map[string]string{config.Key("apple", "telegram.token_secret"): "apple_telegram_token"},
+			"POST /bot456:def/sendMessage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg, srv := newTelegram(t, tt.conf)
+			srv.handle(tt.route, http.StatusOK, `{"ok": true}`)
+			f := match.Finding{Title: "db <timeout>", Line: "error: a < b", IssueURL: "https://example.com/3"}
+
+			if _, err := tg.Deliver(context.Background(), config.Service{Name: "apple"}, f); err != nil {
+				t.Fatal(err)
+			}
+			msg := srv.call(t, tt.route).decode(t)
+			text, _ := msg["text"].(string)
+			if msg["chat_id"] != "-100123" || !strings.HasPrefix(text, "<b>db &lt;timeout&gt;</b>") ||
+				!strings.Contains(text, "<pre>error: a &lt; b</pre>") {
+				t.Fatalf("got %v, want the escaped finding sent to chat -100123", msg)
+			}
+		})
+	}
+}
+
+func TestTelegramRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   map[string]string
+		status int
+		body   string
+	}{
+		{"not ok", nil, http.StatusOK, `{"ok": false, "description": "Bad Request: chat not found"}`},
+		{"unauthorized", nil, http.StatusUnauthorized, `{"ok": false, "description": "Unauthorized"}`},
+		{"no chat", map[string]string{config.Key("apple", "telegram.chat_id"): ""}, http.StatusOK, `{"ok": true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg, srv := newTelegram(t, tt.conf)
+			srv.handle("POST /bot123:abc/sendMessage", tt.status, tt.body)
+
+			_, err := tg.Deliver(context.Background(), config.Service{Name: "apple"}, match.Finding{Title: "db timeout"})
+			if err == nil {
+				t.Fatal("got nil, want the message refused")
+			}
+			// The token is in the path of the call, it must not leak into the errors.
+			if strings.Contains(err.Error(), "123:abc") {
+				t.Fatalf("got %q, want the token redacted", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	"github.com/google/go-github/github"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"sync"
+)
+
+// serviceToken supplies the github, or tracker, token of a service from its own keys in config file, so one osprey
+// can file issues in the repositories of organizations granting different tokens:
+//
+//	services:
+//	  apple:
+//	    repo_owner: acme
+//	    token_file: /run/secrets/acme_token
+//
+// token holds the token itself, token_env names the environment variable holding it, token_file the file,
+// token_command the command printing it and token_secret the secret of the credentials provider, e.g. of Vault; the
+// first one set is used.
+type serviceToken struct {
+	tracker, service, token, env, file, secret string
+
+	command []string
+
+	// creds supplies the secret, nil if the sink has no credentials provider.
+	creds credentials.Provider
+}
+
+// newServiceToken returns the token keys of a service for a tracker, e.g. github, its secret read from creds.
+func newServiceToken(tracker string, svc config.Service, creds credentials.Provider) serviceToken {
+	return serviceToken{
+		tracker: tracker,
+		service: svc.Name,
+		token:   viper.GetString(config.Key(svc.Name, "token")),
+		env:     viper.GetString(config.Key(svc.Name, "token_env")),
+		file:    viper.GetString(config.Key(svc.Name, "token_file")),
+		command: viper.GetStringSlice(config.Key(svc.Name, "token_command")),
+		secret:  viper.GetString(config.Key(svc.Name, "token_secret")),
+		creds:   creds,
+	}
+}
+
+// isSet reports whether the service has a token of its own.
+func (t serviceToken) isSet() bool {
+	return t.token != "" || t.env != "" || t.file != "" || len(t.command) > 0 || t.secret != ""
+}
+
+// Secret implements credentials.Provider, returning the token of the service whichever the name.
+func (t serviceToken) Secret(string) (string, error) {
+	switch {
+	case t.token != "":
+		return strings.TrimSpace(t.token), nil
+	case t.env != "":
+		if tk := strings.TrimSpace(os.Getenv(t.env)); tk != "" {
+			return tk, nil
+		}
+		return "", fmt.Errorf("%s token of %s is not set, set %s", t.tracker, t.service, t.env)
+	case t.file != "":
+		return credentials.ReadTokenFile(t.file)
+	case len(t.command) > 0:
+		return credentials.RunTokenCommand(t.command)
+	case t.creds == nil:
+		return "", fmt.Errorf("%s token secret %s of %s needs a credentials provider", t.tracker, t.secret,
+			t.service)
+	}
+
+	return t.creds.Secret(t.secret)
+}
+
+// client returns the github client of a service: a client of its own if it has a token of its own, read again every
+// github.token_refresh, g.Client otherwise.
+func (g *GitHub) client(svc config.Service) (*github.Client, error) {
+	t := newServiceToken("github", svc, g.Credentials)
+	if !t.isSet() {
+		if g.Client == nil {
+			return nil, errors.New("github sink has no client")
+		}
+		return g.Client, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if c, ok := g.clients[svc.Name]; ok {
+		return c, nil
+	}
+	ts, err := credentials.NewRotatingTokenSource(t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read github token of %s, %s", svc.Name, err.Error())
+	}
+	c, err := newGitHubClient(context.Background(), ts)
+	if err != nil {
+		return nil, err
+	}
+	if g.clients == nil {
+		g.clients = make(map[string]*github.Client)
+	}
+	g.clients[svc.Name] = c
+
+	return c, nil
+}
+
+// trackerTokens supplies the tokens of a tracker's services: a service's own token keys if it has them, the
+// tracker's secret of the credentials provider otherwise, e.g. gitlab_token. Either is read again every
+// github.token_refresh.
+type trackerTokens struct {
+	mu sync.Mutex
+
+	// tracker names the tracker, secret its secret.
+	tracker, secret string
+
+	// sources are the token sources of the services with a token of their own by name, "" for the others.
+	sources map[string]*credentials.RotatingTokenSource
+}
+
+// token returns the current token of a service.
+func (t *trackerTokens) token(svc config.Service) (string, error) {
+	creds, err := sinkCredentials()
+	if err != nil {
+		return "", err
+	}
+	st := newServiceToken(t.tracker, svc, creds)
+	name := svc.Name
+	var p credentials.Provider = st
+	if !st.isSet() {
+		name, p = "", namedSecret{creds: creds, name: t.secret}
+	}
+
+	t.mu.Lock()
+	ts, ok := t.sources[name]
+	if !ok {
+		if ts, err = credentials.NewRotatingTokenSource(p); err != nil {
+			t.mu.Unlock()
+			return "", fmt.Errorf("unable to read %s token of %s, %s", t.tracker, svc.Name, err.Error())
+		}
+		if t.sources == nil {
+			t.sources = make(map[string]*credentials.RotatingTokenSource)
+		}
+		t.sources[name] = ts
+	}
+	t.mu.Unlock()
+
+	tk, err := ts.Token()
+	if err != nil {
+		return "", err
+	}
+
+	return tk.AccessToken, nil
+}
+
+// namedSecret is a credentials.Provider returning one secret of creds whichever the name.
+type namedSecret struct {
+	creds credentials.Provider
+	name  string
+}
+
+// Secret implements credentials.Provider.
+func (s namedSecret) Secret(string) (string, error) {
+	return s.creds.Secret(s.name)
+}
@@ -0,0 +1,229 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/credentials"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Tracker is an issue tracker besides github, e.g. GitLab, which a TrackerSink files the findings in. The sink
+// keeps the fingerprints and counts the occurrences, a tracker only calls its API; its errors wrap
+// ErrSinkUnavailable, or are a RateLimitError, when they are of those kinds.
+type Tracker interface {
+	// Create files an issue in the service's project.
+	Create(ctx context.Context, svc config.Service, iss Issue) (Ticket, error)
+
+	// Find returns the issues of the service's project which may have the fingerprint in their body, the open ones
+	// only unless closed is set, the most recently updated first.
+	Find(ctx context.Context, svc config.Service, fingerprint string, closed bool) ([]Ticket, error)
+
+	// Edit replaces the body of an issue.
+	Edit(ctx context.Context, svc config.Service, number int, body string) error
+
+	// SetClosed closes or reopens an issue.
+	SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error
+
+	// AddComment comments on an issue, returning the url of the comment, empty if it has none.
+	AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error)
+
+	// CountOpen returns the number of open issues of the service's project with a fingerprint comment.
+	CountOpen(ctx context.Context, svc config.Service) (int, error)
+}
+
+// Issue is an issue to file in a tracker.
+type Issue struct {
+	Title, Body string
+
+	// Labels, Assignees and Milestone are those of the finding and its service, as the tracker supports them.
+	Labels, Assignees []string
+
+	Milestone int
+}
+
+// Ticket is an issue of a tracker.
+type Ticket struct {
+	// Number identifies the issue in the service's project.
+	Number int
+
+	URL, Title, Body string
+
+	Closed bool
+}
+
+// TrackerSink delivers findings as the issues of a Tracker. Like the github sink, a finding is dropped, or commented
+// on its issue with on_duplicate: comment, if an open issue already has its fingerprint, and with reopen a closed
+// one is reopened. Deliveries are refused while the tracker is rate limited or its circuit breaker open.
+type TrackerSink struct {
+	// Name names the tracker in logs and errors.
+	Name string
+
+	// Tracker files the issues.
+	Tracker Tracker
+
+	// guard refuses deliveries while the tracker is rate limited or down, nil to try every delivery.
+	guard *guard
+}
+
+// NewTrackerSink returns the sink of a tracker, its circuit breaker set by <name>.breaker.failures and
+// <name>.breaker.cooldown in config file like github's.
+func NewTrackerSink(name string, t Tracker) *TrackerSink {
+	return &TrackerSink{Name: name, Tracker: t, guard: newGuard(name)}
+}
+
+// shareTracker returns a factory of the sink of a tracker created once by newTracker, so all services share it and
+// its circuit breaker.
+func shareTracker(name string, newTracker func() (Tracker, error)) Factory {
+	return share(func() (Sink, error) {
+		t, err := newTracker()
+		if err != nil {
+			return nil, err
+		}
+		return NewTrackerSink(name, t), nil
+	})
+}
+
+// Deliver implements Sink.
+func (s *TrackerSink) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if err := s.guard.allow(); err != nil {
+		return Delivery{}, err
+	}
+	dlv, err := s.deliver(ctx, svc, f)
+	s.guard.record(err)
+
+	return dlv, err
+}
+
+// deliver delivers a finding like Deliver, regardless of the guard. If the search fails the issue is filed anyway,
+// unless it is rate limited.
+func (s *TrackerSink) deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	if f.Fingerprint != "" && svc.SearchDuplicates {
+		dup, err := s.findDuplicate(ctx, svc, f.Fingerprint, svc.Reopen)
+		if _, ok := RetryAt(err); ok {
+			return Delivery{}, err
+		} else if err != nil {
+			log.Printf("Unable to search duplicates of %s in %s, %s\n", svc.Name, s.Name, err.Error())
+		} else if dup != nil && dup.Closed {
+			return s.comment(ctx, svc, *dup, f, true)
+		} else if dup != nil && svc.OnDuplicate == config.DuplicateComment {
+			return s.comment(ctx, svc, *dup, f, false)
+		} else if dup != nil {
+			return Delivery{}, fmt.Errorf("%w, %s issue #%d is open for fingerprint %s", ErrDropped, s.Name,
+				dup.Number, f.Fingerprint)
+		}
+	}
+
+	body := f.Body
+	if f.Fingerprint != "" {
+		body = strings.TrimRight(body, "\n") + "\n\n" + FingerprintComment(f.Fingerprint)
+	}
+	t, err := s.Tracker.Create(ctx, svc, Issue{Title: f.Title, Body: body, Labels: f.Labels,
+		Assignees: svc.Assignees, Milestone: svc.Milestone})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	return Delivery{Number: t.Number, URL: t.URL}, nil
+}
+
+// findDuplicate returns an open issue with the fingerprint, or with closed the most recently updated closed one if
+// none is open, nil if there is none.
+func (s *TrackerSink) findDuplicate(ctx context.Context, svc config.Service, fingerprint string,
+	closed bool) (*Ticket, error) {
+	tickets, err := s.Tracker.Find(ctx, svc, fingerprint, closed)
+	if err != nil {
+		return nil, err
+	}
+	var found *Ticket
+	for i := range tickets {
+		t := &tickets[i]
+		if !strings.Contains(t.Body, FingerprintComment(fingerprint)) && !strings.Contains(t.Title, fingerprint) {
+			continue
+		}
+		if !t.Closed {
+			return t, nil
+		}
+		if found == nil {
+			found = t
+		}
+	}
+
+	return found, nil
+}
+
+// comment comments on the issue of a finding seen again, reopening it if asked to, and counts the occurrence in its
+// body.
+func (s *TrackerSink) comment(ctx context.Context, svc config.Service, t Ticket, f match.Finding,
+	reopen bool) (Delivery, error) {
+	body, n := countOccurrences(t.Body, f)
+	if err := s.Tracker.Edit(ctx, svc, t.Number, body); err != nil {
+		return Delivery{}, err
+	}
+	if reopen {
+		if err := s.Tracker.SetClosed(ctx, svc, t.Number, false); err != nil {
+			return Delivery{}, err
+		}
+	}
+	url, err := s.Tracker.AddComment(ctx, svc, t.Number, seenAgain(svc, f, n, reopen))
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if url == "" {
+		url = t.URL
+	}
+	return Delivery{Number: t.Number, URL: url, Updated: true}, nil
+}
+
+// Resolve implements Resolver, commenting on the issue and closing it.
+func (s *TrackerSink) Resolve(ctx context.Context, svc config.Service, number int, comment string) error {
+	if err := s.Comment(ctx, svc, number, comment); err != nil {
+		return err
+	}
+
+	return s.Tracker.SetClosed(ctx, svc, number, true)
+}
+
+// Comment implements Commenter.
+func (s *TrackerSink) Comment(ctx context.Context, svc config.Service, number int, comment string) error {
+	_, err := s.Tracker.AddComment(ctx, svc, number, comment)
+	return err
+}
+
+// OpenIssues implements OpenCounter.
+func (s *TrackerSink) OpenIssues(ctx context.Context, svc config.Service) (int, error) {
+	return s.Tracker.CountOpen(ctx, svc)
+}
+
+var (
+	credsMu sync.Mutex
+	creds   credentials.Provider
+)
+
+// SetCredentials sets the credentials provider the registered sinks read their secrets from, e.g. the one of the
+// github sink, so secret stores are only logged into once. Without one they create the provider of config file.
+func SetCredentials(p credentials.Provider) {
+	credsMu.Lock()
+	defer credsMu.Unlock()
+	creds = p
+}
+
+// sinkCredentials returns the credentials provider of the registered sinks.
+func sinkCredentials() (credentials.Provider, error) {
+	credsMu.Lock()
+	defer credsMu.Unlock()
+
+	if creds == nil {
+		p, err := credentials.New()
+		if err != nil {
+			return nil, err
+		}
+		creds = p
+	}
+
+	return creds, nil
+}
@@ -0,0 +1,238 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// apiServer is a fake tracker API answering calls by "<method> <escaped path>" and recording them.
+type apiServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	routes map[string]apiResponse
+	calls  []apiCall
+}
+
+// apiResponse is the canned response of a route.
+type apiResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// apiCall is a recorded call.
+type apiCall struct {
+	route  string
+	query  string
+	header http.Header
+	body   string
+}
+
+// newAPIServer starts a fake tracker API answering 404 to calls without a route.
+func newAPIServer(t *testing.T) *apiServer {
+	t.Helper()
+
+	s := &apiServer{routes: make(map[string]apiResponse)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dat, _ := io.ReadAll(r.Body)
+		route := r.Method + " " + r.URL.EscapedPath()
+		s.mu.Lock()
+		s.calls = append(s.calls, apiCall{route: route, query: r.URL.RawQuery, header: r.Header, body: string(dat)})
+		resp, ok := s.routes[route]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"message": "not found"}`, http.StatusNotFound)
+			return
+		}
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		if resp.status != 0 {
+			w.WriteHeader(resp.status)
+		}
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// handle sets the response of a route, e.g. "POST /projects/1/issues".
+func (s *apiServer) handle(route string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[route] = apiResponse{status: status, body: body}
+}
+
+// handleWithHeader sets the response of a route with headers.
+func (s *apiServer) handleWithHeader(route string, status int, header map[string]string, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[route] = apiResponse{status: status, header: header, body: body}
+}
+
+// call returns the last call of a route, failing the test if there is none.
+func (s *apiServer) call(t *testing.T, route string) apiCall {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.calls) - 1; i >= 0; i-- {
+		if s.calls[i].route == route {
+			return s.calls[i]
+		}
+	}
+	t.Fatalf("got no call of %s", route)
+
+	return apiCall{}
+}
+
+// decode decodes the JSON body of a call.
+func (c apiCall) decode(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(c.body), &m); err != nil {
+		t.Fatalf("got body %q, want a JSON object: %v", c.body, err)
+	}
+
+	return m
+}
+
+// fakeTracker is an in-memory Tracker.
+type fakeTracker struct {
+	tickets  []sink.Ticket
+	comments map[int][]string
+
+	// findErr is returned by Find if set.
+	findErr error
+}
+
+func (t *fakeTracker) Create(ctx context.Context, svc config.Service, iss sink.Issue) (sink.Ticket, error) {
+	tk := sink.Ticket{Number: len(t.tickets) + 1, Title: iss.Title, Body: iss.Body,
+		URL: fmt.Sprintf("https://tracker.example.com/%d", len(t.tickets)+1)}
+	t.tickets = append(t.tickets, tk)
+	return tk, nil
+}
+
+func (t *fakeTracker) Find(ctx context.Context, svc config.Service, fingerprint string,
+	closed bool) ([]sink.Ticket, error) {
+	if t.findErr != nil {
+		return nil, t.findErr
+	}
+	var found []sink.Ticket
+	for _, tk := range t.tickets {
+		if strings.Contains(tk.Body, fingerprint) && (closed || !tk.Closed) {
+			found = append(found, tk)
+		}
+	}
+	return found, nil
+}
+
+func (t *fakeTracker) Edit(ctx context.Context, svc config.Service, number int, body string) error {
+	t.tickets[number-1].Body = body
+	return nil
+}
+
+func (t *fakeTracker) SetClosed(ctx context.Context, svc config.Service, number int, closed bool) error {
+	t.tickets[number-1].Closed = closed
+	return nil
+}
+
+func (t *fakeTracker) AddComment(ctx context.Context, svc config.Service, number int, text string) (string, error) {
+	if t.comments == nil {
+		t.comments = make(map[int][]string)
+	}
+	t.comments[number] = append(t.comments[number], text)
+	return "", nil
+}
+
+func (t *fakeTracker) CountOpen(ctx context.Context, svc config.Service) (int, error) {
+	n := 0
+	for _, tk := range t.tickets {
+		if !tk.Closed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestTrackerSink(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	ft := &fakeTracker{}
+	s := sink.NewTrackerSink("fake", ft)
+	svc := config.Service{Name: "apple", SearchDuplicates: true, Reopen: true}
+	f := match.Finding{Title: "apple: db timeout", Body: "error: db timeout", Fingerprint: "f00d"}
+
+	tests := []struct {
+		name        string
+		onDuplicate string
+		close       bool
+		findErr     error
+		dropped     bool
+		tickets     int
+		comments    int
+		updated     bool
+	}{
+		{"filed", "", false, nil, false, 1, 0, false},
+		{"duplicate dropped", "", false, nil, true, 1, 0, false},
+		{"duplicate commented", config.DuplicateComment, false, nil, false, 1, 1, true},
+		{"closed issue reopened", "", true, nil, false, 1, 2, true},
+		// A failed search files the issue anyway.
+		{"search failed", "", false, errors.New("search is down"), false, 2, 2, false},
+	}
+	for _, tt := range tests {
+		svc.OnDuplicate = tt.onDuplicate
+		if tt.close {
+			ft.tickets[0].Closed = true
+		}
+		ft.findErr = tt.findErr
+		dlv, err := s.Deliver(context.Background(), svc, f)
+		if errors.Is(err, sink.ErrDropped) != tt.dropped || (err != nil && !tt.dropped) {
+			t.Fatalf("%s: got %v, want dropped %v", tt.name, err, tt.dropped)
+		}
+		if len(ft.tickets) != tt.tickets || len(ft.comments[1]) != tt.comments || dlv.Updated != tt.updated {
+			t.Fatalf("%s: got %d tickets, %d comments, updated %v, want %d, %d, %v", tt.name, len(ft.tickets),
+				len(ft.comments[1]), dlv.Updated, tt.tickets, tt.comments, tt.updated)
+		}
+	}
+	if ft.tickets[0].Closed {
+		t.Fatal("got the issue seen again closed, want it reopened")
+	}
+	if !strings.Contains(ft.tickets[0].Body, sink.FingerprintComment("f00d")) {
+		t.Fatalf("got body %q, want the fingerprint comment", ft.tickets[0].Body)
+	}
+}
+
+func TestTrackerSinkRateLimitedSearch(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	reset := time.Now().Add(time.Minute)
+	ft := &fakeTracker{findErr: &sink.RateLimitError{Reset: reset, Err: errors.New("slow down")}}
+	s := sink.NewTrackerSink("fake", ft)
+	svc := config.Service{Name: "apple", SearchDuplicates: true}
+
+	// A rate limited search is not filed blindly, the finding waits for the reset instead.
+	_, err := s.Deliver(context.Background(), svc, match.Finding{Title: "apple: db timeout", Fingerprint: "f00d"})
+	if at, ok := sink.RetryAt(err); !ok || !at.Equal(reset) {
+		t.Fatalf("got %v, want a rate limit until %s", err, reset)
+	}
+	if len(ft.tickets) != 0 {
+		t.Fatalf("got %d tickets, want none", len(ft.tickets))
+	}
+}
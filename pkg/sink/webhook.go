@@ -0,0 +1,244 @@
+package sink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of a webhook's payload, sha256= and its hex digest like
+// github's X-Hub-Signature-256.
+const SignatureHeader = "X-Osprey-Signature-256"
+
+func init() {
+	Register("webhook", share(func() (Sink, error) { return NewWebhook() }))
+}
+
+// Webhook sends findings to an HTTP endpoint as JSON documents, rendered by the service's webhook.template if it has
+// one:
+//
+//	services:
+//	  apple:
+//	    sinks: [github, webhook]
+//	    webhook:
+//	      url: https://hooks.internal.example/osprey
+//	      headers: {X-Team: apple}
+//	      header_secrets: {Authorization: apple_hook_auth}
+//	      hmac_secret: apple_hook_hmac
+//	      template: '{"text": {{json .Title}}, "link": {{json .IssueURL}}}'
+//
+// With webhook.hmac_secret the payload is signed with that secret in the X-Osprey-Signature-256 header. Each url has
+// its own circuit breaker, so one endpoint being down does not hold back the others.
+type Webhook struct {
+	api *restClient
+
+	mu sync.Mutex
+
+	// templates are the parsed templates, keyed on their text.
+	templates map[string]*template.Template
+
+	// guards refuse deliveries to the urls which are rate limited or down, keyed on the url.
+	guards map[string]*guard
+}
+
+// findingEvent is the JSON document of a finding the event sinks publish, e.g. to SNS, and what webhook templates
+// render.
+type findingEvent struct {
+	Service     string            `json:"service"`
+	Title       string            `json:"title"`
+	Body        string            `json:"body"`
+	Line        string            `json:"line"`
+	Keyword     string            `json:"keyword,omitempty"`
+	Severity    string            `json:"severity,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Labels      []string          `json:"labels,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Occurrences int               `json:"occurrences"`
+	IssueURL    string            `json:"issue_url,omitempty"`
+	Time        string            `json:"time"`
+}
+
+// webhookFuncs are the functions of webhook templates: json renders a value as JSON, e.g. a quoted string.
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		dat, err := json.Marshal(v)
+		return string(dat), err
+	},
+}
+
+// NewWebhook returns the webhook sink of config file.
+func NewWebhook() (*Webhook, error) {
+	w := &Webhook{templates: make(map[string]*template.Template), guards: make(map[string]*guard)}
+	api, err := newRESTClient("webhook", "http://localhost/", w.authorize)
+	if err != nil {
+		return nil, err
+	}
+	w.api = api
+
+	return w, nil
+}
+
+// authorize sets the headers of the service on a request and signs its body.
+func (w *Webhook) authorize(req *http.Request, svc config.Service) error {
+	for k, v := range settingMap(svc, "webhook.headers") {
+		req.Header.Set(k, v)
+	}
+	for k, name := range settingMap(svc, "webhook.header_secrets") {
+		v, err := secret(name)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+
+	name := setting(svc, "webhook.hmac_secret")
+	if name == "" || req.GetBody == nil {
+		return nil
+	}
+	key, err := secret(name)
+	if err != nil {
+		return err
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	dat, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(dat)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	return nil
+}
+
+// Deliver implements Sink.
+func (w *Webhook) Deliver(ctx context.Context, svc config.Service, f match.Finding) (Delivery, error) {
+	url := setting(svc, "webhook.url")
+	if url == "" {
+		return Delivery{}, fmt.Errorf("webhook sink of %s needs webhook.url", svc.Name)
+	}
+	payload, err := w.payload(svc, f)
+	if err != nil {
+		return Delivery{}, err
+	}
+	method := strings.ToUpper(setting(svc, "webhook.method"))
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	g := w.guard(url)
+	if err := g.allow(); err != nil {
+		return Delivery{}, err
+	}
+	_, err = w.api.do(ctx, svc, method, url, payload, nil)
+	g.record(err)
+
+	return Delivery{}, err
+}
+
+// guard returns the guard of a url.
+func (w *Webhook) guard(url string) *guard {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	g, ok := w.guards[url]
+	if !ok {
+		g = newGuard("webhook")
+		w.guards[url] = g
+	}
+
+	return g
+}
+
+// payload returns the document of a finding, rendered by the service's template, or else its findingEvent.
+func (w *Webhook) payload(svc config.Service, f match.Finding) (json.RawMessage, error) {
+	data := newFindingEvent(svc, f)
+	t, err := w.template(svc)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return json.Marshal(data)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("unable to render webhook.template of %s, %s", svc.Name, err.Error())
+	}
+	if !json.Valid([]byte(b.String())) {
+		return nil, fmt.Errorf("webhook.template of %s did not render valid JSON", svc.Name)
+	}
+
+	return json.RawMessage(b.String()), nil
+}
+
+// newFindingEvent returns the event of a finding of a service, detected now.
+func newFindingEvent(svc config.Service, f match.Finding) findingEvent {
+	ev := findingEvent{
+		Service:     svc.Name,
+		Title:       f.Title,
+		Body:        f.Body,
+		Line:        f.Line,
+		Keyword:     f.Keyword,
+		Severity:    f.Severity,
+		Fingerprint: f.Fingerprint,
+		Labels:      f.Labels,
+		Fields:      f.Fields,
+		Occurrences: f.Occurrences,
+		IssueURL:    f.IssueURL,
+		Time:        time.Now().Format(time.RFC3339),
+	}
+	if ev.Occurrences < 1 {
+		ev.Occurrences = 1
+	}
+
+	return ev
+}
+
+// template returns the parsed webhook.template of a service, nil if it has none. A value without actions is the
+// path of a template file, relative to the service's base_dir.
+func (w *Webhook) template(svc config.Service) (*template.Template, error) {
+	text := setting(svc, "webhook.template")
+	if text == "" {
+		return nil, nil
+	}
+	if !strings.Contains(text, "{{") {
+		path := text
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(svc.BaseDir, path)
+		}
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read webhook.template of %s, %s", svc.Name, err.Error())
+		}
+		text = string(dat)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.templates[text]; ok {
+		return t, nil
+	}
+	t, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook.template of %s, %s", svc.Name, err.Error())
+	}
+	w.templates[text] = t
+
+	return t, nil
+}
@@ -0,0 +1,128 @@
+package sink_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"github.com/NBCFB/Iguana2/pkg/sink"
+	"github.com/spf13/viper"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWebhookDeliver(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_APPLE_HOOK_AUTH", "Bearer hook")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hook.tmpl"), []byte(`{"service": {{json .Service}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := match.Finding{Title: "db timeout", Line: "error: db timeout", Fingerprint: "f00d"}
+
+	tests := []struct {
+		name  string
+		conf  map[string]interface{}
+		route string
+		field string
+		want  interface{}
+	}{
+		{"finding event", nil, "POST /osprey", "title", "db timeout"},
+		{"method", map[string]interface{}{"webhook.method": "put"}, "PUT /osprey", "fingerprint", "f00d"},
+		{"template", map[string]interface{}{"webhook.template": `{"text": {{json .Title}}}`}, "POST /osprey",
+			"text", "db timeout"},
+		{"template file", map[string]interface{}{"webhook.template": "hook.tmpl"}, "POST /osprey", "service",
+			"apple"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newAPIServer(t)
+			srv.handle(tt.route, http.StatusNoContent, "")
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set(config.Key("apple", "webhook.url"), srv.URL+"/osprey")
+			viper.Set(config.Key("apple", "webhook.headers"), map[string]string{"X-Team": "apple"})
+			viper.Set(config.Key("apple", "webhook.header_secrets"), map[string]string{
+				"Authorization": "apple_hook_auth"})
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			w, err := sink.NewWebhook()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Deliver(context.Background(), config.Service{Name: "apple", BaseDir: dir}, f); err != nil {
+				t.Fatal(err)
+			}
+			call := srv.call(t, tt.route)
+			if got := call.decode(t)[tt.field]; got != tt.want {
+				t.Fatalf("got %s %v, want %v", tt.field, got, tt.want)
+			}
+			if call.header.Get("X-Team") != "apple" || call.header.Get("Authorization") != "Bearer hook" {
+				t.Fatalf("got headers %v, want those of the service", call.header)
+			}
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	t.Setenv("OSPREY_SECRET_APPLE_HOOK_HMAC", "s3cret")
+	srv := newAPIServer(t)
+	srv.handle("POST /osprey", http.StatusOK, "")
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set(config.Key("apple", "webhook.url"), srv.URL+"/osprey")
+	viper.Set(config.Key("apple", "webhook.hmac_secret"), "apple_hook_hmac")
+	w, err := sink.NewWebhook()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Deliver(context.Background(), config.Service{Name: "apple"},
+		match.Finding{Title: "db timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	call := srv.call(t, "POST /osprey")
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(call.body))
+	if got, want := call.header.Get(sink.SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestWebhookInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+	}{
+		{"no url", map[string]interface{}{config.Key("apple", "webhook.template"): `{}`}},
+		{"invalid template", map[string]interface{}{config.Key("apple", "webhook.url"): "http://localhost/",
+			config.Key("apple", "webhook.template"): `{"text": {{.Title}`}},
+		{"invalid JSON", map[string]interface{}{config.Key("apple", "webhook.url"): "http://localhost/",
+			config.Key("apple", "webhook.template"): `{"text": {{.Title}}}`}},
+		{"missing template file", map[string]interface{}{config.Key("apple", "webhook.url"): "http://localhost/",
+			config.Key("apple", "webhook.template"): "missing.tmpl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+			w, err := sink.NewWebhook()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Deliver(context.Background(), config.Service{Name: "apple", BaseDir: t.TempDir()},
+				match.Finding{Title: "db timeout"}); err == nil {
+				t.Fatal("got nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package source
+
+// Lines returns the lines after anchor.
+//
+// Deprecated: use Read, which also returns the checkpoint to resume from.
+func (f *File) Lines(anchor int) ([]string, error) {
+	lines, _, err := f.Read(anchor)
+	return lines, err
+}
@@ -0,0 +1,319 @@
+// Package source reads the log lines osprey scans.
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrLogNotFound is returned, wrapped, when a log file does not exist, e.g. before the service's first start.
+var ErrLogNotFound = errors.New("log file not found")
+
+// ErrLogUnreadable is returned, wrapped, when osprey is not allowed to read a log file.
+var ErrLogUnreadable = errors.New("log file is unreadable")
+
+// DefaultFilePattern is the glob pattern of the log files in a directory location without a file pattern.
+const DefaultFilePattern = "*.log"
+
+// errRotatedMatched is returned by rotatedLines for a rotated file matched by the pattern of the File.
+var errRotatedMatched = errors.New("rotated file is read on its own")
+
+// File reads the lines of a local log file. It is a Resumer, keeping the byte offset of the next line and the
+// identity of the file in the position, so a read seeks to the unread lines rather than reading the file again from
+// the start.
+type File struct {
+	// Path is the log file location.
+	Path string
+
+	// Pattern is the glob pattern Path was matched by, if any. The rest of a rotated file the pattern also matches
+	// is not read, since it is read as a file of its own.
+	Pattern string
+
+	mu sync.Mutex
+
+	// last is where the last read ended, nil before the first one.
+	last *Position
+}
+
+// Read implements Source. The checkpoint is the number of lines read so far. It resumes from the position of the
+// last read if that ended at checkpoint, and otherwise counts the lines from the start.
+func (f *File) Read(checkpoint int) ([]string, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pos := Position{Line: checkpoint}
+	if f.last != nil && f.last.Line == checkpoint {
+		pos = *f.last
+	}
+	lines, next, err := f.resume(pos)
+	return lines, next.Line, err
+}
+
+// Resume implements Resumer. A line is read once its newline is written, so a line being written is not scanned
+// half. The file is read from its offset, or from the start if it was rotated, being another file than that of pos,
+// or truncated, being smaller than it was or no longer having a line start at the offset. The lines of a rotated
+// file after the offset are read first, if it is found next to the file, e.g. as app.log.1 or app-20240101.log,
+// so errors logged right before the rotation are scanned. The lines before a position without an offset are
+// counted from the start, clamped to its end.
+func (f *File) Resume(pos Position) ([]string, Position, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.resume(pos)
+}
+
+// resume reads the lines after pos. Call with mu held.
+func (f *File) resume(pos Position) ([]string, Position, error) {
+	fh, err := openLog(f.Path)
+	if err != nil {
+		return nil, pos, err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, pos, err
+	}
+
+	var lines []string
+	next := Position{Size: fi.Size()}
+	next.Device, next.Inode = fileID(fh, fi)
+	switch {
+	case pos.Line == 0 && pos.Offset == 0:
+	case pos.rotated(next):
+		rotated, name, err := f.rotatedLines(pos)
+		if err == errRotatedMatched {
+			log.Printf("%s was rotated to %s, reading the new file from the start\n", f.Path, name)
+		} else if err != nil {
+			log.Printf("%s was rotated, reading the new file from the start, %s\n", f.Path, err.Error())
+		} else {
+			log.Printf("%s was rotated, reading the rest of %s and the new file from the start\n", f.Path, name)
+		}
+		lines, pos = rotated, Position{}
+	case pos.Offset > 0 && (fi.Size() < pos.Size || fi.Size() < pos.Offset || !lineStart(fh, pos.Offset)):
+		log.Printf("%s was truncated, reading it from the start\n", f.Path)
+		pos = Position{}
+	case pos.Offset > 0:
+		if _, err := fh.Seek(pos.Offset, io.SeekStart); err != nil {
+			return nil, pos, err
+		}
+		next.Line, next.Offset = pos.Line, pos.Offset
+	}
+
+	lines, err = readLines(bufio.NewReader(fh), pos.Line, &next, lines, false)
+	f.last = &next
+
+	return lines, next, err
+}
+
+// readLines appends the lines of r after the first skip to lines, counting all of them in next. A last line without
+// a newline is read with partial only, e.g. that of a rotated file no longer written.
+func readLines(r *bufio.Reader, skip int, next *Position, lines []string, partial bool) ([]string, error) {
+	for {
+		s, err := r.ReadString('\n')
+		if err == io.EOF && (!partial || s == "") {
+			return lines, nil
+		}
+		if err != nil && err != io.EOF {
+			return lines, err
+		}
+		next.Offset += int64(len(s))
+		next.Line++
+		// The lines before skip have been scanned.
+		if next.Line > skip {
+			lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r"))
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+	}
+}
+
+// rotatedLines returns the lines after the position of a file rotated out of the path, and its name. The file is
+// looked for in the directory, among those whose names start like the path's without its extension, by its device
+// and inode; a compressed one is not found.
+func (f *File) rotatedLines(pos Position) ([]string, string, error) {
+	dir, base := filepath.Split(f.Path)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, "", err
+	}
+	for _, e := range entries {
+		if e.Name() == base || !strings.HasPrefix(e.Name(), stem) || !e.Type().IsRegular() {
+			continue
+		}
+		fh, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		fi, err := fh.Stat()
+		if err != nil {
+			fh.Close()
+			continue
+		}
+		if dev, ino := fileID(fh, fi); dev != pos.Device || ino != pos.Inode {
+			fh.Close()
+			continue
+		}
+		defer fh.Close()
+
+		if f.Pattern != "" {
+			if ok, _ := filepath.Match(f.Pattern, filepath.Join(dir, e.Name())); ok {
+				return nil, e.Name(), errRotatedMatched
+			}
+		}
+		if fi.Size() < pos.Offset || !lineStart(fh, pos.Offset) {
+			return nil, e.Name(), fmt.Errorf("%s was truncated", e.Name())
+		}
+		if _, err := fh.Seek(pos.Offset, io.SeekStart); err != nil {
+			return nil, e.Name(), err
+		}
+		end := Position{Line: pos.Line, Offset: pos.Offset}
+		lines, err := readLines(bufio.NewReader(fh), pos.Line, &end, nil, true)
+		return lines, e.Name(), err
+	}
+
+	return nil, "", errors.New("the rotated file was not found")
+}
+
+// rotated reports whether the file of the position is another than the file read next, both being known.
+func (pos Position) rotated(next Position) bool {
+	known := func(p Position) bool { return p.Device != 0 || p.Inode != 0 }
+	return known(pos) && known(next) && (pos.Device != next.Device || pos.Inode != next.Inode)
+}
+
+// lineStart reports whether a line of the file starts at offset, the byte before it being a newline.
+func lineStart(f *os.File, offset int64) bool {
+	b := make([]byte, 1)
+	_, err := f.ReadAt(b, offset-1)
+	return err == nil && b[0] == '\n'
+}
+
+// Identify returns the device and inode of a file, 0 if they are not known.
+func Identify(path string) (uint64, uint64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	dev, ino := fileID(fh, fi)
+
+	return dev, ino, nil
+}
+
+// ReadFile reads a log file, opened read-only. A permission error names the file and the user osprey runs as,
+// since after dropping privileges it is usually a missing group membership.
+func ReadFile(path string) ([]byte, error) {
+	f, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
+// openLog opens a log file read-only, with the errors of ReadFile.
+func openLog(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, permissionError(path)
+		}
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w, %w", ErrLogNotFound, err)
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Pattern returns the glob pattern of the log files at a location and whether it may match several: the location
+// if it is a pattern, the files of filePattern, DefaultFilePattern if empty, in it if it is a directory, and the
+// location itself otherwise.
+func Pattern(location, filePattern string) (string, bool) {
+	if IsPattern(location) {
+		return location, true
+	}
+	if IsDir(location) {
+		if filePattern == "" {
+			filePattern = DefaultFilePattern
+		}
+		return filepath.Join(location, filePattern), true
+	}
+
+	return location, false
+}
+
+// IsDir reports whether a log file location is a directory.
+func IsDir(location string) bool {
+	fi, err := os.Stat(location)
+	return err == nil && fi.IsDir()
+}
+
+// IsPattern reports whether a log file location is a glob pattern, e.g. /var/log/app/*.log, which may match several
+// files.
+func IsPattern(location string) bool {
+	return strings.ContainsAny(location, "*?[")
+}
+
+// Glob returns the log files a pattern matches, sorted, leaving out directories.
+func Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("log file pattern %q is invalid, %s", pattern, err.Error())
+	}
+	var paths []string
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && !fi.IsDir() {
+			paths = append(paths, m)
+		}
+	}
+
+	return paths, nil
+}
+
+// CheckAccess returns an error if the log file exists but cannot be read, or one of the files a pattern matches.
+func CheckAccess(path string) error {
+	if IsPattern(path) {
+		paths, err := Glob(path)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if err := CheckAccess(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		if os.IsPermission(err) {
+			return permissionError(path)
+		}
+		return nil
+	}
+
+	return f.Close()
+}
+
+// permissionError explains a log file osprey is not allowed to read.
+func permissionError(path string) error {
+	return fmt.Errorf("%w, no permission to read %s as uid %d gid %d, grant read access to "+
+		"the file (e.g. through its group) or change run_as", ErrLogUnreadable, path, os.Getuid(), os.Getgid())
+}
@@ -0,0 +1,175 @@
+package source
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// appendTo appends text to a file, creating it if needed.
+func appendTo(t *testing.T, path, text string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// Each step changes the file and resumes from the position the step before returned.
+	tests := []struct {
+		name   string
+		change func()
+		want   []string
+	}{
+		{"first read", func() { appendTo(t, path, "a\nb\n") }, []string{"a", "b"}},
+		{"nothing new", func() {}, nil},
+		{"appended", func() { appendTo(t, path, "c\n") }, []string{"c"}},
+		{"partial line is left", func() { appendTo(t, path, "d\npart") }, []string{"d"}},
+		{"partial line completed", func() { appendTo(t, path, "ial\n") }, []string{"partial"}},
+		{"crlf", func() { appendTo(t, path, "e\r\n") }, []string{"e"}},
+		{"rotated", func() {
+			appendTo(t, path, "before rotation\n")
+			if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+				t.Fatal(err)
+			}
+			appendTo(t, path, "f\nlonger\n")
+		}, []string{"before rotation", "f", "longer"}},
+		{"truncated", func() {
+			if err := os.WriteFile(path, []byte("g\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, []string{"g"}},
+	}
+
+	f := &File{Path: path}
+	var pos Position
+	for _, tt := range tests {
+		tt.change()
+		lines, next, err := f.Resume(pos)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(lines, tt.want) {
+			t.Fatalf("%s: got %q, want %q", tt.name, lines, tt.want)
+		}
+		// A new File, e.g. after a restart, resumes from the position alone.
+		f, pos = &File{Path: path}, next
+	}
+}
+
+func TestFileResumeRotatedMatched(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "a\n")
+	f := &File{Path: path, Pattern: filepath.Join(dir, "app*.log")}
+	_, pos, err := f.Resume(Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rotated file is matched by the pattern and read on its own, so its rest is not read twice.
+	appendTo(t, path, "before rotation\n")
+	if err := os.Rename(path, filepath.Join(dir, "app-20240101.log")); err != nil {
+		t.Fatal(err)
+	}
+	appendTo(t, path, "b\n")
+	lines, _, err := f.Resume(pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+}
+
+func TestFileRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendTo(t, path, "a\nb\nc\n")
+
+	tests := []struct {
+		checkpoint int
+		want       []string
+		next       int
+	}{
+		{0, []string{"a", "b", "c"}, 3},
+		{1, []string{"b", "c"}, 3},
+		{3, nil, 3},
+		{5, nil, 3},
+	}
+	for _, tt := range tests {
+		lines, next, err := (&File{Path: path}).Read(tt.checkpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lines, tt.want) || next != tt.next {
+			t.Errorf("checkpoint %d: got %q, %d, want %q, %d", tt.checkpoint, lines, next, tt.want, tt.next)
+		}
+	}
+}
+
+func TestFileMissing(t *testing.T) {
+	_, _, err := (&File{Path: filepath.Join(t.TempDir(), "app.log")}).Read(0)
+	if !errors.Is(err, ErrLogNotFound) {
+		t.Fatalf("got %v, want ErrLogNotFound", err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	appendTo(t, file, "a\n")
+
+	tests := []struct {
+		name        string
+		location    string
+		filePattern string
+		want        string
+		several     bool
+	}{
+		{"file", file, "", file, false},
+		{"missing file", filepath.Join(dir, "gone.log"), "", filepath.Join(dir, "gone.log"), false},
+		{"glob", filepath.Join(dir, "*.log"), "*.txt", filepath.Join(dir, "*.log"), true},
+		{"directory", dir, "", filepath.Join(dir, DefaultFilePattern), true},
+		{"directory with file pattern", dir, "*.txt", filepath.Join(dir, "*.txt"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, several := Pattern(tt.location, tt.filePattern)
+			if got != tt.want || several != tt.several {
+				t.Fatalf("got %s, %v, want %s, %v", got, several, tt.want, tt.several)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.log", "a.log", "c.txt"} {
+		appendTo(t, filepath.Join(dir, name), "a\n")
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, err := Glob(filepath.Join(dir, "[")); err == nil {
+		t.Fatal("got no error for an invalid pattern")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package source
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode of an open file, 0 if they are not known.
+func fileID(f *os.File, fi os.FileInfo) (uint64, uint64) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(st.Dev), uint64(st.Ino)
+}
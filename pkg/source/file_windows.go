@@ -0,0 +1,18 @@
+//go:build windows
+
+package source
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// fileID returns the volume serial number and file index of an open file, 0 if they are not known.
+func fileID(f *os.File, fi os.FileInfo) (uint64, uint64) {
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &info); err != nil {
+		return 0, 0
+	}
+
+	return uint64(info.VolumeSerialNumber), uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+}
@@ -0,0 +1,96 @@
+package source
+
+import (
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultSource is the source used by services without a source key.
+const DefaultSource = "file"
+
+// Source is a stream of log lines. The checkpoint is where a read resumes, it is saved in the service's .igu file
+// between reads.
+type Source interface {
+	// Read returns the lines after checkpoint and the checkpoint to resume from next time.
+	Read(checkpoint int) (lines []string, next int, err error)
+}
+
+// Position is where a read of a log file resumes, saved in the service's .igu file by sources which are Resumers.
+type Position struct {
+	// Line is the number of lines read so far, the checkpoint of Read.
+	Line int
+
+	// Offset is the byte offset of the next line, 0 after lines were read if it is not known, e.g. in the state of an
+	// older osprey.
+	Offset int64
+
+	// Device and Inode identify the file read, 0 if they are not known.
+	Device, Inode uint64
+
+	// Size is the size of the file when it was read, so a file truncated since is noticed.
+	Size int64
+}
+
+// Resumer is implemented by sources which resume a read at a Position rather than a checkpoint, so they can seek
+// directly to the unread lines.
+type Resumer interface {
+	Source
+
+	// Resume returns the lines after pos and the position to resume from next time.
+	Resume(pos Position) (lines []string, next Position, err error)
+}
+
+// Factory creates the source of a service.
+type Factory func(svc config.Service) (Source, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+func init() {
+	Register(DefaultSource, func(svc config.Service) (Source, error) {
+		return &File{Path: svc.Location}, nil
+	})
+}
+
+// Register makes a source available under the given name. It panics if the name is taken, like database/sql
+// drivers, since two packages registering the same source is a programming error.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("source: source %s is registered twice", name))
+	}
+	factories[name] = f
+}
+
+// New creates the named source for a service.
+func New(name string, svc config.Service) (Source, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q of %s, registered are %s", name, svc.Name, strings.Join(Names(), ", "))
+	}
+
+	return f(svc)
+}
+
+// Names returns the registered source names, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxWriterLines bounds the lines a Writer buffers between reads; the oldest are dropped beyond it.
+const maxWriterLines = 10000
+
+// Writer is a source fed in-process through io.Writer, so a program embedding osprey can scan its own log output:
+//
+//	w := source.NewWriter()
+//	logger := log.New(io.MultiWriter(os.Stderr, w), "", log.LstdFlags)
+//	source.Register("self", func(config.Service) (source.Source, error) { return w, nil })
+//
+// The checkpoint is the number of lines written since the Writer was created. Lines are kept until a read
+// resumes past them.
+type Writer struct {
+	mu sync.Mutex
+
+	// lines are the complete lines not yet consumed.
+	lines []string
+
+	// base is the number of lines consumed or dropped before lines.
+	base int
+
+	// partial is the last line written, until its newline is.
+	partial strings.Builder
+}
+
+// NewWriter creates an empty writer source.
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Write implements io.Writer. A line is readable once its newline is written.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := string(p)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			w.partial.WriteString(s)
+			break
+		}
+		w.partial.WriteString(s[:i])
+		w.lines = append(w.lines, strings.TrimSuffix(w.partial.String(), "\r"))
+		w.partial.Reset()
+		s = s[i+1:]
+	}
+
+	if over := len(w.lines) - maxWriterLines; over > 0 {
+		w.lines = append([]string(nil), w.lines[over:]...)
+		w.base += over
+	}
+
+	return len(p), nil
+}
+
+// Read implements Source. A checkpoint beyond the lines written, e.g. saved by an earlier process, reads from the
+// first buffered line.
+func (w *Writer) Read(checkpoint int) ([]string, int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if checkpoint < w.base || checkpoint > w.base+len(w.lines) {
+		checkpoint = w.base
+	}
+
+	// The lines before checkpoint have been scanned.
+	w.lines = w.lines[checkpoint-w.base:]
+	w.base = checkpoint
+
+	lines := append([]string(nil), w.lines...)
+
+	return lines, checkpoint + len(lines), nil
+}
@@ -0,0 +1,325 @@
+package state
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// SecretKey is the name of the secret state files are signed with.
+	SecretKey = "state_hmac_key"
+
+	sigPrefix = "sig:"
+
+	// maxAnchor bounds the anchor far beyond any log file, so a garbled state file is reported rather than trusted.
+	maxAnchor = 1<<31 - 1
+)
+
+// ErrStateCorrupt is returned, wrapped, when a state file cannot be trusted: its anchor is unreadable or its
+// signature is invalid.
+var ErrStateCorrupt = errors.New("state file is corrupt")
+
+// Anchor is the .igu file holding the position of the last visited line of a service's log file: its line
+// number, and for sources which are Resumers, e.g. the file source, its byte offset and the identity of the file:
+//
+//	last:1042 offset:88113 file:2049:1311768 size:88140
+//
+// A signed file also numbers its writes, so an older copy of it, validly signed too, is refused:
+//
+//	last:1042 offset:88113 file:2049:1311768 size:88140 seq:318
+//	sig:4f1c...
+type Anchor struct {
+	// Path is the .igu file path.
+	Path string
+
+	// Service is the service name.
+	Service string
+
+	// Key signs the file if state signing is enabled, nil otherwise.
+	Key []byte
+
+	// mu serializes reads and writes of the file within the process; Lock guards it across processes.
+	mu sync.Mutex
+
+	// seq is the highest write number of the signed file read or written so far.
+	seq int64
+}
+
+// NewAnchor returns the anchor file of a service in the given state directory.
+func NewAnchor(dir, service string, key []byte) *Anchor {
+	return &Anchor{
+		Path:    fmt.Sprintf("%s/%s.igu", dir, service),
+		Service: service,
+		Key:     key,
+	}
+}
+
+// NewFileAnchor returns the anchor file of one of the log files of a service whose location is a glob pattern, named
+// after a hash of the file's path, <service>@<hash>.igu. The path is signed with the service name.
+func NewFileAnchor(dir, service, file string, key []byte) *Anchor {
+	sum := sha256.Sum256([]byte(file))
+	return &Anchor{
+		Path:    fmt.Sprintf("%s/%s@%s.igu", dir, service, hex.EncodeToString(sum[:6])),
+		Service: service + ":" + file,
+		Key:     key,
+	}
+}
+
+// Load reads the line number of the anchor saved earlier, creating the file if it does not exist yet and state
+// signing is not enabled.
+func (a *Anchor) Load() (int, error) {
+	pos, err := a.LoadPosition()
+	return pos.Line, err
+}
+
+// LoadPosition reads the position of the anchor saved earlier, like Load. The offset of an anchor saved by an older
+// osprey, a line number alone, is 0. With state signing, a missing file is refused rather than started over, as
+// deleting it would rewind the service; Init creates it.
+func (a *Anchor) LoadPosition() (source.Position, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Check out if a file exists, if not, create one.
+	if _, err := os.Stat(a.Path); os.IsNotExist(err) {
+		if a.Key != nil {
+			return source.Position{}, fmt.Errorf("%w, %s of %s does not exist and is refused as state files are "+
+				"signed, run osprey state reset to start the service over", ErrStateCorrupt, a.Path, a.Service)
+		}
+		if err := a.write(source.Position{}); err != nil {
+			return source.Position{}, err
+		}
+		return source.Position{}, nil
+	}
+
+	// Read out the anchor save earlier.
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return source.Position{}, err
+	}
+	defer f.Close()
+
+	// Create a file scanner for reading
+	fScanner := bufio.NewScanner(bufio.NewReader(f))
+
+	// The anchor line is followed by its signature line if state files are signed.
+	var anchorLine, sigLine string
+	for fScanner.Scan() {
+		if anchorLine == "" {
+			anchorLine = fScanner.Text()
+			continue
+		}
+		sigLine = fScanner.Text()
+		break
+	}
+
+	if err := fScanner.Err(); err != nil {
+		return source.Position{}, err
+	}
+
+	if err := a.verify(anchorLine, sigLine); err != nil {
+		return source.Position{}, err
+	}
+
+	// If the line is empty, we assume log file for a given service has never been read by Iguana before.
+	if anchorLine == "" {
+		return source.Position{}, nil
+	}
+
+	anchor, seq, err := extract(anchorLine)
+	if err != nil {
+		return source.Position{}, fmt.Errorf("%w, %s of %s has an unreadable anchor %q, %s", ErrStateCorrupt, a.Path, a.Service,
+			anchorLine, err.Error())
+	}
+	if a.Key != nil {
+		if seq < a.seq {
+			return source.Position{}, fmt.Errorf("%w, %s of %s is an older copy, write %d of %d, and is refused",
+				ErrStateCorrupt, a.Path, a.Service, seq, a.seq)
+		}
+		a.seq = seq
+	}
+
+	return anchor, nil
+}
+
+// Init creates the file of a new anchor at the start of the log, reporting whether it did not exist. With state
+// signing it is the only way to start a service over, as LoadPosition refuses a missing file.
+func (a *Anchor) Init() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return true, a.write(source.Position{})
+}
+
+// Reset moves the anchor back to the start of the log, creating its file if needed, so the service is scanned
+// over.
+func (a *Anchor) Reset() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.write(source.Position{})
+}
+
+// Save updates anchor info in the file, the line number alone.
+func (a *Anchor) Save(newAnchor int) error {
+	return a.SavePosition(source.Position{Line: newAnchor})
+}
+
+// SavePosition updates the anchor in the file to a position, with its offset and file identity if they are known.
+func (a *Anchor) SavePosition(pos source.Position) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.write(pos)
+}
+
+// write writes a position to the file, signed if state signing is enabled; the caller holds mu. The write of a
+// signed file is numbered after the last one, of this or another osprey process.
+func (a *Anchor) write(pos source.Position) error {
+	if a.Key != nil {
+		if dat, err := ioutil.ReadFile(a.Path); err == nil {
+			line, _, _ := strings.Cut(string(dat), "\n")
+			if _, seq, err := extract(line); err == nil && seq > a.seq {
+				a.seq = seq
+			}
+		}
+	}
+
+	f, err := os.OpenFile(a.Path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	anchorLine := fmt.Sprintf("last:%d", pos.Line)
+	if pos.Offset > 0 {
+		anchorLine += fmt.Sprintf(" offset:%d", pos.Offset)
+	}
+	if pos.Device != 0 || pos.Inode != 0 {
+		anchorLine += fmt.Sprintf(" file:%d:%d", pos.Device, pos.Inode)
+	}
+	if pos.Size > 0 {
+		anchorLine += fmt.Sprintf(" size:%d", pos.Size)
+	}
+	if a.Key != nil {
+		a.seq++
+		anchorLine += fmt.Sprintf(" seq:%d", a.seq)
+		anchorLine += "\n" + Signature(a.Key, a.Service, anchorLine)
+	}
+	_, err = f.WriteString(anchorLine + "\n")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Sign signs an existing unsigned anchor file, so state signing can be enabled without scanning the log again from
+// the start. It reports whether the file had to be signed.
+func (a *Anchor) Sign() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dat, err := ioutil.ReadFile(a.Path)
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.SplitN(strings.TrimRight(string(dat), "\n"), "\n", 2)
+	if len(lines) > 1 && strings.HasPrefix(lines[1], sigPrefix) {
+		return false, nil
+	}
+
+	out := fmt.Sprintf("%s\n%s\n", lines[0], Signature(a.Key, a.Service, lines[0]))
+	if err := ioutil.WriteFile(a.Path, []byte(out), 0666); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Signature returns the signature line of an anchor line. The service name is signed as well, so the state file of
+// one service cannot be copied over another's.
+func Signature(key []byte, serviceName, anchorLine string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(serviceName + "\n" + anchorLine))
+
+	return sigPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature line of an anchor line, refusing state that was not written by osprey, e.g. an
+// anchor rewound to re-open a flood of issues. Nothing is checked without a signing key.
+func (a *Anchor) verify(anchorLine, sigLine string) error {
+	if a.Key == nil {
+		return nil
+	}
+
+	want := Signature(a.Key, a.Service, anchorLine)
+	if !hmac.Equal([]byte(sigLine), []byte(want)) {
+		return fmt.Errorf("%w, %s of %s has an invalid signature and is refused, it may have been tampered with",
+			ErrStateCorrupt, a.Path, a.Service)
+	}
+
+	return nil
+}
+
+// extract extracts the position of an anchor line, last:<line number>, optionally followed by offset:<byte offset>,
+// file:<device>:<inode>, size:<file size> and the write number of a signed file, seq:<number>.
+func extract(line string) (pos source.Position, seq int64, err error) {
+	tks := strings.Fields(line)
+	if len(tks) == 0 || !strings.HasPrefix(tks[0], "last:") {
+		return pos, 0, errors.New("want last:<line number>")
+	}
+
+	anchor, err := strconv.Atoi(strings.TrimPrefix(tks[0], "last:"))
+	if err != nil {
+		return pos, 0, fmt.Errorf("%q is not a line number", strings.TrimPrefix(tks[0], "last:"))
+	}
+	if anchor < 0 || anchor > maxAnchor {
+		return pos, 0, fmt.Errorf("line number %d is out of range", anchor)
+	}
+	pos.Line = anchor
+
+	for _, tk := range tks[1:] {
+		key, val, _ := strings.Cut(tk, ":")
+		switch key {
+		case "offset":
+			if pos.Offset, err = strconv.ParseInt(val, 10, 64); err != nil || pos.Offset < 0 {
+				return pos, 0, fmt.Errorf("%q is not a byte offset", val)
+			}
+		case "file":
+			dev, ino, _ := strings.Cut(val, ":")
+			if pos.Device, err = strconv.ParseUint(dev, 10, 64); err != nil {
+				return pos, 0, fmt.Errorf("%q is not a file identity", val)
+			}
+			if pos.Inode, err = strconv.ParseUint(ino, 10, 64); err != nil {
+				return pos, 0, fmt.Errorf("%q is not a file identity", val)
+			}
+		case "size":
+			if pos.Size, err = strconv.ParseInt(val, 10, 64); err != nil || pos.Size < 0 {
+				return pos, 0, fmt.Errorf("%q is not a file size", val)
+			}
+		case "seq":
+			if seq, err = strconv.ParseInt(val, 10, 64); err != nil || seq < 0 {
+				return pos, 0, fmt.Errorf("%q is not a write number", val)
+			}
+		default:
+			return pos, 0, fmt.Errorf("unknown anchor field %q", tk)
+		}
+	}
+
+	return pos, seq, nil
+}
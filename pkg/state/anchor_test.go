@@ -0,0 +1,265 @@
+package state
+
+import (
+	"errors"
+	"github.com/NBCFB/Iguana2/pkg/source"
+	"os"
+	"testing"
+)
+
+func TestLoadPositionCreatesAnchor(t *testing.T) {
+	a := NewAnchor(t.TempDir(), "apple", nil)
+	// The first load creates the file, the second must trust it although nothing was saved in between.
+	for i := 0; i < 2; i++ {
+		pos, err := a.LoadPosition()
+		if err != nil {
+			t.Fatalf("load %d: %v", i+1, err)
+		}
+		if pos != (source.Position{}) {
+			t.Fatalf("load %d: got %+v, want the start", i+1, pos)
+		}
+	}
+}
+
+func TestLoadPositionRefusesMissingSignedAnchor(t *testing.T) {
+	a := NewAnchor(t.TempDir(), "apple", []byte("secret"))
+	if _, err := a.LoadPosition(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want ErrStateCorrupt", err)
+	}
+	if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+		t.Fatalf("got %v, want the anchor left missing", err)
+	}
+
+	for i, want := range []bool{true, false} {
+		if created, err := a.Init(); err != nil || created != want {
+			t.Fatalf("init %d: got %v, %v, want %v", i+1, created, err, want)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if pos, err := a.LoadPosition(); err != nil || pos != (source.Position{}) {
+			t.Fatalf("load %d: got %+v, %v, want the start", i+1, pos, err)
+		}
+	}
+
+	// Deleting the file of a running osprey does not rewind it either.
+	if err := a.SavePosition(source.Position{Line: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(a.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.LoadPosition(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want the deleted anchor refused", err)
+	}
+}
+
+func TestLoadPositionRefusesOlderCopy(t *testing.T) {
+	a := NewAnchor(t.TempDir(), "apple", []byte("secret"))
+	if err := a.SavePosition(source.Position{Line: 10}); err != nil {
+		t.Fatal(err)
+	}
+	older, err := os.ReadFile(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SavePosition(source.Position{Line: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(a.Path, older, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.LoadPosition(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want the older copy refused", err)
+	}
+}
+
+func TestSignedAnchorOfTwoProcesses(t *testing.T) {
+	dir, key := t.TempDir(), []byte("secret")
+	a, b := NewAnchor(dir, "apple", key), NewAnchor(dir, "apple", key)
+	// Each write is numbered after the other's, so neither takes the other's for an older copy.
+	for i, w := range []*Anchor{a, b, a, b} {
+		if err := w.SavePosition(source.Position{Line: i + 1}); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range []*Anchor{a, b} {
+			if pos, err := r.LoadPosition(); err != nil || pos.Line != i+1 {
+				t.Fatalf("write %d: got %+v, %v, want line %d", i+1, pos, err, i+1)
+			}
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   []byte
+		saved bool
+	}{
+		{"missing", []byte("secret"), false},
+		{"signed", []byte("secret"), true},
+		{"unsigned", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := NewAnchor(dir, "apple", tt.key)
+			if tt.saved {
+				if err := a.SavePosition(source.Position{Line: 10, Offset: 90}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// A running osprey holding the file takes the reset of another process.
+			running := NewAnchor(dir, "apple", tt.key)
+			if tt.saved {
+				if _, err := running.LoadPosition(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := NewAnchor(dir, "apple", tt.key).Reset(); err != nil {
+				t.Fatal(err)
+			}
+			if pos, err := running.LoadPosition(); err != nil || pos != (source.Position{}) {
+				t.Fatalf("got %+v, %v, want the start", pos, err)
+			}
+		})
+	}
+}
+
+func TestLoadPositionRefusesTamperedAnchor(t *testing.T) {
+	a := NewAnchor(t.TempDir(), "apple", []byte("secret"))
+	if err := a.SavePosition(source.Position{Line: 10, Offset: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(a.Path, []byte("last:0\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.LoadPosition(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want ErrStateCorrupt", err)
+	}
+}
+
+func TestSavePositionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+		pos  source.Position
+	}{
+		{"line only", nil, source.Position{Line: 42}},
+		{"offset", nil, source.Position{Line: 42, Offset: 1024}},
+		{"file identity", nil, source.Position{Line: 42, Offset: 1024, Device: 2049, Inode: 1311768, Size: 2048}},
+		{"signed", []byte("secret"), source.Position{Line: 7, Offset: 90, Device: 1, Inode: 2, Size: 90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnchor(t.TempDir(), "apple", tt.key)
+			if err := a.SavePosition(tt.pos); err != nil {
+				t.Fatal(err)
+			}
+			got, err := a.LoadPosition()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.pos {
+				t.Fatalf("got %+v, want %+v", got, tt.pos)
+			}
+			if line, err := a.Load(); err != nil || line != tt.pos.Line {
+				t.Fatalf("Load got %d, %v, want %d", line, err, tt.pos.Line)
+			}
+		})
+	}
+}
+
+func TestLoadPositionOfFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    source.Position
+		corrupt bool
+	}{
+		{"older osprey", "last:12\n", source.Position{Line: 12}, false},
+		{"empty", "", source.Position{}, false},
+		{"all fields", "last:3 offset:40 file:5:6 size:41\n", source.Position{Line: 3, Offset: 40, Device: 5,
+			Inode: 6, Size: 41}, false},
+		{"write number", "last:3 seq:7\n", source.Position{Line: 3}, false},
+		{"not a write number", "last:3 seq:-7\n", source.Position{}, true},
+		{"not a line number", "last:x\n", source.Position{}, true},
+		{"negative line", "last:-1\n", source.Position{}, true},
+		{"out of range", "last:99999999999\n", source.Position{}, true},
+		{"negative offset", "last:1 offset:-5\n", source.Position{}, true},
+		{"unknown field", "last:1 color:red\n", source.Position{}, true},
+		{"garbled", "garbage\n", source.Position{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnchor(t.TempDir(), "apple", nil)
+			if err := os.WriteFile(a.Path, []byte(tt.content), 0666); err != nil {
+				t.Fatal(err)
+			}
+			got, err := a.LoadPosition()
+			if tt.corrupt {
+				if !errors.Is(err, ErrStateCorrupt) {
+					t.Fatalf("got %v, want ErrStateCorrupt", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedAnchorOfAnotherService(t *testing.T) {
+	dir, key := t.TempDir(), []byte("secret")
+	apple, orange := NewAnchor(dir, "apple", key), NewAnchor(dir, "orange", key)
+	if err := apple.Save(10); err != nil {
+		t.Fatal(err)
+	}
+	dat, err := os.ReadFile(apple.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orange.Path, dat, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orange.Load(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want the copied anchor to be refused", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewAnchor(dir, "apple", nil).SavePosition(source.Position{Line: 5, Offset: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAnchor(dir, "apple", []byte("secret"))
+	if _, err := a.Load(); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("got %v, want the unsigned anchor to be refused once signing is enabled", err)
+	}
+	for i, want := range []bool{true, false} {
+		signed, err := a.Sign()
+		if err != nil || signed != want {
+			t.Fatalf("sign %d: got %v, %v, want %v", i+1, signed, err, want)
+		}
+	}
+	if pos, err := a.LoadPosition(); err != nil || pos != (source.Position{Line: 5, Offset: 60}) {
+		t.Fatalf("got %+v, %v, want the position kept", pos, err)
+	}
+}
+
+func TestNewFileAnchor(t *testing.T) {
+	dir := t.TempDir()
+	a := NewFileAnchor(dir, "apple", "/var/log/apple/a.log", nil)
+	b := NewFileAnchor(dir, "apple", "/var/log/apple/b.log", nil)
+	if a.Path == b.Path {
+		t.Fatalf("the anchors of two files share %s", a.Path)
+	}
+	if again := NewFileAnchor(dir, "apple", "/var/log/apple/a.log", nil); again.Path != a.Path {
+		t.Fatalf("got %s, want the anchor of a file to be stable, %s", again.Path, a.Path)
+	}
+}
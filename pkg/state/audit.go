@@ -0,0 +1,157 @@
+// Package state keeps osprey's state on disk: the anchor of every service and the audit log of created issues.
+package state
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditFileName = "audit.log"
+)
+
+// AuditRecord is a single entry of the audit log, one per created issue.
+type AuditRecord struct {
+	// Time is when the issue was created.
+	Time time.Time `json:"time"`
+
+	// Service is the service whose log produced the issue.
+	Service string `json:"service"`
+
+	// Repo is the target repository in owner/name form.
+	Repo string `json:"repo"`
+
+	// Number is the issue number returned by github.
+	Number int `json:"number"`
+
+	// URL is the issue html url returned by github.
+	URL string `json:"url"`
+
+	// Fingerprint is the fingerprint of the error the issue is about, as the issue carries it.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// AuditLog is an append-only JSON lines file recording every created issue. An audit log is shared by all scanners.
+type AuditLog struct {
+	// path is the audit log file path.
+	path string
+
+	// mu serializes appends from concurrent workers.
+	mu sync.Mutex
+}
+
+// AuditFilter narrows down the records returned by Query.
+type AuditFilter struct {
+	// Service matches the record service if not empty.
+	Service string
+
+	// Repo matches the record repo if not empty.
+	Repo string
+
+	// Fingerprint matches the record fingerprint if not empty.
+	Fingerprint string
+
+	// Since drops records older than this time if not zero.
+	Since time.Time
+}
+
+// NewAuditLog returns an audit log stored at the given path.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Append writes a record to the end of the audit log.
+func (a *AuditLog) Append(rec AuditRecord) error {
+	dat, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(dat, '\n'))
+	return err
+}
+
+// Query reads all records matching the given filter.
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditRecord, error) {
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recs []AuditRecord
+	fScanner := bufio.NewScanner(f)
+	for n := 1; fScanner.Scan(); n++ {
+		line := strings.TrimSpace(fScanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("audit log %s line %d: %s", a.path, n, err.Error())
+		}
+		if filter.match(rec) {
+			recs = append(recs, rec)
+		}
+	}
+
+	if err := fScanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
+// match reports whether the record passes the filter.
+func (f AuditFilter) match(rec AuditRecord) bool {
+	if f.Service != "" && f.Service != rec.Service {
+		return false
+	}
+	if f.Repo != "" && f.Repo != rec.Repo {
+		return false
+	}
+	if f.Fingerprint != "" && f.Fingerprint != rec.Fingerprint {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+
+	return true
+}
+
+// Fingerprint returns a short stable hash identifying a log line.
+func Fingerprint(line string) string {
+	sum := sha1.Sum([]byte(strings.TrimSpace(line)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// AuditFilePath returns the configured audit log path, defaulting to a file next to the .igu files.
+func AuditFilePath() string {
+	if p := viper.GetString("state.audit_file"); p != "" {
+		return p
+	}
+
+	return fmt.Sprintf("%s/%s", viper.GetString("state.dir"), defaultAuditFileName)
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLogQuery(t *testing.T) {
+	l := NewAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	now := time.Now().UTC().Truncate(time.Second)
+	recs := []AuditRecord{
+		{Time: now.Add(-2 * time.Hour), Service: "apple", Repo: "owner/apple", Number: 1, Fingerprint: "f00d"},
+		{Time: now.Add(-time.Hour), Service: "orange", Repo: "owner/orange", Number: 2, Fingerprint: "beef"},
+		{Time: now, Service: "apple", Repo: "owner/apple", Number: 3, Fingerprint: "f00d"},
+	}
+	for _, rec := range recs {
+		if err := l.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int
+	}{
+		{"all", AuditFilter{}, []int{1, 2, 3}},
+		{"service", AuditFilter{Service: "apple"}, []int{1, 3}},
+		{"repo", AuditFilter{Repo: "owner/orange"}, []int{2}},
+		{"fingerprint", AuditFilter{Fingerprint: "f00d"}, []int{1, 3}},
+		{"since", AuditFilter{Since: now.Add(-90 * time.Minute)}, []int{2, 3}},
+		{"none", AuditFilter{Service: "pear"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.want))
+			}
+			for i, rec := range got {
+				if rec.Number != tt.want[i] {
+					t.Fatalf("got record #%d at %d, want #%d", rec.Number, i, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAuditLogQueryMissing(t *testing.T) {
+	recs, err := NewAuditLog(filepath.Join(t.TempDir(), "audit.log")).Query(AuditFilter{})
+	if err != nil || recs != nil {
+		t.Fatalf("got %v, %v, want no records", recs, err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint("error: db timeout") != Fingerprint("  error: db timeout\n") {
+		t.Fatal("surrounding space changes the fingerprint")
+	}
+	if Fingerprint("error: db timeout") == Fingerprint("error: disk full") {
+		t.Fatal("two errors share a fingerprint")
+	}
+}
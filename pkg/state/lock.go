@@ -0,0 +1,36 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrStateLocked is returned, wrapped, when another process holds the lock of a state file.
+var ErrStateLocked = errors.New("state file is locked by another process")
+
+// errWouldBlock is returned by lockFile if the lock is held elsewhere.
+var errWouldBlock = errors.New("lock is held")
+
+// Lock takes the cross-process lock of the anchor, an advisory lock on the .igu.lock file next to it, until unlock
+// is called. It fails with ErrStateLocked rather than waiting, so a second osprey process scanning the same service
+// skips the scan instead of interleaving its writes and filing the issues twice.
+func (a *Anchor) Lock() (unlock func(), err error) {
+	f, err := os.OpenFile(a.Path+".lock", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errWouldBlock) {
+			return nil, fmt.Errorf("%w, %s of %s", ErrStateLocked, a.Path, a.Service)
+		}
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
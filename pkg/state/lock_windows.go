@@ -0,0 +1,26 @@
+//go:build windows
+
+package state
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// lockFile takes an exclusive lock on the first byte of f without waiting.
+func lockFile(f *os.File) error {
+	var ol windows.Overlapped
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, &ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errWouldBlock
+	}
+
+	return err
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
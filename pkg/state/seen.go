@@ -0,0 +1,71 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// SeenIssue is the issue of a fingerprinted error and when the error was last seen.
+type SeenIssue struct {
+	// Number is the issue number, 0 until an issue is known.
+	Number int `json:"number"`
+
+	// LastSeen is when the error was last seen.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Seen is the .seen file of a service, holding when the errors of its issues were last seen by fingerprint, so the
+// issues of errors which stopped occurring can be closed.
+type Seen struct {
+	// Path is the .seen file path.
+	Path string
+
+	// mu serializes reads and writes of the file within the process.
+	mu sync.Mutex
+}
+
+// NewSeen returns the .seen file of a service in the given state directory.
+func NewSeen(dir, service string) *Seen {
+	return &Seen{Path: fmt.Sprintf("%s/%s.seen", dir, service)}
+}
+
+// Load reads the issues saved earlier, none if the file does not exist yet.
+func (s *Seen) Load() (map[string]SeenIssue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issues := make(map[string]SeenIssue)
+	dat, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return issues, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(dat, &issues); err != nil {
+		return nil, fmt.Errorf("%w, %s: %s", ErrStateCorrupt, s.Path, err.Error())
+	}
+
+	return issues, nil
+}
+
+// Save replaces the issues in the file. It is written aside and renamed, so a crash leaves the former issues.
+func (s *Seen) Save(issues map[string]SeenIssue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dat, err := json.Marshal(issues)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, dat, 0666); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/NBCFB/Iguana2/pkg/match"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Spool is the .spool file of a service, holding the findings its sink could not take yet, e.g. while github is
+// unreachable or rate limited, so they are delivered after a restart rather than lost.
+type Spool struct {
+	// Path is the .spool file path.
+	Path string
+
+	// mu serializes reads and writes of the file within the process.
+	mu sync.Mutex
+}
+
+// NewSpool returns the .spool file of a service in the given state directory.
+func NewSpool(dir, service string) *Spool {
+	return &Spool{Path: fmt.Sprintf("%s/%s.spool", dir, service)}
+}
+
+// Load reads the findings saved earlier, oldest first, none if the file does not exist.
+func (s *Spool) Load() ([]match.Finding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dat, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var findings []match.Finding
+	if err := json.Unmarshal(dat, &findings); err != nil {
+		return nil, fmt.Errorf("%w, %s: %s", ErrStateCorrupt, s.Path, err.Error())
+	}
+
+	return findings, nil
+}
+
+// Save replaces the findings in the file, removing it if there are none. It is written aside and renamed, so a crash
+// leaves the former findings.
+func (s *Spool) Save(findings []match.Finding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(findings) == 0 {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	dat, err := json.Marshal(findings)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, dat, 0666); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Handler receives the events a subscriber asked for. Handlers run synchronously on the scanner's goroutine, so
+// they must return quickly and hand slow work off to a goroutine of their own.
+type Handler func(Event)
+
+// Bus delivers internal events in-process, so embedders and plugins can react to them, e.g. increment custom
+// metrics, without modifying osprey. A nil bus discards all events.
+type Bus struct {
+	mu sync.RWMutex
+
+	// subs are the subscriptions, in subscription order.
+	subs []subscription
+
+	// next is the id of the next subscription.
+	next int
+}
+
+// subscription is a handler and the event types it receives, all if types is nil.
+type subscription struct {
+	id      int
+	handler Handler
+	types   map[string]bool
+}
+
+// DefaultBus is the bus scanners publish to unless given another one.
+var DefaultBus = NewBus()
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls h for every published event of the given types, or of all types if none is given. It returns a
+// function removing the subscription.
+func (b *Bus) Subscribe(h Handler, types ...string) (unsubscribe func()) {
+	sub := subscription{handler: h}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool)
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	sub.id = b.next
+	b.next++
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for i, s := range b.subs {
+				if s.id == sub.id {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// Publish calls the handlers subscribed to the event's type, in subscription order. A panicking handler is logged
+// and does not stop the others.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	var handlers []Handler
+	for _, sub := range b.subs {
+		if sub.types == nil || sub.types[ev.Type] {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		call(h, ev)
+	}
+}
+
+// call runs a handler, recovering from its panic.
+func call(h Handler, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Unable to handle %s event, %v\n", ev.Type, r)
+		}
+	}()
+
+	h(ev)
+}
+
+// Subscribe subscribes h to events of the default bus, see Bus.Subscribe.
+func Subscribe(h Handler, types ...string) (unsubscribe func()) {
+	return DefaultBus.Subscribe(h, types...)
+}
@@ -0,0 +1,41 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	var all, failed []string
+	unsubscribe := b.Subscribe(func(ev Event) { all = append(all, ev.Type) })
+	b.Subscribe(func(ev Event) { failed = append(failed, ev.Type) }, EventScanFailed, EventDeliveryFailed)
+	// A panicking handler does not stop the others.
+	b.Subscribe(func(ev Event) { panic("boom") })
+
+	b.Publish(Event{Type: EventScanStarted})
+	b.Publish(Event{Type: EventScanFailed})
+	unsubscribe()
+	unsubscribe()
+	b.Publish(Event{Type: EventDeliveryFailed})
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"all types until unsubscribed", all, []string{EventScanStarted, EventScanFailed}},
+		{"given types", failed, []string{EventScanFailed, EventDeliveryFailed}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	// A nil bus discards events.
+	b.Publish(Event{Type: EventScanStarted})
+}
@@ -0,0 +1,147 @@
+// Package telemetry publishes osprey's internal events and metrics to external systems.
+package telemetry
+
+import (
+	"encoding/json"
+	"github.com/spf13/viper"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventScanStarted    = "scan_started"
+	EventScanFinished   = "scan_finished"
+	EventScanFailed     = "scan_failed"
+	EventFindingMatched = "finding_matched"
+	EventIssueCreated   = "issue_created"
+	EventIssueUpdated   = "issue_updated"
+	EventDeliveryFailed = "delivery_failed"
+	EventSecretDetected = "secret_detected"
+)
+
+const (
+	eventWriteTimeout = time.Second
+	eventRedialDelay  = 5 * time.Second
+)
+
+// Event is an internal osprey event, written to the event stream as a JSON line.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Service     string    `json:"service"`
+	Line        string    `json:"line,omitempty"`
+	IssueNumber int       `json:"issue_number,omitempty"`
+	IssueURL    string    `json:"issue_url,omitempty"`
+	Findings    int       `json:"findings,omitempty"`
+	DurationMs  float64   `json:"duration_ms,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// EventStream writes events as JSON lines to a file or a socket. An event stream is shared by all scanners.
+// A nil event stream discards all events.
+type EventStream struct {
+	mu sync.Mutex
+
+	// network and addr locate the socket, network is empty for a file.
+	network string
+	addr    string
+
+	// w is the open file or connection, nil while disconnected.
+	w io.WriteCloser
+
+	// lastDial is when the socket was last dialed, to avoid redialing on every event.
+	lastDial time.Time
+}
+
+// NewEventStream creates an event stream based on config file. It returns nil if no target is configured.
+// The target is a file path, or a socket given as unix:///path/to.sock or tcp://host:port.
+func NewEventStream() (*EventStream, error) {
+	target := viper.GetString("events.target")
+	if target == "" {
+		return nil, nil
+	}
+
+	es := &EventStream{}
+	switch {
+	case strings.HasPrefix(target, "unix://"):
+		es.network, es.addr = "unix", strings.TrimPrefix(target, "unix://")
+	case strings.HasPrefix(target, "tcp://"):
+		es.network, es.addr = "tcp", strings.TrimPrefix(target, "tcp://")
+	default:
+		f, err := os.OpenFile(strings.TrimPrefix(target, "file://"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		es.w = f
+		return es, nil
+	}
+
+	// A missing listener must not stop osprey, the stream reconnects later.
+	if err := es.dial(); err != nil {
+		log.Printf("Unable to connect event stream, %s\n", err.Error())
+	}
+
+	return es, nil
+}
+
+// dial connects the event socket.
+func (es *EventStream) dial() error {
+	es.lastDial = time.Now()
+	conn, err := net.DialTimeout(es.network, es.addr, eventWriteTimeout)
+	if err != nil {
+		return err
+	}
+	es.w = conn
+
+	return nil
+}
+
+// Emit writes an event. Events are dropped rather than blocking scanners when the socket is unavailable.
+func (es *EventStream) Emit(ev Event) {
+	if es == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	dat, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Unable to encode event, %s\n", err.Error())
+		return
+	}
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.w == nil {
+		if time.Since(es.lastDial) < eventRedialDelay {
+			return
+		}
+		if err := es.dial(); err != nil {
+			return
+		}
+	}
+
+	if conn, ok := es.w.(net.Conn); ok {
+		conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	}
+	if _, err := es.w.Write(append(dat, '\n')); err != nil {
+		log.Printf("Unable to write event, %s\n", err.Error())
+		if es.network != "" {
+			es.w.Close()
+			es.w = nil
+		}
+	}
+}
+
+// DurationMs converts a duration to fractional milliseconds.
+func DurationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
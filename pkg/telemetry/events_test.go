@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStreamFile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	viper.Set("events.target", "file://"+path)
+
+	es, err := NewEventStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	es.Emit(Event{Type: EventScanFinished, Service: "apple", Findings: 2})
+	es.Emit(Event{Type: EventScanFailed, Service: "apple", Error: "log not found"})
+
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(dat)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d events, want 2", len(lines))
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[0]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != EventScanFinished || ev.Findings != 2 || ev.Time.IsZero() {
+		t.Fatalf("got %+v, want a timed scan_finished event of 2 findings", ev)
+	}
+}
+
+func TestEventStreamSocket(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	viper.Set("events.target", "tcp://"+l.Addr().String())
+
+	es, err := NewEventStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	es.Emit(Event{Type: EventIssueCreated, Service: "apple", IssueNumber: 7})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, `"type":"issue_created"`) || !strings.Contains(line, `"issue_number":7`) {
+		t.Fatalf("got %q, want the issue_created event", line)
+	}
+}
+
+func TestEventStreamWithoutListener(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("events.target", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	// A missing listener does not stop osprey, events are dropped until it is back.
+	es, err := NewEventStream()
+	if err != nil || es == nil {
+		t.Fatalf("got %v, %v, want an event stream", es, err)
+	}
+	es.Emit(Event{Type: EventScanStarted, Service: "apple"})
+}
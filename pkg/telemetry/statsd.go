@@ -0,0 +1,113 @@
+package telemetry
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStatsdPrefix   = "osprey."
+	defaultStatsdInterval = 10 * time.Second
+)
+
+// Statsd pushes metrics to a statsd or DogStatsD agent over UDP. A nil client discards all metrics.
+type Statsd struct {
+	// conn is the UDP connection to the agent.
+	conn net.Conn
+
+	// prefix is prepended to every metric name.
+	prefix string
+
+	// dogstatsd sends the service as a tag instead of a name segment.
+	dogstatsd bool
+
+	// tags are extra DogStatsD tags sent with every metric.
+	tags []string
+
+	// interval is how often gauges are pushed.
+	interval time.Duration
+}
+
+// NewStatsd creates a statsd client based on config file. It returns nil if no statsd address is configured.
+func NewStatsd() (*Statsd, error) {
+	addr := viper.GetString("statsd.addr")
+	if addr == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Statsd{
+		conn:      conn,
+		prefix:    defaultStatsdPrefix,
+		dogstatsd: viper.GetBool("statsd.dogstatsd"),
+		tags:      viper.GetStringSlice("statsd.tags"),
+		interval:  viper.GetDuration("statsd.interval"),
+	}
+	if viper.IsSet("statsd.prefix") {
+		c.prefix = viper.GetString("statsd.prefix")
+	}
+	if c.interval <= 0 {
+		c.interval = defaultStatsdInterval
+	}
+
+	return c, nil
+}
+
+// Count adds n to a counter of a service.
+func (c *Statsd) Count(service, name string, n int) {
+	c.send(service, name, fmt.Sprintf("%d|c", n))
+}
+
+// Gauge sets a gauge of a service.
+func (c *Statsd) Gauge(service, name string, v float64) {
+	c.send(service, name, fmt.Sprintf("%g|g", v))
+}
+
+// Timing records a duration of a service in milliseconds.
+func (c *Statsd) Timing(service, name string, d time.Duration) {
+	c.send(service, name, fmt.Sprintf("%g|ms", float64(d)/float64(time.Millisecond)))
+}
+
+// send writes a single metric. Errors are only logged, metrics must never get in the way of scanning.
+func (c *Statsd) send(service, name, value string) {
+	if c == nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(c.prefix)
+	if c.dogstatsd {
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(value)
+		b.WriteString("|#service:")
+		b.WriteString(service)
+		for _, tag := range c.tags {
+			b.WriteString(",")
+			b.WriteString(tag)
+		}
+	} else {
+		b.WriteString(service)
+		b.WriteString(".")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(value)
+	}
+
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		log.Printf("Unable to send statsd metric, %s\n", err.Error())
+	}
+}
+
+// Interval returns how often gauges are pushed.
+func (c *Statsd) Interval() time.Duration {
+	return c.interval
+}
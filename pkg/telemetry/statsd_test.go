@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"github.com/spf13/viper"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsd(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+		want string
+	}{
+		{"count", nil, "osprey.apple.findings:2|c"},
+		{"prefix", map[string]interface{}{"statsd.prefix": "logs."}, "logs.apple.findings:2|c"},
+		{"dogstatsd", map[string]interface{}{"statsd.dogstatsd": true, "statsd.tags": []string{"env:prod"}},
+			"osprey.findings:2|c|#service:apple,env:prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			viper.Set("statsd.addr", pc.LocalAddr().String())
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+
+			c, err := NewStatsd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Count("apple", "findings", 2)
+
+			buf := make([]byte, 512)
+			pc.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			if c.Interval() != defaultStatsdInterval {
+				t.Fatalf("got interval %s, want %s", c.Interval(), defaultStatsdInterval)
+			}
+		})
+	}
+}
+
+func TestStatsdDisabled(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	c, err := NewStatsd()
+	if err != nil || c != nil {
+		t.Fatalf("got %v, %v, want no client", c, err)
+	}
+	// A nil client discards metrics.
+	c.Count("apple", "findings", 1)
+}
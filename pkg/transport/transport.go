@@ -0,0 +1,129 @@
+// Package transport builds the http clients of osprey's outbound calls, honoring the proxy and TLS settings of
+// the config file.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/spf13/viper"
+	"golang.org/x/net/http/httpproxy"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// tlsVersions maps min_version values to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewHTTPClient returns an http client for outbound calls to an endpoint such as github or vault. The client goes
+// through the proxy set by proxy.url (http, https or socks5), or HTTP_PROXY/HTTPS_PROXY/NO_PROXY otherwise, and uses
+// the TLS settings of the endpoint.
+func NewHTTPClient(endpoint string, timeout time.Duration) (*http.Client, error) {
+	t, err := NewTransport(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
+
+// NewTransport returns an http transport honoring the proxy and the TLS settings of an endpoint.
+func NewTransport(endpoint string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc()
+
+	cfg, err := TLSConfig(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings for %s, %s", endpoint, err.Error())
+	}
+	if cfg != nil {
+		t.TLSClientConfig = cfg
+	}
+
+	return t, nil
+}
+
+// proxyFunc returns the proxy selection function for outbound requests.
+func proxyFunc() func(*http.Request) (*url.URL, error) {
+	u := viper.GetString("proxy.url")
+	if u == "" {
+		return http.ProxyFromEnvironment
+	}
+
+	cfg := httpproxy.Config{
+		HTTPProxy:  u,
+		HTTPSProxy: u,
+		NoProxy:    viper.GetString("proxy.no_proxy"),
+	}
+	f := cfg.ProxyFunc()
+
+	return func(r *http.Request) (*url.URL, error) {
+		return f(r.URL)
+	}
+}
+
+// TLSConfig builds the TLS config of an endpoint from tls.<endpoint>, falling back to tls.default. It returns nil if
+// neither is configured, so the system defaults apply. A CA bundle is added to the system roots rather than
+// replacing them, so endpoints behind internal PKI and public ones can share the settings.
+func TLSConfig(endpoint string) (*tls.Config, error) {
+	key := "tls." + endpoint
+	if !viper.IsSet(key) {
+		key = "tls.default"
+	}
+	if !viper.IsSet(key) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{ServerName: viper.GetString(key + ".server_name")}
+
+	if v := viper.GetString(key + ".min_version"); v != "" {
+		ver, err := ParseTLSVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MinVersion = ver
+	}
+
+	if path := viper.GetString(key + ".ca_file"); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+		cfg.RootCAs = pool
+	}
+
+	certFile, keyFile := viper.GetString(key+".cert_file"), viper.GetString(key+".key_file")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ParseTLSVersion parses a min_version setting: 1.0, 1.1, 1.2 or 1.3.
+func ParseTLSVersion(v string) (uint16, error) {
+	ver, ok := tlsVersions[v]
+	if !ok {
+		return 0, fmt.Errorf("unknown min_version %q, use 1.0, 1.1, 1.2 or 1.3", v)
+	}
+
+	return ver, nil
+}
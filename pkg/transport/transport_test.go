@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		noProxy string
+		url     string
+		want    string
+	}{
+		{"https", "", "https://api.github.com/repos", "http://proxy.internal:3128"},
+		{"http", "", "http://hooks.example.com/", "http://proxy.internal:3128"},
+		{"no proxy", "vault.internal,.corp", "https://vault.internal:8200/v1/secret", ""},
+		{"no proxy domain", "vault.internal,.corp", "https://jira.corp/rest", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.Set("proxy.url", "http://proxy.internal:3128")
+			viper.Set("proxy.no_proxy", tt.noProxy)
+
+			tr, err := NewTransport("github")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := tr.Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if u != nil {
+				got = u.String()
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("no certificates"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		conf    map[string]interface{}
+		ok      bool
+		trusted bool
+		minVer  uint16
+	}{
+		{"system defaults", nil, true, false, 0},
+		{"endpoint ca", map[string]interface{}{"tls.vault.ca_file": caFile}, true, true, 0},
+		{"default ca", map[string]interface{}{"tls.default.ca_file": caFile, "tls.default.min_version": "1.2"},
+			true, true, tls.VersionTLS12},
+		// The settings of the endpoint replace the default ones.
+		{"endpoint over default", map[string]interface{}{"tls.default.ca_file": caFile,
+			"tls.vault.min_version": "1.3"}, true, false, tls.VersionTLS13},
+		{"no certificates", map[string]interface{}{"tls.vault.ca_file": emptyFile}, false, false, 0},
+		{"unknown version", map[string]interface{}{"tls.vault.min_version": "1.4"}, false, false, 0},
+		{"missing key", map[string]interface{}{"tls.vault.cert_file": caFile}, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for k, v := range tt.conf {
+				viper.Set(k, v)
+			}
+
+			c, err := NewHTTPClient("vault", 0)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			if cfg := c.Transport.(*http.Transport).TLSClientConfig; cfg != nil && cfg.MinVersion != tt.minVer {
+				t.Fatalf("got min version %x, want %x", cfg.MinVersion, tt.minVer)
+			}
+			res, err := c.Get(srv.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if (err == nil) != tt.trusted {
+				t.Fatalf("got %v, want trusted %v", err, tt.trusted)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: osprey/plugin/v1/plugin.proto
+
+// Package osprey.plugin.v1 is the protocol of osprey's external plugins. Plugins are binaries
+// started by osprey through hashicorp/go-plugin, serving sources, matchers and sinks over gRPC.
+
+package pluginv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Info_Describe_FullMethodName = "/osprey.plugin.v1.Info/Describe"
+)
+
+// InfoClient is the client API for Info service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Info describes a plugin.
+type InfoClient interface {
+	// Describe returns the kinds of components the plugin provides.
+	Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*DescribeResponse, error)
+}
+
+type infoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInfoClient(cc grpc.ClientConnInterface) InfoClient {
+	return &infoClient{cc}
+}
+
+func (c *infoClient) Describe(ctx context.Context, in *DescribeRequest, opts ...grpc.CallOption) (*DescribeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DescribeResponse)
+	err := c.cc.Invoke(ctx, Info_Describe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InfoServer is the server API for Info service.
+// All implementations must embed UnimplementedInfoServer
+// for forward compatibility.
+//
+// Info describes a plugin.
+type InfoServer interface {
+	// Describe returns the kinds of components the plugin provides.
+	Describe(context.Context, *DescribeRequest) (*DescribeResponse, error)
+	mustEmbedUnimplementedInfoServer()
+}
+
+// UnimplementedInfoServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInfoServer struct{}
+
+func (UnimplementedInfoServer) Describe(context.Context, *DescribeRequest) (*DescribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Describe not implemented")
+}
+func (UnimplementedInfoServer) mustEmbedUnimplementedInfoServer() {}
+func (UnimplementedInfoServer) testEmbeddedByValue()              {}
+
+// UnsafeInfoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InfoServer will
+// result in compilation errors.
+type UnsafeInfoServer interface {
+	mustEmbedUnimplementedInfoServer()
+}
+
+func RegisterInfoServer(s grpc.ServiceRegistrar, srv InfoServer) {
+	// If the following call pancis, it indicates UnimplementedInfoServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Info_ServiceDesc, srv)
+}
+
+func _Info_Describe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InfoServer).Describe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Info_Describe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InfoServer).Describe(ctx, req.(*DescribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Info_ServiceDesc is the grpc.ServiceDesc for Info service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Info_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "osprey.plugin.v1.Info",
+	HandlerType: (*InfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Describe",
+			Handler:    _Info_Describe_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "osprey/plugin/v1/plugin.proto",
+}
+
+const (
+	Source_Read_FullMethodName = "/osprey.plugin.v1.Source/Read"
+)
+
+// SourceClient is the client API for Source service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Source reads the logs of a service.
+type SourceClient interface {
+	// Read returns the lines after a checkpoint and the checkpoint to resume from.
+	Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error)
+}
+
+type sourceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSourceClient(cc grpc.ClientConnInterface) SourceClient {
+	return &sourceClient{cc}
+}
+
+func (c *sourceClient) Read(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*ReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReadResponse)
+	err := c.cc.Invoke(ctx, Source_Read_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SourceServer is the server API for Source service.
+// All implementations must embed UnimplementedSourceServer
+// for forward compatibility.
+//
+// Source reads the logs of a service.
+type SourceServer interface {
+	// Read returns the lines after a checkpoint and the checkpoint to resume from.
+	Read(context.Context, *ReadRequest) (*ReadResponse, error)
+	mustEmbedUnimplementedSourceServer()
+}
+
+// UnimplementedSourceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSourceServer struct{}
+
+func (UnimplementedSourceServer) Read(context.Context, *ReadRequest) (*ReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Read not implemented")
+}
+func (UnimplementedSourceServer) mustEmbedUnimplementedSourceServer() {}
+func (UnimplementedSourceServer) testEmbeddedByValue()                {}
+
+// UnsafeSourceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SourceServer will
+// result in compilation errors.
+type UnsafeSourceServer interface {
+	mustEmbedUnimplementedSourceServer()
+}
+
+func RegisterSourceServer(s grpc.ServiceRegistrar, srv SourceServer) {
+	// If the following call pancis, it indicates UnimplementedSourceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Source_ServiceDesc, srv)
+}
+
+func _Source_Read_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SourceServer).Read(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Source_Read_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SourceServer).Read(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Source_ServiceDesc is the grpc.ServiceDesc for Source service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Source_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "osprey.plugin.v1.Source",
+	HandlerType: (*SourceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Read",
+			Handler:    _Source_Read_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "osprey/plugin/v1/plugin.proto",
+}
+
+const (
+	Matcher_Match_FullMethodName = "/osprey.plugin.v1.Matcher/Match"
+)
+
+// MatcherClient is the client API for Matcher service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Matcher finds the errors in parsed log lines.
+type MatcherClient interface {
+	// Match returns the finding of a log entry, if it is one.
+	Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+}
+
+type matcherClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatcherClient(cc grpc.ClientConnInterface) MatcherClient {
+	return &matcherClient{cc}
+}
+
+func (c *matcherClient) Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, Matcher_Match_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MatcherServer is the server API for Matcher service.
+// All implementations must embed UnimplementedMatcherServer
+// for forward compatibility.
+//
+// Matcher finds the errors in parsed log lines.
+type MatcherServer interface {
+	// Match returns the finding of a log entry, if it is one.
+	Match(context.Context, *MatchRequest) (*MatchResponse, error)
+	mustEmbedUnimplementedMatcherServer()
+}
+
+// UnimplementedMatcherServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMatcherServer struct{}
+
+func (UnimplementedMatcherServer) Match(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedMatcherServer) mustEmbedUnimplementedMatcherServer() {}
+func (UnimplementedMatcherServer) testEmbeddedByValue()                 {}
+
+// UnsafeMatcherServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MatcherServer will
+// result in compilation errors.
+type UnsafeMatcherServer interface {
+	mustEmbedUnimplementedMatcherServer()
+}
+
+func RegisterMatcherServer(s grpc.ServiceRegistrar, srv MatcherServer) {
+	// If the following call pancis, it indicates UnimplementedMatcherServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Matcher_ServiceDesc, srv)
+}
+
+func _Matcher_Match_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MatcherServer).Match(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Matcher_Match_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MatcherServer).Match(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Matcher_ServiceDesc is the grpc.ServiceDesc for Matcher service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Matcher_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "osprey.plugin.v1.Matcher",
+	HandlerType: (*MatcherServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Match",
+			Handler:    _Matcher_Match_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "osprey/plugin/v1/plugin.proto",
+}
+
+const (
+	Sink_Deliver_FullMethodName = "/osprey.plugin.v1.Sink/Deliver"
+)
+
+// SinkClient is the client API for Sink service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Sink delivers findings.
+type SinkClient interface {
+	// Deliver delivers a finding of a service.
+	Deliver(ctx context.Context, in *DeliverRequest, opts ...grpc.CallOption) (*DeliverResponse, error)
+}
+
+type sinkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSinkClient(cc grpc.ClientConnInterface) SinkClient {
+	return &sinkClient{cc}
+}
+
+func (c *sinkClient) Deliver(ctx context.Context, in *DeliverRequest, opts ...grpc.CallOption) (*DeliverResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeliverResponse)
+	err := c.cc.Invoke(ctx, Sink_Deliver_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SinkServer is the server API for Sink service.
+// All implementations must embed UnimplementedSinkServer
+// for forward compatibility.
+//
+// Sink delivers findings.
+type SinkServer interface {
+	// Deliver delivers a finding of a service.
+	Deliver(context.Context, *DeliverRequest) (*DeliverResponse, error)
+	mustEmbedUnimplementedSinkServer()
+}
+
+// UnimplementedSinkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSinkServer struct{}
+
+func (UnimplementedSinkServer) Deliver(context.Context, *DeliverRequest) (*DeliverResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deliver not implemented")
+}
+func (UnimplementedSinkServer) mustEmbedUnimplementedSinkServer() {}
+func (UnimplementedSinkServer) testEmbeddedByValue()              {}
+
+// UnsafeSinkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SinkServer will
+// result in compilation errors.
+type UnsafeSinkServer interface {
+	mustEmbedUnimplementedSinkServer()
+}
+
+func RegisterSinkServer(s grpc.ServiceRegistrar, srv SinkServer) {
+	// If the following call pancis, it indicates UnimplementedSinkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Sink_ServiceDesc, srv)
+}
+
+func _Sink_Deliver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeliverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SinkServer).Deliver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sink_Deliver_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SinkServer).Deliver(ctx, req.(*DeliverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Sink_ServiceDesc is the grpc.ServiceDesc for Sink service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sink_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "osprey.plugin.v1.Sink",
+	HandlerType: (*SinkServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Deliver",
+			Handler:    _Sink_Deliver_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "osprey/plugin/v1/plugin.proto",
+}
@@ -0,0 +1,613 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: osprey/v1/control.proto
+
+// Package osprey.v1 is osprey's control API. It offers the same control surface as the admin
+// REST API, so other services can trigger scans, query lag and stream findings.
+
+package ospreyv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Service is the status of a scanned service.
+type Service struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Name     string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Paused   bool                   `protobuf:"varint,2,opt,name=paused,proto3" json:"paused,omitempty"`
+	Scans    int64                  `protobuf:"varint,3,opt,name=scans,proto3" json:"scans,omitempty"`
+	Failures int64                  `protobuf:"varint,4,opt,name=failures,proto3" json:"failures,omitempty"`
+	// avg_duration_ms is the average scan duration in milliseconds.
+	AvgDurationMs    float64 `protobuf:"fixed64,5,opt,name=avg_duration_ms,json=avgDurationMs,proto3" json:"avg_duration_ms,omitempty"`
+	FindingsLastHour int64   `protobuf:"varint,6,opt,name=findings_last_hour,json=findingsLastHour,proto3" json:"findings_last_hour,omitempty"`
+	// lag_seconds is how long ago the log was last scanned successfully, -1 if never.
+	LagSeconds    float64                `protobuf:"fixed64,7,opt,name=lag_seconds,json=lagSeconds,proto3" json:"lag_seconds,omitempty"`
+	LastScan      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=last_scan,json=lastScan,proto3" json:"last_scan,omitempty"`
+	LastError     string                 `protobuf:"bytes,9,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	LastErrorTime *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=last_error_time,json=lastErrorTime,proto3" json:"last_error_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Service) Reset() {
+	*x = Service{}
+	mi := &file_osprey_v1_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Service) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Service) ProtoMessage() {}
+
+func (x *Service) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Service.ProtoReflect.Descriptor instead.
+func (*Service) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Service) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Service) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *Service) GetScans() int64 {
+	if x != nil {
+		return x.Scans
+	}
+	return 0
+}
+
+func (x *Service) GetFailures() int64 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *Service) GetAvgDurationMs() float64 {
+	if x != nil {
+		return x.AvgDurationMs
+	}
+	return 0
+}
+
+func (x *Service) GetFindingsLastHour() int64 {
+	if x != nil {
+		return x.FindingsLastHour
+	}
+	return 0
+}
+
+func (x *Service) GetLagSeconds() float64 {
+	if x != nil {
+		return x.LagSeconds
+	}
+	return 0
+}
+
+func (x *Service) GetLastScan() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastScan
+	}
+	return nil
+}
+
+func (x *Service) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *Service) GetLastErrorTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastErrorTime
+	}
+	return nil
+}
+
+// Finding is a log line matched by a scanner.
+type Finding struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Time    *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Service string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Line    string                 `protobuf:"bytes,3,opt,name=line,proto3" json:"line,omitempty"`
+	// issue_url is the url of the issue created for the finding, if any.
+	IssueUrl string `protobuf:"bytes,4,opt,name=issue_url,json=issueUrl,proto3" json:"issue_url,omitempty"`
+	// error is why no issue was created for the finding, if any.
+	Error         string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_osprey_v1_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Finding) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Finding) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Finding) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+func (x *Finding) GetIssueUrl() string {
+	if x != nil {
+		return x.IssueUrl
+	}
+	return ""
+}
+
+func (x *Finding) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ListServicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListServicesRequest) Reset() {
+	*x = ListServicesRequest{}
+	mi := &file_osprey_v1_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListServicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListServicesRequest) ProtoMessage() {}
+
+func (x *ListServicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListServicesRequest.ProtoReflect.Descriptor instead.
+func (*ListServicesRequest) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{2}
+}
+
+type ListServicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Services      []*Service             `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListServicesResponse) Reset() {
+	*x = ListServicesResponse{}
+	mi := &file_osprey_v1_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListServicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListServicesResponse) ProtoMessage() {}
+
+func (x *ListServicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListServicesResponse.ProtoReflect.Descriptor instead.
+func (*ListServicesResponse) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListServicesResponse) GetServices() []*Service {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+type ServiceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceRequest) Reset() {
+	*x = ServiceRequest{}
+	mi := &file_osprey_v1_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceRequest) ProtoMessage() {}
+
+func (x *ServiceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceRequest.ProtoReflect.Descriptor instead.
+func (*ServiceRequest) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ServiceRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListFindingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// service only returns findings of this service if not empty.
+	Service string `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	// limit caps the number of findings returned if positive.
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFindingsRequest) Reset() {
+	*x = ListFindingsRequest{}
+	mi := &file_osprey_v1_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFindingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFindingsRequest) ProtoMessage() {}
+
+func (x *ListFindingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFindingsRequest.ProtoReflect.Descriptor instead.
+func (*ListFindingsRequest) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListFindingsRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *ListFindingsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListFindingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Findings      []*Finding             `protobuf:"bytes,1,rep,name=findings,proto3" json:"findings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFindingsResponse) Reset() {
+	*x = ListFindingsResponse{}
+	mi := &file_osprey_v1_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFindingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFindingsResponse) ProtoMessage() {}
+
+func (x *ListFindingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFindingsResponse.ProtoReflect.Descriptor instead.
+func (*ListFindingsResponse) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListFindingsResponse) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+type StreamFindingsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// service only streams findings of this service if not empty.
+	Service       string `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamFindingsRequest) Reset() {
+	*x = StreamFindingsRequest{}
+	mi := &file_osprey_v1_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamFindingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamFindingsRequest) ProtoMessage() {}
+
+func (x *StreamFindingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_osprey_v1_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamFindingsRequest.ProtoReflect.Descriptor instead.
+func (*StreamFindingsRequest) Descriptor() ([]byte, []int) {
+	return file_osprey_v1_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamFindingsRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+var File_osprey_v1_control_proto protoreflect.FileDescriptor
+
+const file_osprey_v1_control_proto_rawDesc = "" +
+	"\n" +
+	"\x17osprey/v1/control.proto\x12\tosprey.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfa\x02\n" +
+	"\aService\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06paused\x18\x02 \x01(\bR\x06paused\x12\x14\n" +
+	"\x05scans\x18\x03 \x01(\x03R\x05scans\x12\x1a\n" +
+	"\bfailures\x18\x04 \x01(\x03R\bfailures\x12&\n" +
+	"\x0favg_duration_ms\x18\x05 \x01(\x01R\ravgDurationMs\x12,\n" +
+	"\x12findings_last_hour\x18\x06 \x01(\x03R\x10findingsLastHour\x12\x1f\n" +
+	"\vlag_seconds\x18\a \x01(\x01R\n" +
+	"lagSeconds\x127\n" +
+	"\tlast_scan\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\blastScan\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\t \x01(\tR\tlastError\x12B\n" +
+	"\x0flast_error_time\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\rlastErrorTime\"\x9a\x01\n" +
+	"\aFinding\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\tR\x04line\x12\x1b\n" +
+	"\tissue_url\x18\x04 \x01(\tR\bissueUrl\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\"\x15\n" +
+	"\x13ListServicesRequest\"F\n" +
+	"\x14ListServicesResponse\x12.\n" +
+	"\bservices\x18\x01 \x03(\v2\x12.osprey.v1.ServiceR\bservices\"$\n" +
+	"\x0eServiceRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"E\n" +
+	"\x13ListFindingsRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"F\n" +
+	"\x14ListFindingsResponse\x12.\n" +
+	"\bfindings\x18\x01 \x03(\v2\x12.osprey.v1.FindingR\bfindings\"1\n" +
+	"\x15StreamFindingsRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice2\xda\x03\n" +
+	"\aControl\x12O\n" +
+	"\fListServices\x12\x1e.osprey.v1.ListServicesRequest\x1a\x1f.osprey.v1.ListServicesResponse\x12;\n" +
+	"\n" +
+	"GetService\x12\x19.osprey.v1.ServiceRequest\x1a\x12.osprey.v1.Service\x125\n" +
+	"\x04Scan\x12\x19.osprey.v1.ServiceRequest\x1a\x12.osprey.v1.Service\x126\n" +
+	"\x05Pause\x12\x19.osprey.v1.ServiceRequest\x1a\x12.osprey.v1.Service\x127\n" +
+	"\x06Resume\x12\x19.osprey.v1.ServiceRequest\x1a\x12.osprey.v1.Service\x12O\n" +
+	"\fListFindings\x12\x1e.osprey.v1.ListFindingsRequest\x1a\x1f.osprey.v1.ListFindingsResponse\x12H\n" +
+	"\x0eStreamFindings\x12 .osprey.v1.StreamFindingsRequest\x1a\x12.osprey.v1.Finding0\x01B3Z1github.com/NBCFB/Iguana2/proto/osprey/v1;ospreyv1b\x06proto3"
+
+var (
+	file_osprey_v1_control_proto_rawDescOnce sync.Once
+	file_osprey_v1_control_proto_rawDescData []byte
+)
+
+func file_osprey_v1_control_proto_rawDescGZIP() []byte {
+	file_osprey_v1_control_proto_rawDescOnce.Do(func() {
+		file_osprey_v1_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_osprey_v1_control_proto_rawDesc), len(file_osprey_v1_control_proto_rawDesc)))
+	})
+	return file_osprey_v1_control_proto_rawDescData
+}
+
+var file_osprey_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_osprey_v1_control_proto_goTypes = []any{
+	(*Service)(nil),               // 0: osprey.v1.Service
+	(*Finding)(nil),               // 1: osprey.v1.Finding
+	(*ListServicesRequest)(nil),   // 2: osprey.v1.ListServicesRequest
+	(*ListServicesResponse)(nil),  // 3: osprey.v1.ListServicesResponse
+	(*ServiceRequest)(nil),        // 4: osprey.v1.ServiceRequest
+	(*ListFindingsRequest)(nil),   // 5: osprey.v1.ListFindingsRequest
+	(*ListFindingsResponse)(nil),  // 6: osprey.v1.ListFindingsResponse
+	(*StreamFindingsRequest)(nil), // 7: osprey.v1.StreamFindingsRequest
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_osprey_v1_control_proto_depIdxs = []int32{
+	8,  // 0: osprey.v1.Service.last_scan:type_name -> google.protobuf.Timestamp
+	8,  // 1: osprey.v1.Service.last_error_time:type_name -> google.protobuf.Timestamp
+	8,  // 2: osprey.v1.Finding.time:type_name -> google.protobuf.Timestamp
+	0,  // 3: osprey.v1.ListServicesResponse.services:type_name -> osprey.v1.Service
+	1,  // 4: osprey.v1.ListFindingsResponse.findings:type_name -> osprey.v1.Finding
+	2,  // 5: osprey.v1.Control.ListServices:input_type -> osprey.v1.ListServicesRequest
+	4,  // 6: osprey.v1.Control.GetService:input_type -> osprey.v1.ServiceRequest
+	4,  // 7: osprey.v1.Control.Scan:input_type -> osprey.v1.ServiceRequest
+	4,  // 8: osprey.v1.Control.Pause:input_type -> osprey.v1.ServiceRequest
+	4,  // 9: osprey.v1.Control.Resume:input_type -> osprey.v1.ServiceRequest
+	5,  // 10: osprey.v1.Control.ListFindings:input_type -> osprey.v1.ListFindingsRequest
+	7,  // 11: osprey.v1.Control.StreamFindings:input_type -> osprey.v1.StreamFindingsRequest
+	3,  // 12: osprey.v1.Control.ListServices:output_type -> osprey.v1.ListServicesResponse
+	0,  // 13: osprey.v1.Control.GetService:output_type -> osprey.v1.Service
+	0,  // 14: osprey.v1.Control.Scan:output_type -> osprey.v1.Service
+	0,  // 15: osprey.v1.Control.Pause:output_type -> osprey.v1.Service
+	0,  // 16: osprey.v1.Control.Resume:output_type -> osprey.v1.Service
+	6,  // 17: osprey.v1.Control.ListFindings:output_type -> osprey.v1.ListFindingsResponse
+	1,  // 18: osprey.v1.Control.StreamFindings:output_type -> osprey.v1.Finding
+	12, // [12:19] is the sub-list for method output_type
+	5,  // [5:12] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_osprey_v1_control_proto_init() }
+func file_osprey_v1_control_proto_init() {
+	if File_osprey_v1_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_osprey_v1_control_proto_rawDesc), len(file_osprey_v1_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_osprey_v1_control_proto_goTypes,
+		DependencyIndexes: file_osprey_v1_control_proto_depIdxs,
+		MessageInfos:      file_osprey_v1_control_proto_msgTypes,
+	}.Build()
+	File_osprey_v1_control_proto = out.File
+	file_osprey_v1_control_proto_goTypes = nil
+	file_osprey_v1_control_proto_depIdxs = nil
+}